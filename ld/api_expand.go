@@ -317,7 +317,10 @@ func (api *JsonLdApi) Expand(activeCtx *Context, activeProperty string, element
 }
 
 func (api *JsonLdApi) expandObject(activeCtx *Context, activeProperty string, expandedActiveProperty string, elem map[string]interface{}, resultMap map[string]interface{}, typeKey string, opts *JsonLdOptions, typeScopedContext *Context, frameExpansion bool) error {
-	inputType := elem[typeKey]
+	var inputType interface{}
+	if typeKey != "" {
+		inputType = elem[typeKey]
+	}
 	if inputType != nil {
 		if itArray, isArray := inputType.([]interface{}); isArray {
 			if len(itArray) > 0 {
@@ -334,8 +337,12 @@ func (api *JsonLdApi) expandObject(activeCtx *Context, activeProperty string, ex
 			}
 		}
 		if inputType != nil {
+			inputTypeStr, isString := inputType.(string)
+			if !isString {
+				return NewJsonLdError(InvalidTypeValue, "@type value must be a string or array of strings")
+			}
 			var err error
-			inputType, err = activeCtx.ExpandIri(inputType.(string), false, true, nil, nil)
+			inputType, err = activeCtx.ExpandIri(inputTypeStr, false, true, nil, nil)
 			if err != nil {
 				return err
 			}
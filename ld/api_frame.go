@@ -37,8 +37,12 @@ type FramingContext struct {
 	requireAll   bool
 	omitDefault  bool
 	uniqueEmbeds map[string]map[string]*EmbedNode
-	graphMap     map[string]interface{}
-	subjects     map[string]interface{}
+	// graphMap is only populated by GenerateNodeMap and mergeNodeMapGraphs,
+	// so every graph in it (and every node in a graph) is a map[string]interface{}.
+	graphMap map[string]interface{}
+	subjects map[string]interface{}
+	// graph is always a key of graphMap: @default, @merged or the name of a graph
+	// matchFrame is recursing into.
 	graph        string
 	graphStack   []string // TODO: is this field needed?
 	subjectStack []*StackNode
@@ -41,12 +41,15 @@ func (api *JsonLdApi) GenerateNodeMap(element interface{}, graphMap map[string]i
 	// add non-object to list
 	elem, isMap := element.(map[string]interface{})
 	if !isMap {
-		return nil, fmt.Errorf("expected map or list to GenerateNodeMap, got %T", element)
+		return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("expected map or list to GenerateNodeMap, got %T", element))
 	}
 
 	var graph map[string]interface{}
 	if graphVal, found := graphMap[activeGraph]; found {
-		graph = graphVal.(map[string]interface{})
+		graph, isMap = graphVal.(map[string]interface{})
+		if !isMap {
+			return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("invalid node map for graph %s", activeGraph))
+		}
 	} else {
 		graph = make(map[string]interface{})
 		graphMap[activeGraph] = graph
@@ -185,6 +188,10 @@ func (api *JsonLdApi) GenerateNodeMap(element interface{}, graphMap map[string]i
 
 		value := elem[property]
 
+		if IsKeyword(property) {
+			return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("unexpected keyword %s in node object", property))
+		}
+
 		// if property is a bnode, assign it a new id
 		if strings.HasPrefix(property, "_:") {
 			property = issuer.GetId(property)
@@ -0,0 +1,46 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateNodeMapRejectsUnexpectedKeywords(t *testing.T) {
+	input := []interface{}{
+		map[string]interface{}{
+			"@id":       "http://example.org/s",
+			"@language": "en",
+		},
+	}
+	graphMap := map[string]interface{}{"@default": map[string]interface{}{}}
+
+	_, err := NewJsonLdApi().GenerateNodeMap(input, graphMap, "@default", NewIdentifierIssuer("_:b"), "", "", nil)
+	require.Error(t, err)
+	assert.Equal(t, InvalidInput, err.(*JsonLdError).Code) //nolint:errorlint
+}
+
+func TestGenerateNodeMapRejectsInvalidGraphs(t *testing.T) {
+	input := map[string]interface{}{"@id": "http://example.org/s"}
+	graphMap := map[string]interface{}{"@default": []interface{}{}}
+
+	_, err := NewJsonLdApi().GenerateNodeMap(input, graphMap, "@default", NewIdentifierIssuer("_:b"), "", "", nil)
+	require.Error(t, err)
+	assert.Equal(t, InvalidInput, err.(*JsonLdError).Code) //nolint:errorlint
+}
@@ -15,12 +15,14 @@
 package ld
 
 import (
+	"bytes"
 	"crypto/sha1" //nolint:gosec
-	"crypto/sha256"
 	"crypto/sha512"
 	hashPkg "hash"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type MessageDigestAlgorithm string
@@ -35,7 +37,7 @@ const (
 )
 
 func (api *JsonLdApi) Normalize(dataset *RDFDataset, opts *JsonLdOptions) (interface{}, error) {
-	algo := NewNormalisationAlgorithm(opts.Algorithm, opts.MessageDigestAlgorithm)
+	algo := newNormalisationAlgorithmWithOptions(opts)
 	return algo.Main(dataset, opts)
 }
 
@@ -43,31 +45,126 @@ var (
 	Positions = []string{"s", "o", "g"}
 )
 
+// NormalisationAlgorithm implements RDF dataset canonicalization. It works on a copy
+// of the input dataset's quads and never modifies the dataset itself.
 type NormalisationAlgorithm struct {
-	blankNodeInfo          map[string]map[string]interface{}
+	blankNodeInfo          map[string]*blankNodeInfo
 	hashToBlankNodes       map[string][]string
 	canonicalIssuer        *IdentifierIssuer
-	quads                  []*Quad
+	quads                  []*normalizedQuad
 	lines                  []string
 	version                string
 	messageDigestAlgorithm MessageDigestAlgorithm
+	hashFactory            HashFactory
+	hashErr                error
+	workers                int
 }
 
+// NewNormalisationAlgorithm creates a new instance of NormalisationAlgorithm.
+// URDNA2015 uses the given message digest algorithm, which must be registered
+// with RegisterMessageDigestAlgorithm unless it's one of the predefined ones.
+// URGNA2012 always uses SHA-1.
 func NewNormalisationAlgorithm(version string, messageDigestAlgorithm MessageDigestAlgorithm) *NormalisationAlgorithm {
-	return &NormalisationAlgorithm{
-		blankNodeInfo:          make(map[string]map[string]interface{}),
+	na := &NormalisationAlgorithm{
+		blankNodeInfo:          make(map[string]*blankNodeInfo),
 		canonicalIssuer:        NewIdentifierIssuer("_:c14n"),
-		quads:                  make([]*Quad, 0),
+		quads:                  make([]*normalizedQuad, 0),
 		version:                version,
 		messageDigestAlgorithm: messageDigestAlgorithm,
 	}
+	if version == AlgorithmURDNA2015 {
+		na.hashFactory, na.hashErr = lookupMessageDigest(messageDigestAlgorithm)
+	} else {
+		na.hashFactory = sha1.New
+	}
+	return na
+}
+
+// newNormalisationAlgorithmWithOptions creates a NormalisationAlgorithm configured
+// by the given options.
+func newNormalisationAlgorithmWithOptions(opts *JsonLdOptions) *NormalisationAlgorithm {
+	na := NewNormalisationAlgorithm(opts.Algorithm, opts.MessageDigestAlgorithm)
+	na.workers = opts.NormalizationWorkers
+	return na
+}
+
+// blankNodeInfo holds the quads a blank node occurs in and its
+// first degree hash, once calculated.
+type blankNodeInfo struct {
+	quads []*normalizedQuad
+	hash  string
+}
+
+// normalizedQuad is a quad with precomputed N-Quads terms of its components.
+// The terms of blank nodes are empty, because blank node identifiers are
+// replaced during normalisation.
+type normalizedQuad struct {
+	*Quad
+	subject   string
+	predicate string
+	object    string
+	graph     string
+	// relatedPredicate is the predicate as used by the Hash Related Blank Node algorithm
+	relatedPredicate string
+}
+
+func (na *NormalisationAlgorithm) newNormalizedQuad(quad *Quad) *normalizedQuad {
+	nq := &normalizedQuad{
+		Quad:      quad,
+		predicate: predicateTerm(quad.Predicate),
+	}
+	if !IsBlankNode(quad.Subject) {
+		nq.subject = subjectTerm(quad.Subject)
+	}
+	if !IsBlankNode(quad.Object) {
+		nq.object = objectTerm(quad.Object)
+	}
+	if quad.Graph != nil && !IsBlankNode(quad.Graph) {
+		nq.graph = graphTerm(quad.Graph.GetValue())
+	}
+	if na.version == AlgorithmURDNA2015 {
+		nq.relatedPredicate = "<" + quad.Predicate.GetValue() + ">"
+	} else {
+		nq.relatedPredicate = quad.Predicate.GetValue()
+	}
+	return nq
 }
 
+// Quads returns the quads of the normalized dataset, sorted by their canonical
+// serialization once Normalize has run.
 func (na *NormalisationAlgorithm) Quads() []*Quad {
-	return na.quads
+	if na.quads == nil {
+		return nil
+	}
+	quads := make([]*Quad, len(na.quads))
+	for i, nq := range na.quads {
+		quads[i] = nq.Quad
+	}
+	return quads
 }
 
-func (na *NormalisationAlgorithm) Normalize(dataset *RDFDataset) {
+// Normalize runs the normalisation algorithm on the given dataset. It returns an error
+// if the message digest algorithm is unknown.
+func (na *NormalisationAlgorithm) Normalize(dataset *RDFDataset) error {
+	if na.hashErr != nil {
+		return na.hashErr
+	}
+
+	na.issueCanonicalIdentifiers(dataset)
+
+	// 7) For each quad, quad, in input dataset:
+	na.lines = make([]string, len(na.quads))
+	for i, quad := range na.quads {
+		na.lines[i] = na.canonicalNQuad(quad)
+	}
+
+	// sort normalized output
+	sort.Sort(na)
+	return nil
+}
+
+// issueCanonicalIdentifiers runs steps 1-6 of the normalisation algorithm.
+func (na *NormalisationAlgorithm) issueCanonicalIdentifiers(dataset *RDFDataset) {
 	// 1) Create the normalisation state
 
 	// 2) For every quad in input dataset:
@@ -76,6 +173,14 @@ func (na *NormalisationAlgorithm) Normalize(dataset *RDFDataset) {
 			graphName = ""
 		}
 		for _, quad := range triples {
+			// the quads are copied, because blank nodes get relabelled in step 7
+			// and the input dataset must not be modified
+			quad = &Quad{
+				Subject:   copyBlankNode(quad.Subject),
+				Predicate: quad.Predicate,
+				Object:    copyBlankNode(quad.Object),
+				Graph:     copyBlankNode(quad.Graph),
+			}
 			if graphName != "" {
 				if strings.Index(graphName, "_:") == 0 {
 					quad.Graph = NewBlankNode(graphName)
@@ -84,23 +189,22 @@ func (na *NormalisationAlgorithm) Normalize(dataset *RDFDataset) {
 				}
 			}
 
-			na.quads = append(na.quads, quad)
+			nq := na.newNormalizedQuad(quad)
+			na.quads = append(na.quads, nq)
 
 			// 2.1) For each blank node that occurs in the quad, add
 			// a reference to the quad using the blank node identifier
 			// in the blank node to quads map, creating a new entry if necessary.
-			for _, attrNode := range []Node{quad.Subject, quad.Object, quad.Graph} {
+			for _, attrNode := range [...]Node{quad.Subject, quad.Object, quad.Graph} {
 				if attrNode != nil {
 					if IsBlankNode(attrNode) {
 						id := attrNode.GetValue()
 						bNodeInfo, hasID := na.blankNodeInfo[id]
 						if !hasID {
-							bNodeInfo = map[string]interface{}{
-								"quads": make([]*Quad, 0),
-							}
+							bNodeInfo = &blankNodeInfo{}
 							na.blankNodeInfo[id] = bNodeInfo
 						}
-						bNodeInfo["quads"] = append(bNodeInfo["quads"].([]*Quad), quad)
+						bNodeInfo.quads = append(bNodeInfo.quads, nq)
 					}
 				}
 			}
@@ -126,9 +230,18 @@ func (na *NormalisationAlgorithm) Normalize(dataset *RDFDataset) {
 		na.hashToBlankNodes = make(map[string][]string)
 
 		// 5.3) For each blank node identifier in non-normalized identifiers:
+		ids := make([]string, 0, len(nonNormalized))
 		for id := range nonNormalized {
-			// 5.3.1) Create a hash, hash, according to the Hash First Degree Quads algorithm.
-			hash := na.hashFirstDegreeQuads(id)
+			ids = append(ids, id)
+		}
+		// 5.3.1) Create a hash, hash, according to the Hash First Degree Quads algorithm.
+		// Note: the hashes are independent of each other, so they may be calculated in parallel.
+		hashes := make([]string, len(ids))
+		na.forEach(len(ids), func(i int) {
+			hashes[i] = na.hashFirstDegreeQuads(ids[i])
+		})
+		for i, id := range ids {
+			hash := hashes[i]
 
 			// 5.3.2) Add hash and identifier to hash to blank nodes map,
 			// creating a new entry if necessary.
@@ -191,13 +304,25 @@ func (na *NormalisationAlgorithm) Normalize(dataset *RDFDataset) {
 		hashPaths := make(map[string][]*IdentifierIssuer)
 
 		// 6.2) For each blank node identifier identifier in identifier list:
+		pending := make([]string, 0, len(idList))
 		for _, id := range idList {
 			// 6.2.1) If a canonical identifier has already been issued for
 			// identifier, continue to the next identifier.
 			if na.canonicalIssuer.HasId(id) {
 				continue
 			}
+			pending = append(pending, id)
+		}
 
+		// Note: the canonical issuer isn't modified until step 6.3, so the hashes of
+		// the identifiers in the list may be calculated in parallel. The lists themselves
+		// are processed in order, because they depend on the identifiers issued for
+		// the previous lists.
+		results := make([]struct {
+			hash   string
+			issuer *IdentifierIssuer
+		}, len(pending))
+		na.forEach(len(pending), func(i int) {
 			// 6.2.2) Create temporary issuer, an identifier issuer
 			// initialized with the prefix _:b.
 			issuer := NewIdentifierIssuer("_:b")
@@ -205,17 +330,19 @@ func (na *NormalisationAlgorithm) Normalize(dataset *RDFDataset) {
 			// 6.2.3) Use the Issue Identifier algorithm, passing temporary
 			// issuer and identifier, to issue a new temporary blank node
 			// identifier for identifier.
-			issuer.GetId(id)
+			issuer.GetId(pending[i])
 
 			// 6.2.4) Run the Hash N-Degree Quads algorithm, passing
 			// temporary issuer, and append the result to the hash path
 			// list.
-			hash, newIssuer := na.hashNDegreeQuads(id, issuer)
-			issuerList, hasList := hashPaths[hash]
+			results[i].hash, results[i].issuer = na.hashNDegreeQuads(pending[i], issuer)
+		})
+		for _, result := range results {
+			issuerList, hasList := hashPaths[result.hash]
 			if !hasList {
 				issuerList = make([]*IdentifierIssuer, 0)
 			}
-			hashPaths[hash] = append(issuerList, newIssuer)
+			hashPaths[result.hash] = append(issuerList, result.issuer)
 		}
 
 		// 6.3) For each result in the hash path list,
@@ -241,64 +368,139 @@ func (na *NormalisationAlgorithm) Normalize(dataset *RDFDataset) {
 		}
 	}
 
-	// Note: At this point all blank nodes in the set of RDF quads have been
-	// assigned canonical identifiers, which have been stored in the
-	// canonical issuer. Here each quad is updated by assigning each of its
-	// blank nodes its new identifier.
+}
 
-	// 7) For each quad, quad, in input dataset:
-	na.lines = make([]string, len(na.quads))
-	for i, quad := range na.quads {
-		// 7.1) Create a copy, quad copy, of quad and replace any existing blank
-		// node identifiers using the canonical identifiers previously issued by
-		// canonical issuer.
-		// Note: We optimize away the copy here.
-		for _, attrNode := range []Node{quad.Subject, quad.Object, quad.Graph} {
-			if attrNode != nil {
-				attrValue := attrNode.GetValue()
-				if IsBlankNode(attrNode) && strings.Index(attrValue, "_:c14n") != 0 {
-					bn := attrNode.(*BlankNode)
-					bn.Attribute = na.canonicalIssuer.GetId(attrValue)
-				}
+// canonicalNQuad runs steps 7.1 and 7.2 of the normalisation algorithm for the given quad
+// and returns its serialization in N-Quads format.
+//
+// Note: At this point all blank nodes in the set of RDF quads have been
+// assigned canonical identifiers, which have been stored in the
+// canonical issuer. Here each quad is updated by assigning each of its
+// blank nodes its new identifier.
+func (na *NormalisationAlgorithm) canonicalNQuad(quad *normalizedQuad) string {
+	// 7.1) Create a copy, quad copy, of quad and replace any existing blank
+	// node identifiers using the canonical identifiers previously issued by
+	// canonical issuer.
+	// Note: the quads were copied in step 2, so they can be modified in place.
+	for _, attrNode := range [...]Node{quad.Subject, quad.Object, quad.Graph} {
+		if attrNode != nil {
+			attrValue := attrNode.GetValue()
+			if IsBlankNode(attrNode) && strings.Index(attrValue, "_:c14n") != 0 {
+				bn := attrNode.(*BlankNode)
+				bn.Attribute = na.canonicalIssuer.GetId(attrValue)
 			}
 		}
+	}
+
+	// 7.2) Add quad copy to the normalized dataset.
+	subject, object, graph := quad.subject, quad.object, quad.graph
+	if IsBlankNode(quad.Subject) {
+		subject = quad.Subject.GetValue()
+	}
+	if IsBlankNode(quad.Object) {
+		object = quad.Object.GetValue()
+	}
+	if IsBlankNode(quad.Graph) {
+		graph = quad.Graph.GetValue()
+	}
+	return joinNQuad(subject, quad.predicate, object, graph)
+}
 
-		// 7.2) Add quad copy to the normalized dataset.
-		var name string
-		nameVal := quad.Graph
-		if nameVal != nil {
-			name = nameVal.GetValue()
+// dataset returns the normalized dataset. Duplicate quads are skipped.
+func (na *NormalisationAlgorithm) dataset() *RDFDataset {
+	ds := NewRDFDataset()
+	for i, quad := range na.quads {
+		if i > 0 && na.lines[i] == na.lines[i-1] {
+			continue
+		}
+		name := "@default"
+		if quad.Graph != nil {
+			name = quad.Graph.GetValue()
 		}
-		na.lines[i] = toNQuad(quad, name)
+		ds.Graphs[name] = append(ds.Graphs[name], quad.Quad)
 	}
+	return ds
+}
 
-	// sort normalized output
-	sort.Sort(na)
+// forEach calls fn for every index in [0, n). If the algorithm is configured with more than
+// one worker, the calls are distributed among a pool of goroutines. fn must be safe
+// for concurrent use in that case.
+func (na *NormalisationAlgorithm) forEach(n int, fn func(i int)) {
+	workers := na.workers
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := int(atomic.AddInt64(&next, 1)); i < n; i = int(atomic.AddInt64(&next, 1)) {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// copyBlankNode returns a copy of the given node if it's a blank node. Other node types
+// aren't modified during normalisation and can be shared with the input dataset.
+func copyBlankNode(n Node) Node {
+	if bn, isBlankNode := n.(*BlankNode); isBlankNode {
+		return NewBlankNode(bn.Attribute)
+	}
+	return n
 }
 
 func (na *NormalisationAlgorithm) Main(dataset *RDFDataset, opts *JsonLdOptions) (interface{}, error) {
+	if na.hashErr != nil {
+		return nil, na.hashErr
+	}
+	if opts.Format != "" && opts.Format != "application/n-quads" && opts.Format != "application/nquads" {
+		return nil, NewJsonLdError(UnknownFormat, opts.Format)
+	}
+
+	if opts.NormalizationMaxLines > 0 {
+		// the canonical quads aren't retained when spilling to disk
+		var buf bytes.Buffer
+		if err := na.NormalizeTo(dataset, &buf, opts.NormalizationMaxLines, opts.NormalizationTempDir); err != nil {
+			return nil, err
+		}
+		if opts.Format != "" {
+			return buf.String(), nil
+		}
+		return ParseNQuadsFrom(buf.Bytes())
+	}
+
 	// Steps 1 through 7.2, plus sorting
-	na.Normalize(dataset)
+	if err := na.Normalize(dataset); err != nil {
+		return nil, err
+	}
 
 	// 8) Return the normalized dataset.
 	// handle output format
 	if opts.Format != "" {
-		if opts.Format == "application/n-quads" || opts.Format == "application/nquads" {
-			rval := ""
-			for _, n := range na.lines {
-				rval += n
-			}
-			return rval, nil
-		} else {
-			return nil, NewJsonLdError(UnknownFormat, opts.Format)
+		size := 0
+		for _, line := range na.lines {
+			size += len(line)
 		}
-	}
-	var rval []byte
-	for _, n := range na.lines {
-		rval = append(rval, []byte(n)...)
+		var sb strings.Builder
+		sb.Grow(size)
+		for _, line := range na.lines {
+			sb.WriteString(line)
+		}
+		return sb.String(), nil
 	}
 
-	return ParseNQuads(string(rval))
+	return na.dataset(), nil
 }
 
 // Sort interface
@@ -313,20 +515,19 @@ func (na *NormalisationAlgorithm) Swap(i, j int) {
 func (na *NormalisationAlgorithm) hashFirstDegreeQuads(id string) string {
 	// return cached hash
 	info := na.blankNodeInfo[id]
-	if hash, hasHash := info["hash"]; hasHash {
-		return hash.(string)
+	if info.hash != "" {
+		return info.hash
 	}
 
 	// 1) Initialize nquads to an empty list. It will be used to store quads
 	// in N-Quads format.
-	nquads := make([]string, 0)
-
 	// 2) Get the list of quads associated with the reference blank
 	// node identifier in the blank node to quads map.
-	quads := info["quads"].([]*Quad)
+	quads := info.quads
+	nquads := make([]string, len(quads))
 
 	// 3) For each quad quad in quads:
-	for _, quad := range quads {
+	for i, quad := range quads {
 		// 3.1) Serialize the quad in N-Quads format with the following
 		// special rule:
 
@@ -337,20 +538,12 @@ func (na *NormalisationAlgorithm) hashFirstDegreeQuads(id string) string {
 		// matches the reference blank node identifier then use the
 		// blank node identifier _:a, otherwise, use the blank node
 		// identifier _:z.
-		graphCopy := na.modifyFirstDegreeComponent(id, quad.Graph, true)
-		var name string
-		if graphCopy != nil {
-			name = graphCopy.GetValue()
-		}
-
-		quadCopy := NewQuad(
-			na.modifyFirstDegreeComponent(id, quad.Subject, false),
-			quad.Predicate,
-			na.modifyFirstDegreeComponent(id, quad.Object, false),
-			name,
+		nquads[i] = joinNQuad(
+			na.firstDegreeTerm(id, quad.Subject, quad.subject, false),
+			quad.predicate,
+			na.firstDegreeTerm(id, quad.Object, quad.object, false),
+			na.firstDegreeTerm(id, quad.Graph, quad.graph, true),
 		)
-
-		nquads = append(nquads, toNQuad(quadCopy, name))
 	}
 
 	// 4) Sort nquads in lexicographical order.
@@ -359,38 +552,26 @@ func (na *NormalisationAlgorithm) hashFirstDegreeQuads(id string) string {
 	// 5) Return the hash that results from passing the sorted, joined nquads
 	// through the hash algorithm.
 	hash := na.hashNQuads(nquads)
-	info["hash"] = hash
+	info.hash = hash
 	return hash
 }
 
-// helper for modifying component during Hash First Degree Quads
-func (na *NormalisationAlgorithm) modifyFirstDegreeComponent(id string, component Node, isGraph bool) Node {
+// helper for serializing a component during Hash First Degree Quads
+func (na *NormalisationAlgorithm) firstDegreeTerm(id string, component Node, term string, isGraph bool) string {
 	if !IsBlankNode(component) {
-		return component
+		return term
 	}
-	var val string
-	if na.version == AlgorithmURDNA2015 {
-		if component.GetValue() == id {
-			val = "_:a"
-		} else {
-			val = "_:z"
-		}
-	} else {
-		if isGraph {
-			val = "_:g"
-		} else {
-			if component.GetValue() == id {
-				val = "_:a"
-			} else {
-				val = "_:z"
-			}
-		}
+	if isGraph && na.version != AlgorithmURDNA2015 {
+		return "_:g"
+	}
+	if component.GetValue() == id {
+		return "_:a"
 	}
-	return NewBlankNode(val)
+	return "_:z"
 }
 
 // 4.7) Hash Related Blank Node
-func (na *NormalisationAlgorithm) hashRelatedBlankNode(related string, quad *Quad, issuer *IdentifierIssuer, position string) string {
+func (na *NormalisationAlgorithm) hashRelatedBlankNode(related string, quad *normalizedQuad, issuer *IdentifierIssuer, position string) string {
 	// 1) Set the identifier to use for related, preferring first the
 	// canonical identifier for related if issued, second the identifier
 	// issued by issuer if issued, and last, if necessary, the result of
@@ -405,18 +586,19 @@ func (na *NormalisationAlgorithm) hashRelatedBlankNode(related string, quad *Qua
 	}
 
 	// 2) Initialize a string input to the value of position.
-	// Note: We use a hash object instead.
-	md := na.createHash()
-	md.Write([]byte(position))
+	input := make([]byte, 0, len(position)+len(quad.relatedPredicate)+len(id))
+	input = append(input, position...)
 
 	// 3) If position is not g, append <, the value of the predicate in
 	// quad, and > to input.
 	if position != "g" {
-		md.Write([]byte(na.getRelatedPredicate(quad)))
+		input = append(input, quad.relatedPredicate...)
 	}
 
 	// 4) Append identifier to input.
-	md.Write([]byte(id))
+	input = append(input, id...)
+	md := na.createHash()
+	md.Write(input)
 
 	// 5) Return the hash that results from passing input through the hash
 	// algorithm.
@@ -562,38 +744,22 @@ func (na *NormalisationAlgorithm) hashNDegreeQuads(id string, issuer *Identifier
 
 // helper to create appropriate hash object
 func (na *NormalisationAlgorithm) createHash() hashPkg.Hash {
-	if na.version == AlgorithmURDNA2015 {
-		switch na.messageDigestAlgorithm {
-		case MessageDigestAlgorithmSHA256:
-			return sha256.New()
-		case MessageDigestAlgorithmSHA384:
-			return sha512.New384()
-		case MessageDigestAlgorithmSHA512:
-			return sha512.New()
-		default:
-			return sha1.New() //nolint:gosec
-		}
-	} else {
-		return sha1.New() //nolint:gosec
-	}
+	return na.hashFactory()
 }
 
 // helper to hash a list of nquads
 func (na *NormalisationAlgorithm) hashNQuads(nquads []string) string {
-	h := na.createHash()
+	size := 0
 	for _, nquad := range nquads {
-		h.Write([]byte(nquad))
+		size += len(nquad)
 	}
-	return encodeHex(h.Sum(nil))
-}
-
-// helper for getting a related predicate
-func (na *NormalisationAlgorithm) getRelatedPredicate(quad *Quad) string {
-	if na.version == AlgorithmURDNA2015 {
-		return "<" + quad.Predicate.GetValue() + ">"
-	} else {
-		return quad.Predicate.GetValue()
+	data := make([]byte, 0, size)
+	for _, nquad := range nquads {
+		data = append(data, nquad...)
 	}
+	h := na.createHash()
+	h.Write(data)
+	return encodeHex(h.Sum(nil))
 }
 
 // helper for creating hash to related blank nodes map
@@ -604,7 +770,7 @@ func (na *NormalisationAlgorithm) createHashToRelated(id string, issuer *Identif
 
 	// 2) Get a reference, quads, to the list of quads in the blank node to
 	// quads map for the key identifier.
-	quads := na.blankNodeInfo[id]["quads"].([]*Quad)
+	quads := na.blankNodeInfo[id].quads
 
 	// 3) For each quad in quads:
 	var related, position string
@@ -614,7 +780,7 @@ func (na *NormalisationAlgorithm) createHashToRelated(id string, issuer *Identif
 			// object, and graph name and it is a blank node that is not
 			// identified by identifier:
 			i := 0
-			for _, attrNode := range []Node{quad.Subject, quad.Object, quad.Graph} {
+			for _, attrNode := range [...]Node{quad.Subject, quad.Object, quad.Graph} {
 				if attrNode != nil {
 					attrValue := attrNode.GetValue()
 					if IsBlankNode(attrNode) && attrValue != id {
@@ -680,11 +846,16 @@ func (na *NormalisationAlgorithm) createHashToRelated(id string, issuer *Identif
 const hexDigit = "0123456789abcdef"
 
 func encodeHex(data []byte) string {
-	var buf = make([]byte, 0, len(data)*2)
+	// the buffer is large enough for all supported digests
+	var buf [2 * sha512.Size]byte
+	dst := buf[:0]
+	if len(data) > sha512.Size {
+		dst = make([]byte, 0, len(data)*2)
+	}
 	for _, b := range data {
-		buf = append(buf, hexDigit[b>>4], hexDigit[b&0xf])
+		dst = append(dst, hexDigit[b>>4], hexDigit[b&0xf])
 	}
-	return string(buf)
+	return string(dst)
 }
 
 // Permutator
@@ -0,0 +1,214 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"os"
+	"sort"
+)
+
+// NormalizeTo runs the normalisation algorithm on the given dataset and writes
+// the canonical N-Quads to w.
+//
+// If maxLines is greater than zero, no more than maxLines serialized quads are kept
+// in memory at a time: sorted chunks of lines are written to temporary files in tempDir
+// (or the default directory for temporary files, if tempDir is empty) and merged when
+// writing the output. The temporary files are removed before NormalizeTo returns.
+// In this mode the canonical quads aren't retained, so Quads() returns nil afterwards.
+//
+// If maxLines is zero, NormalizeTo sorts the lines in memory, like Normalize.
+func (na *NormalisationAlgorithm) NormalizeTo(dataset *RDFDataset, w io.Writer, maxLines int, tempDir string) error {
+	if na.hashErr != nil {
+		return na.hashErr
+	}
+
+	if maxLines <= 0 {
+		if err := na.Normalize(dataset); err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(w)
+		for _, line := range na.lines {
+			if _, err := bw.WriteString(line); err != nil {
+				return NewJsonLdError(IOError, err)
+			}
+		}
+		if err := bw.Flush(); err != nil {
+			return NewJsonLdError(IOError, err)
+		}
+		return nil
+	}
+
+	na.issueCanonicalIdentifiers(dataset)
+
+	sorter := &lineSorter{maxLines: maxLines, tempDir: tempDir}
+	defer sorter.close()
+
+	// 7) For each quad, quad, in input dataset:
+	for i, quad := range na.quads {
+		if err := sorter.add(na.canonicalNQuad(quad)); err != nil {
+			return err
+		}
+		// the quad isn't needed anymore
+		na.quads[i] = nil
+	}
+	na.quads = nil
+
+	return sorter.writeTo(w)
+}
+
+// lineSorter sorts N-Quads lines using a bounded amount of memory. When the number of lines
+// reaches maxLines, they get sorted and written to a temporary file.
+type lineSorter struct {
+	maxLines int
+	tempDir  string
+	lines    []string
+	chunks   []*os.File
+}
+
+func (ls *lineSorter) add(line string) error {
+	ls.lines = append(ls.lines, line)
+	if len(ls.lines) >= ls.maxLines {
+		return ls.spill()
+	}
+	return nil
+}
+
+// spill writes the sorted lines to a new temporary file.
+func (ls *lineSorter) spill() error {
+	sort.Strings(ls.lines)
+
+	f, err := os.CreateTemp(ls.tempDir, "json-gold-normalize-*")
+	if err != nil {
+		return NewJsonLdError(IOError, err)
+	}
+	ls.chunks = append(ls.chunks, f)
+
+	bw := bufio.NewWriter(f)
+	for _, line := range ls.lines {
+		if _, err := bw.WriteString(line); err != nil {
+			return NewJsonLdError(IOError, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return NewJsonLdError(IOError, err)
+	}
+	ls.lines = ls.lines[:0]
+	return nil
+}
+
+// writeTo merges the temporary files and the lines still held in memory and writes
+// the result to w.
+func (ls *lineSorter) writeTo(w io.Writer) error {
+	sort.Strings(ls.lines)
+
+	h := make(lineHeap, 0, len(ls.chunks)+1)
+	for _, f := range ls.chunks {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return NewJsonLdError(IOError, err)
+		}
+		src := &chunkSource{r: bufio.NewReader(f)}
+		if err := src.next(); err != nil {
+			return err
+		}
+		if src.hasLine {
+			h = append(h, src)
+		}
+	}
+	if len(ls.lines) > 0 {
+		src := &chunkSource{remaining: ls.lines}
+		_ = src.next()
+		h = append(h, src)
+	}
+	heap.Init(&h)
+
+	bw := bufio.NewWriter(w)
+	for len(h) > 0 {
+		src := h[0]
+		if _, err := bw.WriteString(src.line); err != nil {
+			return NewJsonLdError(IOError, err)
+		}
+		if err := src.next(); err != nil {
+			return err
+		}
+		if src.hasLine {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return NewJsonLdError(IOError, err)
+	}
+	return nil
+}
+
+// close removes the temporary files.
+func (ls *lineSorter) close() {
+	for _, f := range ls.chunks {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}
+	ls.chunks = nil
+	ls.lines = nil
+}
+
+// chunkSource reads sorted lines from a temporary file or from memory.
+type chunkSource struct {
+	r         *bufio.Reader
+	remaining []string
+	line      string
+	hasLine   bool
+}
+
+func (cs *chunkSource) next() error {
+	if cs.r == nil {
+		cs.hasLine = len(cs.remaining) > 0
+		if cs.hasLine {
+			cs.line = cs.remaining[0]
+			cs.remaining = cs.remaining[1:]
+		}
+		return nil
+	}
+
+	// every canonical line ends with '\n', which can't appear unescaped within a line
+	line, err := cs.r.ReadString('\n')
+	if err == io.EOF && line == "" { //nolint:errorlint
+		cs.hasLine = false
+		return nil
+	} else if err != nil && err != io.EOF { //nolint:errorlint
+		return NewJsonLdError(IOError, err)
+	}
+	cs.line = line
+	cs.hasLine = true
+	return nil
+}
+
+// lineHeap implements heap.Interface for a k-way merge of chunkSources.
+type lineHeap []*chunkSource
+
+func (h lineHeap) Len() int            { return len(h) }
+func (h lineHeap) Less(i, j int) bool  { return h[i].line < h[j].line }
+func (h lineHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *lineHeap) Push(x interface{}) { *h = append(*h, x.(*chunkSource)) }
+func (h *lineHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,118 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeWithSpilling(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "normalization", "*-in.nq"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	proc := NewJsonLdProcessor()
+	for _, algorithm := range []string{AlgorithmURDNA2015, AlgorithmURGNA2012} {
+		for _, file := range files {
+			input, err := os.ReadFile(file)
+			require.NoError(t, err)
+
+			opts := NewJsonLdOptions("")
+			opts.InputFormat = "application/n-quads"
+			opts.Format = "application/n-quads"
+			opts.Algorithm = algorithm
+			expected, err := proc.Normalize(string(input), opts)
+			require.NoError(t, err, file)
+
+			tempDir := t.TempDir()
+			opts.NormalizationMaxLines = 2
+			opts.NormalizationTempDir = tempDir
+			actual, err := proc.Normalize(string(input), opts)
+			require.NoError(t, err, file)
+			assert.Equal(t, expected, actual, "%s (%s)", file, algorithm)
+
+			var buf bytes.Buffer
+			require.NoError(t, proc.NormalizeTo(string(input), &buf, opts), file)
+			assert.Equal(t, expected, buf.String(), "%s (%s)", file, algorithm)
+
+			// the temporary files must be removed
+			entries, err := os.ReadDir(tempDir)
+			require.NoError(t, err)
+			assert.Empty(t, entries, file)
+		}
+	}
+}
+
+func TestNormalizeToDataset(t *testing.T) {
+	input := `_:b0 <http://schema.org/name> "Jane" .
+_:b0 <http://schema.org/knows> _:b1 .
+_:b1 <http://schema.org/name> "John" .
+<http://example.org/a> <http://schema.org/knows> _:b1 _:g .
+`
+	opts := NewJsonLdOptions("")
+	opts.InputFormat = "application/n-quads"
+	opts.Algorithm = AlgorithmURDNA2015
+
+	proc := NewJsonLdProcessor()
+	expected, err := proc.Normalize(input, opts)
+	require.NoError(t, err)
+
+	opts.NormalizationMaxLines = 1
+	actual, err := proc.Normalize(input, opts)
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestNormalizeToErrors(t *testing.T) {
+	proc := NewJsonLdProcessor()
+	input := strings.Repeat("_:b0 <http://schema.org/name> \"Jane\" .\n", 2) +
+		"_:b1 <http://schema.org/name> \"John\" .\n"
+
+	tempDir := t.TempDir()
+	opts := NewJsonLdOptions("")
+	opts.InputFormat = "application/n-quads"
+	opts.Algorithm = AlgorithmURDNA2015
+	opts.NormalizationMaxLines = 1
+	opts.NormalizationTempDir = tempDir
+
+	err := proc.NormalizeTo(input, failingWriter{}, opts)
+	require.Error(t, err)
+	assert.Equal(t, IOError, err.(*JsonLdError).Code) //nolint:errorlint
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	opts.NormalizationTempDir = filepath.Join(tempDir, "missing")
+	err = proc.NormalizeTo(input, &bytes.Buffer{}, opts)
+	require.Error(t, err)
+	assert.Equal(t, IOError, err.(*JsonLdError).Code) //nolint:errorlint
+}
@@ -0,0 +1,179 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// credentialBatch returns N-Quads of n synthetic verifiable credentials. Every credential
+// and its proof are blank nodes; the proofs are stored in blank node graphs.
+// All credentials have the same shape, so their first degree hashes collide and
+// N-degree hashing is required.
+func credentialBatch(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "_:vc%d <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://www.w3.org/2018/credentials#VerifiableCredential> .\n", i)
+		fmt.Fprintf(&sb, "_:vc%d <https://www.w3.org/2018/credentials#issuer> <did:example:issuer> .\n", i)
+		fmt.Fprintf(&sb, "_:vc%d <https://www.w3.org/2018/credentials#credentialSubject> _:subj%d .\n", i, i)
+		fmt.Fprintf(&sb, "_:vc%d <https://w3id.org/security#proof> _:proofGraph%d .\n", i, i)
+		fmt.Fprintf(&sb, "_:subj%d <http://schema.org/name> \"Subject %d\" .\n", i, i%3)
+		fmt.Fprintf(&sb, "_:subj%d <http://schema.org/address> _:addr%d .\n", i, i)
+		fmt.Fprintf(&sb, "_:addr%d <http://schema.org/addressCountry> \"GB\" .\n", i)
+		fmt.Fprintf(&sb, "_:proof%d <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://w3id.org/security#DataIntegrityProof> _:proofGraph%d .\n", i, i)
+		fmt.Fprintf(&sb, "_:proof%d <https://w3id.org/security#proofValue> \"z%d\" _:proofGraph%d .\n", i, i%3, i)
+	}
+	return sb.String()
+}
+
+func normalizeNQuads(tb testing.TB, input, algorithm string, workers int) string {
+	tb.Helper()
+	opts := NewJsonLdOptions("")
+	opts.InputFormat = "application/n-quads"
+	opts.Format = "application/n-quads"
+	opts.Algorithm = algorithm
+	opts.NormalizationWorkers = workers
+	normalized, err := NewJsonLdProcessor().Normalize(input, opts)
+	require.NoError(tb, err)
+	return normalized.(string)
+}
+
+func TestNormalizeParallel(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "normalization", "*-in.nq"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	inputs := map[string]string{"credential batch": credentialBatch(30)}
+	for _, file := range files {
+		input, err := os.ReadFile(file)
+		require.NoError(t, err)
+		inputs[file] = string(input)
+	}
+
+	for _, algorithm := range []string{AlgorithmURDNA2015, AlgorithmURGNA2012} {
+		for name, input := range inputs {
+			expected := normalizeNQuads(t, input, algorithm, 0)
+			for _, workers := range []int{2, 8} {
+				assert.Equal(t, expected, normalizeNQuads(t, input, algorithm, workers),
+					"%s (%s, %d workers)", name, algorithm, workers)
+			}
+		}
+	}
+}
+
+func TestNormalizeDatasetMatchesNQuads(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "normalization", "*-in.nq"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	api := NewJsonLdApi()
+	for _, algorithm := range []string{AlgorithmURDNA2015, AlgorithmURGNA2012} {
+		for _, file := range files {
+			input, err := os.ReadFile(file)
+			require.NoError(t, err)
+			dataset, err := ParseNQuads(string(input))
+			require.NoError(t, err)
+
+			opts := NewJsonLdOptions("")
+			opts.Algorithm = algorithm
+			opts.Format = "application/n-quads"
+			nquads, err := api.Normalize(dataset, opts)
+			require.NoError(t, err, file)
+			expected, err := ParseNQuads(nquads.(string))
+			require.NoError(t, err, file)
+
+			opts.Format = ""
+			actual, err := api.Normalize(dataset, opts)
+			require.NoError(t, err, file)
+			assert.Equal(t, expected, actual, "%s (%s)", file, algorithm)
+		}
+	}
+}
+
+func benchmarkNormalize(b *testing.B, credentials int) {
+	dataset, err := ParseNQuads(credentialBatch(credentials))
+	require.NoError(b, err)
+
+	for _, workers := range []int{0, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			opts := NewJsonLdOptions("")
+			opts.Format = "application/n-quads"
+			opts.Algorithm = AlgorithmURDNA2015
+			opts.NormalizationWorkers = workers
+			api := NewJsonLdApi()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := api.Normalize(dataset, opts)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func BenchmarkNormalizeCredentials100(b *testing.B) {
+	benchmarkNormalize(b, 100)
+}
+
+func BenchmarkNormalizeCredentials1000(b *testing.B) {
+	benchmarkNormalize(b, 1000)
+}
+
+// BenchmarkNormalizeTestSuite normalizes all inputs of the normalization test suite
+// on every iteration.
+func BenchmarkNormalizeTestSuite(b *testing.B) {
+	files, err := filepath.Glob(filepath.Join("testdata", "normalization", "*-in.nq"))
+	require.NoError(b, err)
+
+	datasets := make([]*RDFDataset, 0, len(files))
+	for _, file := range files {
+		input, err := os.ReadFile(file)
+		require.NoError(b, err)
+		dataset, err := ParseNQuads(string(input))
+		require.NoError(b, err)
+		datasets = append(datasets, dataset)
+	}
+
+	for _, format := range []string{"application/n-quads", ""} {
+		name := "dataset"
+		if format != "" {
+			name = "nquads"
+		}
+		b.Run(name, func(b *testing.B) {
+			opts := NewJsonLdOptions("")
+			opts.Format = format
+			opts.Algorithm = AlgorithmURDNA2015
+			api := NewJsonLdApi()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, dataset := range datasets {
+					_, err := api.Normalize(dataset, opts)
+					require.NoError(b, err)
+				}
+			}
+		})
+	}
+}
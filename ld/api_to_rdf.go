@@ -14,6 +14,10 @@
 
 package ld
 
+import (
+	"fmt"
+)
+
 // ToRDF adds RDF triples for each graph in the current node map to an RDF dataset.
 func (api *JsonLdApi) ToRDF(input interface{}, opts *JsonLdOptions) (*RDFDataset, error) {
 	issuer := NewIdentifierIssuer("_:b")
@@ -31,8 +35,13 @@ func (api *JsonLdApi) ToRDF(input interface{}, opts *JsonLdOptions) (*RDFDataset
 		if IsRelativeIri(graphName) {
 			continue
 		}
-		graph := graphVal.(map[string]interface{})
-		dataset.GraphToRDF(graphName, graph, issuer, opts.ProduceGeneralizedRdf)
+		graph, isMap := graphVal.(map[string]interface{})
+		if !isMap {
+			return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("invalid node map for graph %s", graphName))
+		}
+		if err := dataset.GraphToRDF(graphName, graph, issuer, opts.ProduceGeneralizedRdf); err != nil {
+			return nil, err
+		}
 	}
 
 	return dataset, nil
@@ -0,0 +1,210 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/piprate/json-gold/ld/internal/multibase"
+)
+
+// ContentIDFormat defines the form of content identifiers returned by JsonLdProcessor.ContentID.
+type ContentIDFormat string
+
+const (
+	// ContentIDMultihash is a base58-btc multibase-encoded multihash, for example "zQmY...".
+	ContentIDMultihash ContentIDFormat = "multihash"
+	// ContentIDCID is a CIDv1 (https://github.com/multiformats/cid) with the raw codec,
+	// base32 multibase-encoded, for example "bafkrei...".
+	ContentIDCID ContentIDFormat = "cid"
+	// ContentIDURNHash is a URN in the form "urn:hash::<algorithm>:<hex digest>",
+	// for example "urn:hash::sha256:9f86d08...".
+	ContentIDURNHash ContentIDFormat = "urn:hash"
+)
+
+const (
+	cidVersion1 = 0x01
+	// multicodec code of raw binary content: the canonical N-Quads document
+	cidCodecRaw = 0x55
+
+	urnHashPrefix = "urn:hash::"
+)
+
+// ContentID returns a self-describing identifier of the canonical form of the given input.
+// The input is JSON-LD unless the 'inputFormat' option is used or it's an *RDFDataset.
+//
+// The digest is computed over the URDNA2015 canonical N-Quads of the input with the hash
+// function selected by the MessageDigestAlgorithm option, which must have a multihash
+// code (see RegisterMultihashCode). Datasets with the same canonical form get the same
+// identifier, whatever their original serialization or blank node labels.
+func (jldp *JsonLdProcessor) ContentID(input interface{}, format ContentIDFormat,
+	opts *JsonLdOptions) (string, error) {
+
+	opts = jldp.prepareOptions(opts)
+	if opts.MessageDigestAlgorithm == "" {
+		opts.MessageDigestAlgorithm = MessageDigestAlgorithmSHA256
+	}
+
+	var code uint64
+	switch format {
+	case ContentIDMultihash, ContentIDCID:
+		var found bool
+		if code, found = lookupMultihashCode(opts.MessageDigestAlgorithm); !found {
+			return "", NewJsonLdError(UnknownMessageDigestAlgorithm,
+				fmt.Sprintf("no multihash code for %s", opts.MessageDigestAlgorithm))
+		}
+	case ContentIDURNHash:
+	default:
+		return "", NewJsonLdError(UnknownFormat, string(format))
+	}
+
+	digest, err := jldp.canonicalDigest(input, opts)
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case ContentIDMultihash:
+		return multibase.Encode(multibase.Base58BTC, encodeMultihash(code, digest))
+	case ContentIDCID:
+		cid := appendUvarint([]byte{cidVersion1}, cidCodecRaw)
+		return multibase.Encode(multibase.Base32, append(cid, encodeMultihash(code, digest)...))
+	default:
+		return urnHashPrefix + strings.ToLower(string(opts.MessageDigestAlgorithm)) + ":" +
+			hex.EncodeToString(digest), nil
+	}
+}
+
+// VerifyContentID checks that the given identifier, in any of the forms returned by ContentID,
+// matches the canonical form of the input. The hash function is defined by the identifier,
+// so the MessageDigestAlgorithm option is ignored.
+// If the identifier doesn't match, the error code is IntegrityCheckFailed.
+func (jldp *JsonLdProcessor) VerifyContentID(input interface{}, id string, opts *JsonLdOptions) error {
+	algorithm, expected, err := ParseContentID(id)
+	if err != nil {
+		return err
+	}
+
+	opts = jldp.prepareOptions(opts)
+	opts.MessageDigestAlgorithm = algorithm
+
+	actual, err := jldp.canonicalDigest(input, opts)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(expected, actual) {
+		return NewJsonLdError(IntegrityCheckFailed, fmt.Sprintf("content doesn't match %s", id))
+	}
+	return nil
+}
+
+// ParseContentID returns the message digest algorithm and the digest of the given
+// content identifier.
+func ParseContentID(id string) (MessageDigestAlgorithm, []byte, error) {
+	if strings.HasPrefix(id, urnHashPrefix) {
+		return parseURNHash(id)
+	}
+
+	encoding, data, err := multibase.Decode(id)
+	if err != nil {
+		return "", nil, NewJsonLdError(InvalidContentID, err)
+	}
+
+	// a CIDv1 starts with the version and the content codec, a multihash - with
+	// the hash function code, which can't be 0x01
+	if encoding == multibase.Base32 || (len(data) > 0 && data[0] == cidVersion1) {
+		version, n := binary.Uvarint(data)
+		if n <= 0 || version != cidVersion1 {
+			return "", nil, NewJsonLdError(InvalidContentID, fmt.Sprintf("unsupported CID version: %s", id))
+		}
+		data = data[n:]
+		codec, n := binary.Uvarint(data)
+		if n <= 0 || codec != cidCodecRaw {
+			return "", nil, NewJsonLdError(InvalidContentID, fmt.Sprintf("unsupported CID codec: %s", id))
+		}
+		data = data[n:]
+	}
+
+	return decodeMultihash(data)
+}
+
+// canonicalDigest returns the digest of the canonical N-Quads of the input.
+func (jldp *JsonLdProcessor) canonicalDigest(input interface{}, opts *JsonLdOptions) ([]byte, error) {
+	factory, err := lookupMessageDigest(opts.MessageDigestAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Algorithm = AlgorithmURDNA2015
+	h := factory()
+	if err = jldp.NormalizeTo(input, h, opts); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func encodeMultihash(code uint64, digest []byte) []byte {
+	rval := appendUvarint(nil, code)
+	rval = appendUvarint(rval, uint64(len(digest)))
+	return append(rval, digest...)
+}
+
+// appendUvarint appends the unsigned varint encoding of v to b. Multiformats use
+// the same encoding as encoding/binary.
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func decodeMultihash(data []byte) (MessageDigestAlgorithm, []byte, error) {
+	code, n := binary.Uvarint(data)
+	if n <= 0 {
+		return "", nil, NewJsonLdError(InvalidContentID, "invalid multihash")
+	}
+	data = data[n:]
+	length, n := binary.Uvarint(data)
+	if n <= 0 || length != uint64(len(data)-n) {
+		return "", nil, NewJsonLdError(InvalidContentID, "invalid multihash length")
+	}
+
+	algorithm, found := lookupMultihashAlgorithm(code)
+	if !found {
+		return "", nil, NewJsonLdError(UnknownMessageDigestAlgorithm, fmt.Sprintf("multihash code 0x%x", code))
+	}
+	return algorithm, data[n:], nil
+}
+
+func parseURNHash(id string) (MessageDigestAlgorithm, []byte, error) {
+	name, hexDigest, found := strings.Cut(strings.TrimPrefix(id, urnHashPrefix), ":")
+	if !found {
+		return "", nil, NewJsonLdError(InvalidContentID, id)
+	}
+	digest, err := hex.DecodeString(hexDigest)
+	if err != nil {
+		return "", nil, NewJsonLdError(InvalidContentID, err)
+	}
+
+	for _, algorithm := range MessageDigestAlgorithms() {
+		if strings.EqualFold(string(algorithm), name) {
+			return algorithm, digest, nil
+		}
+	}
+	return "", nil, NewJsonLdError(UnknownMessageDigestAlgorithm, name)
+}
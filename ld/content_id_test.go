@@ -0,0 +1,152 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nquadsOptions() *JsonLdOptions {
+	opts := NewJsonLdOptions("")
+	opts.InputFormat = "application/n-quads"
+	return opts
+}
+
+func TestContentID(t *testing.T) {
+	proc := NewJsonLdProcessor()
+
+	normalized, err := normalizeWithDigest(t, MessageDigestAlgorithmSHA256)
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte(normalized))
+
+	id, err := proc.ContentID(digestTestInput, ContentIDURNHash, nquadsOptions())
+	require.NoError(t, err)
+	assert.Equal(t, "urn:hash::sha256:"+hex.EncodeToString(sum[:]), id)
+
+	multihash, err := proc.ContentID(digestTestInput, ContentIDMultihash, nquadsOptions())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(multihash, "zQm"), multihash)
+
+	cid, err := proc.ContentID(digestTestInput, ContentIDCID, nquadsOptions())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(cid, "bafkrei"), cid)
+
+	for _, id := range []string{id, multihash, cid} {
+		algorithm, digest, err := ParseContentID(id)
+		require.NoError(t, err, id)
+		assert.Equal(t, MessageDigestAlgorithmSHA256, algorithm, id)
+		assert.Equal(t, sum[:], digest, id)
+	}
+
+	// the identifier doesn't depend on blank node labels or the input format
+	relabelled := strings.NewReplacer("_:x", "_:b1", "_:y", "_:b0").Replace(digestTestInput)
+	actual, err := proc.ContentID(relabelled, ContentIDCID, nquadsOptions())
+	require.NoError(t, err)
+	assert.Equal(t, cid, actual)
+
+	dataset, err := ParseNQuads(digestTestInput)
+	require.NoError(t, err)
+	actual, err = proc.ContentID(dataset, ContentIDCID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, cid, actual)
+
+	doc := []interface{}{
+		map[string]interface{}{"http://schema.org/name": "B"},
+		map[string]interface{}{"http://schema.org/name": "A"},
+	}
+	actual, err = proc.ContentID(doc, ContentIDCID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, cid, actual)
+
+	_, err = proc.ContentID(doc, "sri", nil)
+	require.Error(t, err)
+	assert.Equal(t, UnknownFormat, err.(*JsonLdError).Code) //nolint:errorlint
+}
+
+func TestContentIDMessageDigestAlgorithm(t *testing.T) {
+	RestoreMessageDigestsOnCleanup(t)
+
+	proc := NewJsonLdProcessor()
+
+	opts := nquadsOptions()
+	opts.MessageDigestAlgorithm = MessageDigestAlgorithmSHA384
+	id, err := proc.ContentID(digestTestInput, ContentIDMultihash, opts)
+	require.NoError(t, err)
+	algorithm, digest, err := ParseContentID(id)
+	require.NoError(t, err)
+	assert.Equal(t, MessageDigestAlgorithmSHA384, algorithm)
+	assert.Len(t, digest, sha512.Size384)
+
+	// the identifier selects the hash function for verification
+	assert.NoError(t, proc.VerifyContentID(digestTestInput, id, nquadsOptions()))
+
+	// digests without a multihash code can only be used in URNs
+	RegisterMessageDigestAlgorithm("SHA512/224", sha512.New512_224)
+	opts.MessageDigestAlgorithm = "SHA512/224"
+	_, err = proc.ContentID(digestTestInput, ContentIDCID, opts)
+	require.Error(t, err)
+	assert.Equal(t, UnknownMessageDigestAlgorithm, err.(*JsonLdError).Code) //nolint:errorlint
+
+	id, err = proc.ContentID(digestTestInput, ContentIDURNHash, opts)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(id, "urn:hash::sha512/224:"), id)
+	assert.NoError(t, proc.VerifyContentID(digestTestInput, id, nquadsOptions()))
+
+	RegisterMultihashCode("SHA512/224", 0x1013)
+	id, err = proc.ContentID(digestTestInput, ContentIDCID, opts)
+	require.NoError(t, err)
+	assert.NoError(t, proc.VerifyContentID(digestTestInput, id, nquadsOptions()))
+}
+
+func TestVerifyContentID(t *testing.T) {
+	proc := NewJsonLdProcessor()
+	tampered := strings.Replace(digestTestInput, `"B"`, `"C"`, 1)
+
+	for _, format := range []ContentIDFormat{ContentIDMultihash, ContentIDCID, ContentIDURNHash} {
+		id, err := proc.ContentID(digestTestInput, format, nquadsOptions())
+		require.NoError(t, err)
+		assert.NoError(t, proc.VerifyContentID(digestTestInput, id, nquadsOptions()), id)
+
+		err = proc.VerifyContentID(tampered, id, nquadsOptions())
+		require.Error(t, err, id)
+		assert.Equal(t, IntegrityCheckFailed, err.(*JsonLdError).Code, id) //nolint:errorlint
+	}
+
+	for _, id := range []string{
+		"",
+		"urn:hash::sha256",
+		"urn:hash::sha256:not-hex",
+		"f1220",                // unsupported multibase encoding
+		"z",                    // empty multihash
+		"zQm",                  // truncated multihash
+		"bafyreigbtj4x7ip5leg", // dag-cbor CID
+	} {
+		err := proc.VerifyContentID(digestTestInput, id, nquadsOptions())
+		require.Error(t, err, id)
+		assert.Equal(t, InvalidContentID, err.(*JsonLdError).Code, id) //nolint:errorlint
+	}
+
+	err := proc.VerifyContentID(digestTestInput, "urn:hash::md5:d41d8cd98f00b204e9800998ecf8427e", nquadsOptions())
+	require.Error(t, err)
+	assert.Equal(t, UnknownMessageDigestAlgorithm, err.(*JsonLdError).Code) //nolint:errorlint
+}
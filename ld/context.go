@@ -47,6 +47,9 @@ type Context struct {
 	inverse         map[string]interface{}
 	protected       map[string]bool
 	previousContext *Context
+	// source is the local context which produced this context, if known.
+	// It's written to the output of operations which take a parsed context (e.g. Compact).
+	source interface{}
 }
 
 // NewContext creates and returns a new Context object.
@@ -100,6 +103,8 @@ func CopyContext(ctx *Context) *Context {
 
 	// do not copy c.inverse, because it will be regenerated
 
+	context.source = ctx.source
+
 	if ctx.previousContext != nil {
 		context.previousContext = CopyContext(ctx.previousContext)
 	}
@@ -107,6 +112,23 @@ func CopyContext(ctx *Context) *Context {
 	return context
 }
 
+// withOptions returns the context bound to the given options. Contexts processed by other
+// operations (parsed, compiled or cached ones) must use the options of the current operation,
+// such as its document loader and safe mode. The context isn't modified: if it's bound to
+// other options, a shallow copy is returned which shares the term definitions and
+// the inverse context with the original, so it must not be modified either.
+func (c *Context) withOptions(options *JsonLdOptions) *Context {
+	if c.options == options {
+		return c
+	}
+	ctx := *c
+	ctx.options = options
+	if c.previousContext != nil {
+		ctx.previousContext = c.previousContext.withOptions(options)
+	}
+	return &ctx
+}
+
 // Parse processes a local context, retrieving any URLs as necessary, and
 // returns a new active context.
 // Refer to http://www.w3.org/TR/json-ld-api/#context-processing-algorithms for details
@@ -147,6 +169,10 @@ func (c *Context) parse(localContext interface{}, remoteContexts []string, parsi
 	// 1. Initialize result to the result of cloning active context.
 	result := CopyContext(c)
 
+	// shared is true when result refers to a context which must not be modified
+	// (a pre-parsed or cached context). It gets copied before any modification.
+	shared := false
+
 	// track the previous context
 	// if not propagating, make sure result has a previous context
 	if !propagate && result.previousContext == nil {
@@ -168,6 +194,7 @@ func (c *Context) parse(localContext interface{}, remoteContexts []string, parsi
 				nullCtx.previousContext = result
 			}
 			result = nullCtx
+			shared = false
 			continue
 		}
 
@@ -175,7 +202,25 @@ func (c *Context) parse(localContext interface{}, remoteContexts []string, parsi
 
 		switch ctx := context.(type) {
 		case *Context:
-			result = ctx
+			result = ctx.withOptions(c.options)
+			shared = true
+			continue
+		case *CompiledContext:
+			// a compiled context can replace the active context only if the latter is empty,
+			// otherwise the original context is processed on top of the active context
+			if propagate && result.isInitial() {
+				result = ctx.activeContext(result.values["@base"]).withOptions(c.options)
+				shared = true
+				continue
+			}
+			resultRef, err := result.parse(ctx.source, remoteContexts, parsingARemoteContext, propagate,
+				protected, overrideProtected)
+			if err != nil {
+				return nil, err
+			}
+			result = resultRef
+			shared = false
+			continue
 		// 3.2)
 		case string:
 			uri := Resolve(result.values["@base"].(string), ctx)
@@ -187,8 +232,25 @@ func (c *Context) parse(localContext interface{}, remoteContexts []string, parsi
 			}
 			remoteContexts = append(remoteContexts, uri)
 
+			// parsed remote contexts may be reused if they are applied to an empty context
+			var cacheKey contextCacheKey
+			cache := c.options.contextCache
+			cacheable := false
+			if cache != nil && result.isInitial() {
+				cacheKey, cacheable = newContextCacheKey(uri, c.options.DocumentLoader, result, overrideProtected)
+			}
+			if cacheable {
+				if cachedCtx := cache.get(cacheKey); cachedCtx != nil {
+					result = cachedCtx.withOptions(c.options)
+					shared = true
+					continue
+				}
+			} else {
+				cache = nil
+			}
+
 			// 3.2.3: Dereference context
-			rd, err := c.options.DocumentLoader.LoadDocument(uri)
+			rd, err := loadDocumentWithOptions(c.options.DocumentLoader, uri, contextLoadOptions())
 			if err != nil {
 				return nil, NewJsonLdError(LoadingRemoteContextFailed,
 					fmt.Errorf("dereferencing a URL did not result in a valid JSON-LD context (%s): %w", uri, err))
@@ -202,13 +264,18 @@ func (c *Context) parse(localContext interface{}, remoteContexts []string, parsi
 			}
 
 			// 3.2.4
-			remoteContextsCpy := make([]string, 0, len(remoteContexts))
+			remoteContextsCpy := make([]string, len(remoteContexts))
 			copy(remoteContextsCpy, remoteContexts)
 			resultRef, err := result.parse(context, remoteContextsCpy, true, true, false, overrideProtected)
 			if err != nil {
 				return nil, err
 			}
 			result = resultRef
+			shared = false
+			if cache != nil {
+				cache.put(cacheKey, result)
+				shared = true
+			}
 			// 3.2.5
 			continue
 		case map[string]interface{}:
@@ -218,6 +285,11 @@ func (c *Context) parse(localContext interface{}, remoteContexts []string, parsi
 			return nil, NewJsonLdError(InvalidLocalContext, context)
 		}
 
+		if shared {
+			result = CopyContext(result)
+			shared = false
+		}
+
 		// dereference @context key if present
 		if nestedContext := contextMap["@context"]; nestedContext != nil {
 			contextMap, isMap = nestedContext.(map[string]interface{})
@@ -257,7 +329,7 @@ func (c *Context) parse(localContext interface{}, remoteContexts []string, parsi
 			}
 			uri := Resolve(result.values["@base"].(string), importStr)
 
-			rd, err := c.options.DocumentLoader.LoadDocument(uri)
+			rd, err := loadDocumentWithOptions(c.options.DocumentLoader, uri, contextLoadOptions())
 			if err != nil {
 				return nil, NewJsonLdError(LoadingRemoteContextFailed,
 					fmt.Errorf("dereferencing a URL did not result in a valid JSON-LD context (%s): %w", uri, err))
@@ -276,11 +348,16 @@ func (c *Context) parse(localContext interface{}, remoteContexts []string, parsi
 						fmt.Sprintf("%s must not include @import entry", importStr))
 				}
 
-				// merge import context into the outer context
+				// merge import context into the outer context. The imported document
+				// may be shared (for example, by a caching loader), so it's not modified.
+				mergedCtxMap := make(map[string]interface{}, len(importCtxMap)+len(contextMap))
+				for k, v := range importCtxMap {
+					mergedCtxMap[k] = v
+				}
 				for k, v := range contextMap {
-					importCtxMap[k] = v
+					mergedCtxMap[k] = v
 				}
-				contextMap = importCtxMap
+				contextMap = mergedCtxMap
 			} else {
 				return nil, NewJsonLdError(InvalidRemoteContext, fmt.Sprintf("%s must be an object", importStr))
 			}
@@ -295,7 +372,7 @@ func (c *Context) parse(localContext interface{}, remoteContexts []string, parsi
 				if IsAbsoluteIri(baseString) {
 					result.values["@base"] = baseValue
 				} else {
-					baseURI := result.values["@base"].(string)
+					baseURI, _ := result.values["@base"].(string)
 					if !IsAbsoluteIri(baseURI) {
 						return nil, NewJsonLdError(InvalidBaseIRI, baseURI)
 					}
@@ -371,7 +448,11 @@ func (c *Context) parse(localContext interface{}, remoteContexts []string, parsi
 		// all its terms to be "protected" (exceptions can be made on a
 		// per-definition basis)
 		if protectedVal, protectedPresent := contextMap["@protected"]; protectedPresent {
-			defined["@protected"] = protectedVal.(bool)
+			protectedBool, isBool := protectedVal.(bool)
+			if !isBool {
+				return nil, NewJsonLdError(InvalidProtectedValue, "@protected value must be a boolean")
+			}
+			defined["@protected"] = protectedBool
 		} else if protected {
 			defined["@protected"] = true
 		}
@@ -385,9 +466,65 @@ func (c *Context) parse(localContext interface{}, remoteContexts []string, parsi
 		}
 	}
 
+	if !shared {
+		result.source = c.sourceWith(contexts)
+	}
+
 	return result, nil
 }
 
+// sourceWith returns the local context which produces the result of applying
+// the given contexts to this context, or nil if the source of this context is unknown.
+func (c *Context) sourceWith(contexts []interface{}) interface{} {
+	source := make([]interface{}, 0, len(contexts))
+	known := c.source != nil || c.isInitial()
+	if c.source != nil {
+		source = append(source, Arrayify(c.source)...)
+	}
+	for _, context := range contexts {
+		switch ctx := context.(type) {
+		case nil:
+			// null resets the active context
+			source = append(source[:0], nil)
+			known = true
+		case *Context:
+			// a parsed context replaces the active context
+			if ctx.source == nil {
+				return nil
+			}
+			source = append(source[:0], Arrayify(ctx.source)...)
+			known = true
+		case *CompiledContext:
+			source = append(source, ctx.Source())
+		default:
+			source = append(source, context)
+		}
+	}
+	if !known {
+		return nil
+	}
+	if len(source) == 1 {
+		return source[0]
+	}
+	return source
+}
+
+// sourceContext returns a copy of the local context which produced this context.
+// If the source is unknown, the context is serialized instead.
+func (c *Context) sourceContext() (interface{}, error) {
+	if c.source != nil {
+		return CloneDocument(c.source), nil
+	}
+	serialized, err := c.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	if serializedCtx, hasCtx := serialized["@context"]; hasCtx {
+		return serializedCtx, nil
+	}
+	return make(map[string]interface{}), nil
+}
+
 // CompactValue performs value compaction on an object with @value or @id as the only property.
 // See https://www.w3.org/TR/2019/CR-json-ld11-api-20191212/#value-compaction
 func (c *Context) CompactValue(activeProperty string, value map[string]interface{}) (interface{}, error) {
@@ -730,8 +867,12 @@ func (c *Context) createTermDefinition(context map[string]interface{}, term stri
 			}
 			if termDef, hasTermDef := c.termDefinitions[prefix]; hasTermDef {
 				termDefMap, _ := termDef.(map[string]interface{})
+				prefixID, isString := termDefMap["@id"].(string)
+				if !isString {
+					return NewJsonLdError(InvalidIRIMapping, fmt.Sprintf("prefix %s of term %s has no IRI mapping", prefix, term))
+				}
 				suffix := term[colIndex+1:]
-				definition["@id"] = termDefMap["@id"].(string) + suffix
+				definition["@id"] = prefixID + suffix
 			} else {
 				definition["@id"] = term
 			}
@@ -745,7 +886,11 @@ func (c *Context) createTermDefinition(context map[string]interface{}, term stri
 
 	// handle term protection
 	valProtected, protectedFound := mapValue["@protected"]
-	if (protectedFound && valProtected.(bool)) || (defined["@protected"] && !(protectedFound && !valProtected.(bool))) {
+	protectedBool, isBool := valProtected.(bool)
+	if protectedFound && !isBool {
+		return NewJsonLdError(InvalidProtectedValue, fmt.Sprintf("@protected value must be a boolean on term %s", term))
+	}
+	if (protectedFound && protectedBool) || (defined["@protected"] && !(protectedFound && !protectedBool)) {
 		c.protected[term] = true
 		definition["protected"] = true
 	}
@@ -793,12 +938,22 @@ func (c *Context) createTermDefinition(context map[string]interface{}, term stri
 		if isArray {
 			container = make([]interface{}, 0)
 			for _, c := range containerArray {
+				cStr, isString := c.(string)
+				if !isString {
+					return NewJsonLdError(InvalidContainerMapping,
+						fmt.Sprintf("@container values must be strings: %v on term %s", containerVal, term))
+				}
 				container = append(container, c)
-				containerValueMap[c.(string)] = true
+				containerValueMap[cStr] = true
 			}
 		} else {
+			containerStr, isString := containerVal.(string)
+			if !isString {
+				return NewJsonLdError(InvalidContainerMapping,
+					fmt.Sprintf("@container value must be a string or an array of strings: %v on term %s", containerVal, term))
+			}
 			container = []interface{}{containerVal}
-			containerValueMap[containerVal.(string)] = true
+			containerValueMap[containerStr] = true
 		}
 
 		validContainers := map[string]bool{
@@ -1032,7 +1187,8 @@ func (c *Context) ExpandIri(value string, relative bool, vocab bool, context map
 	if termDef, hasTermDef := c.termDefinitions[value]; vocab && hasTermDef {
 		termDefMap, isMap := termDef.(map[string]interface{})
 		if isMap && termDefMap != nil {
-			return termDefMap["@id"].(string), nil
+			id, _ := termDefMap["@id"].(string)
+			return id, nil
 		}
 
 		return "", nil
@@ -1060,10 +1216,11 @@ func (c *Context) ExpandIri(value string, relative bool, vocab bool, context map
 		// 4.4)
 		// If active context contains a term definition for prefix, return the result of concatenating
 		// the IRI mapping associated with prefix and suffix.
-		termDef, hasPrefix := c.termDefinitions[prefix]
-		if hasPrefix && termDef.(map[string]interface{})["@id"] != "" && termDef.(map[string]interface{})["_prefix"].(bool) {
-			termDefMap := termDef.(map[string]interface{})
-			return termDefMap["@id"].(string) + suffix, nil
+		termDefMap, _ := c.termDefinitions[prefix].(map[string]interface{})
+		prefixID, _ := termDefMap["@id"].(string)
+		isPrefix, _ := termDefMap["_prefix"].(bool)
+		if prefixID != "" && isPrefix {
+			return prefixID + suffix, nil
 		} else if IsAbsoluteIri(value) {
 			// Otherwise, if the value has the form of an absolute IRI, return it
 			return value, nil
@@ -0,0 +1,217 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"reflect"
+	"sync"
+)
+
+// CompiledContext is a JSON-LD context which has been processed once and can be reused
+// across operations and goroutines. It carries the term definitions and the precomputed
+// inverse context, and it is never modified after creation.
+//
+// A CompiledContext can be used anywhere a context is accepted: as the context argument
+// of Compact and Flatten, as JsonLdOptions.ExpandContext, as the @context of a frame
+// or as the value of @context in a document.
+//
+// The context is processed with the options given to JsonLdProcessor.CompileContext
+// (for example, the processing mode and the document loader used for its remote contexts).
+// Operations which use the compiled context apply their own options to any further
+// processing, such as loading the remote and scoped contexts of the document
+// and the checks of safe mode.
+type CompiledContext struct {
+	context *Context
+	source  interface{}
+	ownBase bool
+}
+
+// CompileContext processes the given local context and returns a CompiledContext.
+// localContext may be a context definition, an IRI of a remote context, an array of those,
+// or a document with a @context entry.
+func (jldp *JsonLdProcessor) CompileContext(localContext interface{}, opts *JsonLdOptions) (*CompiledContext, error) {
+	opts = jldp.prepareOptions(opts)
+
+	localContext = CloneDocument(localContext)
+	if contextMap, isMap := localContext.(map[string]interface{}); isMap {
+		if innerCtx, hasCtx := contextMap["@context"]; hasCtx {
+			localContext = innerCtx
+		}
+	}
+
+	activeCtx, err := NewContext(nil, opts).Parse(localContext)
+	if err != nil {
+		return nil, err
+	}
+	// the parsed context may be shared with the local context (if it's a *Context),
+	// so the compiled context gets its own copy
+	activeCtx = CopyContext(activeCtx)
+
+	// precompute the inverse context, so that it's never generated concurrently
+	activeCtx.GetInverse()
+
+	return &CompiledContext{
+		context: activeCtx,
+		source:  localContext,
+		ownBase: definesBase(localContext),
+	}, nil
+}
+
+// definesBase returns true if the base IRI of the given local context is set by the context
+// itself (with @base) rather than inherited from the options it's processed with.
+func definesBase(localContext interface{}) bool {
+	ownBase := false
+	for _, ctx := range Arrayify(localContext) {
+		switch v := ctx.(type) {
+		case nil:
+			// a null context resets the base IRI to the one of the options
+			ownBase = false
+		case map[string]interface{}:
+			if _, hasBase := v["@base"]; hasBase {
+				ownBase = true
+			}
+		case *CompiledContext:
+			ownBase = ownBase || v.ownBase
+		case *Context:
+			// parsed contexts carry the base IRI they were processed with
+			ownBase = true
+		}
+		// @base is ignored in remote contexts
+	}
+	return ownBase
+}
+
+// Source returns a copy of the local context the compiled context was created from.
+func (cc *CompiledContext) Source() interface{} {
+	return CloneDocument(cc.source)
+}
+
+// activeContext returns the compiled context as an active context with the given base IRI.
+// The base IRI is ignored if the context defines its own @base.
+// The returned context must not be modified.
+func (cc *CompiledContext) activeContext(base interface{}) *Context {
+	if cc.ownBase || cc.context.values["@base"] == base {
+		return cc.context
+	}
+
+	ctx := CopyContext(cc.context)
+	if base == nil {
+		delete(ctx.values, "@base")
+	} else {
+		ctx.values["@base"] = base
+	}
+	// the inverse context doesn't depend on the base IRI
+	ctx.inverse = cc.context.inverse
+
+	return ctx
+}
+
+// isInitial returns true if the context has no term definitions and no values
+// other than the base IRI and the processing mode.
+func (c *Context) isInitial() bool {
+	if len(c.termDefinitions) > 0 || c.previousContext != nil {
+		return false
+	}
+	for k := range c.values {
+		if k != "@base" && k != "processingMode" {
+			return false
+		}
+	}
+	return true
+}
+
+// ContextCache is a cache of parsed remote contexts, keyed by URL, the document loader
+// and the processing options which affect context processing. It is safe for concurrent use.
+//
+// Contexts loaded by one document loader are never served to operations which use another
+// one, so a cache may be shared between loaders which apply different policies (for example,
+// an IntegrityDocumentLoader and a plain one). To benefit from the cache, operations must
+// use the same loader instance: note that NewJsonLdOptions creates a new default loader.
+// Contexts loaded by loaders which aren't comparable with == aren't cached.
+// A cached context is always used with the options of the operation which reads it.
+//
+// Cached contexts are kept for the lifetime of the cache, regardless of any caching
+// headers of the remote documents.
+type ContextCache struct {
+	mu      sync.RWMutex
+	entries map[contextCacheKey]*Context
+}
+
+type contextCacheKey struct {
+	url               string
+	loader            DocumentLoader
+	base              string
+	hasBase           bool
+	processingMode    string
+	overrideProtected bool
+}
+
+// NewContextCache creates a new instance of ContextCache.
+func NewContextCache() *ContextCache {
+	return &ContextCache{
+		entries: make(map[contextCacheKey]*Context),
+	}
+}
+
+// Len returns the number of cached contexts.
+func (cc *ContextCache) Len() int {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+
+	return len(cc.entries)
+}
+
+// Clear removes all cached contexts.
+func (cc *ContextCache) Clear() {
+	cc.mu.Lock()
+	cc.entries = make(map[contextCacheKey]*Context)
+	cc.mu.Unlock()
+}
+
+// newContextCacheKey returns the cache key of the remote context loaded by the given loader. It returns false
+// if the context can't be cached because the document loader isn't comparable.
+func newContextCacheKey(u string, loader DocumentLoader, activeCtx *Context,
+	overrideProtected bool) (contextCacheKey, bool) {
+	if loader != nil && !reflect.TypeOf(loader).Comparable() {
+		return contextCacheKey{}, false
+	}
+	base, hasBase := activeCtx.values["@base"].(string)
+	pm, _ := activeCtx.values["processingMode"].(string)
+	return contextCacheKey{
+		url:               u,
+		loader:            loader,
+		base:              base,
+		hasBase:           hasBase,
+		processingMode:    pm,
+		overrideProtected: overrideProtected,
+	}, true
+}
+
+func (cc *ContextCache) get(key contextCacheKey) *Context {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+
+	return cc.entries[key]
+}
+
+// put stores the given context. The context must not be modified afterwards.
+func (cc *ContextCache) put(key contextCacheKey, ctx *Context) {
+	// precompute the inverse context, so that it's never generated concurrently
+	ctx.GetInverse()
+
+	cc.mu.Lock()
+	cc.entries[key] = ctx
+	cc.mu.Unlock()
+}
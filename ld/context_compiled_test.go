@@ -0,0 +1,328 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compiledContextTestData() (map[string]interface{}, map[string]interface{}) {
+	context := map[string]interface{}{
+		"@context": map[string]interface{}{
+			"name":  "http://schema.org/name",
+			"knows": map[string]interface{}{"@id": "http://schema.org/knows", "@type": "@id"},
+			"Person": map[string]interface{}{
+				"@id":      "http://schema.org/Person",
+				"@context": map[string]interface{}{"title": "http://schema.org/jobTitle"},
+			},
+		},
+	}
+	doc := map[string]interface{}{
+		"@id":                    "http://example.org/jane",
+		"@type":                  "http://schema.org/Person",
+		"http://schema.org/name": "Jane Doe",
+		"http://schema.org/knows": map[string]interface{}{
+			"@id": "http://example.org/john",
+		},
+		"http://schema.org/jobTitle": "Professor",
+	}
+	return context, doc
+}
+
+func TestCompiledContextMatchesUncompiled(t *testing.T) {
+	proc := NewJsonLdProcessor()
+	context, doc := compiledContextTestData()
+
+	cc, err := proc.CompileContext(context, nil)
+	require.NoError(t, err)
+	assert.Equal(t, context["@context"], cc.Source())
+
+	// Compact
+	expected, err := proc.Compact(doc, context, nil)
+	require.NoError(t, err)
+	actual, err := proc.Compact(doc, cc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	// Expand with ExpandContext
+	compacted := map[string]interface{}{
+		"@id":   "http://example.org/jane",
+		"@type": "Person",
+		"name":  "Jane Doe",
+		"title": "Professor",
+	}
+	opts := NewJsonLdOptions("")
+	opts.ExpandContext = context
+	expectedExpanded, err := proc.Expand(compacted, opts)
+	require.NoError(t, err)
+	opts.ExpandContext = cc
+	actualExpanded, err := proc.Expand(compacted, opts)
+	require.NoError(t, err)
+	assert.Equal(t, expectedExpanded, actualExpanded)
+
+	// Flatten
+	expectedFlattened, err := proc.Flatten(doc, context, nil)
+	require.NoError(t, err)
+	actualFlattened, err := proc.Flatten(doc, cc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, expectedFlattened, actualFlattened)
+
+	// Frame
+	frame := map[string]interface{}{
+		"@context": context["@context"],
+		"@type":    "Person",
+	}
+	expectedFramed, err := proc.Frame(doc, frame, nil)
+	require.NoError(t, err)
+	compiledFrame := map[string]interface{}{
+		"@context": cc,
+		"@type":    "Person",
+	}
+	actualFramed, err := proc.Frame(doc, compiledFrame, nil)
+	require.NoError(t, err)
+	assert.Equal(t, expectedFramed, actualFramed)
+}
+
+func TestCompiledContextIsNotModified(t *testing.T) {
+	proc := NewJsonLdProcessor()
+	context, doc := compiledContextTestData()
+
+	cc, err := proc.CompileContext(context, nil)
+	require.NoError(t, err)
+
+	doc["http://schema.org/url"] = "http://example.org/jane.html"
+
+	// extend the compiled context with another term
+	extended := []interface{}{cc, map[string]interface{}{"url": "http://schema.org/url"}}
+	compacted, err := proc.Compact(doc, extended, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.org/jane.html", compacted["url"])
+
+	// the compiled context must not have picked up the extra term
+	compacted, err = proc.Compact(doc, cc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.org/jane.html", compacted["http://schema.org/url"])
+	assert.Nil(t, compacted["url"])
+}
+
+func TestCompiledContextConcurrentUse(t *testing.T) {
+	proc := NewJsonLdProcessor()
+	context, doc := compiledContextTestData()
+
+	cc, err := proc.CompileContext(context, nil)
+	require.NoError(t, err)
+
+	expected, err := proc.Compact(doc, context, nil)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	results := make([]map[string]interface{}, 16)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = proc.Compact(doc, cc, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, res := range results {
+		require.NoError(t, errs[i])
+		assert.Equal(t, expected, res)
+	}
+}
+
+func TestContextCache(t *testing.T) {
+	fsys := fstest.MapFS{
+		"person.jsonld": {Data: []byte(`{"@context": {"name": "http://schema.org/name"}}`)},
+	}
+	recorder := &recordingLoader{
+		nextLoader: NewFSDocumentLoader(fsys, map[string]string{"https://example.org/": "."}),
+	}
+
+	cache := NewContextCache()
+	proc := NewJsonLdProcessorWithContextCache(cache)
+
+	opts := NewJsonLdOptions("")
+	opts.DocumentLoader = recorder
+
+	doc := map[string]interface{}{
+		"@context": "https://example.org/person.jsonld",
+		"name":     "Jane Doe",
+	}
+	for i := 0; i < 3; i++ {
+		expanded, err := proc.Expand(doc, opts)
+		require.NoError(t, err)
+		require.Len(t, expanded, 1)
+		assert.Equal(t, []interface{}{map[string]interface{}{"@value": "Jane Doe"}},
+			expanded[0].(map[string]interface{})["http://schema.org/name"])
+	}
+	assert.Equal(t, []string{"https://example.org/person.jsonld"}, recorder.loaded)
+	assert.Equal(t, 1, cache.Len())
+
+	// a different processing mode requires a separate entry
+	opts.ProcessingMode = JsonLd_1_0
+	_, err := proc.Expand(doc, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cache.Len())
+	assert.Len(t, recorder.loaded, 2)
+
+	cache.Clear()
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestCompiledContextKeepsExplicitBase(t *testing.T) {
+	proc := NewJsonLdProcessor()
+
+	// the explicit @base is the same as the base of the options used for compilation
+	cc, err := proc.CompileContext(map[string]interface{}{
+		"@base": "http://example.org/a/",
+	}, NewJsonLdOptions("http://example.org/a/"))
+	require.NoError(t, err)
+
+	opts := NewJsonLdOptions("http://example.org/b/")
+	opts.ExpandContext = cc
+	expanded, err := proc.Expand(map[string]interface{}{
+		"@id":                 "doc",
+		"http://schema.org/x": "y",
+	}, opts)
+	require.NoError(t, err)
+	require.Len(t, expanded, 1)
+	assert.Equal(t, "http://example.org/a/doc", expanded[0].(map[string]interface{})["@id"])
+
+	// without @base, the compiled context takes the base of the operation
+	cc, err = proc.CompileContext(map[string]interface{}{}, NewJsonLdOptions("http://example.org/a/"))
+	require.NoError(t, err)
+	opts.ExpandContext = cc
+	expanded, err = proc.Expand(map[string]interface{}{
+		"@id":                 "doc",
+		"http://schema.org/x": "y",
+	}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.org/b/doc", expanded[0].(map[string]interface{})["@id"])
+}
+
+func TestContextCacheIsKeyedByLoader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"person.jsonld": {Data: []byte(`{"@context": {"name": "http://schema.org/name"}}`)},
+	}
+	first := &recordingLoader{
+		nextLoader: NewFSDocumentLoader(fsys, map[string]string{"https://example.org/": "."}),
+	}
+	second := &recordingLoader{nextLoader: first.nextLoader}
+
+	cache := NewContextCache()
+	proc := NewJsonLdProcessorWithContextCache(cache)
+	doc := map[string]interface{}{
+		"@context": "https://example.org/person.jsonld",
+		"name":     "Jane Doe",
+	}
+	for _, loader := range []*recordingLoader{first, second, first} {
+		opts := NewJsonLdOptions("")
+		opts.DocumentLoader = loader
+		_, err := proc.Expand(doc, opts)
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, first.loaded, 1)
+	assert.Len(t, second.loaded, 1)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestCompiledContextUsesOperationLoader(t *testing.T) {
+	compileLoader := &recordingLoader{
+		nextLoader: NewFSDocumentLoader(fstest.MapFS{
+			"ctx": {Data: []byte(`{"@context": {"name": "http://schema.org/name"}}`)},
+		}, map[string]string{"https://example.org/": "."}),
+	}
+	operationLoader := &recordingLoader{
+		nextLoader: NewFSDocumentLoader(fstest.MapFS{
+			"ctx": {Data: []byte(`{"@context": {"name": "http://example.org/name"}}`)},
+		}, map[string]string{"https://example.org/": "."}),
+	}
+
+	proc := NewJsonLdProcessor()
+	compileOpts := NewJsonLdOptions("")
+	compileOpts.DocumentLoader = compileLoader
+	cc, err := proc.CompileContext(map[string]interface{}{
+		"title": "http://schema.org/title",
+	}, compileOpts)
+	require.NoError(t, err)
+
+	// the remote context of the document must be loaded with the loader of the operation
+	opts := NewJsonLdOptions("")
+	opts.DocumentLoader = operationLoader
+	opts.ExpandContext = cc
+	expanded, err := proc.Expand(map[string]interface{}{
+		"@context": "https://example.org/ctx",
+		"name":     "Jane Doe",
+	}, opts)
+	require.NoError(t, err)
+	require.Len(t, expanded, 1)
+	assert.Contains(t, expanded[0], "http://example.org/name")
+	assert.Equal(t, []string{"https://example.org/ctx"}, operationLoader.loaded)
+	assert.Empty(t, compileLoader.loaded)
+}
+
+func TestContextCacheUsesOperationSafeMode(t *testing.T) {
+	fsys := fstest.MapFS{
+		"person.jsonld": {Data: []byte(`{"@context": {"name": "http://schema.org/name"}}`)},
+	}
+	loader := NewFSDocumentLoader(fsys, map[string]string{"https://example.org/": "."})
+
+	cache := NewContextCache()
+	proc := NewJsonLdProcessorWithContextCache(cache)
+	doc := map[string]interface{}{
+		"@context": "https://example.org/person.jsonld",
+		"name":     "Jane Doe",
+		"bogus":    "value",
+	}
+	for _, safeMode := range []bool{false, true, false, true} {
+		opts := NewJsonLdOptions("")
+		opts.DocumentLoader = loader
+		opts.SafeMode = safeMode
+		_, err := proc.Expand(doc, opts)
+		if safeMode {
+			require.Error(t, err)
+			assert.Equal(t, InvalidProperty, err.(*JsonLdError).Code) //nolint:errorlint
+		} else {
+			require.NoError(t, err)
+		}
+	}
+	assert.Equal(t, 1, cache.Len())
+
+	// the same applies to compiled contexts
+	compileOpts := NewJsonLdOptions("")
+	compileOpts.DocumentLoader = loader
+	cc, err := proc.CompileContext("https://example.org/person.jsonld", compileOpts)
+	require.NoError(t, err)
+
+	opts := NewJsonLdOptions("")
+	opts.SafeMode = true
+	opts.ExpandContext = cc
+	_, err = proc.Expand(map[string]interface{}{
+		"name":  "Jane Doe",
+		"bogus": "value",
+	}, opts)
+	require.Error(t, err)
+	assert.Equal(t, InvalidProperty, err.(*JsonLdError).Code) //nolint:errorlint
+}
@@ -0,0 +1,163 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	// ContextSnapshotFormat identifies context snapshots written by Context.WriteSnapshot.
+	ContextSnapshotFormat = "json-gold/context-snapshot"
+	// ContextSnapshotVersion is the version of the snapshot format written by this library.
+	ContextSnapshotVersion = 1
+)
+
+// contextSnapshot is the on-disk representation of a snapshot, including the version header.
+type contextSnapshot struct {
+	Format  string                `json:"format"`
+	Version int                   `json:"version"`
+	Context *contextSnapshotState `json:"context"`
+}
+
+// contextSnapshotState is the processed state of a Context.
+type contextSnapshotState struct {
+	Values          map[string]interface{} `json:"values"`
+	TermDefinitions map[string]interface{} `json:"termDefinitions"`
+	Protected       map[string]bool        `json:"protected,omitempty"`
+	Inverse         map[string]interface{} `json:"inverse"`
+	PreviousContext *contextSnapshotState  `json:"previousContext,omitempty"`
+	Source          interface{}            `json:"source,omitempty"`
+}
+
+// WriteSnapshot writes the processed state of the context (values, term definitions,
+// protected terms, the inverse context, the previous context and the source context,
+// if known) as a JSON snapshot.
+// The output is stable: the same context always produces the same snapshot.
+//
+// Use ReadContextSnapshot to load the snapshot without processing the context again.
+func (c *Context) WriteSnapshot(w io.Writer) error {
+	snapshot := &contextSnapshot{
+		Format:  ContextSnapshotFormat,
+		Version: ContextSnapshotVersion,
+		Context: c.snapshotState(),
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return NewJsonLdError(IOError, err)
+	}
+	if _, err = w.Write(append(data, '\n')); err != nil {
+		return NewJsonLdError(IOError, err)
+	}
+	return nil
+}
+
+func (c *Context) snapshotState() *contextSnapshotState {
+	state := &contextSnapshotState{
+		Values:          c.values,
+		TermDefinitions: c.termDefinitions,
+		Protected:       c.protected,
+		Inverse:         c.GetInverse(),
+		Source:          c.source,
+	}
+	if c.previousContext != nil {
+		state.PreviousContext = c.previousContext.snapshotState()
+	}
+	return state
+}
+
+// ReadContextSnapshot loads a context written by Context.WriteSnapshot.
+// The resulting context behaves exactly like the context the snapshot was taken from.
+// Values which were set from JsonLdOptions at parse time (such as the base IRI and
+// the processing mode) are restored from the snapshot; the given options are used
+// for any further processing, e.g. to load scoped contexts. When the context is passed
+// to an operation (for example, as JsonLdOptions.ExpandContext), the options
+// of the operation are used instead.
+func ReadContextSnapshot(r io.Reader, options *JsonLdOptions) (*Context, error) {
+	if options == nil {
+		options = NewJsonLdOptions("")
+	}
+
+	var snapshot contextSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, NewJsonLdError(InvalidContextSnapshot, err)
+	}
+	if snapshot.Format != ContextSnapshotFormat {
+		return nil, NewJsonLdError(InvalidContextSnapshot,
+			fmt.Sprintf("unknown snapshot format: %q", snapshot.Format))
+	}
+	if snapshot.Version != ContextSnapshotVersion {
+		return nil, NewJsonLdError(InvalidContextSnapshot,
+			fmt.Sprintf("unsupported snapshot version: %d", snapshot.Version))
+	}
+	if snapshot.Context == nil {
+		return nil, NewJsonLdError(InvalidContextSnapshot, "snapshot has no context")
+	}
+
+	return snapshot.Context.restore(options)
+}
+
+func (s *contextSnapshotState) restore(options *JsonLdOptions) (*Context, error) {
+	if s.Values == nil {
+		return nil, NewJsonLdError(InvalidContextSnapshot, "context values are missing")
+	}
+	if _, isString := s.Values["processingMode"].(string); !isString {
+		return nil, NewJsonLdError(InvalidContextSnapshot, "processing mode is missing")
+	}
+	if base, hasBase := s.Values["@base"]; hasBase && base != nil {
+		if _, isString := base.(string); !isString {
+			return nil, NewJsonLdError(InvalidContextSnapshot, fmt.Sprintf("invalid base IRI: %v", base))
+		}
+	}
+	for term, def := range s.TermDefinitions {
+		if def == nil {
+			continue
+		}
+		defMap, isMap := def.(map[string]interface{})
+		if !isMap {
+			return nil, NewJsonLdError(InvalidContextSnapshot, fmt.Sprintf("invalid definition of term %s", term))
+		}
+		if _, isString := defMap["@id"].(string); !isString {
+			return nil, NewJsonLdError(InvalidContextSnapshot, fmt.Sprintf("term %s has no IRI mapping", term))
+		}
+	}
+
+	ctx := &Context{
+		values:          s.Values,
+		options:         options,
+		termDefinitions: s.TermDefinitions,
+		inverse:         s.Inverse,
+		protected:       s.Protected,
+		source:          s.Source,
+	}
+	if ctx.termDefinitions == nil {
+		ctx.termDefinitions = make(map[string]interface{})
+	}
+	if ctx.protected == nil {
+		ctx.protected = make(map[string]bool)
+	}
+
+	if s.PreviousContext != nil {
+		prevCtx, err := s.PreviousContext.restore(options)
+		if err != nil {
+			return nil, err
+		}
+		ctx.previousContext = prevCtx
+	}
+
+	return ctx, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextSnapshotRoundTrip(t *testing.T) {
+	opts := NewJsonLdOptions("http://example.org/")
+	localCtx := map[string]interface{}{
+		"@vocab":     "http://schema.org/",
+		"@language":  "en",
+		"@propagate": false,
+		"@protected": true,
+		"name":       "http://schema.org/name",
+		"knows":      map[string]interface{}{"@id": "http://schema.org/knows", "@type": "@id", "@container": "@set"},
+		"Person": map[string]interface{}{
+			"@id":      "http://schema.org/Person",
+			"@context": map[string]interface{}{"title": "http://schema.org/jobTitle"},
+		},
+	}
+	ctx, err := NewContext(nil, opts).Parse(localCtx)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, ctx.WriteSnapshot(&buf))
+
+	// snapshots are stable
+	var buf2 bytes.Buffer
+	require.NoError(t, ctx.WriteSnapshot(&buf2))
+	assert.Equal(t, buf.String(), buf2.String())
+
+	loadedCtx, err := ReadContextSnapshot(bytes.NewReader(buf.Bytes()), opts)
+	require.NoError(t, err)
+	assert.Equal(t, ctx.AsMap(), loadedCtx.AsMap())
+	assert.Equal(t, true, loadedCtx.GetTermDefinition("name")["protected"])
+
+	// the loaded context must behave like the parsed one
+	doc := map[string]interface{}{
+		"@id":   "jane",
+		"@type": "Person",
+		"name":  "Jane Doe",
+		"title": "Professor",
+		"knows": "john",
+	}
+	proc := NewJsonLdProcessor()
+	expandOpts := NewJsonLdOptions("http://example.org/")
+	expandOpts.ExpandContext = localCtx
+	expected, err := proc.Expand(doc, expandOpts)
+	require.NoError(t, err)
+	expandOpts.ExpandContext = loadedCtx
+	actual, err := proc.Expand(doc, expandOpts)
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	compacted, err := proc.Compact(expected, localCtx, opts)
+	require.NoError(t, err)
+	compactedWithSnapshot, err := proc.Compact(expected, loadedCtx, opts)
+	require.NoError(t, err)
+	assert.Equal(t, compacted, compactedWithSnapshot)
+	assert.Equal(t, localCtx, compactedWithSnapshot["@context"])
+}
+
+func TestReadContextSnapshotErrors(t *testing.T) {
+	for name, snapshot := range map[string]string{
+		"malformed JSON":      `{"format": `,
+		"wrong format":        `{"format": "something-else", "version": 1, "context": {}}`,
+		"unsupported version": `{"format": "json-gold/context-snapshot", "version": 99, "context": {}}`,
+		"missing context":     `{"format": "json-gold/context-snapshot", "version": 1}`,
+		"missing values":      `{"format": "json-gold/context-snapshot", "version": 1, "context": {}}`,
+		"invalid definition": `{"format": "json-gold/context-snapshot", "version": 1, "context": {
+			"values": {"@base": "", "processingMode": "json-ld-1.1"},
+			"termDefinitions": {"name": {"@type": "@id"}}
+		}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadContextSnapshot(strings.NewReader(snapshot), nil)
+			require.Error(t, err)
+			assert.Equal(t, InvalidContextSnapshot, err.(*JsonLdError).Code) //nolint:errorlint
+		})
+	}
+}
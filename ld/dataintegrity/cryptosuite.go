@@ -0,0 +1,144 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataintegrity
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// cryptosuite holds the key specific parts of an rdfc cryptosuite.
+type cryptosuite interface {
+	// hashForPrivateKey returns the hash algorithm used with the given private key.
+	hashForPrivateKey(privateKey crypto.PrivateKey) (crypto.Hash, error)
+	// hashForPublicKey returns the hash algorithm used with the given public key.
+	hashForPublicKey(publicKey crypto.PublicKey) (crypto.Hash, error)
+	sign(privateKey crypto.PrivateKey, hashData []byte) ([]byte, error)
+	verify(publicKey crypto.PublicKey, hashData, proofBytes []byte) bool
+}
+
+var cryptosuites = map[string]cryptosuite{
+	CryptosuiteEdDSARDFC2022: eddsaCryptosuite{},
+	CryptosuiteECDSARDFC2019: ecdsaCryptosuite{},
+}
+
+func lookupCryptosuite(name string, code ErrorCode) (cryptosuite, error) {
+	suite, found := cryptosuites[name]
+	if !found {
+		return nil, NewError(code, fmt.Sprintf("unsupported cryptosuite: %s", name))
+	}
+	return suite, nil
+}
+
+// eddsaCryptosuite implements eddsa-rdfc-2022: Ed25519 signatures over SHA-256 hashes.
+type eddsaCryptosuite struct{}
+
+func (eddsaCryptosuite) hashForPrivateKey(privateKey crypto.PrivateKey) (crypto.Hash, error) {
+	if key, isEd25519 := privateKey.(ed25519.PrivateKey); !isEd25519 || len(key) != ed25519.PrivateKeySize {
+		return 0, fmt.Errorf("%s requires an Ed25519 private key", CryptosuiteEdDSARDFC2022)
+	}
+	return crypto.SHA256, nil
+}
+
+func (eddsaCryptosuite) hashForPublicKey(publicKey crypto.PublicKey) (crypto.Hash, error) {
+	if key, isEd25519 := publicKey.(ed25519.PublicKey); !isEd25519 || len(key) != ed25519.PublicKeySize {
+		return 0, fmt.Errorf("%s requires an Ed25519 public key", CryptosuiteEdDSARDFC2022)
+	}
+	return crypto.SHA256, nil
+}
+
+func (eddsaCryptosuite) sign(privateKey crypto.PrivateKey, hashData []byte) ([]byte, error) {
+	return ed25519.Sign(privateKey.(ed25519.PrivateKey), hashData), nil
+}
+
+func (eddsaCryptosuite) verify(publicKey crypto.PublicKey, hashData, proofBytes []byte) bool {
+	return ed25519.Verify(publicKey.(ed25519.PublicKey), hashData, proofBytes)
+}
+
+// ecdsaCryptosuite implements ecdsa-rdfc-2019: ECDSA signatures with P-256 and SHA-256
+// or with P-384 and SHA-384. Signatures are encoded as r || s, each padded to the size
+// of the curve order (IEEE P1363 format).
+type ecdsaCryptosuite struct{}
+
+func (ecdsaCryptosuite) hashForCurve(curve elliptic.Curve) (crypto.Hash, error) {
+	switch curve {
+	case elliptic.P256():
+		return crypto.SHA256, nil
+	case elliptic.P384():
+		return crypto.SHA384, nil
+	default:
+		return 0, fmt.Errorf("%s requires a P-256 or P-384 key", CryptosuiteECDSARDFC2019)
+	}
+}
+
+func (s ecdsaCryptosuite) hashForPrivateKey(privateKey crypto.PrivateKey) (crypto.Hash, error) {
+	key, isECDSA := privateKey.(*ecdsa.PrivateKey)
+	if !isECDSA {
+		return 0, fmt.Errorf("%s requires an ECDSA private key", CryptosuiteECDSARDFC2019)
+	}
+	return s.hashForCurve(key.Curve)
+}
+
+func (s ecdsaCryptosuite) hashForPublicKey(publicKey crypto.PublicKey) (crypto.Hash, error) {
+	key, isECDSA := publicKey.(*ecdsa.PublicKey)
+	if !isECDSA {
+		return 0, fmt.Errorf("%s requires an ECDSA public key", CryptosuiteECDSARDFC2019)
+	}
+	return s.hashForCurve(key.Curve)
+}
+
+func (s ecdsaCryptosuite) sign(privateKey crypto.PrivateKey, hashData []byte) ([]byte, error) {
+	key := privateKey.(*ecdsa.PrivateKey)
+	h, err := s.hashForCurve(key.Curve)
+	if err != nil {
+		return nil, err
+	}
+	digest := h.New()
+	digest.Write(hashData)
+
+	r, sigS, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	size := (key.Curve.Params().BitSize + 7) / 8
+	proofBytes := make([]byte, 2*size)
+	r.FillBytes(proofBytes[:size])
+	sigS.FillBytes(proofBytes[size:])
+	return proofBytes, nil
+}
+
+func (s ecdsaCryptosuite) verify(publicKey crypto.PublicKey, hashData, proofBytes []byte) bool {
+	key := publicKey.(*ecdsa.PublicKey)
+	h, err := s.hashForCurve(key.Curve)
+	if err != nil {
+		return false
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	if len(proofBytes) != 2*size {
+		return false
+	}
+	digest := h.New()
+	digest.Write(hashData)
+
+	r := new(big.Int).SetBytes(proofBytes[:size])
+	sigS := new(big.Int).SetBytes(proofBytes[size:])
+	return ecdsa.Verify(key, digest.Sum(nil), r, sigS)
+}
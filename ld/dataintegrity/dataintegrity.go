@@ -0,0 +1,359 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dataintegrity implements proof creation and verification for the
+// Verifiable Credential Data Integrity cryptosuites based on RDF Dataset Canonicalization:
+// eddsa-rdfc-2022 (https://www.w3.org/TR/vc-di-eddsa/) and
+// ecdsa-rdfc-2019 (https://www.w3.org/TR/vc-di-ecdsa/).
+//
+// Documents and proof configurations are canonicalized with URDNA2015 using
+// ld.JsonLdProcessor.Normalize, so all contexts they use must be available
+// through the document loader of the given ld.JsonLdOptions.
+package dataintegrity
+
+import (
+	"crypto"
+	"fmt"
+	"reflect"
+
+	"github.com/piprate/json-gold/ld"
+	"github.com/piprate/json-gold/ld/internal/xsd"
+)
+
+const (
+	// ProofType is the type of all proofs created by this package.
+	ProofType = "DataIntegrityProof"
+
+	// CryptosuiteEdDSARDFC2022 is the identifier of the eddsa-rdfc-2022 cryptosuite.
+	CryptosuiteEdDSARDFC2022 = "eddsa-rdfc-2022"
+	// CryptosuiteECDSARDFC2019 is the identifier of the ecdsa-rdfc-2019 cryptosuite.
+	CryptosuiteECDSARDFC2019 = "ecdsa-rdfc-2019"
+)
+
+// ErrorCode is a Data Integrity processing error code as per spec.
+type ErrorCode string
+
+// Error is a Data Integrity processing error.
+type Error struct {
+	Code    ErrorCode
+	Details interface{}
+}
+
+const (
+	ProofGenerationError     ErrorCode = "PROOF_GENERATION_ERROR"
+	ProofVerificationError   ErrorCode = "PROOF_VERIFICATION_ERROR"
+	ProofTransformationError ErrorCode = "PROOF_TRANSFORMATION_ERROR"
+	MalformedProofError      ErrorCode = "MALFORMED_PROOF_ERROR"
+)
+
+func (e Error) Error() string {
+	if e.Details != nil {
+		return fmt.Sprintf("%v: %v", e.Code, e.Details)
+	}
+	return fmt.Sprintf("%v", e.Code)
+}
+
+// Unwrap returns Error.Details if it is an error, otherwise nil.
+func (e Error) Unwrap() error {
+	cause, _ := e.Details.(error)
+	return cause
+}
+
+// NewError creates a new instance of Error.
+func NewError(code ErrorCode, details interface{}) *Error {
+	return &Error{Code: code, Details: details}
+}
+
+// ProofOptions holds the properties of a proof to be created. Empty properties
+// are omitted from the proof.
+type ProofOptions struct {
+	// Type must be empty or DataIntegrityProof.
+	Type string
+	// Cryptosuite is either CryptosuiteEdDSARDFC2022 or CryptosuiteECDSARDFC2019.
+	Cryptosuite string
+	// Created and Expires must be valid XML Schema dateTime values.
+	Created            string
+	Expires            string
+	VerificationMethod string
+	ProofPurpose       string
+	Domain             string
+	Challenge          string
+	Nonce              string
+}
+
+func (po *ProofOptions) toMap() map[string]interface{} {
+	proofType := po.Type
+	if proofType == "" {
+		proofType = ProofType
+	}
+	rval := map[string]interface{}{
+		"type":        proofType,
+		"cryptosuite": po.Cryptosuite,
+	}
+	for key, value := range map[string]string{
+		"created":            po.Created,
+		"expires":            po.Expires,
+		"verificationMethod": po.VerificationMethod,
+		"proofPurpose":       po.ProofPurpose,
+		"domain":             po.Domain,
+		"challenge":          po.Challenge,
+		"nonce":              po.Nonce,
+	} {
+		if value != "" {
+			rval[key] = value
+		}
+	}
+	return rval
+}
+
+// AddProof creates a proof for the given document and returns a shallow copy of
+// the document with the proof added as its "proof" property.
+// Proof sets aren't supported, so the document must not have a proof already.
+func AddProof(document map[string]interface{}, options *ProofOptions, privateKey crypto.PrivateKey,
+	opts *ld.JsonLdOptions) (map[string]interface{}, error) {
+
+	if _, hasProof := document["proof"]; hasProof {
+		return nil, NewError(ProofGenerationError, "document already has a proof")
+	}
+
+	proof, err := CreateProof(document, options, privateKey, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	securedDocument := make(map[string]interface{}, len(document)+1)
+	for key, value := range document {
+		securedDocument[key] = value
+	}
+	securedDocument["proof"] = proof
+	return securedDocument, nil
+}
+
+// CreateProof implements the Create Proof algorithm of the selected cryptosuite.
+// It returns the proof, including its proofValue.
+func CreateProof(unsecuredDocument map[string]interface{}, options *ProofOptions, privateKey crypto.PrivateKey,
+	opts *ld.JsonLdOptions) (map[string]interface{}, error) {
+
+	suite, err := lookupCryptosuite(options.Cryptosuite, ProofGenerationError)
+	if err != nil {
+		return nil, err
+	}
+	h, err := suite.hashForPrivateKey(privateKey)
+	if err != nil {
+		return nil, NewError(ProofGenerationError, err)
+	}
+
+	proof := options.toMap()
+
+	canonicalProofConfig, err := ProofConfiguration(unsecuredDocument, proof, ProofGenerationError, opts)
+	if err != nil {
+		return nil, err
+	}
+	transformedData, err := Transform(unsecuredDocument, proof, ProofTransformationError, opts)
+	if err != nil {
+		return nil, err
+	}
+	hashData := HashData(transformedData, canonicalProofConfig, h)
+
+	proofBytes, err := suite.sign(privateKey, hashData)
+	if err != nil {
+		return nil, NewError(ProofGenerationError, err)
+	}
+	proof["proofValue"] = encodeMultibase(proofBytes)
+
+	return proof, nil
+}
+
+// VerifyProof implements the Verify Proof algorithm of the cryptosuite referenced
+// by the proof of the secured document. It returns nil if the proof is valid
+// for the given public key.
+func VerifyProof(securedDocument map[string]interface{}, publicKey crypto.PublicKey, opts *ld.JsonLdOptions) error {
+	proof, isMap := securedDocument["proof"].(map[string]interface{})
+	if !isMap {
+		return NewError(MalformedProofError, "document must have exactly one proof object")
+	}
+
+	unsecuredDocument := make(map[string]interface{}, len(securedDocument))
+	for key, value := range securedDocument {
+		if key != "proof" {
+			unsecuredDocument[key] = value
+		}
+	}
+
+	proofOptions := make(map[string]interface{}, len(proof))
+	for key, value := range proof {
+		if key != "proofValue" {
+			proofOptions[key] = value
+		}
+	}
+
+	proofValue, isString := proof["proofValue"].(string)
+	if !isString {
+		return NewError(MalformedProofError, "proofValue must be a string")
+	}
+	proofBytes, err := decodeMultibase(proofValue)
+	if err != nil {
+		return NewError(MalformedProofError, err)
+	}
+
+	if proofContext, hasContext := proofOptions["@context"]; hasContext {
+		if !contextStartsWith(securedDocument["@context"], proofContext) {
+			return NewError(ProofVerificationError, "document @context doesn't start with proof @context")
+		}
+		unsecuredDocument["@context"] = proofContext
+		delete(proofOptions, "@context")
+	}
+
+	cryptosuite, _ := proofOptions["cryptosuite"].(string)
+	suite, err := lookupCryptosuite(cryptosuite, ProofVerificationError)
+	if err != nil {
+		return err
+	}
+	h, err := suite.hashForPublicKey(publicKey)
+	if err != nil {
+		return NewError(ProofVerificationError, err)
+	}
+
+	transformedData, err := Transform(unsecuredDocument, proofOptions, ProofVerificationError, opts)
+	if err != nil {
+		return err
+	}
+	canonicalProofConfig, err := ProofConfiguration(unsecuredDocument, proofOptions, ProofVerificationError, opts)
+	if err != nil {
+		return err
+	}
+	hashData := HashData(transformedData, canonicalProofConfig, h)
+
+	if !suite.verify(publicKey, hashData, proofBytes) {
+		return NewError(ProofVerificationError, "invalid signature")
+	}
+	return nil
+}
+
+// Transform implements the Transformation algorithm of the rdfc cryptosuites:
+// it checks the type and cryptosuite of the proof options and returns the
+// canonical N-Quads of the unsecured document. Errors are reported with the given code.
+func Transform(unsecuredDocument map[string]interface{}, options map[string]interface{}, code ErrorCode,
+	opts *ld.JsonLdOptions) (string, error) {
+
+	if err := checkTypeAndCryptosuite(options, code); err != nil {
+		return "", err
+	}
+	canonicalDocument, err := canonicalize(unsecuredDocument, opts)
+	if err != nil {
+		return "", NewError(code, err)
+	}
+	return canonicalDocument, nil
+}
+
+// ProofConfiguration implements the Proof Configuration algorithm of the rdfc cryptosuites.
+// It returns the canonical N-Quads of the proof options, with the @context
+// of the unsecured document. Errors are reported with the given code.
+func ProofConfiguration(unsecuredDocument map[string]interface{}, options map[string]interface{}, code ErrorCode,
+	opts *ld.JsonLdOptions) (string, error) {
+
+	if err := checkTypeAndCryptosuite(options, code); err != nil {
+		return "", err
+	}
+
+	proofConfig := make(map[string]interface{}, len(options)+1)
+	for key, value := range options {
+		proofConfig[key] = value
+	}
+
+	for _, key := range []string{"created", "expires"} {
+		value, present := proofConfig[key]
+		if !present {
+			continue
+		}
+		if s, isString := value.(string); !isString || !isDateTime(s) {
+			return "", NewError(code, fmt.Sprintf("invalid %s value: %v", key, value))
+		}
+	}
+
+	if documentContext, hasContext := unsecuredDocument["@context"]; hasContext {
+		proofConfig["@context"] = documentContext
+	}
+
+	canonicalProofConfig, err := canonicalize(proofConfig, opts)
+	if err != nil {
+		return "", NewError(code, err)
+	}
+	return canonicalProofConfig, nil
+}
+
+// HashData implements the Hashing algorithm of the rdfc cryptosuites. It returns
+// the hash of the canonical proof configuration followed by the hash of
+// the transformed document.
+func HashData(transformedDocument, canonicalProofConfig string, h crypto.Hash) []byte {
+	proofConfigHash := h.New()
+	proofConfigHash.Write([]byte(canonicalProofConfig))
+	documentHash := h.New()
+	documentHash.Write([]byte(transformedDocument))
+
+	return documentHash.Sum(proofConfigHash.Sum(nil))
+}
+
+func checkTypeAndCryptosuite(options map[string]interface{}, code ErrorCode) error {
+	if options["type"] != ProofType {
+		return NewError(code, fmt.Sprintf("unsupported proof type: %v", options["type"]))
+	}
+	if cryptosuite, _ := options["cryptosuite"].(string); cryptosuites[cryptosuite] == nil {
+		return NewError(code, fmt.Sprintf("unsupported cryptosuite: %v", options["cryptosuite"]))
+	}
+	return nil
+}
+
+// canonicalize returns the URDNA2015 canonical N-Quads of the given document. Any property
+// that doesn't expand to an absolute IRI is an error.
+func canonicalize(document map[string]interface{}, opts *ld.JsonLdOptions) (string, error) {
+	if opts == nil {
+		opts = ld.NewJsonLdOptions("")
+	} else {
+		opts = opts.Copy()
+	}
+	opts.Algorithm = ld.AlgorithmURDNA2015
+	opts.MessageDigestAlgorithm = ld.MessageDigestAlgorithmSHA256
+	opts.Format = "application/n-quads"
+	opts.InputFormat = ""
+	opts.SafeMode = true
+
+	normalized, err := ld.NewJsonLdProcessor().Normalize(document, opts)
+	if err != nil {
+		return "", err
+	}
+	return normalized.(string), nil
+}
+
+// contextStartsWith checks that the document context starts with all values of
+// the proof context, in the same order.
+func contextStartsWith(documentContext, proofContext interface{}) bool {
+	documentValues := ld.Arrayify(documentContext)
+	proofValues := ld.Arrayify(proofContext)
+	if len(proofValues) > len(documentValues) {
+		return false
+	}
+	for i, value := range proofValues {
+		if !reflect.DeepEqual(documentValues[i], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// isDateTime returns true if the value is an XML Schema dateTime, with or without a timezone.
+func isDateTime(value string) bool {
+	_, err := xsd.ParseDateTime(value)
+	return err == nil
+}
@@ -0,0 +1,340 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataintegrity_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld/dataintegrity"
+
+	"github.com/piprate/json-gold/ld"
+	"github.com/piprate/json-gold/ld/internal/multibase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test vectors of the eddsa-rdfc-2022 and ecdsa-rdfc-2019 specifications.
+// The credential contexts are served from testdata (see testOptions).
+const (
+	eddsaPublicKey = "z6MkrJVnaZkeFzdQyMZu1cgjg7k1pZZ6pvBQ7XJPt4swbTQ2"
+	eddsaSecretKey = "z3u2en7t5LR2WtQH5PfFqMqwVHBeXouLzo6haApm8XHqvjxq"
+	ecdsaPublicKey = "zDnaepBuvsQ8cpsWrVKw8fbpGpvPeNSjVPTWoq6cRqaYzBKVP"
+	ecdsaSecretKey = "z42twTcNeSYcnqg1FLuSFs2bsGH3ZqbRHFmvS9XMsYhjxvHN"
+
+	canonicalCredential = `<did:example:abcdefgh> <https://www.w3.org/ns/credentials/examples#alumniOf> "The School of Examples" .
+<urn:uuid:58172aac-d8ba-11ed-83dd-0b3aef56cc33> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://www.w3.org/2018/credentials#VerifiableCredential> .
+<urn:uuid:58172aac-d8ba-11ed-83dd-0b3aef56cc33> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://www.w3.org/ns/credentials/examples#AlumniCredential> .
+<urn:uuid:58172aac-d8ba-11ed-83dd-0b3aef56cc33> <https://schema.org/description> "A minimum viable example of an Alumni Credential." .
+<urn:uuid:58172aac-d8ba-11ed-83dd-0b3aef56cc33> <https://schema.org/name> "Alumni Credential" .
+<urn:uuid:58172aac-d8ba-11ed-83dd-0b3aef56cc33> <https://www.w3.org/2018/credentials#credentialSubject> <did:example:abcdefgh> .
+<urn:uuid:58172aac-d8ba-11ed-83dd-0b3aef56cc33> <https://www.w3.org/2018/credentials#issuer> <https://vc.example/issuers/5678> .
+<urn:uuid:58172aac-d8ba-11ed-83dd-0b3aef56cc33> <https://www.w3.org/2018/credentials#validFrom> "2023-01-01T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> .
+`
+	canonicalCredentialHash = "517744132ae165a5349155bef0bb0cf2258fff99dfe1dbd914b938d775a36017"
+
+	eddsaProofConfigHash = "bea7b7acfbad0126b135104024a5f1733e705108f42d59668b05c0c50004c6b0"
+	ecdsaProofConfigHash = "3a8a522f689025727fb9d1f0fa99a618da023e8494ac74f51015d009d35abc2e"
+	eddsaProofValue      = "z2YwC8z3ap7yx1nZYCg4L3j3ApHsF8kgPdSb5xoS1VR7vPG3F561B52hYnQF9iseabecm3ijx4K1FBTQsCZahKZme"
+)
+
+func testOptions() *ld.JsonLdOptions {
+	opts := ld.NewJsonLdOptions("")
+	opts.DocumentLoader = ld.NewFSDocumentLoader(os.DirFS("testdata"), map[string]string{
+		"https://www.w3.org/ns/credentials/": "credentials/",
+	})
+	return opts
+}
+
+func unsecuredCredential() map[string]interface{} {
+	return map[string]interface{}{
+		"@context": []interface{}{
+			"https://www.w3.org/ns/credentials/v2",
+			"https://www.w3.org/ns/credentials/examples/v2",
+		},
+		"id":          "urn:uuid:58172aac-d8ba-11ed-83dd-0b3aef56cc33",
+		"type":        []interface{}{"VerifiableCredential", "AlumniCredential"},
+		"name":        "Alumni Credential",
+		"description": "A minimum viable example of an Alumni Credential.",
+		"issuer":      "https://vc.example/issuers/5678",
+		"validFrom":   "2023-01-01T00:00:00Z",
+		"credentialSubject": map[string]interface{}{
+			"id":       "did:example:abcdefgh",
+			"alumniOf": "The School of Examples",
+		},
+	}
+}
+
+func proofOptions(cryptosuite, publicKey string) *ProofOptions {
+	return &ProofOptions{
+		Type:               ProofType,
+		Cryptosuite:        cryptosuite,
+		Created:            "2023-02-24T23:36:38Z",
+		VerificationMethod: "did:key:" + publicKey + "#" + publicKey,
+		ProofPurpose:       "assertionMethod",
+	}
+}
+
+func proofMap(options *ProofOptions) map[string]interface{} {
+	return map[string]interface{}{
+		"type":               options.Type,
+		"cryptosuite":        options.Cryptosuite,
+		"created":            options.Created,
+		"verificationMethod": options.VerificationMethod,
+		"proofPurpose":       options.ProofPurpose,
+	}
+}
+
+func sha256Hex(s string) string {
+	h := crypto.SHA256.New()
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestEdDSARDFC2022TestVector(t *testing.T) {
+	opts := testOptions()
+	document := unsecuredCredential()
+	options := proofOptions(CryptosuiteEdDSARDFC2022, eddsaPublicKey)
+
+	transformed, err := Transform(document, proofMap(options), ProofTransformationError, opts)
+	require.NoError(t, err)
+	assert.Equal(t, canonicalCredential, transformed)
+	assert.Equal(t, canonicalCredentialHash, sha256Hex(transformed))
+
+	proofConfig, err := ProofConfiguration(document, proofMap(options), ProofGenerationError, opts)
+	require.NoError(t, err)
+	assert.Equal(t, eddsaProofConfigHash, sha256Hex(proofConfig))
+
+	hashData := HashData(transformed, proofConfig, crypto.SHA256)
+	assert.Equal(t, eddsaProofConfigHash+canonicalCredentialHash, hex.EncodeToString(hashData))
+
+	privateKey, err := ParseSecretKeyMultibase(eddsaSecretKey)
+	require.NoError(t, err)
+	publicKey, err := ParsePublicKeyMultibase(eddsaPublicKey)
+	require.NoError(t, err)
+
+	// Ed25519 signatures are deterministic
+	securedDocument, err := AddProof(document, options, privateKey, opts)
+	require.NoError(t, err)
+	proof := securedDocument["proof"].(map[string]interface{})
+	assert.Equal(t, eddsaProofValue, proof["proofValue"])
+	assert.NotContains(t, document, "proof")
+
+	assert.NoError(t, VerifyProof(securedDocument, publicKey, opts))
+}
+
+func TestECDSARDFC2019(t *testing.T) {
+	opts := testOptions()
+	document := unsecuredCredential()
+	options := proofOptions(CryptosuiteECDSARDFC2019, ecdsaPublicKey)
+
+	// the transformation doesn't depend on the cryptosuite
+	transformed, err := Transform(document, proofMap(options), ProofTransformationError, opts)
+	require.NoError(t, err)
+	assert.Equal(t, canonicalCredential, transformed)
+
+	proofConfig, err := ProofConfiguration(document, proofMap(options), ProofGenerationError, opts)
+	require.NoError(t, err)
+	assert.Equal(t, ecdsaProofConfigHash, sha256Hex(proofConfig))
+
+	hashData := HashData(transformed, proofConfig, crypto.SHA256)
+	assert.Equal(t, ecdsaProofConfigHash+canonicalCredentialHash, hex.EncodeToString(hashData))
+
+	privateKey, err := ParseSecretKeyMultibase(ecdsaSecretKey)
+	require.NoError(t, err)
+	publicKey, err := ParsePublicKeyMultibase(ecdsaPublicKey)
+	require.NoError(t, err)
+	ecPublicKey := publicKey.(*ecdsa.PublicKey)
+
+	// ECDSA signatures are randomised, so the proof value is checked with crypto/ecdsa:
+	// it must be the base58-btc encoded P1363 signature (r || s) of SHA-256(hashData)
+	securedDocument, err := AddProof(document, options, privateKey, opts)
+	require.NoError(t, err)
+	assert.NoError(t, VerifyProof(securedDocument, publicKey, opts))
+
+	proofValue := securedDocument["proof"].(map[string]interface{})["proofValue"].(string)
+	require.Equal(t, "z", proofValue[:1])
+	signature, err := multibase.DecodeBase58(proofValue[1:])
+	require.NoError(t, err)
+	require.Len(t, signature, 64)
+	digest := sha256.Sum256(hashData)
+	assert.True(t, ecdsa.Verify(ecPublicKey, digest[:],
+		new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])))
+
+	// and a signature made with crypto/ecdsa verifies
+	r, sigS, err := ecdsa.Sign(rand.Reader, privateKey.(*ecdsa.PrivateKey), digest[:])
+	require.NoError(t, err)
+	signature = make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sigS.FillBytes(signature[32:])
+	proof := proofMap(options)
+	proof["proofValue"] = "z" + multibase.EncodeBase58(signature)
+	securedDocument = unsecuredCredential()
+	securedDocument["proof"] = proof
+	assert.NoError(t, VerifyProof(securedDocument, publicKey, opts))
+}
+
+func TestECDSARDFC2019P384(t *testing.T) {
+	opts := testOptions()
+	privateKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	publicKeyMultibase, err := EncodePublicKeyMultibase(&privateKey.PublicKey)
+	require.NoError(t, err)
+	assert.Equal(t, "z82", publicKeyMultibase[:3])
+
+	options := proofOptions(CryptosuiteECDSARDFC2019, publicKeyMultibase)
+	securedDocument, err := AddProof(unsecuredCredential(), options, privateKey, opts)
+	require.NoError(t, err)
+	assert.NoError(t, VerifyProof(securedDocument, &privateKey.PublicKey, opts))
+}
+
+func TestVerifyProofDetectsTampering(t *testing.T) {
+	opts := testOptions()
+	privateKey, err := ParseSecretKeyMultibase(eddsaSecretKey)
+	require.NoError(t, err)
+	publicKey, err := ParsePublicKeyMultibase(eddsaPublicKey)
+	require.NoError(t, err)
+
+	securedDocument, err := AddProof(unsecuredCredential(), proofOptions(CryptosuiteEdDSARDFC2022, eddsaPublicKey),
+		privateKey, opts)
+	require.NoError(t, err)
+
+	assertInvalid := func(doc map[string]interface{}, code ErrorCode) {
+		t.Helper()
+		err := VerifyProof(doc, publicKey, opts)
+		require.Error(t, err)
+		assert.Equal(t, code, err.(*Error).Code) //nolint:errorlint
+	}
+
+	tampered := copyDocument(securedDocument)
+	tampered["name"] = "Forged Credential"
+	assertInvalid(tampered, ProofVerificationError)
+
+	tampered = copyDocument(securedDocument)
+	tamperedProof := copyDocument(tampered["proof"].(map[string]interface{}))
+	tamperedProof["created"] = "2024-02-24T23:36:38Z"
+	tampered["proof"] = tamperedProof
+	assertInvalid(tampered, ProofVerificationError)
+
+	tamperedProof["created"] = "yesterday"
+	assertInvalid(tampered, ProofVerificationError)
+
+	// the proof @context must be a prefix of the document @context
+	tampered = copyDocument(securedDocument)
+	tamperedProof = copyDocument(tampered["proof"].(map[string]interface{}))
+	tamperedProof["@context"] = "https://www.w3.org/ns/credentials/examples/v2"
+	tampered["proof"] = tamperedProof
+	assertInvalid(tampered, ProofVerificationError)
+
+	tamperedProof["@context"] = unsecuredCredential()["@context"]
+	assert.NoError(t, VerifyProof(tampered, publicKey, opts))
+
+	// the document can't be verified with the credentials context alone
+	tamperedProof["@context"] = "https://www.w3.org/ns/credentials/v2"
+	assertInvalid(tampered, ProofVerificationError)
+
+	tamperedProof["proofValue"] = "u" + eddsaProofValue[1:]
+	assertInvalid(tampered, MalformedProofError)
+
+	tampered["proof"] = []interface{}{securedDocument["proof"]}
+	assertInvalid(tampered, MalformedProofError)
+
+	// the verification method of another key
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	err = VerifyProof(securedDocument, otherKey, opts)
+	require.Error(t, err)
+	assert.Equal(t, ProofVerificationError, err.(*Error).Code) //nolint:errorlint
+}
+
+func TestCreateProofErrors(t *testing.T) {
+	opts := testOptions()
+	edKey, err := ParseSecretKeyMultibase(eddsaSecretKey)
+	require.NoError(t, err)
+
+	options := proofOptions("bbs-2023", eddsaPublicKey)
+	_, err = CreateProof(unsecuredCredential(), options, edKey, opts)
+	require.Error(t, err)
+	assert.Equal(t, ProofGenerationError, err.(*Error).Code) //nolint:errorlint
+
+	// key type mismatch
+	ecKey, err := ParseSecretKeyMultibase(ecdsaSecretKey)
+	require.NoError(t, err)
+	_, err = CreateProof(unsecuredCredential(), proofOptions(CryptosuiteEdDSARDFC2022, eddsaPublicKey), ecKey, opts)
+	require.Error(t, err)
+	assert.Equal(t, ProofGenerationError, err.(*Error).Code) //nolint:errorlint
+
+	options = proofOptions(CryptosuiteEdDSARDFC2022, eddsaPublicKey)
+	options.Type = "Ed25519Signature2020"
+	_, err = CreateProof(unsecuredCredential(), options, edKey, opts)
+	require.Error(t, err)
+	assert.Equal(t, ProofGenerationError, err.(*Error).Code) //nolint:errorlint
+
+	// properties which don't expand to IRIs would be silently dropped from the signed data
+	document := unsecuredCredential()
+	document["@context"] = "https://www.w3.org/ns/credentials/v2"
+	document["undefinedTerm"] = "not signed"
+	_, err = CreateProof(document, proofOptions(CryptosuiteEdDSARDFC2022, eddsaPublicKey), edKey, opts)
+	require.Error(t, err)
+	assert.Equal(t, ProofTransformationError, err.(*Error).Code) //nolint:errorlint
+
+	securedDocument, err := AddProof(unsecuredCredential(), proofOptions(CryptosuiteEdDSARDFC2022, eddsaPublicKey),
+		edKey, opts)
+	require.NoError(t, err)
+	_, err = AddProof(securedDocument, proofOptions(CryptosuiteEdDSARDFC2022, eddsaPublicKey), edKey, opts)
+	require.Error(t, err)
+	assert.Equal(t, ProofGenerationError, err.(*Error).Code) //nolint:errorlint
+}
+
+func TestProofDateTimes(t *testing.T) {
+	opts := testOptions()
+	edKey, err := ParseSecretKeyMultibase(eddsaSecretKey)
+	require.NoError(t, err)
+	publicKey, err := ParsePublicKeyMultibase(eddsaPublicKey)
+	require.NoError(t, err)
+
+	// XML Schema dateTime values may omit the timezone
+	for _, created := range []string{"2023-02-24T23:36:38", "2023-02-24T23:36:38.123+02:00"} {
+		options := proofOptions(CryptosuiteEdDSARDFC2022, eddsaPublicKey)
+		options.Created = created
+		securedDocument, err := AddProof(unsecuredCredential(), options, edKey, opts)
+		require.NoError(t, err, created)
+		assert.NoError(t, VerifyProof(securedDocument, publicKey, opts), created)
+	}
+
+	for _, created := range []string{"2023-02-24", "2023-02-24 23:36:38Z"} {
+		options := proofOptions(CryptosuiteEdDSARDFC2022, eddsaPublicKey)
+		options.Created = created
+		_, err = AddProof(unsecuredCredential(), options, edKey, opts)
+		require.Error(t, err, created)
+		assert.Equal(t, ProofGenerationError, err.(*Error).Code) //nolint:errorlint
+	}
+}
+
+func copyDocument(document map[string]interface{}) map[string]interface{} {
+	rval := make(map[string]interface{}, len(document))
+	for key, value := range document {
+		rval[key] = value
+	}
+	return rval
+}
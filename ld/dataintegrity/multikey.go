@@ -0,0 +1,146 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataintegrity
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"fmt"
+	"math/big"
+
+	"github.com/piprate/json-gold/ld/internal/multibase"
+)
+
+// multicodec prefixes (as unsigned varints) of the key types supported by Multikey
+var (
+	ed25519PublicKeyPrefix = []byte{0xed, 0x01}
+	ed25519SecretKeyPrefix = []byte{0x80, 0x26}
+	p256PublicKeyPrefix    = []byte{0x80, 0x24}
+	p256SecretKeyPrefix    = []byte{0x86, 0x26}
+	p384PublicKeyPrefix    = []byte{0x81, 0x24}
+	p384SecretKeyPrefix    = []byte{0x87, 0x26}
+)
+
+// ParsePublicKeyMultibase parses the publicKeyMultibase value of a Multikey
+// (https://www.w3.org/TR/controller-document/#multikey). The result is either
+// ed25519.PublicKey or *ecdsa.PublicKey (P-256 or P-384).
+func ParsePublicKeyMultibase(value string) (crypto.PublicKey, error) {
+	data, err := decodeMultibase(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, ed25519PublicKeyPrefix):
+		key := data[len(ed25519PublicKeyPrefix):]
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key length: %d", len(key))
+		}
+		return ed25519.PublicKey(key), nil
+	case bytes.HasPrefix(data, p256PublicKeyPrefix):
+		return parseECDSAPublicKey(elliptic.P256(), data[len(p256PublicKeyPrefix):])
+	case bytes.HasPrefix(data, p384PublicKeyPrefix):
+		return parseECDSAPublicKey(elliptic.P384(), data[len(p384PublicKeyPrefix):])
+	default:
+		return nil, fmt.Errorf("unsupported Multikey public key type")
+	}
+}
+
+// ParseSecretKeyMultibase parses the secretKeyMultibase value of a Multikey. The result
+// is either ed25519.PrivateKey or *ecdsa.PrivateKey (P-256 or P-384).
+func ParseSecretKeyMultibase(value string) (crypto.PrivateKey, error) {
+	data, err := decodeMultibase(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, ed25519SecretKeyPrefix):
+		seed := data[len(ed25519SecretKeyPrefix):]
+		if len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid Ed25519 secret key length: %d", len(seed))
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	case bytes.HasPrefix(data, p256SecretKeyPrefix):
+		return parseECDSAPrivateKey(elliptic.P256(), data[len(p256SecretKeyPrefix):])
+	case bytes.HasPrefix(data, p384SecretKeyPrefix):
+		return parseECDSAPrivateKey(elliptic.P384(), data[len(p384SecretKeyPrefix):])
+	default:
+		return nil, fmt.Errorf("unsupported Multikey secret key type")
+	}
+}
+
+// EncodePublicKeyMultibase returns the publicKeyMultibase value of a Multikey for
+// the given ed25519.PublicKey or *ecdsa.PublicKey (P-256 or P-384).
+func EncodePublicKeyMultibase(publicKey crypto.PublicKey) (string, error) {
+	var data []byte
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		data = append(append(data, ed25519PublicKeyPrefix...), key...)
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			data = append(data, p256PublicKeyPrefix...)
+		case elliptic.P384():
+			data = append(data, p384PublicKeyPrefix...)
+		default:
+			return "", fmt.Errorf("unsupported ECDSA curve: %s", key.Curve.Params().Name)
+		}
+		data = append(data, elliptic.MarshalCompressed(key.Curve, key.X, key.Y)...)
+	default:
+		return "", fmt.Errorf("unsupported public key type: %T", publicKey)
+	}
+	return encodeMultibase(data), nil
+}
+
+func parseECDSAPublicKey(curve elliptic.Curve, data []byte) (*ecdsa.PublicKey, error) {
+	x, y := elliptic.UnmarshalCompressed(curve, data)
+	if x == nil {
+		return nil, fmt.Errorf("invalid %s public key", curve.Params().Name)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func parseECDSAPrivateKey(curve elliptic.Curve, data []byte) (*ecdsa.PrivateKey, error) {
+	size := (curve.Params().BitSize + 7) / 8
+	d := new(big.Int).SetBytes(data)
+	if len(data) != size || d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, fmt.Errorf("invalid %s secret key", curve.Params().Name)
+	}
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(data)
+	return key, nil
+}
+
+// decodeMultibase decodes a base58-btc multibase value.
+func decodeMultibase(value string) ([]byte, error) {
+	encoding, data, err := multibase.Decode(value)
+	if err != nil {
+		return nil, err
+	}
+	if encoding != multibase.Base58BTC {
+		return nil, fmt.Errorf("only base58-btc multibase values are supported")
+	}
+	return data, nil
+}
+
+// encodeMultibase encodes data as a base58-btc multibase value.
+func encodeMultibase(data []byte) string {
+	return "z" + multibase.EncodeBase58(data)
+}
@@ -0,0 +1,91 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataintegrity
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultikey(t *testing.T) {
+	// the key pair of the eddsa-rdfc-2022 test vectors
+	secretKey, err := ParseSecretKeyMultibase("z3u2en7t5LR2WtQH5PfFqMqwVHBeXouLzo6haApm8XHqvjxq")
+	require.NoError(t, err)
+	publicKey, err := ParsePublicKeyMultibase("z6MkrJVnaZkeFzdQyMZu1cgjg7k1pZZ6pvBQ7XJPt4swbTQ2")
+	require.NoError(t, err)
+	assert.Equal(t, publicKey, secretKey.(ed25519.PrivateKey).Public())
+
+	encoded, err := EncodePublicKeyMultibase(publicKey)
+	require.NoError(t, err)
+	assert.Equal(t, "z6MkrJVnaZkeFzdQyMZu1cgjg7k1pZZ6pvBQ7XJPt4swbTQ2", encoded)
+
+	// the key pair of the ecdsa-rdfc-2019 test vectors
+	secretKey, err = ParseSecretKeyMultibase("z42twTcNeSYcnqg1FLuSFs2bsGH3ZqbRHFmvS9XMsYhjxvHN")
+	require.NoError(t, err)
+	publicKey, err = ParsePublicKeyMultibase("zDnaepBuvsQ8cpsWrVKw8fbpGpvPeNSjVPTWoq6cRqaYzBKVP")
+	require.NoError(t, err)
+	assert.True(t, secretKey.(*ecdsa.PrivateKey).PublicKey.Equal(publicKey))
+
+	encoded, err = EncodePublicKeyMultibase(publicKey)
+	require.NoError(t, err)
+	assert.Equal(t, "zDnaepBuvsQ8cpsWrVKw8fbpGpvPeNSjVPTWoq6cRqaYzBKVP", encoded)
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	encoded, err = EncodePublicKeyMultibase(&p384Key.PublicKey)
+	require.NoError(t, err)
+	publicKey, err = ParsePublicKeyMultibase(encoded)
+	require.NoError(t, err)
+	assert.True(t, p384Key.PublicKey.Equal(publicKey))
+
+	p224Key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	_, err = EncodePublicKeyMultibase(&p224Key.PublicKey)
+	assert.Error(t, err)
+
+	for _, invalid := range []string{
+		"",
+		"u7QE",     // base64url
+		"z6Mkr",    // truncated Ed25519 key
+		"zQ3sh",    // unsupported key type
+		"zDnaepBu", // truncated P-256 key
+	} {
+		_, err = ParsePublicKeyMultibase(invalid)
+		assert.Error(t, err, invalid)
+	}
+	_, err = ParseSecretKeyMultibase("z3u2en7t")
+	assert.Error(t, err)
+}
+
+func TestECDSAProofBytes(t *testing.T) {
+	for curve, size := range map[elliptic.Curve]int{elliptic.P256(): 64, elliptic.P384(): 96} {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		require.NoError(t, err)
+
+		suite := ecdsaCryptosuite{}
+		proofBytes, err := suite.sign(key, []byte("hash data"))
+		require.NoError(t, err)
+		assert.Len(t, proofBytes, size)
+		assert.True(t, suite.verify(&key.PublicKey, []byte("hash data"), proofBytes))
+		assert.False(t, suite.verify(&key.PublicKey, []byte("other data"), proofBytes))
+		assert.False(t, suite.verify(&key.PublicKey, []byte("hash data"), proofBytes[1:]))
+	}
+}
@@ -15,13 +15,18 @@
 package ld
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pquerna/cachecontrol"
@@ -35,13 +40,39 @@ const (
 
 	// JSON-LD link header rel
 	linkHeaderRel = "http://www.w3.org/ns/json-ld#context"
+
+	// ContextProfile is the profile used to request JSON-LD contexts.
+	ContextProfile = "http://www.w3.org/ns/json-ld#context"
 )
 
 // RemoteDocument is a document retrieved from a remote source.
+// See https://www.w3.org/TR/json-ld11-api/#remotedocument
 type RemoteDocument struct {
 	DocumentURL string
 	Document    interface{}
 	ContextURL  string
+	// ContentType is the media type of the document, without parameters.
+	ContentType string
+	// Profile is the value of the profile parameter of the content type, if any.
+	Profile string
+	// Headers contains the response headers, if the document was retrieved via HTTP.
+	Headers http.Header
+}
+
+// HTTPStatusError is the cause of LoadingDocumentFailed errors returned by
+// HTTP based document loaders when the server responds with a status code other than 200.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Header     http.Header
+}
+
+func newHTTPStatusError(u string, res *http.Response) *HTTPStatusError {
+	return &HTTPStatusError{URL: u, StatusCode: res.StatusCode, Header: res.Header}
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("Bad response status code: %d", e.StatusCode)
 }
 
 // DocumentLoader knows how to load remote documents.
@@ -49,8 +80,93 @@ type DocumentLoader interface {
 	LoadDocument(u string) (*RemoteDocument, error)
 }
 
+// LoadDocumentOptions type as specified in the JSON-LD-API specification:
+// https://www.w3.org/TR/json-ld11-api/#loaddocumentoptions
+type LoadDocumentOptions struct {
+	// Profile is the expected profile of the document.
+	Profile string
+	// RequestProfile lists profiles which are sent to the server in the Accept header.
+	RequestProfile []string
+}
+
+// OptionsDocumentLoader is implemented by document loaders which accept LoadDocumentOptions.
+type OptionsDocumentLoader interface {
+	DocumentLoader
+	LoadDocumentWithOptions(u string, opts *LoadDocumentOptions) (*RemoteDocument, error)
+}
+
+// loadDocumentWithOptions loads the document using the given loader, passing
+// the options if the loader accepts them.
+func loadDocumentWithOptions(dl DocumentLoader, u string, opts *LoadDocumentOptions) (*RemoteDocument, error) {
+	if optsLoader, ok := dl.(OptionsDocumentLoader); ok && opts != nil {
+		return optsLoader.LoadDocumentWithOptions(u, opts)
+	}
+	return dl.LoadDocument(u)
+}
+
+// contextLoadOptions returns the options for loading remote contexts, which are requested
+// with the JSON-LD context profile, as per
+// https://www.w3.org/TR/json-ld11-api/#context-processing-algorithm.
+// A new value is returned on every call, so that loaders may not affect each other.
+func contextLoadOptions() *LoadDocumentOptions {
+	return &LoadDocumentOptions{
+		Profile:        ContextProfile,
+		RequestProfile: []string{ContextProfile},
+	}
+}
+
+// acceptHeaderFor returns the Accept header for the given options.
+func acceptHeaderFor(opts *LoadDocumentOptions) string {
+	if opts == nil || len(opts.RequestProfile) == 0 {
+		return acceptHeader
+	}
+	return fmt.Sprintf("%s;profile=\"%s\", %s", ApplicationJSONLDType,
+		strings.Join(opts.RequestProfile, " "), acceptHeader)
+}
+
+// parseContentType splits the given Content-Type header value into
+// the media type and the profile parameter.
+func parseContentType(contentType string) (mediaType string, profile string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+		return mediaType, ""
+	}
+	return mediaType, params["profile"]
+}
+
+// isJSONMediaType returns true if the given media type is application/json
+// or any media type with a +json suffix.
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// contentTypeForFile guesses the media type of a local file from its extension.
+func contentTypeForFile(fileName string) string {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".jsonld":
+		return ApplicationJSONLDType
+	case ".json":
+		return "application/json"
+	default:
+		return ""
+	}
+}
+
+// RawDocumentLoader is implemented by document loaders which can also return
+// the raw bytes of the document, exactly as they were retrieved.
+type RawDocumentLoader interface {
+	DocumentLoader
+	LoadRawDocument(u string) (*RemoteDocument, []byte, error)
+}
+
 // DefaultDocumentLoader is a standard implementation of DocumentLoader
 // which can retrieve documents via HTTP.
+//
+// It also decodes data URLs (RFC 2397). As per RFC 2397, a data URL without a media type,
+// such as data:,{"@id":"x"}, has the text/plain media type, so JsonLdProcessor rejects it
+// as an input document with a loading document failed error. Use an explicit JSON media type,
+// as in data:application/ld+json,{"@id":"x"}.
 type DefaultDocumentLoader struct {
 	httpClient *http.Client
 }
@@ -80,65 +196,111 @@ func DocumentFromReader(r io.Reader) (interface{}, error) {
 	return document, nil
 }
 
+// documentFromReader works like DocumentFromReader, but it also returns
+// the raw contents of the resource if keepRaw is true.
+func documentFromReader(r io.Reader, keepRaw bool) (interface{}, []byte, error) {
+	if !keepRaw {
+		doc, err := DocumentFromReader(r)
+		return doc, nil, err
+	}
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, NewJsonLdError(LoadingDocumentFailed, err)
+	}
+	doc, err := DocumentFromReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, raw, nil
+}
+
 // LoadDocument returns a RemoteDocument containing the contents of the JSON resource
 // from the given URL.
 func (dl *DefaultDocumentLoader) LoadDocument(u string) (*RemoteDocument, error) {
+	remoteDoc, _, err := dl.loadDocument(u, false, nil)
+	return remoteDoc, err
+}
+
+// LoadDocumentWithOptions works like LoadDocument, but it also sends the requested profiles
+// in the Accept header.
+func (dl *DefaultDocumentLoader) LoadDocumentWithOptions(u string, opts *LoadDocumentOptions) (*RemoteDocument, error) {
+	remoteDoc, _, err := dl.loadDocument(u, false, opts)
+	return remoteDoc, err
+}
+
+// LoadRawDocument works like LoadDocument, but it also returns the raw bytes of the document.
+func (dl *DefaultDocumentLoader) LoadRawDocument(u string) (*RemoteDocument, []byte, error) {
+	return dl.loadDocument(u, true, nil)
+}
+
+func (dl *DefaultDocumentLoader) loadDocument(u string, keepRaw bool,
+	opts *LoadDocumentOptions) (*RemoteDocument, []byte, error) {
+
+	if isDataURL(u) {
+		return loadDataURL(u, keepRaw)
+	}
+
 	parsedURL, err := url.Parse(u)
 	if err != nil {
-		return nil, NewJsonLdError(LoadingDocumentFailed, fmt.Sprintf("error parsing URL: %s", u))
+		return nil, nil, NewJsonLdError(LoadingDocumentFailed, fmt.Sprintf("error parsing URL: %s", u))
 	}
 
+	var raw []byte
+
 	remoteDoc := &RemoteDocument{}
 
 	protocol := parsedURL.Scheme
 	if protocol != "http" && protocol != "https" {
 		// Can't use the HTTP client for those!
 		remoteDoc.DocumentURL = u
+		remoteDoc.ContentType = contentTypeForFile(u)
 		var file *os.File
 		file, err = os.Open(u)
 		if err != nil {
-			return nil, NewJsonLdError(LoadingDocumentFailed, err)
+			return nil, nil, NewJsonLdError(LoadingDocumentFailed, err)
 		}
 		defer file.Close()
 
-		remoteDoc.Document, err = DocumentFromReader(file)
+		remoteDoc.Document, raw, err = documentFromReader(file, keepRaw)
 		if err != nil {
-			return nil, NewJsonLdError(LoadingDocumentFailed, err)
+			return nil, nil, NewJsonLdError(LoadingDocumentFailed, err)
 		}
 	} else {
 
 		req, err := http.NewRequest("GET", u, http.NoBody)
 		if err != nil {
-			return nil, NewJsonLdError(LoadingDocumentFailed, err)
+			return nil, nil, NewJsonLdError(LoadingDocumentFailed, err)
 		}
 		// We prefer application/ld+json, but fallback to application/json
 		// or whatever is available
-		req.Header.Add("Accept", acceptHeader)
+		req.Header.Add("Accept", acceptHeaderFor(opts))
 
 		res, err := dl.httpClient.Do(req)
 		if err != nil {
-			return nil, NewJsonLdError(LoadingDocumentFailed, err)
+			return nil, nil, NewJsonLdError(LoadingDocumentFailed, err)
 		}
 		defer res.Body.Close()
 
 		if res.StatusCode != http.StatusOK {
-			return nil, NewJsonLdError(LoadingDocumentFailed,
-				fmt.Sprintf("Bad response status code: %d", res.StatusCode))
+			return nil, nil, NewJsonLdError(LoadingDocumentFailed, newHTTPStatusError(u, res))
 		}
 
 		remoteDoc.DocumentURL = res.Request.URL.String()
+		remoteDoc.Headers = res.Header
 
-		contentType := res.Header.Get("Content-Type")
+		contentType, profile := parseContentType(res.Header.Get("Content-Type"))
+		remoteDoc.ContentType = contentType
+		remoteDoc.Profile = profile
 		linkHeader := res.Header.Get("Link")
 
 		if len(linkHeader) > 0 {
 			parsedLinkHeader := ParseLinkHeader(linkHeader)
 			contextLink := parsedLinkHeader[linkHeaderRel]
-			if contextLink != nil && contentType != ApplicationJSONLDType &&
-				(contentType == "application/json" || rApplicationJSON.MatchString(contentType)) {
+			if contextLink != nil && contentType != ApplicationJSONLDType && isJSONMediaType(contentType) {
 
 				if len(contextLink) > 1 {
-					return nil, NewJsonLdError(MultipleContextLinkHeaders, nil)
+					return nil, nil, NewJsonLdError(MultipleContextLinkHeaders, nil)
 				} else if len(contextLink) == 1 {
 					remoteDoc.ContextURL = contextLink[0]["target"]
 				}
@@ -150,24 +312,23 @@ func (dl *DefaultDocumentLoader) LoadDocument(u string) (*RemoteDocument, error)
 			alternateLink := parsedLinkHeader["alternate"]
 			if len(alternateLink) > 0 &&
 				alternateLink[0]["type"] == ApplicationJSONLDType &&
-				!rApplicationJSON.MatchString(contentType) {
+				!isJSONMediaType(contentType) {
 
 				finalURL := Resolve(u, alternateLink[0]["target"])
-				return dl.LoadDocument(finalURL)
+				return dl.loadDocument(finalURL, keepRaw, opts)
 			}
 		}
 
-		remoteDoc.Document, err = DocumentFromReader(res.Body)
+		remoteDoc.Document, raw, err = documentFromReader(res.Body, keepRaw)
 		if err != nil {
-			return nil, NewJsonLdError(LoadingDocumentFailed, err)
+			return nil, nil, NewJsonLdError(LoadingDocumentFailed, err)
 		}
 	}
-	return remoteDoc, nil
+	return remoteDoc, raw, nil
 }
 
 var rSplitOnComma = regexp.MustCompile("(?:<[^>]*?>|\"[^\"]*?\"|[^,])+")
 var rLinkHeader = regexp.MustCompile(`\s*<([^>]*?)>\s*(?:;\s*(.*))?`)
-var rApplicationJSON = regexp.MustCompile(`^application/(\w*\+)?json$`)
 var rParams = regexp.MustCompile("(.*?)=(?:(?:\"([^\"]*?)\")|([^\"]*?))\\s*(?:(?:;\\s*)|$)")
 
 // ParseLinkHeader parses a link header. The results will be keyed by the value of "rel".
@@ -227,8 +388,11 @@ func ParseLinkHeader(header string) map[string][]map[string]string {
 // which allows caching documents as soon as they get retrieved
 // from the underlying loader. You may also preload it with documents -
 // this is useful for testing.
+//
+// CachingDocumentLoader is safe for concurrent use.
 type CachingDocumentLoader struct {
 	nextLoader DocumentLoader
+	mu         sync.RWMutex
 	cache      map[string]*RemoteDocument
 }
 
@@ -245,21 +409,45 @@ func NewCachingDocumentLoader(nextLoader DocumentLoader) *CachingDocumentLoader
 // LoadDocument returns a RemoteDocument containing the contents of the JSON resource
 // from the given URL.
 func (cdl *CachingDocumentLoader) LoadDocument(u string) (*RemoteDocument, error) {
-	if doc, cached := cdl.cache[u]; cached {
+	cdl.mu.RLock()
+	doc, cached := cdl.cache[u]
+	cdl.mu.RUnlock()
+	if cached {
 		return doc, nil
-	} else {
-		doc, err := cdl.nextLoader.LoadDocument(u)
-		if err != nil {
-			return nil, err
-		}
-		cdl.cache[u] = doc
+	}
+
+	return cdl.load(u, nil)
+}
+
+// LoadDocumentWithOptions works like LoadDocument, but it also passes the options
+// to the underlying loader, if it accepts them, when the document isn't cached.
+func (cdl *CachingDocumentLoader) LoadDocumentWithOptions(u string, opts *LoadDocumentOptions) (*RemoteDocument, error) {
+	cdl.mu.RLock()
+	doc, cached := cdl.cache[u]
+	cdl.mu.RUnlock()
+	if cached {
 		return doc, nil
 	}
+
+	return cdl.load(u, opts)
+}
+
+func (cdl *CachingDocumentLoader) load(u string, opts *LoadDocumentOptions) (*RemoteDocument, error) {
+	doc, err := loadDocumentWithOptions(cdl.nextLoader, u, opts)
+	if err != nil {
+		return nil, err
+	}
+	cdl.mu.Lock()
+	cdl.cache[u] = doc
+	cdl.mu.Unlock()
+	return doc, nil
 }
 
 // AddDocument populates the cache with the given document (doc) for the provided URL (u).
 func (cdl *CachingDocumentLoader) AddDocument(u string, doc interface{}) {
+	cdl.mu.Lock()
 	cdl.cache[u] = &RemoteDocument{DocumentURL: u, Document: doc, ContextURL: ""}
+	cdl.mu.Unlock()
 }
 
 // PreloadWithMapping populates the cache with a number of documents which may be loaded
@@ -276,7 +464,9 @@ func (cdl *CachingDocumentLoader) PreloadWithMapping(urlMap map[string]string) e
 		if err != nil {
 			return err
 		}
+		cdl.mu.Lock()
 		cdl.cache[srcURL] = doc
+		cdl.mu.Unlock()
 	}
 	return nil
 }
@@ -288,9 +478,12 @@ type cachedRemoteDocument struct {
 }
 
 // RFC7324CachingDocumentLoader respects RFC7324 caching headers in order to
-// cache effectively
+// cache effectively.
+//
+// RFC7324CachingDocumentLoader is safe for concurrent use.
 type RFC7324CachingDocumentLoader struct {
 	httpClient *http.Client
+	mu         sync.RWMutex
 	cache      map[string]*cachedRemoteDocument
 }
 
@@ -311,7 +504,32 @@ func NewRFC7324CachingDocumentLoader(httpClient *http.Client) *RFC7324CachingDoc
 // LoadDocument returns a RemoteDocument containing the contents of the JSON resource
 // from the given URL.
 func (rcdl *RFC7324CachingDocumentLoader) LoadDocument(u string) (*RemoteDocument, error) {
-	entry, ok := rcdl.cache[u]
+	return rcdl.loadDocument(u, nil)
+}
+
+// LoadDocumentWithOptions works like LoadDocument, but it also sends the requested profiles
+// in the Accept header. Documents requested with different profiles are cached separately.
+func (rcdl *RFC7324CachingDocumentLoader) LoadDocumentWithOptions(u string,
+	opts *LoadDocumentOptions) (*RemoteDocument, error) {
+	return rcdl.loadDocument(u, opts)
+}
+
+func (rcdl *RFC7324CachingDocumentLoader) loadDocument(u string, opts *LoadDocumentOptions) (*RemoteDocument, error) {
+	// data URLs carry the document, there is nothing to cache
+	if isDataURL(u) {
+		remoteDoc, _, err := loadDataURL(u, false)
+		return remoteDoc, err
+	}
+
+	accept := acceptHeaderFor(opts)
+	cacheKey := u
+	if accept != acceptHeader {
+		cacheKey = accept + " " + u
+	}
+
+	rcdl.mu.RLock()
+	entry, ok := rcdl.cache[cacheKey]
+	rcdl.mu.RUnlock()
 	now := time.Now()
 
 	// First we check if we hit in the cache, and the cache entry is valid
@@ -337,6 +555,7 @@ func (rcdl *RFC7324CachingDocumentLoader) LoadDocument(u string) (*RemoteDocumen
 	if protocol != "http" && protocol != "https" {
 		// Can't use the HTTP client for those!
 		remoteDoc.DocumentURL = u
+		remoteDoc.ContentType = contentTypeForFile(u)
 		var file *os.File
 		file, err = os.Open(u)
 		if err != nil {
@@ -357,7 +576,7 @@ func (rcdl *RFC7324CachingDocumentLoader) LoadDocument(u string) (*RemoteDocumen
 		}
 		// We prefer application/ld+json, but fallback to application/json
 		// or whatever is available
-		req.Header.Add("Accept", acceptHeader)
+		req.Header.Add("Accept", accept)
 
 		res, err := rcdl.httpClient.Do(req)
 		if err != nil {
@@ -366,13 +585,15 @@ func (rcdl *RFC7324CachingDocumentLoader) LoadDocument(u string) (*RemoteDocumen
 		defer res.Body.Close()
 
 		if res.StatusCode != http.StatusOK {
-			return nil, NewJsonLdError(LoadingDocumentFailed,
-				fmt.Sprintf("Bad response status code: %d", res.StatusCode))
+			return nil, NewJsonLdError(LoadingDocumentFailed, newHTTPStatusError(u, res))
 		}
 
 		remoteDoc.DocumentURL = res.Request.URL.String()
+		remoteDoc.Headers = res.Header
 
-		contentType := res.Header.Get("Content-Type")
+		contentType, profile := parseContentType(res.Header.Get("Content-Type"))
+		remoteDoc.ContentType = contentType
+		remoteDoc.Profile = profile
 		linkHeader := res.Header.Get("Link")
 
 		if len(linkHeader) > 0 {
@@ -392,10 +613,10 @@ func (rcdl *RFC7324CachingDocumentLoader) LoadDocument(u string) (*RemoteDocumen
 			alternateLink := parsedLinkHeader["alternate"]
 			if len(alternateLink) > 0 &&
 				alternateLink[0]["type"] == ApplicationJSONLDType &&
-				!rApplicationJSON.MatchString(contentType) {
+				!isJSONMediaType(contentType) {
 
 				finalURL := Resolve(u, alternateLink[0]["target"])
-				remoteDoc, err = rcdl.LoadDocument(finalURL)
+				remoteDoc, err = rcdl.loadDocument(finalURL, opts)
 				if err != nil {
 					return nil, NewJsonLdError(LoadingDocumentFailed, err)
 				}
@@ -425,7 +646,9 @@ func (rcdl *RFC7324CachingDocumentLoader) LoadDocument(u string) (*RemoteDocumen
 			expireTime:     expireTime,
 			neverExpires:   neverExpires,
 		}
-		rcdl.cache[u] = cacheEntry
+		rcdl.mu.Lock()
+		rcdl.cache[cacheKey] = cacheEntry
+		rcdl.mu.Unlock()
 	}
 
 	return remoteDoc, nil
@@ -0,0 +1,102 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const dataURLDefaultMediaType = "text/plain"
+
+// isDataURL returns true if the given URL is a data URL as defined in RFC 2397.
+func isDataURL(u string) bool {
+	return len(u) >= 5 && strings.EqualFold(u[:5], "data:")
+}
+
+// loadDataURL decodes a data URL (RFC 2397) with either base64 or percent-encoded data.
+// The DocumentURL of the resulting document is the data URL itself. The content type
+// defaults to text/plain, which isn't accepted for JSON-LD input documents.
+func loadDataURL(u string, keepRaw bool) (*RemoteDocument, []byte, error) {
+	if !isDataURL(u) {
+		return nil, nil, NewJsonLdError(LoadingDocumentFailed, fmt.Sprintf("not a data URL: %s", u))
+	}
+
+	// fragments aren't part of the data
+	content := u[5:]
+	if idx := strings.IndexByte(content, '#'); idx >= 0 {
+		content = content[:idx]
+	}
+
+	commaIdx := strings.IndexByte(content, ',')
+	if commaIdx < 0 {
+		return nil, nil, NewJsonLdError(LoadingDocumentFailed, "malformed data URL: missing comma")
+	}
+	header, data := content[:commaIdx], content[commaIdx+1:]
+
+	isBase64 := false
+	if strings.HasSuffix(strings.ToLower(header), ";base64") {
+		isBase64 = true
+		header = header[:len(header)-len(";base64")]
+	}
+
+	// an omitted media type means text/plain, optionally with parameters (e.g. ";charset=utf-8")
+	if header == "" || strings.HasPrefix(header, ";") {
+		header = dataURLDefaultMediaType + header
+	}
+	header, err := url.PathUnescape(header)
+	if err != nil {
+		return nil, nil, NewJsonLdError(LoadingDocumentFailed, fmt.Errorf("malformed data URL: %w", err))
+	}
+
+	var raw []byte
+	if isBase64 {
+		data, err = url.PathUnescape(data)
+		if err == nil {
+			raw, err = decodeBase64(data)
+		}
+	} else {
+		data, err = url.PathUnescape(data)
+		raw = []byte(data)
+	}
+	if err != nil {
+		return nil, nil, NewJsonLdError(LoadingDocumentFailed, fmt.Errorf("malformed data URL: %w", err))
+	}
+
+	contentType, profile := parseContentType(header)
+	remoteDoc := &RemoteDocument{
+		DocumentURL: u,
+		ContentType: contentType,
+		Profile:     profile,
+	}
+	remoteDoc.Document, err = DocumentFromReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !keepRaw {
+		raw = nil
+	}
+	return remoteDoc, raw, nil
+}
+
+// decodeBase64 decodes both padded and unpadded standard base64 data.
+func decodeBase64(data string) ([]byte, error) {
+	data = strings.TrimRight(strings.Join(strings.Fields(data), ""), "=")
+	return base64.RawStdEncoding.DecodeString(data)
+}
@@ -0,0 +1,114 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"encoding/base64"
+	"net/url"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dataURLContext = `{"@context": {"name": "http://schema.org/name", "@vocab": "http://example.org/"}}`
+
+func TestLoadDataURL(t *testing.T) {
+	for name, u := range map[string]string{
+		"base64":          "data:application/ld+json;base64," + base64.StdEncoding.EncodeToString([]byte(dataURLContext)),
+		"unpadded base64": "data:application/ld+json;base64," + base64.RawStdEncoding.EncodeToString([]byte(dataURLContext)),
+		"percent-encoded": "data:application/ld+json," + url.PathEscape(dataURLContext),
+	} {
+		for loaderName, dl := range map[string]DocumentLoader{
+			"default": NewDefaultDocumentLoader(nil),
+			"RFC7324": NewRFC7324CachingDocumentLoader(nil),
+			"secure":  NewSecureDocumentLoader(&LoaderPolicy{AllowedSchemes: []string{"https", "data"}}, nil),
+			"caching": NewCachingDocumentLoader(NewDefaultDocumentLoader(nil)),
+			"mux":     NewMuxDocumentLoader(NewDefaultDocumentLoader(nil)),
+		} {
+			rd, err := dl.LoadDocument(u)
+			require.NoError(t, err, name, loaderName)
+			assert.Equal(t, u, rd.DocumentURL, name)
+			assert.Equal(t, "application/ld+json", rd.ContentType, name)
+			assert.Equal(t, "http://schema.org/name",
+				rd.Document.(map[string]interface{})["@context"].(map[string]interface{})["name"], name)
+		}
+	}
+}
+
+func TestLoadDataURLDefaults(t *testing.T) {
+	dl := NewDefaultDocumentLoader(nil)
+
+	rd, err := dl.LoadDocument(`data:,{"a":1}`)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", rd.ContentType)
+
+	rd, err = dl.LoadDocument(`data:application/ld+json;profile=%22http://www.w3.org/ns/json-ld%23context%22,{}`)
+	require.NoError(t, err)
+	assert.Equal(t, "http://www.w3.org/ns/json-ld#context", rd.Profile)
+
+	_, err = dl.LoadDocument("data:application/ld+json;base64")
+	require.Error(t, err)
+
+	_, err = NewSecureDocumentLoader(nil, nil).LoadDocument(`data:application/ld+json,{}`)
+	require.Error(t, err)
+}
+
+func TestExpandDataURL(t *testing.T) {
+	ctxURL := "data:application/ld+json;base64," + base64.StdEncoding.EncodeToString([]byte(dataURLContext))
+	doc := `{"@context": "` + ctxURL + `", "@id": "#me", "name": "Jane Doe", "knows": {"@id": "http://example.org/john"}}`
+	docURL := "data:application/ld+json;base64," + base64.StdEncoding.EncodeToString([]byte(doc))
+
+	proc := NewJsonLdProcessor()
+
+	// relative IRIs resolve against the base from options, not against the data URL
+	opts := NewJsonLdOptions("http://example.org/people/jane")
+	expanded, err := proc.Expand(docURL, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"@id": "http://example.org/people/jane#me",
+			"http://schema.org/name": []interface{}{
+				map[string]interface{}{"@value": "Jane Doe"},
+			},
+			"http://example.org/knows": []interface{}{
+				map[string]interface{}{"@id": "http://example.org/john"},
+			},
+		},
+	}, expanded)
+
+	// without a base, relative IRIs stay relative
+	expanded, err = proc.Expand(docURL, NewJsonLdOptions(""))
+	require.NoError(t, err)
+	assert.Equal(t, "#me", expanded[0].(map[string]interface{})["@id"])
+}
+
+func TestExpandDataURLWithoutMediaType(t *testing.T) {
+	proc := NewJsonLdProcessor()
+
+	// RFC 2397 data URLs without a media type are text/plain, which isn't a JSON media type
+	_, err := proc.Expand(`data:,{"@id":"http://example.org/x"}`, NewJsonLdOptions(""))
+	require.Error(t, err)
+	assert.Equal(t, LoadingDocumentFailed, err.(*JsonLdError).Code) //nolint:errorlint
+
+	expanded, err := proc.Expand(`data:application/json,{"@id":"http://example.org/x","@type":"http://example.org/T"}`,
+		NewJsonLdOptions(""))
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"@id":   "http://example.org/x",
+		"@type": []interface{}{"http://example.org/T"},
+	}}, expanded)
+}
@@ -0,0 +1,175 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// FixtureMode defines whether FixtureDocumentLoader records or replays documents.
+type FixtureMode int
+
+const (
+	// FixtureReplay makes the loader serve documents from fixtures only.
+	FixtureReplay FixtureMode = iota
+	// FixtureRecord makes the loader save every document it loads as a fixture.
+	FixtureRecord
+)
+
+// documentFixture is the on-disk representation of a RemoteDocument.
+type documentFixture struct {
+	URL         string          `json:"url"`
+	DocumentURL string          `json:"documentUrl"`
+	ContextURL  string          `json:"contextUrl,omitempty"`
+	ContentType string          `json:"contentType,omitempty"`
+	Profile     string          `json:"profile,omitempty"`
+	Headers     http.Header     `json:"headers,omitempty"`
+	Document    json.RawMessage `json:"document"`
+}
+
+// FixtureDocumentLoader is a DocumentLoader which records documents into a fixtures directory
+// and replays them later, so that tests can run without network access.
+//
+// In FixtureRecord mode, the loader retrieves documents using the next loader and saves
+// them, together with their metadata, as JSON files in the fixtures directory.
+// In FixtureReplay mode, the loader serves documents from the fixtures directory only
+// and fails with LoadingDocumentFailed if a fixture is missing.
+//
+// FixtureDocumentLoader may be wrapped with CachingDocumentLoader.
+type FixtureDocumentLoader struct {
+	nextLoader DocumentLoader
+	dir        string
+	mode       FixtureMode
+}
+
+// NewFixtureDocumentLoader creates a new instance of FixtureDocumentLoader.
+// nextLoader is only used in FixtureRecord mode and may be nil in FixtureReplay mode.
+func NewFixtureDocumentLoader(nextLoader DocumentLoader, dir string, mode FixtureMode) *FixtureDocumentLoader {
+	return &FixtureDocumentLoader{
+		nextLoader: nextLoader,
+		dir:        dir,
+		mode:       mode,
+	}
+}
+
+// LoadDocument returns a RemoteDocument containing the contents of the JSON resource
+// from the given URL.
+func (fdl *FixtureDocumentLoader) LoadDocument(u string) (*RemoteDocument, error) {
+	if fdl.mode == FixtureRecord {
+		return fdl.record(u)
+	}
+	return fdl.replay(u)
+}
+
+// FixturePath returns the path of the fixture file for the given URL.
+func (fdl *FixtureDocumentLoader) FixturePath(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return filepath.Join(fdl.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+func (fdl *FixtureDocumentLoader) record(u string) (*RemoteDocument, error) {
+	if fdl.nextLoader == nil {
+		return nil, NewJsonLdError(LoadingDocumentFailed, "no document loader to record fixtures from")
+	}
+	rd, err := fdl.nextLoader.LoadDocument(u)
+	if err != nil {
+		return nil, err
+	}
+
+	docBytes, err := json.Marshal(rd.Document)
+	if err != nil {
+		return nil, NewJsonLdError(IOError, err)
+	}
+	fixture := &documentFixture{
+		URL:         u,
+		DocumentURL: rd.DocumentURL,
+		ContextURL:  rd.ContextURL,
+		ContentType: rd.ContentType,
+		Profile:     rd.Profile,
+		Headers:     rd.Headers,
+		Document:    docBytes,
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, NewJsonLdError(IOError, err)
+	}
+
+	if err = os.MkdirAll(fdl.dir, 0o755); err != nil {
+		return nil, NewJsonLdError(IOError, err)
+	}
+
+	// write to a temporary file first, so that concurrent loads never see partial fixtures
+	fixturePath := fdl.FixturePath(u)
+	tmpFile, err := os.CreateTemp(fdl.dir, ".fixture-*")
+	if err != nil {
+		return nil, NewJsonLdError(IOError, err)
+	}
+	_, err = tmpFile.Write(append(data, '\n'))
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), fixturePath)
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return nil, NewJsonLdError(IOError, err)
+	}
+
+	return rd, nil
+}
+
+func (fdl *FixtureDocumentLoader) replay(u string) (*RemoteDocument, error) {
+	fixturePath := fdl.FixturePath(u)
+	data, err := os.ReadFile(fixturePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, NewJsonLdError(LoadingDocumentFailed,
+			fmt.Sprintf("no fixture recorded for %s (expected at %s)", u, fixturePath))
+	} else if err != nil {
+		return nil, NewJsonLdError(LoadingDocumentFailed, err)
+	}
+
+	var fixture documentFixture
+	if err = json.Unmarshal(data, &fixture); err != nil {
+		return nil, NewJsonLdError(LoadingDocumentFailed, fmt.Errorf("invalid fixture %s: %w", fixturePath, err))
+	}
+	if fixture.URL != u {
+		return nil, NewJsonLdError(LoadingDocumentFailed,
+			fmt.Sprintf("fixture %s was recorded for %s, not %s", fixturePath, fixture.URL, u))
+	}
+
+	doc, err := DocumentFromReader(bytes.NewReader(fixture.Document))
+	if err != nil {
+		return nil, err
+	}
+
+	return &RemoteDocument{
+		DocumentURL: fixture.DocumentURL,
+		Document:    doc,
+		ContextURL:  fixture.ContextURL,
+		ContentType: fixture.ContentType,
+		Profile:     fixture.Profile,
+		Headers:     fixture.Headers,
+	}, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixtureDocumentLoaderRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/context.jsonld", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ld+json")
+		_, _ = w.Write([]byte(`{"@context": {"name": "http://schema.org/name"}}`))
+	}))
+
+	dir := t.TempDir()
+	contextURL := server.URL + "/redirect"
+
+	recorder := NewFixtureDocumentLoader(NewDefaultDocumentLoader(nil), dir, FixtureRecord)
+	recorded, err := recorder.LoadDocument(contextURL)
+	require.NoError(t, err)
+	assert.FileExists(t, recorder.FixturePath(contextURL))
+
+	// no network access from now on
+	server.Close()
+
+	replayer := NewCachingDocumentLoader(NewFixtureDocumentLoader(nil, dir, FixtureReplay))
+	replayed, err := replayer.LoadDocument(contextURL)
+	require.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+	assert.Equal(t, server.URL+"/context.jsonld", replayed.DocumentURL)
+	assert.Equal(t, "application/ld+json", replayed.ContentType)
+	assert.Equal(t, "application/ld+json", replayed.Headers.Get("Content-Type"))
+
+	opts := NewJsonLdOptions("")
+	opts.DocumentLoader = replayer
+	_, err = NewJsonLdProcessor().Expand(map[string]interface{}{
+		"@context": contextURL,
+		"name":     "Jane Doe",
+	}, opts)
+	require.NoError(t, err)
+}
+
+func TestFixtureDocumentLoaderReplayMiss(t *testing.T) {
+	dl := NewFixtureDocumentLoader(nil, t.TempDir(), FixtureReplay)
+
+	_, err := dl.LoadDocument("https://example.org/missing.jsonld")
+	require.Error(t, err)
+	assert.Equal(t, LoadingDocumentFailed, err.(*JsonLdError).Code) //nolint:errorlint
+	assert.Contains(t, err.Error(), "no fixture recorded for https://example.org/missing.jsonld")
+}
@@ -0,0 +1,131 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+type prefixMapping struct {
+	prefix string
+	target string
+}
+
+// FSDocumentLoader is an implementation of DocumentLoader which serves documents
+// from an fs.FS (for example, embed.FS or testing/fstest.MapFS). URLs are mapped
+// to paths inside the file system using prefix rewrite rules.
+type FSDocumentLoader struct {
+	fsys     fs.FS
+	mappings []prefixMapping
+}
+
+// NewFSDocumentLoader creates a new instance of FSDocumentLoader. Each key of prefixMap
+// is a URL prefix and each value is the directory inside fsys the prefix is rewritten to.
+//
+// Example:
+//
+//	//go:embed contexts
+//	var contexts embed.FS
+//
+//	l := NewFSDocumentLoader(contexts, map[string]string{
+//	    "https://example.org/contexts/": "contexts/",
+//	})
+//
+// With the loader above, https://example.org/contexts/v1.jsonld is served from contexts/v1.jsonld.
+func NewFSDocumentLoader(fsys fs.FS, prefixMap map[string]string) *FSDocumentLoader {
+	rval := &FSDocumentLoader{
+		fsys:     fsys,
+		mappings: make([]prefixMapping, 0, len(prefixMap)),
+	}
+
+	for prefix, target := range prefixMap {
+		rval.mappings = append(rval.mappings, prefixMapping{prefix: prefix, target: target})
+	}
+
+	// the longest matching prefix wins
+	sort.Slice(rval.mappings, func(i, j int) bool {
+		pi, pj := rval.mappings[i].prefix, rval.mappings[j].prefix
+		if len(pi) != len(pj) {
+			return len(pi) > len(pj)
+		}
+		return pi < pj
+	})
+
+	return rval
+}
+
+// LoadDocument returns a RemoteDocument containing the contents of the JSON resource
+// from the given URL.
+func (fdl *FSDocumentLoader) LoadDocument(u string) (*RemoteDocument, error) {
+	remoteDoc, _, err := fdl.loadDocument(u, false)
+	return remoteDoc, err
+}
+
+// LoadRawDocument works like LoadDocument, but it also returns the raw bytes of the document.
+func (fdl *FSDocumentLoader) LoadRawDocument(u string) (*RemoteDocument, []byte, error) {
+	return fdl.loadDocument(u, true)
+}
+
+func (fdl *FSDocumentLoader) loadDocument(u string, keepRaw bool) (*RemoteDocument, []byte, error) {
+	filePath, err := fdl.resolvePath(u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := fdl.fsys.Open(filePath)
+	if err != nil {
+		return nil, nil, NewJsonLdError(LoadingDocumentFailed, err)
+	}
+	defer file.Close()
+
+	remoteDoc := &RemoteDocument{DocumentURL: u, ContentType: contentTypeForFile(filePath)}
+	var raw []byte
+	remoteDoc.Document, raw, err = documentFromReader(file, keepRaw)
+	if err != nil {
+		return nil, nil, NewJsonLdError(LoadingDocumentFailed, err)
+	}
+
+	return remoteDoc, raw, nil
+}
+
+// resolvePath maps the given URL to a path inside the file system.
+func (fdl *FSDocumentLoader) resolvePath(u string) (string, error) {
+	// fragments never take part in document retrieval
+	if idx := strings.IndexByte(u, '#'); idx >= 0 {
+		u = u[:idx]
+	}
+
+	for _, m := range fdl.mappings {
+		if !strings.HasPrefix(u, m.prefix) {
+			continue
+		}
+		target := path.Clean(m.target)
+		filePath := path.Join(target, strings.TrimPrefix(u, m.prefix))
+
+		// don't let dot segments escape the target directory
+		insideTarget := target == "." || strings.HasPrefix(filePath, target+"/")
+		if !fs.ValidPath(filePath) || !insideTarget {
+			return "", NewJsonLdError(LoadingDocumentFailed,
+				fmt.Sprintf("URL %s maps to an invalid path: %s", u, filePath))
+		}
+		return filePath, nil
+	}
+
+	return "", NewJsonLdError(LoadingDocumentFailed, fmt.Sprintf("no file system mapping for URL: %s", u))
+}
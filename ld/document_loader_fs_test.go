@@ -0,0 +1,92 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testContextFS = fstest.MapFS{
+	"contexts/v1.jsonld":      {Data: []byte(`{"@context": {"name": "http://schema.org/name"}}`)},
+	"contexts/v2/main.jsonld": {Data: []byte(`{"@context": {"title": "http://schema.org/title"}}`)},
+	"secret.json":             {Data: []byte(`{"secret": true}`)},
+}
+
+func TestFSDocumentLoaderLoadDocument(t *testing.T) {
+	dl := NewFSDocumentLoader(testContextFS, map[string]string{
+		"https://example.org/contexts/": "contexts/",
+		"https://example.org/v2/":       "contexts/v2",
+	})
+
+	rd, err := dl.LoadDocument("https://example.org/contexts/v1.jsonld")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/contexts/v1.jsonld", rd.DocumentURL)
+	assert.Equal(t, map[string]interface{}{"name": "http://schema.org/name"},
+		rd.Document.(map[string]interface{})["@context"])
+
+	rd, err = dl.LoadDocument("https://example.org/v2/main.jsonld#frag")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"title": "http://schema.org/title"},
+		rd.Document.(map[string]interface{})["@context"])
+
+	// the longest prefix must win
+	rd, err = dl.LoadDocument("https://example.org/contexts/v2/main.jsonld")
+	require.NoError(t, err)
+	assert.NotNil(t, rd.Document)
+}
+
+func TestFSDocumentLoaderRejectsUnmappedAndEscapingURLs(t *testing.T) {
+	dl := NewFSDocumentLoader(testContextFS, map[string]string{
+		"https://example.org/contexts/": "contexts/",
+	})
+
+	for _, u := range []string{
+		"https://example.org/other/v1.jsonld",
+		"https://example.org/contexts/../secret.json",
+		"https://example.org/contexts/missing.jsonld",
+		"/etc/passwd",
+	} {
+		_, err := dl.LoadDocument(u)
+		require.Error(t, err, u)
+		assert.Equal(t, LoadingDocumentFailed, err.(*JsonLdError).Code, u) //nolint:errorlint
+	}
+}
+
+func TestFSDocumentLoaderExpand(t *testing.T) {
+	opts := NewJsonLdOptions("")
+	opts.DocumentLoader = NewFSDocumentLoader(testContextFS, map[string]string{
+		"https://example.org/contexts/": "contexts/",
+	})
+
+	doc := map[string]interface{}{
+		"@context": "https://example.org/contexts/v1.jsonld",
+		"name":     "Jane Doe",
+	}
+
+	expanded, err := NewJsonLdProcessor().Expand(doc, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"http://schema.org/name": []interface{}{
+				map[string]interface{}{"@value": "Jane Doe"},
+			},
+		},
+	}, expanded)
+}
@@ -0,0 +1,252 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/piprate/json-gold/ld/internal/jsoncanonicalizer"
+)
+
+// DigestMethod identifies how the digest of a document is computed.
+type DigestMethod string
+
+const (
+	// DigestMethodSHA256 is SHA-256 over the raw bytes of the document.
+	DigestMethodSHA256 DigestMethod = "sha256"
+	// DigestMethodJCSSHA256 is SHA-256 over the JCS (RFC 8785) canonical form of the document.
+	DigestMethodJCSSHA256 DigestMethod = "jcs-sha256"
+)
+
+// IntegrityMode defines how IntegrityDocumentLoader handles loaded documents.
+type IntegrityMode int
+
+const (
+	// IntegrityEnforce makes the loader refuse documents which don't match their pins.
+	IntegrityEnforce IntegrityMode = iota
+	// IntegrityReport makes the loader return documents which don't match their pins,
+	// reporting every mismatch to IntegrityOptions.OnMismatch.
+	IntegrityReport
+	// IntegrityRecord makes the loader record digests of all loaded documents without
+	// verifying them. Use WriteLockfile to save the digests.
+	IntegrityRecord
+)
+
+// IntegrityOptions configures IntegrityDocumentLoader.
+type IntegrityOptions struct {
+	Mode IntegrityMode
+	// RequirePins makes documents without a pin fail verification.
+	RequirePins bool
+	// RecordMethod is the digest method used for documents without a pin.
+	// Defaults to DigestMethodJCSSHA256.
+	RecordMethod DigestMethod
+	// OnMismatch is called in IntegrityReport mode for every document which
+	// fails verification. expected is empty for documents without a pin.
+	OnMismatch func(u, expected, actual string)
+}
+
+// IntegrityDocumentLoader is an overlay on top of DocumentLoader instance which verifies
+// loaded documents against pinned digests. Pins map URLs to digests in the form
+// "<method>:<hex digest>", for example "jcs-sha256:9f86d08...".
+//
+// DigestMethodSHA256 pins require the next loader to implement RawDocumentLoader.
+// To cache verified documents, wrap IntegrityDocumentLoader with CachingDocumentLoader.
+type IntegrityDocumentLoader struct {
+	nextLoader DocumentLoader
+	pins       map[string]string
+	opts       IntegrityOptions
+
+	mu       sync.Mutex
+	observed map[string]string
+}
+
+// NewIntegrityDocumentLoader creates a new instance of IntegrityDocumentLoader.
+// If opts is nil, pins are enforced and documents without pins are accepted.
+func NewIntegrityDocumentLoader(nextLoader DocumentLoader, pins map[string]string,
+	opts *IntegrityOptions) *IntegrityDocumentLoader {
+
+	rval := &IntegrityDocumentLoader{
+		nextLoader: nextLoader,
+		pins:       make(map[string]string, len(pins)),
+		observed:   make(map[string]string),
+	}
+	for u, pin := range pins {
+		rval.pins[u] = pin
+	}
+	if opts != nil {
+		rval.opts = *opts
+	}
+	if rval.opts.RecordMethod == "" {
+		rval.opts.RecordMethod = DigestMethodJCSSHA256
+	}
+
+	return rval
+}
+
+// LoadDocument returns a RemoteDocument containing the contents of the JSON resource
+// from the given URL.
+func (idl *IntegrityDocumentLoader) LoadDocument(u string) (*RemoteDocument, error) {
+	return idl.loadDocument(u, nil)
+}
+
+// LoadDocumentWithOptions works like LoadDocument, but it also passes the options
+// to the next loader, if it accepts them. Documents pinned with DigestMethodSHA256
+// are loaded with LoadRawDocument, which doesn't take options.
+func (idl *IntegrityDocumentLoader) LoadDocumentWithOptions(u string, opts *LoadDocumentOptions) (*RemoteDocument, error) {
+	return idl.loadDocument(u, opts)
+}
+
+func (idl *IntegrityDocumentLoader) loadDocument(u string, opts *LoadDocumentOptions) (*RemoteDocument, error) {
+	pin, pinned := idl.pins[u]
+
+	method := idl.opts.RecordMethod
+	if pinned {
+		var err error
+		if method, err = parsePin(pin); err != nil {
+			return nil, err
+		}
+	}
+
+	var rd *RemoteDocument
+	var raw []byte
+	var err error
+	if method == DigestMethodSHA256 {
+		rawLoader, ok := idl.nextLoader.(RawDocumentLoader)
+		if !ok {
+			return nil, NewJsonLdError(IntegrityCheckFailed,
+				fmt.Sprintf("%s digest requires a loader which supports raw documents", method))
+		}
+		rd, raw, err = rawLoader.LoadRawDocument(u)
+	} else {
+		rd, err = loadDocumentWithOptions(idl.nextLoader, u, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err := DocumentDigest(method, rd.Document, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	idl.mu.Lock()
+	idl.observed[u] = actual
+	idl.mu.Unlock()
+
+	if idl.opts.Mode == IntegrityRecord {
+		return rd, nil
+	}
+
+	if (pinned && !strings.EqualFold(pin, actual)) || (!pinned && idl.opts.RequirePins) {
+		if idl.opts.Mode == IntegrityReport {
+			if idl.opts.OnMismatch != nil {
+				idl.opts.OnMismatch(u, pin, actual)
+			}
+			return rd, nil
+		}
+		if !pinned {
+			return nil, NewJsonLdError(IntegrityCheckFailed, fmt.Sprintf("no pin for %s", u))
+		}
+		return nil, NewJsonLdError(IntegrityCheckFailed,
+			fmt.Sprintf("digest mismatch for %s: expected %s, got %s", u, pin, actual))
+	}
+
+	return rd, nil
+}
+
+// ObservedDigests returns digests of all documents loaded so far, keyed by URL.
+func (idl *IntegrityDocumentLoader) ObservedDigests() map[string]string {
+	idl.mu.Lock()
+	defer idl.mu.Unlock()
+
+	rval := make(map[string]string, len(idl.observed))
+	for u, digest := range idl.observed {
+		rval[u] = digest
+	}
+	return rval
+}
+
+// WriteLockfile writes digests of all documents loaded so far to w as a JSON object
+// which maps URLs to pins. The result can be read with ReadLockfile.
+func (idl *IntegrityDocumentLoader) WriteLockfile(w io.Writer) error {
+	data, err := json.MarshalIndent(idl.ObservedDigests(), "", "  ")
+	if err != nil {
+		return NewJsonLdError(IOError, err)
+	}
+	if _, err = w.Write(append(data, '\n')); err != nil {
+		return NewJsonLdError(IOError, err)
+	}
+	return nil
+}
+
+// ReadLockfile reads pins written by IntegrityDocumentLoader.WriteLockfile.
+func ReadLockfile(r io.Reader) (map[string]string, error) {
+	pins := make(map[string]string)
+	if err := json.NewDecoder(r).Decode(&pins); err != nil {
+		return nil, NewJsonLdError(IOError, err)
+	}
+	for u, pin := range pins {
+		if _, err := parsePin(pin); err != nil {
+			return nil, NewJsonLdError(IntegrityCheckFailed, fmt.Sprintf("invalid pin for %s: %s", u, pin))
+		}
+	}
+	return pins, nil
+}
+
+// DocumentDigest computes the digest of a document in the form "<method>:<hex digest>".
+// DigestMethodSHA256 digests are computed over raw; DigestMethodJCSSHA256 digests
+// are computed over the canonical JSON form of document.
+func DocumentDigest(method DigestMethod, document interface{}, raw []byte) (string, error) {
+	var data []byte
+	switch method {
+	case DigestMethodSHA256:
+		if raw == nil {
+			return "", NewJsonLdError(IntegrityCheckFailed, "raw document bytes aren't available")
+		}
+		data = raw
+	case DigestMethodJCSSHA256:
+		jsonBytes, err := json.Marshal(document)
+		if err != nil {
+			return "", NewJsonLdError(IntegrityCheckFailed, err)
+		}
+		if data, err = jsoncanonicalizer.Transform(jsonBytes); err != nil {
+			return "", NewJsonLdError(IntegrityCheckFailed, err)
+		}
+	default:
+		return "", NewJsonLdError(IntegrityCheckFailed, fmt.Sprintf("unknown digest method: %s", method))
+	}
+
+	sum := sha256.Sum256(data)
+	return string(method) + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// parsePin returns the digest method of the given pin.
+func parsePin(pin string) (DigestMethod, error) {
+	idx := strings.IndexByte(pin, ':')
+	if idx < 0 {
+		return "", NewJsonLdError(IntegrityCheckFailed, fmt.Sprintf("invalid pin: %s", pin))
+	}
+	method := DigestMethod(pin[:idx])
+	if method != DigestMethodSHA256 && method != DigestMethodJCSSHA256 {
+		return "", NewJsonLdError(IntegrityCheckFailed, fmt.Sprintf("unknown digest method: %s", method))
+	}
+	return method, nil
+}
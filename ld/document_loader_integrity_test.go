@@ -0,0 +1,154 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pinnedContextURL = "https://example.org/contexts/v1.jsonld"
+
+var pinnedContextBytes = []byte(`{"@context": {"name": "http://schema.org/name"}}`)
+
+func newPinnedContextLoader() *FSDocumentLoader {
+	return NewFSDocumentLoader(fstest.MapFS{
+		"contexts/v1.jsonld": {Data: pinnedContextBytes},
+	}, map[string]string{
+		"https://example.org/contexts/": "contexts/",
+	})
+}
+
+func TestDocumentDigest(t *testing.T) {
+	rawSum := sha256.Sum256(pinnedContextBytes)
+	digest, err := DocumentDigest(DigestMethodSHA256, nil, pinnedContextBytes)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:"+hex.EncodeToString(rawSum[:]), digest)
+
+	// JCS digests don't depend on formatting
+	canonicalSum := sha256.Sum256([]byte(`{"@context":{"name":"http://schema.org/name"}}`))
+	rd, err := newPinnedContextLoader().LoadDocument(pinnedContextURL)
+	require.NoError(t, err)
+	digest, err = DocumentDigest(DigestMethodJCSSHA256, rd.Document, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "jcs-sha256:"+hex.EncodeToString(canonicalSum[:]), digest)
+
+	_, err = DocumentDigest(DigestMethodSHA256, rd.Document, nil)
+	assert.Error(t, err)
+}
+
+func TestIntegrityDocumentLoaderEnforce(t *testing.T) {
+	rd, err := newPinnedContextLoader().LoadDocument(pinnedContextURL)
+	require.NoError(t, err)
+	jcsPin, _ := DocumentDigest(DigestMethodJCSSHA256, rd.Document, nil)
+	rawPin, _ := DocumentDigest(DigestMethodSHA256, nil, pinnedContextBytes)
+
+	for _, pin := range []string{jcsPin, rawPin} {
+		dl := NewIntegrityDocumentLoader(newPinnedContextLoader(), map[string]string{
+			pinnedContextURL: pin,
+		}, nil)
+		_, err = dl.LoadDocument(pinnedContextURL)
+		assert.NoError(t, err, pin)
+	}
+
+	dl := NewIntegrityDocumentLoader(newPinnedContextLoader(), map[string]string{
+		pinnedContextURL: "jcs-sha256:0000",
+	}, nil)
+	_, err = dl.LoadDocument(pinnedContextURL)
+	require.Error(t, err)
+	assert.Equal(t, IntegrityCheckFailed, err.(*JsonLdError).Code) //nolint:errorlint
+
+	// documents without pins are rejected only if pins are required
+	dl = NewIntegrityDocumentLoader(newPinnedContextLoader(), nil, &IntegrityOptions{RequirePins: true})
+	_, err = dl.LoadDocument(pinnedContextURL)
+	require.Error(t, err)
+	assert.Equal(t, IntegrityCheckFailed, err.(*JsonLdError).Code) //nolint:errorlint
+}
+
+func TestIntegrityDocumentLoaderReport(t *testing.T) {
+	var mismatches []string
+	dl := NewIntegrityDocumentLoader(newPinnedContextLoader(), map[string]string{
+		pinnedContextURL: "jcs-sha256:0000",
+	}, &IntegrityOptions{
+		Mode: IntegrityReport,
+		OnMismatch: func(u, expected, actual string) {
+			mismatches = append(mismatches, u)
+			assert.Equal(t, "jcs-sha256:0000", expected)
+		},
+	})
+
+	rd, err := dl.LoadDocument(pinnedContextURL)
+	require.NoError(t, err)
+	assert.NotNil(t, rd.Document)
+	assert.Equal(t, []string{pinnedContextURL}, mismatches)
+}
+
+func TestIntegrityDocumentLoaderRecord(t *testing.T) {
+	dl := NewIntegrityDocumentLoader(newPinnedContextLoader(), nil, &IntegrityOptions{Mode: IntegrityRecord})
+
+	opts := NewJsonLdOptions("")
+	opts.DocumentLoader = dl
+	_, err := NewJsonLdProcessor().Expand(map[string]interface{}{
+		"@context": pinnedContextURL,
+		"name":     "Jane Doe",
+	}, opts)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, dl.WriteLockfile(&buf))
+
+	pins, err := ReadLockfile(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, dl.ObservedDigests(), pins)
+	require.Contains(t, pins, pinnedContextURL)
+
+	// the lockfile can be used to enforce the recorded digests
+	_, err = NewIntegrityDocumentLoader(newPinnedContextLoader(), pins, nil).LoadDocument(pinnedContextURL)
+	assert.NoError(t, err)
+}
+
+func TestIntegrityDocumentLoaderPassesOptions(t *testing.T) {
+	var acceptHeaders []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptHeaders = append(acceptHeaders, r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "application/ld+json")
+		_, _ = w.Write(pinnedContextBytes)
+	}))
+	defer server.Close()
+
+	dl := NewIntegrityDocumentLoader(NewDefaultDocumentLoader(nil), nil, nil)
+
+	opts := NewJsonLdOptions("")
+	opts.DocumentLoader = dl
+	_, err := NewJsonLdProcessor().Expand(map[string]interface{}{
+		"@context": server.URL + "/context.jsonld",
+		"name":     "Jane Doe",
+	}, opts)
+	require.NoError(t, err)
+
+	// remote contexts are requested with the context profile
+	require.Len(t, acceptHeaders, 1)
+	assert.Contains(t, acceptHeaders[0], `application/ld+json;profile="`+ContextProfile+`"`)
+	assert.Contains(t, dl.ObservedDigests(), server.URL+"/context.jsonld")
+}
@@ -0,0 +1,132 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type muxRoute struct {
+	prefix string
+	loader DocumentLoader
+}
+
+// MuxDocumentLoader is a DocumentLoader which dispatches requests to other loaders
+// based on the URL prefix, host or scheme of the requested document.
+//
+// Routes are matched in the following order: the longest matching URL prefix,
+// then the host name, then the scheme. If no route matches, the fallback loader
+// is used (if provided).
+type MuxDocumentLoader struct {
+	prefixes []muxRoute
+	hosts    map[string]DocumentLoader
+	schemes  map[string]DocumentLoader
+	fallback DocumentLoader
+}
+
+// NewMuxDocumentLoader creates a new instance of MuxDocumentLoader.
+// fallback may be nil, in which case unmatched URLs fail to load.
+func NewMuxDocumentLoader(fallback DocumentLoader) *MuxDocumentLoader {
+	return &MuxDocumentLoader{
+		prefixes: make([]muxRoute, 0),
+		hosts:    make(map[string]DocumentLoader),
+		schemes:  make(map[string]DocumentLoader),
+		fallback: fallback,
+	}
+}
+
+// HandlePrefix routes all URLs starting with the given prefix to the given loader.
+func (mdl *MuxDocumentLoader) HandlePrefix(prefix string, loader DocumentLoader) *MuxDocumentLoader {
+	mdl.prefixes = append(mdl.prefixes, muxRoute{prefix: prefix, loader: loader})
+	sort.SliceStable(mdl.prefixes, func(i, j int) bool {
+		return len(mdl.prefixes[i].prefix) > len(mdl.prefixes[j].prefix)
+	})
+	return mdl
+}
+
+// HandleHost routes all URLs with the given host (case-insensitive) to the given loader.
+func (mdl *MuxDocumentLoader) HandleHost(host string, loader DocumentLoader) *MuxDocumentLoader {
+	mdl.hosts[strings.ToLower(host)] = loader
+	return mdl
+}
+
+// HandleScheme routes all URLs with the given scheme (e.g. "https" or "file") to the given loader.
+func (mdl *MuxDocumentLoader) HandleScheme(scheme string, loader DocumentLoader) *MuxDocumentLoader {
+	mdl.schemes[strings.ToLower(scheme)] = loader
+	return mdl
+}
+
+// LoadDocument returns a RemoteDocument containing the contents of the JSON resource
+// from the given URL.
+func (mdl *MuxDocumentLoader) LoadDocument(u string) (*RemoteDocument, error) {
+	loader, err := mdl.route(u)
+	if err != nil {
+		return nil, err
+	}
+	return loader.LoadDocument(u)
+}
+
+// LoadDocumentWithOptions works like LoadDocument, but it also passes the options
+// to the selected loader, if it accepts them.
+func (mdl *MuxDocumentLoader) LoadDocumentWithOptions(u string, opts *LoadDocumentOptions) (*RemoteDocument, error) {
+	loader, err := mdl.route(u)
+	if err != nil {
+		return nil, err
+	}
+	return loadDocumentWithOptions(loader, u, opts)
+}
+
+// LoadRawDocument works like LoadDocument, but it also returns the raw bytes of the document.
+// It fails if the selected loader doesn't implement RawDocumentLoader.
+func (mdl *MuxDocumentLoader) LoadRawDocument(u string) (*RemoteDocument, []byte, error) {
+	loader, err := mdl.route(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	rawLoader, ok := loader.(RawDocumentLoader)
+	if !ok {
+		return nil, nil, NewJsonLdError(LoadingDocumentFailed,
+			fmt.Sprintf("document loader for URL %s doesn't support raw documents", u))
+	}
+	return rawLoader.LoadRawDocument(u)
+}
+
+func (mdl *MuxDocumentLoader) route(u string) (DocumentLoader, error) {
+	for _, r := range mdl.prefixes {
+		if strings.HasPrefix(u, r.prefix) {
+			return r.loader, nil
+		}
+	}
+
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return nil, NewJsonLdError(LoadingDocumentFailed, fmt.Sprintf("error parsing URL: %s", u))
+	}
+
+	if loader, found := mdl.hosts[strings.ToLower(parsedURL.Hostname())]; found && parsedURL.Host != "" {
+		return loader, nil
+	}
+	if loader, found := mdl.schemes[strings.ToLower(parsedURL.Scheme)]; found {
+		return loader, nil
+	}
+	if mdl.fallback != nil {
+		return mdl.fallback, nil
+	}
+
+	return nil, NewJsonLdError(LoadingDocumentFailed, fmt.Sprintf("no document loader registered for URL: %s", u))
+}
@@ -0,0 +1,57 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type namedLoader string
+
+func (nl namedLoader) LoadDocument(u string) (*RemoteDocument, error) {
+	return &RemoteDocument{DocumentURL: u, Document: string(nl)}, nil
+}
+
+func TestMuxDocumentLoaderRouting(t *testing.T) {
+	dl := NewMuxDocumentLoader(namedLoader("fallback")).
+		HandleScheme("https", namedLoader("https")).
+		HandleHost("Example.org", namedLoader("host")).
+		HandlePrefix("https://example.org/", namedLoader("short-prefix")).
+		HandlePrefix("https://example.org/contexts/", namedLoader("long-prefix"))
+
+	for u, expected := range map[string]string{
+		"https://example.org/contexts/v1.jsonld": "long-prefix",
+		"https://example.org/doc.jsonld":         "short-prefix",
+		"http://EXAMPLE.org/doc.jsonld":          "host",
+		"https://w3id.org/security/v1":           "https",
+		"file:///tmp/doc.jsonld":                 "fallback",
+	} {
+		rd, err := dl.LoadDocument(u)
+		require.NoError(t, err, u)
+		assert.Equal(t, expected, rd.Document, u)
+	}
+}
+
+func TestMuxDocumentLoaderWithoutFallback(t *testing.T) {
+	dl := NewMuxDocumentLoader(nil).HandleScheme("https", namedLoader("https"))
+
+	_, err := dl.LoadDocument("http://example.org/doc.jsonld")
+	require.Error(t, err)
+	assert.Equal(t, LoadingDocumentFailed, err.(*JsonLdError).Code) //nolint:errorlint
+}
@@ -0,0 +1,211 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy defines when and how RetryingDocumentLoader retries failed requests.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries after the first attempt.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries, including delays requested
+	// by the server with a Retry-After header.
+	MaxBackoff time.Duration
+	// Multiplier is the factor the delay grows by after each retry.
+	Multiplier float64
+	// Jitter randomises each backoff delay by up to the given fraction of it in either
+	// direction (0.2 means ±20%), so that clients don't retry in lockstep.
+	// Delays requested by the server with a Retry-After header aren't shortened.
+	Jitter float64
+	// MaxElapsedTime, if greater than zero, limits the total time spent on a document.
+	// No retry is made if waiting for it would exceed the limit.
+	MaxElapsedTime time.Duration
+	// RetryableStatusCodes lists HTTP status codes which are worth retrying.
+	RetryableStatusCodes []int
+	// RetryNetworkErrors enables retries of requests which failed because of network errors.
+	RetryNetworkErrors bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy which retries up to 3 times with
+// exponential backoff, starting at 100ms with 20% jitter, on network errors and
+// on status codes 408, 429, 500, 502, 503 and 504.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries:     3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatusCodes: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryNetworkErrors: true,
+	}
+}
+
+// RetryingDocumentLoader is an overlay on top of DocumentLoader instance
+// which retries transient failures according to a RetryPolicy.
+type RetryingDocumentLoader struct {
+	nextLoader DocumentLoader
+	policy     *RetryPolicy
+	ctx        context.Context
+}
+
+// NewRetryingDocumentLoader creates a new instance of RetryingDocumentLoader.
+// If policy is nil, DefaultRetryPolicy is used.
+func NewRetryingDocumentLoader(nextLoader DocumentLoader, policy *RetryPolicy) *RetryingDocumentLoader {
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+	return &RetryingDocumentLoader{
+		nextLoader: nextLoader,
+		policy:     policy,
+		ctx:        context.Background(),
+	}
+}
+
+// WithContext returns a copy of the loader which stops waiting for the next attempt
+// as soon as the given context is done, for example, to bound the time spent by
+// a single Expand call. Requests in progress are not interrupted by the context.
+func (rdl *RetryingDocumentLoader) WithContext(ctx context.Context) *RetryingDocumentLoader {
+	rval := *rdl
+	rval.ctx = ctx
+	return &rval
+}
+
+// LoadDocument returns a RemoteDocument containing the contents of the JSON resource
+// from the given URL.
+func (rdl *RetryingDocumentLoader) LoadDocument(u string) (*RemoteDocument, error) {
+	return rdl.retry(func() (*RemoteDocument, error) {
+		return rdl.nextLoader.LoadDocument(u)
+	})
+}
+
+// LoadDocumentWithOptions works like LoadDocument, but it also passes the options
+// to the next loader, if it accepts them.
+func (rdl *RetryingDocumentLoader) LoadDocumentWithOptions(u string, opts *LoadDocumentOptions) (*RemoteDocument, error) {
+	return rdl.retry(func() (*RemoteDocument, error) {
+		return loadDocumentWithOptions(rdl.nextLoader, u, opts)
+	})
+}
+
+func (rdl *RetryingDocumentLoader) retry(load func() (*RemoteDocument, error)) (*RemoteDocument, error) {
+	start := time.Now()
+	backoff := rdl.policy.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		rd, err := load()
+		if err == nil || attempt >= rdl.policy.MaxRetries {
+			return rd, err
+		}
+
+		retryable, retryAfter := rdl.policy.isRetryable(err)
+		if !retryable {
+			return nil, err
+		}
+
+		delay := rdl.policy.withJitter(backoff)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if rdl.policy.MaxBackoff > 0 && delay > rdl.policy.MaxBackoff {
+			delay = rdl.policy.MaxBackoff
+		}
+		if rdl.policy.MaxElapsedTime > 0 && time.Since(start)+delay > rdl.policy.MaxElapsedTime {
+			return nil, err
+		}
+		if ctxErr := rdl.wait(delay); ctxErr != nil {
+			return nil, NewJsonLdError(LoadingDocumentFailed, ctxErr)
+		}
+
+		if rdl.policy.Multiplier > 1 {
+			backoff = time.Duration(float64(backoff) * rdl.policy.Multiplier)
+		}
+	}
+}
+
+// wait waits for the given delay, or until the context of the loader is done.
+func (rdl *RetryingDocumentLoader) wait(delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-rdl.ctx.Done():
+		return rdl.ctx.Err()
+	}
+}
+
+// withJitter randomises the delay according to the Jitter of the policy.
+func (p *RetryPolicy) withJitter(delay time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return delay
+	}
+	return time.Duration(float64(delay) * (1 + p.Jitter*(2*rand.Float64()-1))) //nolint:gosec
+}
+
+// isRetryable returns true if the given error is worth retrying,
+// together with the delay requested by the server, if any.
+func (p *RetryPolicy) isRetryable(err error) (bool, time.Duration) {
+	if errors.Is(err, ErrPolicyViolation) {
+		return false, 0
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		for _, code := range p.RetryableStatusCodes {
+			if code == statusErr.StatusCode {
+				return true, parseRetryAfter(statusErr.Header.Get("Retry-After"))
+			}
+		}
+		return false, 0
+	}
+
+	var netErr net.Error
+	if p.RetryNetworkErrors && errors.As(err, &netErr) {
+		return true, 0
+	}
+
+	return false, 0
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
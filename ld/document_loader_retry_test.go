@@ -0,0 +1,120 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFlakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ld+json")
+		_, _ = w.Write([]byte(`{"@id": "http://example.org/doc"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &calls
+}
+
+func fastRetryPolicy() *RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+	return policy
+}
+
+func TestRetryingDocumentLoaderRetriesTransientErrors(t *testing.T) {
+	server, calls := newFlakyServer(t, 2, http.StatusServiceUnavailable)
+
+	dl := NewRetryingDocumentLoader(NewDefaultDocumentLoader(nil), fastRetryPolicy())
+
+	rd, err := dl.LoadDocument(server.URL + "/doc.jsonld")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.org/doc", rd.Document.(map[string]interface{})["@id"])
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestRetryingDocumentLoaderGivesUp(t *testing.T) {
+	server, calls := newFlakyServer(t, 10, http.StatusServiceUnavailable)
+
+	dl := NewRetryingDocumentLoader(NewDefaultDocumentLoader(nil), fastRetryPolicy())
+
+	_, err := dl.LoadDocument(server.URL + "/doc.jsonld")
+	require.Error(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(calls))
+
+	var statusErr *HTTPStatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+}
+
+func TestRetryingDocumentLoaderDoesNotRetryPermanentErrors(t *testing.T) {
+	server, calls := newFlakyServer(t, 10, http.StatusNotFound)
+
+	dl := NewRetryingDocumentLoader(NewDefaultDocumentLoader(nil), fastRetryPolicy())
+
+	_, err := dl.LoadDocument(server.URL + "/doc.jsonld")
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func TestRetryingDocumentLoaderWithContext(t *testing.T) {
+	server, calls := newFlakyServer(t, 10, http.StatusServiceUnavailable)
+
+	policy := fastRetryPolicy()
+	policy.InitialBackoff = time.Hour
+	policy.MaxBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	dl := NewRetryingDocumentLoader(NewDefaultDocumentLoader(nil), policy).WithContext(ctx)
+
+	start := time.Now()
+	_, err := dl.LoadDocument(server.URL + "/doc.jsonld")
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func TestRetryingDocumentLoaderMaxElapsedTime(t *testing.T) {
+	server, calls := newFlakyServer(t, 10, http.StatusServiceUnavailable)
+
+	policy := fastRetryPolicy()
+	policy.InitialBackoff = time.Hour
+	policy.MaxBackoff = time.Hour
+	policy.MaxElapsedTime = time.Second
+	dl := NewRetryingDocumentLoader(NewDefaultDocumentLoader(nil), policy)
+
+	_, err := dl.LoadDocument(server.URL + "/doc.jsonld")
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
@@ -0,0 +1,324 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPolicyViolation is the cause of all errors returned by SecureDocumentLoader
+// when a request violates the configured LoaderPolicy. Use errors.Is to detect it.
+var ErrPolicyViolation = errors.New("document loader policy violation")
+
+// LoaderPolicy defines the restrictions enforced by SecureDocumentLoader.
+type LoaderPolicy struct {
+	// AllowedSchemes lists URL schemes which may be loaded. Only "http", "https"
+	// and "data" are supported. If empty, only "https" is allowed.
+	AllowedSchemes []string
+	// AllowedHosts lists host name patterns which may be loaded, e.g. "example.org"
+	// or "*.example.org". If empty, any host is allowed.
+	AllowedHosts []string
+	// DeniedHosts lists host name patterns which may never be loaded.
+	// Denied hosts take precedence over allowed hosts.
+	DeniedHosts []string
+	// AllowPrivateNetworks disables blocking of loopback, private, link-local and other
+	// non-public addresses. The check is performed at dial time, after name resolution.
+	AllowPrivateNetworks bool
+	// MaxRedirects is the maximum number of HTTP redirects to follow.
+	// Zero disables redirects.
+	MaxRedirects int
+	// MaxResponseSize is the maximum size of a response body in bytes. Zero means no limit.
+	MaxResponseSize int64
+	// AllowedContentTypes lists media type patterns (e.g. "application/ld+json" or
+	// "application/*+json") accepted in successful responses. If empty, any content type is accepted.
+	AllowedContentTypes []string
+}
+
+// DefaultLoaderPolicy returns a LoaderPolicy suitable for loading untrusted documents:
+// HTTPS only, no private networks, up to 5 redirects, up to 10MB per document
+// and JSON content types only.
+func DefaultLoaderPolicy() *LoaderPolicy {
+	return &LoaderPolicy{
+		AllowedSchemes:  []string{"https"},
+		MaxRedirects:    5,
+		MaxResponseSize: 10 << 20,
+		AllowedContentTypes: []string{
+			"application/ld+json",
+			"application/json",
+			"application/*+json",
+		},
+	}
+}
+
+// SecureDocumentLoader is an implementation of DocumentLoader which retrieves
+// documents via HTTP(S) while enforcing a LoaderPolicy. Unlike DefaultDocumentLoader,
+// it never opens local files. Data URLs are only loaded if "data" is one of the allowed schemes.
+//
+// Requests that violate the policy fail with LoadingDocumentFailed, wrapping ErrPolicyViolation.
+type SecureDocumentLoader struct {
+	policy *LoaderPolicy
+	loader *DefaultDocumentLoader
+}
+
+// NewSecureDocumentLoader creates a new instance of SecureDocumentLoader.
+// If policy is nil, DefaultLoaderPolicy is used. The loader uses a copy of httpClient
+// (or http.DefaultClient, if nil) with its transport, dialer and redirect policy
+// replaced to enforce the policy. Proxies are disabled, because dial-time address
+// checks can't see the destination of a proxied request.
+func NewSecureDocumentLoader(policy *LoaderPolicy, httpClient *http.Client) *SecureDocumentLoader {
+	if policy == nil {
+		policy = DefaultLoaderPolicy()
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	var transport *http.Transport
+	if t, ok := httpClient.Transport.(*http.Transport); ok {
+		transport = t.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.Proxy = nil
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   policy.checkDialAddress,
+	}
+	transport.DialContext = dialer.DialContext
+
+	client := *httpClient
+	client.Transport = &policyTransport{policy: policy, base: transport}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > policy.MaxRedirects {
+			return policyViolation("too many redirects (max %d)", policy.MaxRedirects)
+		}
+		return policy.checkURL(req.URL)
+	}
+
+	return &SecureDocumentLoader{
+		policy: policy,
+		loader: NewDefaultDocumentLoader(&client),
+	}
+}
+
+// LoadDocument returns a RemoteDocument containing the contents of the JSON resource
+// from the given URL.
+func (sdl *SecureDocumentLoader) LoadDocument(u string) (*RemoteDocument, error) {
+	remoteDoc, _, err := sdl.loadDocument(u, false, nil)
+	return remoteDoc, err
+}
+
+// LoadDocumentWithOptions works like LoadDocument, but it also sends the requested profiles
+// in the Accept header.
+func (sdl *SecureDocumentLoader) LoadDocumentWithOptions(u string, opts *LoadDocumentOptions) (*RemoteDocument, error) {
+	remoteDoc, _, err := sdl.loadDocument(u, false, opts)
+	return remoteDoc, err
+}
+
+// LoadRawDocument works like LoadDocument, but it also returns the raw bytes of the document.
+func (sdl *SecureDocumentLoader) LoadRawDocument(u string) (*RemoteDocument, []byte, error) {
+	return sdl.loadDocument(u, true, nil)
+}
+
+func (sdl *SecureDocumentLoader) loadDocument(u string, keepRaw bool,
+	opts *LoadDocumentOptions) (*RemoteDocument, []byte, error) {
+
+	// data URLs don't involve any I/O, so only the scheme needs to be checked
+	if isDataURL(u) {
+		if !matchesAny(sdl.policy.AllowedSchemes, "data", strings.EqualFold) {
+			return nil, nil, NewJsonLdError(LoadingDocumentFailed, policyViolation("scheme not allowed: \"data\""))
+		}
+		return loadDataURL(u, keepRaw)
+	}
+
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return nil, nil, NewJsonLdError(LoadingDocumentFailed, fmt.Sprintf("error parsing URL: %s", u))
+	}
+	if err = sdl.policy.checkURL(parsedURL); err != nil {
+		return nil, nil, NewJsonLdError(LoadingDocumentFailed, err)
+	}
+
+	return sdl.loader.loadDocument(u, keepRaw, opts)
+}
+
+func policyViolation(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrPolicyViolation, fmt.Sprintf(format, args...))
+}
+
+// checkURL verifies the scheme and the host of the given URL.
+func (p *LoaderPolicy) checkURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return policyViolation("scheme not supported: %q", u.Scheme)
+	}
+	allowedSchemes := p.AllowedSchemes
+	if len(allowedSchemes) == 0 {
+		allowedSchemes = []string{"https"}
+	}
+	if !matchesAny(allowedSchemes, scheme, strings.EqualFold) {
+		return policyViolation("scheme not allowed: %q", u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return policyViolation("URL has no host: %s", u)
+	}
+	if matchesAny(p.DeniedHosts, host, matchHost) {
+		return policyViolation("host denied: %s", host)
+	}
+	if len(p.AllowedHosts) > 0 && !matchesAny(p.AllowedHosts, host, matchHost) {
+		return policyViolation("host not allowed: %s", host)
+	}
+
+	return nil
+}
+
+// checkDialAddress is used as net.Dialer.Control. It receives resolved IP addresses,
+// which protects against DNS names pointing at internal hosts.
+func (p *LoaderPolicy) checkDialAddress(network, address string, _ syscall.RawConn) error {
+	if p.AllowPrivateNetworks {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return policyViolation("invalid address %q", address)
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return policyViolation("invalid address %q", address)
+	}
+	if !isPublicAddress(addr.Unmap()) {
+		return policyViolation("connection to non-public address %s is not allowed", addr)
+	}
+
+	return nil
+}
+
+func (p *LoaderPolicy) checkContentType(contentType string) error {
+	if len(p.AllowedContentTypes) == 0 {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return policyViolation("invalid content type %q", contentType)
+	}
+	if !matchesAny(p.AllowedContentTypes, mediaType, matchPattern) {
+		return policyViolation("content type not allowed: %s", mediaType)
+	}
+	return nil
+}
+
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPublicAddress(addr netip.Addr) bool {
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+func matchesAny(patterns []string, value string, match func(pattern, value string) bool) bool {
+	for _, pattern := range patterns {
+		if match(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(pattern, value string) bool {
+	matched, err := path.Match(strings.ToLower(pattern), value)
+	return err == nil && matched
+}
+
+func matchHost(pattern, host string) bool {
+	return matchPattern(strings.TrimSuffix(pattern, "."), strings.TrimSuffix(host, "."))
+}
+
+// policyTransport enforces URL, content type and size restrictions on every request,
+// including redirects and alternate links followed by DefaultDocumentLoader.
+type policyTransport struct {
+	policy *LoaderPolicy
+	base   http.RoundTripper
+}
+
+func (pt *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := pt.policy.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	res, err := pt.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusOK {
+		if err = pt.policy.checkContentType(res.Header.Get("Content-Type")); err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+	}
+
+	if maxSize := pt.policy.MaxResponseSize; maxSize > 0 {
+		if res.ContentLength > maxSize {
+			res.Body.Close()
+			return nil, policyViolation("response too large: %d bytes (max %d)", res.ContentLength, maxSize)
+		}
+		res.Body = &limitedBody{ReadCloser: res.Body, remaining: maxSize}
+	}
+
+	return res, nil
+}
+
+// limitedBody fails with a policy violation once more than the allowed number of bytes is read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	if lb.remaining < 0 {
+		return 0, policyViolation("response exceeds size limit")
+	}
+	if int64(len(p)) > lb.remaining+1 {
+		p = p[:lb.remaining+1]
+	}
+	n, err := lb.ReadCloser.Read(p)
+	lb.remaining -= int64(n)
+	if lb.remaining < 0 {
+		return n, policyViolation("response exceeds size limit")
+	}
+	return n, err
+}
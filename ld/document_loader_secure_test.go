@@ -0,0 +1,141 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPolicyTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/doc.jsonld", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/ld+json")
+		_, _ = w.Write([]byte(`{"@id": "http://example.org/doc"}`))
+	})
+	mux.HandleFunc("/big.jsonld", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/ld+json")
+		_, _ = w.Write([]byte(`{"@id": "` + strings.Repeat("a", 1000) + `"}`))
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/doc.jsonld", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func requirePolicyViolation(t *testing.T, err error) {
+	t.Helper()
+
+	require.Error(t, err)
+	assert.Equal(t, LoadingDocumentFailed, err.(*JsonLdError).Code) //nolint:errorlint
+	assert.True(t, errors.Is(err, ErrPolicyViolation), err.Error())
+}
+
+func TestSecureDocumentLoaderRejectsLocalFiles(t *testing.T) {
+	dl := NewSecureDocumentLoader(nil, nil)
+
+	for _, u := range []string{
+		"testdata/expand/0002-in.jsonld",
+		"file:///etc/passwd",
+		"http://example.org/doc.jsonld",
+	} {
+		_, err := dl.LoadDocument(u)
+		requirePolicyViolation(t, err)
+	}
+}
+
+func TestSecureDocumentLoaderBlocksPrivateNetworks(t *testing.T) {
+	server := newPolicyTestServer(t)
+
+	policy := DefaultLoaderPolicy()
+	policy.AllowedSchemes = []string{"http"}
+	dl := NewSecureDocumentLoader(policy, nil)
+
+	_, err := dl.LoadDocument(server.URL + "/doc.jsonld")
+	requirePolicyViolation(t, err)
+	assert.Contains(t, err.Error(), "non-public address")
+
+	policy.AllowPrivateNetworks = true
+	dl = NewSecureDocumentLoader(policy, nil)
+
+	rd, err := dl.LoadDocument(server.URL + "/doc.jsonld")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.org/doc", rd.Document.(map[string]interface{})["@id"])
+}
+
+func TestSecureDocumentLoaderHosts(t *testing.T) {
+	server := newPolicyTestServer(t)
+
+	policy := DefaultLoaderPolicy()
+	policy.AllowedSchemes = []string{"http"}
+	policy.AllowPrivateNetworks = true
+	policy.AllowedHosts = []string{"*.example.org"}
+
+	_, err := NewSecureDocumentLoader(policy, nil).LoadDocument(server.URL + "/doc.jsonld")
+	requirePolicyViolation(t, err)
+	assert.Contains(t, err.Error(), "host not allowed")
+
+	policy.AllowedHosts = nil
+	policy.DeniedHosts = []string{"127.0.0.*"}
+
+	_, err = NewSecureDocumentLoader(policy, nil).LoadDocument(server.URL + "/doc.jsonld")
+	requirePolicyViolation(t, err)
+	assert.Contains(t, err.Error(), "host denied")
+}
+
+func TestSecureDocumentLoaderLimits(t *testing.T) {
+	server := newPolicyTestServer(t)
+
+	policy := DefaultLoaderPolicy()
+	policy.AllowedSchemes = []string{"http"}
+	policy.AllowPrivateNetworks = true
+	policy.MaxResponseSize = 100
+
+	dl := NewSecureDocumentLoader(policy, nil)
+
+	_, err := dl.LoadDocument(server.URL + "/big.jsonld")
+	requirePolicyViolation(t, err)
+
+	_, err = dl.LoadDocument(server.URL + "/page.html")
+	requirePolicyViolation(t, err)
+	assert.Contains(t, err.Error(), "content type not allowed")
+
+	rd, err := dl.LoadDocument(server.URL + "/redirect")
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/doc.jsonld", rd.DocumentURL)
+
+	policy.MaxRedirects = 0
+	dl = NewSecureDocumentLoader(policy, nil)
+
+	_, err = dl.LoadDocument(server.URL + "/redirect")
+	requirePolicyViolation(t, err)
+	assert.Contains(t, err.Error(), "too many redirects")
+}
@@ -16,6 +16,8 @@ package ld_test
 
 import (
 	"bytes"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	. "github.com/piprate/json-gold/ld"
@@ -82,3 +84,78 @@ func TestCachingDocumentLoaderLoadDocument(t *testing.T) {
 
 	assert.Equal(t, "t1", rd.Document.(map[string]interface{})["@type"])
 }
+
+func TestDefaultDocumentLoaderRemoteDocumentFields(t *testing.T) {
+	var acceptHeaders []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptHeaders = append(acceptHeaders, r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", `application/ld+json; profile="http://www.w3.org/ns/json-ld#expanded"`)
+		w.Header().Set("X-Custom", "value")
+		_, _ = w.Write([]byte(`[{"@id": "http://example.org/doc"}]`))
+	}))
+	defer server.Close()
+
+	dl := NewDefaultDocumentLoader(nil)
+
+	rd, err := dl.LoadDocument(server.URL + "/doc.jsonld")
+	require.NoError(t, err)
+	assert.Equal(t, "application/ld+json", rd.ContentType)
+	assert.Equal(t, "http://www.w3.org/ns/json-ld#expanded", rd.Profile)
+	assert.Equal(t, "value", rd.Headers.Get("X-Custom"))
+
+	_, err = dl.LoadDocumentWithOptions(server.URL+"/doc.jsonld", &LoadDocumentOptions{
+		RequestProfile: []string{"http://www.w3.org/ns/json-ld#expanded"},
+	})
+	require.NoError(t, err)
+	require.Len(t, acceptHeaders, 2)
+	assert.NotContains(t, acceptHeaders[0], "profile=")
+	assert.Contains(t, acceptHeaders[1], `application/ld+json;profile="http://www.w3.org/ns/json-ld#expanded"`)
+
+	rd, err = dl.LoadDocument("testdata/expand/0002-in.jsonld")
+	require.NoError(t, err)
+	assert.Equal(t, "application/ld+json", rd.ContentType)
+	assert.Nil(t, rd.Headers)
+}
+
+func TestRFC7324CachingDocumentLoaderWithOptions(t *testing.T) {
+	var acceptHeaders []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptHeaders = append(acceptHeaders, r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "application/ld+json")
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("X-Custom", "value")
+		_, _ = w.Write([]byte(`{"@context": {"name": "http://schema.org/name"}}`))
+	}))
+	defer server.Close()
+
+	dl := NewRFC7324CachingDocumentLoader(nil)
+	opts := &LoadDocumentOptions{RequestProfile: []string{ContextProfile}}
+
+	rd, err := dl.LoadDocumentWithOptions(server.URL+"/context.jsonld", opts)
+	require.NoError(t, err)
+	assert.Equal(t, "value", rd.Headers.Get("X-Custom"))
+	_, err = dl.LoadDocumentWithOptions(server.URL+"/context.jsonld", opts)
+	require.NoError(t, err)
+	_, err = dl.LoadDocument(server.URL + "/context.jsonld")
+	require.NoError(t, err)
+
+	// the second request with the profile is served from the cache
+	require.Len(t, acceptHeaders, 2)
+	assert.Contains(t, acceptHeaders[0], `application/ld+json;profile="`+ContextProfile+`"`)
+	assert.NotContains(t, acceptHeaders[1], "profile=")
+}
+
+func TestExpandRejectsNonJSONContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(`{"@id": "http://example.org/doc"}`))
+	}))
+	defer server.Close()
+
+	opts := NewJsonLdOptions("")
+	opts.DocumentLoader = NewDefaultDocumentLoader(nil)
+
+	_, err := NewJsonLdProcessor().Expand(server.URL+"/doc", opts)
+	require.Error(t, err)
+	assert.Equal(t, LoadingDocumentFailed, err.(*JsonLdError).Code) //nolint:errorlint
+}
@@ -72,20 +72,25 @@ const (
 	ProtectedTermRedefinition   ErrorCode = "protected term redefinition"
 	InvalidContextEntry         ErrorCode = "invalid context entry"
 	InvalidPropagateValue       ErrorCode = "invalid @propagate value"
+	InvalidProtectedValue       ErrorCode = "invalid @protected value"
 	InvalidBaseDirection        ErrorCode = "invalid base direction"
 	InvalidIncludedValue        ErrorCode = "invalid @included value"
 	InvalidImportValue          ErrorCode = "invalid @import value"
 	IRIConfusedWithPrefix       ErrorCode = "IRI confused with prefix"
 
 	// non spec related errors
-	SyntaxError     ErrorCode = "syntax error"
-	NotImplemented  ErrorCode = "not implemented"
-	UnknownFormat   ErrorCode = "unknown format"
-	InvalidInput    ErrorCode = "invalid input"
-	ParseError      ErrorCode = "parse error"
-	IOError         ErrorCode = "io error"
-	InvalidProperty ErrorCode = "invalid property"
-	UnknownError    ErrorCode = "unknown error"
+	SyntaxError                   ErrorCode = "syntax error"
+	NotImplemented                ErrorCode = "not implemented"
+	UnknownFormat                 ErrorCode = "unknown format"
+	InvalidInput                  ErrorCode = "invalid input"
+	ParseError                    ErrorCode = "parse error"
+	IOError                       ErrorCode = "io error"
+	InvalidProperty               ErrorCode = "invalid property"
+	UnknownError                  ErrorCode = "unknown error"
+	IntegrityCheckFailed          ErrorCode = "integrity check failed"
+	InvalidContextSnapshot        ErrorCode = "invalid context snapshot"
+	UnknownMessageDigestAlgorithm ErrorCode = "unknown message digest algorithm"
+	InvalidContentID              ErrorCode = "invalid content identifier"
 )
 
 func (e JsonLdError) Error() string {
@@ -0,0 +1,451 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"fmt"
+)
+
+// ExpandedObject is an object of an expanded JSON-LD document:
+// *NodeObject, *ValueObject, *ListObject, *SetObject or *GraphObject.
+// See https://www.w3.org/TR/json-ld11/#expanded-document-form
+type ExpandedObject interface {
+	// AsMap returns the object in the map[string]interface{} form used by JsonLdProcessor.
+	AsMap() map[string]interface{}
+
+	expandedObject()
+}
+
+// NodeObject represents a node object in expanded form.
+type NodeObject struct {
+	// ID is the node identifier (an IRI or a blank node identifier); empty if the node has no @id.
+	ID string
+	// EmptyID is true if the node has an empty @id, which expansion produces
+	// for a relative IRI if there is no base IRI.
+	EmptyID bool
+	// NullID is true if the node has a null @id, which JSON-LD 1.0 expansion produces
+	// for values which look like keywords.
+	NullID bool
+	// Types holds the values of @type. It's nil if the node has no @type
+	// and empty if @type is an empty array.
+	Types []string
+	// Properties maps property IRIs to their values.
+	Properties map[string][]ExpandedObject
+	// Reverse maps reverse property IRIs to the nodes which refer to this node.
+	Reverse map[string][]*NodeObject
+	// Graph holds the nodes of the named graph identified by this node, if any.
+	Graph []*NodeObject
+	// Included holds the nodes of @included.
+	Included []*NodeObject
+	// Index is the value of @index.
+	Index string
+	// EmptyIndex is true if the node has an empty @index.
+	EmptyIndex bool
+}
+
+// ValueObject represents a value object in expanded form.
+type ValueObject struct {
+	// Value is a string, a bool, a number (float64 or json.Number) or, if Type is @json, any JSON value.
+	Value     interface{}
+	Type      string
+	Language  string
+	Direction string
+	Index     string
+	// EmptyLanguage is true if the value has an empty @language.
+	EmptyLanguage bool
+	// EmptyIndex is true if the value has an empty @index.
+	EmptyIndex bool
+}
+
+// ListObject represents a list object in expanded form.
+type ListObject struct {
+	List  []ExpandedObject
+	Index string
+	// EmptyIndex is true if the list has an empty @index.
+	EmptyIndex bool
+}
+
+// SetObject represents a set object. Expansion replaces sets with their contents,
+// so set objects appear only in documents which haven't been fully expanded.
+type SetObject struct {
+	Set   []ExpandedObject
+	Index string
+	// EmptyIndex is true if the set has an empty @index.
+	EmptyIndex bool
+}
+
+// GraphObject represents a graph object in expanded form, i.e. a value of a property
+// with a @graph container.
+type GraphObject struct {
+	// ID is the graph name; empty for simple graph objects.
+	ID string
+	// EmptyID is true if the graph object has an empty @id.
+	EmptyID bool
+	Graph   []*NodeObject
+	Index   string
+	// EmptyIndex is true if the graph object has an empty @index.
+	EmptyIndex bool
+}
+
+func (*NodeObject) expandedObject()  {}
+func (*ValueObject) expandedObject() {}
+func (*ListObject) expandedObject()  {}
+func (*SetObject) expandedObject()   {}
+func (*GraphObject) expandedObject() {}
+
+// ExpandTyped works like Expand, but it returns typed objects.
+func (jldp *JsonLdProcessor) ExpandTyped(input interface{}, opts *JsonLdOptions) ([]ExpandedObject, error) {
+	expanded, err := jldp.Expand(input, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ToExpandedObjects(expanded)
+}
+
+// FlattenTyped flattens the given input, like Flatten with a nil context, and returns typed nodes.
+// Nodes of named graphs are found in the Graph field of the node which names the graph.
+func (jldp *JsonLdProcessor) FlattenTyped(input interface{}, opts *JsonLdOptions) ([]*NodeObject, error) {
+	flattened, err := jldp.Flatten(input, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	list, isList := flattened.([]interface{})
+	if !isList {
+		return nil, NewJsonLdError(InvalidInput, "flattened document is not an array")
+	}
+	return toNodeObjects(list)
+}
+
+// ToExpandedObjects converts an expanded document, as returned by JsonLdProcessor.Expand,
+// into typed objects. The conversion is lossless: FromExpandedObjects returns the original document.
+func ToExpandedObjects(expanded []interface{}) ([]ExpandedObject, error) {
+	return toExpandedObjects(expanded)
+}
+
+// ToExpandedObject converts a single object of an expanded document into a typed object.
+func ToExpandedObject(v interface{}) (ExpandedObject, error) {
+	m, isMap := v.(map[string]interface{})
+	if !isMap {
+		return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("expanded object must be a map, got %T", v))
+	}
+
+	if value, hasValue := m["@value"]; hasValue {
+		return toValueObject(m, value)
+	}
+	if list, hasList := m["@list"]; hasList {
+		items, err := toExpandedObjects(Arrayify(list))
+		if err != nil {
+			return nil, err
+		}
+		lo := &ListObject{List: items}
+		if err := checkKeys(m, &lo.Index, &lo.EmptyIndex, "@list"); err != nil {
+			return nil, err
+		}
+		return lo, nil
+	}
+	if set, hasSet := m["@set"]; hasSet {
+		items, err := toExpandedObjects(Arrayify(set))
+		if err != nil {
+			return nil, err
+		}
+		so := &SetObject{Set: items}
+		if err := checkKeys(m, &so.Index, &so.EmptyIndex, "@set"); err != nil {
+			return nil, err
+		}
+		return so, nil
+	}
+	if IsGraph(m) {
+		return toGraphObject(m)
+	}
+	return toNodeObject(m)
+}
+
+// FromExpandedObjects converts typed objects into an expanded document.
+func FromExpandedObjects(objects []ExpandedObject) []interface{} {
+	res := make([]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		res = append(res, obj.AsMap())
+	}
+	return res
+}
+
+func toExpandedObjects(list []interface{}) ([]ExpandedObject, error) {
+	res := make([]ExpandedObject, 0, len(list))
+	for _, item := range list {
+		obj, err := ToExpandedObject(item)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, obj)
+	}
+	return res, nil
+}
+
+func toNodeObjects(list []interface{}) ([]*NodeObject, error) {
+	res := make([]*NodeObject, 0, len(list))
+	for _, item := range list {
+		m, isMap := item.(map[string]interface{})
+		if !isMap {
+			return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("node object must be a map, got %T", item))
+		}
+		node, err := toNodeObject(m)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, node)
+	}
+	return res, nil
+}
+
+func toValueObject(m map[string]interface{}, value interface{}) (*ValueObject, error) {
+	vo := &ValueObject{Value: value}
+	for k, v := range m {
+		var target *string
+		switch k {
+		case "@value":
+			continue
+		case "@type":
+			target = &vo.Type
+		case "@language":
+			target = &vo.Language
+		case "@direction":
+			target = &vo.Direction
+		case "@index":
+			target = &vo.Index
+		default:
+			return nil, NewJsonLdError(InvalidValueObject, fmt.Sprintf("unexpected key %s in value object", k))
+		}
+		s, isString := v.(string)
+		if !isString {
+			return nil, NewJsonLdError(InvalidValueObject, fmt.Sprintf("%s must be a string, got %T", k, v))
+		}
+		*target = s
+		if s == "" {
+			switch k {
+			case "@type":
+				return nil, NewJsonLdError(InvalidTypedValue, "@type must not be empty")
+			case "@direction":
+				return nil, NewJsonLdError(InvalidBaseDirection, "@direction must be \"ltr\" or \"rtl\"")
+			case "@language":
+				vo.EmptyLanguage = true
+			case "@index":
+				vo.EmptyIndex = true
+			}
+		}
+	}
+	return vo, nil
+}
+
+func toGraphObject(m map[string]interface{}) (*GraphObject, error) {
+	nodes, err := toNodeObjects(Arrayify(m["@graph"]))
+	if err != nil {
+		return nil, err
+	}
+	g := &GraphObject{Graph: nodes}
+	if id, hasID := m["@id"]; hasID {
+		if g.ID, err = stringValue("@id", id); err != nil {
+			return nil, err
+		}
+		g.EmptyID = g.ID == ""
+	}
+	if err := checkKeys(m, &g.Index, &g.EmptyIndex, "@graph", "@id"); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func toNodeObject(m map[string]interface{}) (*NodeObject, error) {
+	node := &NodeObject{}
+	var err error
+	for k, v := range m {
+		switch k {
+		case "@id":
+			if v == nil {
+				node.NullID = true
+				continue
+			}
+			node.ID, err = stringValue(k, v)
+			node.EmptyID = err == nil && node.ID == ""
+		case "@index":
+			node.Index, err = stringValue(k, v)
+			node.EmptyIndex = err == nil && node.Index == ""
+		case "@type":
+			types := Arrayify(v)
+			node.Types = make([]string, 0, len(types))
+			for _, t := range types {
+				var typeStr string
+				if typeStr, err = stringValue(k, t); err != nil {
+					break
+				}
+				node.Types = append(node.Types, typeStr)
+			}
+		case "@graph":
+			node.Graph, err = toNodeObjects(Arrayify(v))
+		case "@included":
+			node.Included, err = toNodeObjects(Arrayify(v))
+		case "@reverse":
+			reverseMap, isMap := v.(map[string]interface{})
+			if !isMap {
+				return nil, NewJsonLdError(InvalidReversePropertyMap, fmt.Sprintf("@reverse must be a map, got %T", v))
+			}
+			node.Reverse = make(map[string][]*NodeObject, len(reverseMap))
+			for prop, val := range reverseMap {
+				if node.Reverse[prop], err = toNodeObjects(Arrayify(val)); err != nil {
+					break
+				}
+			}
+		default:
+			if IsKeyword(k) {
+				return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("unexpected keyword %s in node object", k))
+			}
+			if node.Properties == nil {
+				node.Properties = make(map[string][]ExpandedObject)
+			}
+			node.Properties[k], err = toExpandedObjects(Arrayify(v))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// checkKeys makes sure the map has no keys other than the allowed ones and @index,
+// which is stored in index (emptyIndex is set if it's an empty string).
+func checkKeys(m map[string]interface{}, index *string, emptyIndex *bool, allowed ...string) error {
+	for k, v := range m {
+		if k == "@index" {
+			s, err := stringValue(k, v)
+			if err != nil {
+				return err
+			}
+			*index = s
+			*emptyIndex = s == ""
+			continue
+		}
+		isAllowed := false
+		for _, a := range allowed {
+			if k == a {
+				isAllowed = true
+				break
+			}
+		}
+		if !isAllowed {
+			return NewJsonLdError(InvalidInput, fmt.Sprintf("unexpected key %s in %s object", k, allowed[0]))
+		}
+	}
+	return nil
+}
+
+func stringValue(key string, v interface{}) (string, error) {
+	s, isString := v.(string)
+	if !isString {
+		return "", NewJsonLdError(InvalidInput, fmt.Sprintf("%s must be a string, got %T", key, v))
+	}
+	return s, nil
+}
+
+// AsMap returns the node in the map[string]interface{} form used by JsonLdProcessor.
+func (n *NodeObject) AsMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(n.Properties)+2)
+	if n.ID != "" || n.EmptyID {
+		m["@id"] = n.ID
+	} else if n.NullID {
+		m["@id"] = nil
+	}
+	if n.Types != nil {
+		types := make([]interface{}, 0, len(n.Types))
+		for _, t := range n.Types {
+			types = append(types, t)
+		}
+		m["@type"] = types
+	}
+	for prop, values := range n.Properties {
+		m[prop] = FromExpandedObjects(values)
+	}
+	if n.Reverse != nil {
+		reverseMap := make(map[string]interface{}, len(n.Reverse))
+		for prop, nodes := range n.Reverse {
+			reverseMap[prop] = nodesAsList(nodes)
+		}
+		m["@reverse"] = reverseMap
+	}
+	if n.Graph != nil {
+		m["@graph"] = nodesAsList(n.Graph)
+	}
+	if n.Included != nil {
+		m["@included"] = nodesAsList(n.Included)
+	}
+	if n.Index != "" || n.EmptyIndex {
+		m["@index"] = n.Index
+	}
+	return m
+}
+
+// AsMap returns the value in the map[string]interface{} form used by JsonLdProcessor.
+func (v *ValueObject) AsMap() map[string]interface{} {
+	m := map[string]interface{}{"@value": v.Value}
+	if v.Type != "" {
+		m["@type"] = v.Type
+	}
+	if v.Language != "" || v.EmptyLanguage {
+		m["@language"] = v.Language
+	}
+	if v.Direction != "" {
+		m["@direction"] = v.Direction
+	}
+	if v.Index != "" || v.EmptyIndex {
+		m["@index"] = v.Index
+	}
+	return m
+}
+
+// AsMap returns the list in the map[string]interface{} form used by JsonLdProcessor.
+func (l *ListObject) AsMap() map[string]interface{} {
+	m := map[string]interface{}{"@list": FromExpandedObjects(l.List)}
+	if l.Index != "" || l.EmptyIndex {
+		m["@index"] = l.Index
+	}
+	return m
+}
+
+// AsMap returns the set in the map[string]interface{} form used by JsonLdProcessor.
+func (s *SetObject) AsMap() map[string]interface{} {
+	m := map[string]interface{}{"@set": FromExpandedObjects(s.Set)}
+	if s.Index != "" || s.EmptyIndex {
+		m["@index"] = s.Index
+	}
+	return m
+}
+
+// AsMap returns the graph object in the map[string]interface{} form used by JsonLdProcessor.
+func (g *GraphObject) AsMap() map[string]interface{} {
+	m := map[string]interface{}{"@graph": nodesAsList(g.Graph)}
+	if g.ID != "" || g.EmptyID {
+		m["@id"] = g.ID
+	}
+	if g.Index != "" || g.EmptyIndex {
+		m["@index"] = g.Index
+	}
+	return m
+}
+
+func nodesAsList(nodes []*NodeObject) []interface{} {
+	res := make([]interface{}, 0, len(nodes))
+	for _, n := range nodes {
+		res = append(res, n.AsMap())
+	}
+	return res
+}
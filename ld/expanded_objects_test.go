@@ -0,0 +1,161 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandedObjectsRoundTrip(t *testing.T) {
+	// every expected output of the expansion test suite must survive the conversion
+	files, err := filepath.Glob(filepath.Join("testdata", "expand", "*-out.jsonld"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		f, err := os.Open(file)
+		require.NoError(t, err)
+		doc, err := DocumentFromReader(f)
+		_ = f.Close()
+		require.NoError(t, err, file)
+
+		expanded, isList := doc.([]interface{})
+		if !isList {
+			continue
+		}
+		objects, err := ToExpandedObjects(expanded)
+		if !assert.NoError(t, err, file) {
+			continue
+		}
+		assert.Equal(t, expanded, FromExpandedObjects(objects), file)
+	}
+}
+
+func TestExpandTyped(t *testing.T) {
+	doc := map[string]interface{}{
+		"@context": map[string]interface{}{
+			"@vocab": "http://schema.org/",
+			"tags":   map[string]interface{}{"@container": "@list"},
+		},
+		"@id":   "http://example.org/jane",
+		"@type": "Person",
+		"name":  map[string]interface{}{"@value": "Jane", "@language": "en"},
+		"knows": map[string]interface{}{"@id": "http://example.org/john"},
+		"tags":  []interface{}{"a", "b"},
+	}
+
+	objects, err := NewJsonLdProcessor().ExpandTyped(doc, nil)
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+
+	node, isNode := objects[0].(*NodeObject)
+	require.True(t, isNode)
+	assert.Equal(t, "http://example.org/jane", node.ID)
+	assert.Equal(t, []string{"http://schema.org/Person"}, node.Types)
+
+	require.Len(t, node.Properties["http://schema.org/name"], 1)
+	name := node.Properties["http://schema.org/name"][0].(*ValueObject)
+	assert.Equal(t, &ValueObject{Value: "Jane", Language: "en"}, name)
+
+	knows := node.Properties["http://schema.org/knows"][0].(*NodeObject)
+	assert.Equal(t, "http://example.org/john", knows.ID)
+
+	tags := node.Properties["http://schema.org/tags"][0].(*ListObject)
+	assert.Equal(t, []ExpandedObject{&ValueObject{Value: "a"}, &ValueObject{Value: "b"}}, tags.List)
+}
+
+func TestFlattenTyped(t *testing.T) {
+	doc := map[string]interface{}{
+		"@context": map[string]interface{}{"@vocab": "http://schema.org/"},
+		"@id":      "http://example.org/jane",
+		"knows": map[string]interface{}{
+			"@id":  "http://example.org/john",
+			"name": "John",
+		},
+	}
+
+	nodes, err := NewJsonLdProcessor().FlattenTyped(doc, nil)
+	require.NoError(t, err)
+	require.Len(t, nodes, 2)
+	assert.Equal(t, "http://example.org/jane", nodes[0].ID)
+	assert.Equal(t, []ExpandedObject{&NodeObject{ID: "http://example.org/john"}},
+		nodes[0].Properties["http://schema.org/knows"])
+	assert.Equal(t, "http://example.org/john", nodes[1].ID)
+}
+
+func TestExpandedObjectsEmptyValues(t *testing.T) {
+	doc := map[string]interface{}{
+		"@id":    "",
+		"@type":  []interface{}{},
+		"@index": "",
+		"http://example.org/p": []interface{}{
+			map[string]interface{}{"@value": "x", "@language": "", "@index": ""},
+			map[string]interface{}{"@list": []interface{}{}, "@index": ""},
+			map[string]interface{}{"@graph": []interface{}{}, "@id": "", "@index": ""},
+		},
+	}
+
+	expanded, err := NewJsonLdProcessor().Expand(doc, nil)
+	require.NoError(t, err)
+	objects, err := ToExpandedObjects(expanded)
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+
+	node := objects[0].(*NodeObject)
+	assert.True(t, node.EmptyID)
+	assert.True(t, node.EmptyIndex)
+	assert.NotNil(t, node.Types)
+	assert.Empty(t, node.Types)
+
+	m := node.AsMap()
+	assert.Equal(t, "", m["@id"])
+	assert.Equal(t, "", m["@index"])
+	assert.Equal(t, []interface{}{}, m["@type"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"@value": "x", "@language": "", "@index": ""},
+		map[string]interface{}{"@list": []interface{}{}, "@index": ""},
+		map[string]interface{}{"@graph": []interface{}{}, "@id": "", "@index": ""},
+	}, m["http://example.org/p"])
+
+	// objects without the keys don't get them
+	assert.Equal(t, map[string]interface{}{}, (&NodeObject{}).AsMap())
+}
+
+func TestToExpandedObjectErrors(t *testing.T) {
+	for name, obj := range map[string]interface{}{
+		"not a map":          "http://example.org/",
+		"non-string @id":     map[string]interface{}{"@id": 5.0},
+		"non-string @type":   map[string]interface{}{"@type": []interface{}{true}},
+		"bad value object":   map[string]interface{}{"@value": "x", "@id": "http://example.org/"},
+		"bad reverse":        map[string]interface{}{"@reverse": "x"},
+		"bad list index":     map[string]interface{}{"@list": []interface{}{}, "@index": 1.0},
+		"bad property value": map[string]interface{}{"http://example.org/p": []interface{}{"x"}},
+		"empty value type":   map[string]interface{}{"@value": "x", "@type": ""},
+		"empty direction":    map[string]interface{}{"@value": "x", "@direction": ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ToExpandedObject(obj)
+			require.Error(t, err)
+			_, isJSONLDError := err.(*JsonLdError) //nolint:errorlint
+			assert.True(t, isJSONLDError)
+		})
+	}
+}
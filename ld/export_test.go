@@ -0,0 +1,43 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import "testing"
+
+// RestoreMessageDigestsOnCleanup snapshots the message digest and multihash code
+// registries and restores them when the test finishes, so tests which register
+// algorithms don't leak them into other tests.
+func RestoreMessageDigestsOnCleanup(t testing.TB) {
+	t.Helper()
+
+	messageDigestsMu.RLock()
+	digests := make(map[MessageDigestAlgorithm]HashFactory, len(messageDigests))
+	for name, factory := range messageDigests {
+		digests[name] = factory
+	}
+	codes := make(map[MessageDigestAlgorithm]uint64, len(multihashCodes))
+	for name, code := range multihashCodes {
+		codes[name] = code
+	}
+	messageDigestsMu.RUnlock()
+
+	t.Cleanup(func() {
+		messageDigestsMu.Lock()
+		defer messageDigestsMu.Unlock()
+
+		messageDigests = digests
+		multihashCodes = codes
+	})
+}
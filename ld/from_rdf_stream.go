@@ -0,0 +1,216 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// FromRDFStream reads N-Quads from r and writes them to w as JSON-LD, one node object per subject,
+// in the form described by the JSON-LD Streaming note (https://w3c.github.io/json-ld-streaming/).
+// Only the quads of the current subject are kept in memory.
+//
+// If context is nil, the output is an expanded JSON-LD document: an array of node objects.
+// Otherwise the nodes are compacted using the context and the output is a JSON object with
+// the @context entry followed by @graph (or its alias).
+//
+// The input is expected to be sorted (or at least grouped) by graph and subject. If the quads
+// of a subject are spread across the input, the subject is written as several node objects
+// with the same @id, which is still valid JSON-LD. The quads of named graphs are wrapped in
+// {"@id": graphName, "@graph": [...]} objects.
+//
+// As lists span several subjects, rdf:first and rdf:rest statements aren't converted to @list
+// objects. The useRdfType and useNativeTypes options are supported.
+func (jldp *JsonLdProcessor) FromRDFStream(r io.Reader, w io.Writer, context interface{}, opts *JsonLdOptions) error {
+
+	opts = jldp.prepareOptions(opts)
+
+	s := &jsonLdStreamWriter{
+		w:    w,
+		api:  NewJsonLdApi(),
+		opts: opts,
+	}
+
+	if err := s.writeStart(context); err != nil {
+		return err
+	}
+
+	var (
+		node           *NodeMapNode
+		nodeGraph      string
+		nodeTriples    []*Quad
+		currentSubject string
+	)
+	err := scanNQuads(r, func(triple *Quad, graphName string) error {
+		subject := triple.Subject.GetValue()
+		if node != nil && (subject != currentSubject || graphName != nodeGraph) {
+			if err := s.writeNode(node, nodeGraph); err != nil {
+				return err
+			}
+			node = nil
+		}
+		if node == nil {
+			node = NewNodeMapNode(subject)
+			nodeGraph = graphName
+			nodeTriples = nodeTriples[:0]
+			currentSubject = subject
+		}
+
+		// skip duplicate statements
+		for _, t := range nodeTriples {
+			if triple.Equal(t) {
+				return nil
+			}
+		}
+		nodeTriples = append(nodeTriples, triple)
+
+		return addTripleToNode(node, triple, opts)
+	})
+	if err != nil {
+		return err
+	}
+	if node != nil {
+		if err := s.writeNode(node, nodeGraph); err != nil {
+			return err
+		}
+	}
+
+	return s.writeEnd()
+}
+
+// addTripleToNode adds the given triple to the node, following steps 3.5.4-3.5.7
+// of the Serialize RDF as JSON-LD algorithm.
+func addTripleToNode(node *NodeMapNode, triple *Quad, opts *JsonLdOptions) error {
+	predicate := triple.Predicate.GetValue()
+	object := triple.Object
+
+	if predicate == RDFType && (IsIRI(object) || IsBlankNode(object)) && !opts.UseRdfType {
+		MergeValue(node.Values, "@type", object.GetValue())
+		return nil
+	}
+
+	value, err := RdfToObject(object, opts.UseNativeTypes)
+	if err != nil {
+		return err
+	}
+	MergeValue(node.Values, predicate, value)
+	return nil
+}
+
+type jsonLdStreamWriter struct {
+	w         io.Writer
+	api       *JsonLdApi
+	opts      *JsonLdOptions
+	activeCtx *Context
+	buf       bytes.Buffer
+	count     int
+}
+
+func (s *jsonLdStreamWriter) writeStart(context interface{}) error {
+	if context == nil {
+		return s.write([]byte("["))
+	}
+
+	context = CloneDocument(context)
+	if contextMap, isMap := context.(map[string]interface{}); isMap {
+		if innerCtx, hasCtx := contextMap["@context"]; hasCtx {
+			context = innerCtx
+		}
+	}
+	activeCtx, err := NewContext(nil, s.opts).Parse(context)
+	if err != nil {
+		return err
+	}
+	s.activeCtx = activeCtx
+	// the output must contain the original context, not the compiled one
+	if compiledCtx, isCompiled := context.(*CompiledContext); isCompiled {
+		context = compiledCtx.Source()
+	}
+
+	graphAlias, err := activeCtx.CompactIri("@graph", nil, false, false)
+	if err != nil {
+		return err
+	}
+
+	s.buf.Reset()
+	s.buf.WriteString(`{"@context":`)
+	if err := s.encode(context); err != nil {
+		return err
+	}
+	s.buf.WriteString(",")
+	if err := s.encode(graphAlias); err != nil {
+		return err
+	}
+	s.buf.WriteString(":[")
+	return s.write(s.buf.Bytes())
+}
+
+func (s *jsonLdStreamWriter) writeNode(node *NodeMapNode, graphName string) error {
+	var obj interface{} = node.Serialize()
+	if graphName != "@default" {
+		obj = map[string]interface{}{
+			"@id":    graphName,
+			"@graph": []interface{}{obj},
+		}
+	}
+
+	if s.activeCtx != nil {
+		compacted, err := s.api.Compact(s.activeCtx, "", obj, s.opts.CompactArrays)
+		if err != nil {
+			return err
+		}
+		obj = compacted
+	}
+
+	s.buf.Reset()
+	if s.count > 0 {
+		s.buf.WriteString(",")
+	}
+	s.buf.WriteString("\n")
+	if err := s.encode(obj); err != nil {
+		return err
+	}
+	s.count++
+	return s.write(s.buf.Bytes())
+}
+
+func (s *jsonLdStreamWriter) writeEnd() error {
+	end := "\n]"
+	if s.activeCtx != nil {
+		end += "}"
+	}
+	return s.write([]byte(end + "\n"))
+}
+
+// encode appends the JSON encoding of v to the buffer.
+func (s *jsonLdStreamWriter) encode(v interface{}) error {
+	enc := json.NewEncoder(&s.buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return NewJsonLdError(InvalidInput, err)
+	}
+	// drop the newline added by Encode
+	s.buf.Truncate(s.buf.Len() - 1)
+	return nil
+}
+
+func (s *jsonLdStreamWriter) write(data []byte) error {
+	if _, err := s.w.Write(data); err != nil {
+		return NewJsonLdError(IOError, err)
+	}
+	return nil
+}
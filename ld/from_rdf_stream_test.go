@@ -0,0 +1,204 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromRDFStreamRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "fromRdf", "*-in.nq"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	proc := NewJsonLdProcessor()
+	toRDFOpts := NewJsonLdOptions("")
+	toRDFOpts.Format = "application/n-quads"
+
+	compared := 0
+	for _, file := range files {
+		input, err := os.ReadFile(file)
+		require.NoError(t, err)
+		if _, err := ParseNQuads(string(input)); err != nil {
+			continue
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, proc.FromRDFStream(bytes.NewReader(input), &buf, nil, nil), file)
+
+		// the output must be valid JSON-LD which represents the same dataset
+		doc, err := DocumentFromReader(&buf)
+		require.NoError(t, err, file)
+		output, err := proc.ToRDF(doc, toRDFOpts)
+		if err != nil {
+			// a few inputs contain statements JSON-LD can't represent (e.g. relative IRIs)
+			continue
+		}
+		// skip the inputs FromRDF can't represent without losing information either
+		// (for example, rdf:type rdf:List statements are dropped when converting lists)
+		fromRDF, err := proc.FromRDF(string(input), nil)
+		require.NoError(t, err, file)
+		roundTrip, err := proc.ToRDF(fromRDF, toRDFOpts)
+		require.NoError(t, err, file)
+		expected := canonicalNQuads(t, string(input))
+		if canonicalNQuads(t, roundTrip.(string)) != expected {
+			continue
+		}
+
+		assert.Equal(t, expected, canonicalNQuads(t, output.(string)), file)
+		compared++
+	}
+	assert.Greater(t, compared, 20)
+}
+
+func TestFromRDFStreamExpanded(t *testing.T) {
+	input := `<http://example.org/jane> <http://schema.org/name> "Jane" .
+<http://example.org/jane> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://schema.org/Person> .
+<http://example.org/jane> <http://schema.org/name> "Jane" .
+<http://example.org/jane> <http://schema.org/knows> _:b0 <http://example.org/g> .
+_:b0 <http://schema.org/name> "John"@en .
+`
+	var buf bytes.Buffer
+	require.NoError(t, NewJsonLdProcessor().FromRDFStream(strings.NewReader(input), &buf, nil, nil))
+
+	var actual interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &actual))
+	expected := []interface{}{
+		map[string]interface{}{
+			"@id":                    "http://example.org/jane",
+			"@type":                  []interface{}{"http://schema.org/Person"},
+			"http://schema.org/name": []interface{}{map[string]interface{}{"@value": "Jane"}},
+		},
+		map[string]interface{}{
+			"@id": "http://example.org/g",
+			"@graph": []interface{}{
+				map[string]interface{}{
+					"@id":                     "http://example.org/jane",
+					"http://schema.org/knows": []interface{}{map[string]interface{}{"@id": "_:b0"}},
+				},
+			},
+		},
+		map[string]interface{}{
+			"@id": "_:b0",
+			"http://schema.org/name": []interface{}{
+				map[string]interface{}{"@value": "John", "@language": "en"},
+			},
+		},
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestFromRDFStreamCompacted(t *testing.T) {
+	input := `<http://example.org/jane> <http://schema.org/name> "Jane" .
+<http://example.org/jane> <http://schema.org/age> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/john> <http://schema.org/name> "John" .
+`
+	context := map[string]interface{}{
+		"@context": map[string]interface{}{
+			"@vocab": "http://schema.org/",
+			"nodes":  "@graph",
+		},
+	}
+
+	opts := NewJsonLdOptions("")
+	opts.UseNativeTypes = true
+
+	var buf bytes.Buffer
+	require.NoError(t, NewJsonLdProcessor().FromRDFStream(strings.NewReader(input), &buf, context, opts))
+
+	// @context comes first, followed by the nodes
+	assert.True(t, strings.HasPrefix(buf.String(), `{"@context":{"@vocab":"http://schema.org/","nodes":"@graph"},"nodes":[`))
+
+	var actual interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &actual))
+	expected := map[string]interface{}{
+		"@context": context["@context"],
+		"nodes": []interface{}{
+			map[string]interface{}{"@id": "http://example.org/jane", "name": "Jane", "age": float64(42)},
+			map[string]interface{}{"@id": "http://example.org/john", "name": "John"},
+		},
+	}
+	assert.Equal(t, expected, actual)
+
+	// an empty input still produces a valid document
+	buf.Reset()
+	require.NoError(t, NewJsonLdProcessor().FromRDFStream(strings.NewReader(""), &buf, context, opts))
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &actual))
+	assert.Equal(t, []interface{}{}, actual.(map[string]interface{})["nodes"])
+}
+
+// chanWriter passes every write to a channel.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestFromRDFStreamIsIncremental(t *testing.T) {
+	pr, pw := io.Pipe()
+	out := make(chanWriter, 1000)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- NewJsonLdProcessor().FromRDFStream(pr, out, nil, nil)
+	}()
+
+	assert.Equal(t, "[", <-out)
+	for i := 0; i < 100; i++ {
+		_, err := fmt.Fprintf(pw, "<http://example.org/%d> <http://schema.org/name> \"Node %d\" .\n", i, i)
+		require.NoError(t, err)
+
+		if i > 0 {
+			// the previous subject must have been written before the rest of the input arrived
+			select {
+			case data := <-out:
+				assert.Contains(t, data, fmt.Sprintf(`"@id":"http://example.org/%d"`, i-1))
+			case <-time.After(5 * time.Second):
+				t.Fatalf("subject %d wasn't written before the end of the input", i-1)
+			}
+		}
+	}
+	require.NoError(t, pw.Close())
+	require.NoError(t, <-done)
+
+	assert.Contains(t, <-out, `"@id":"http://example.org/99"`)
+	assert.Equal(t, "\n]\n", <-out)
+}
+
+func TestFromRDFStreamErrors(t *testing.T) {
+	proc := NewJsonLdProcessor()
+
+	var buf bytes.Buffer
+	err := proc.FromRDFStream(strings.NewReader("<http://example.org/a> invalid .\n"), &buf, nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, SyntaxError, err.(*JsonLdError).Code) //nolint:errorlint
+
+	err = proc.FromRDFStream(strings.NewReader(""), &buf, map[string]interface{}{"@context": 5.0}, nil)
+	require.Error(t, err)
+	assert.Equal(t, InvalidLocalContext, err.(*JsonLdError).Code) //nolint:errorlint
+}
@@ -0,0 +1,156 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+)
+
+// The fuzz targets below check that processing arbitrary input never panics and that
+// every failure is reported as a JsonLdError. Inputs which used to crash the processor
+// are kept in testdata/fuzz and run as regression tests by "go test".
+//
+// To look for new crashers, run e.g.:
+//
+//	go test -run '^$' -fuzz FuzzExpand ./ld
+
+// offlineLoader makes sure the fuzz targets never access the network.
+type offlineLoader struct{}
+
+func (offlineLoader) LoadDocument(u string) (*RemoteDocument, error) {
+	return nil, NewJsonLdError(LoadingDocumentFailed, "documents can't be loaded while fuzzing")
+}
+
+func fuzzOptions() *JsonLdOptions {
+	opts := NewJsonLdOptions("http://example.org/")
+	opts.DocumentLoader = offlineLoader{}
+	return opts
+}
+
+// addFuzzSeeds adds the given number of documents from a test suite directory to the seed corpus.
+func addFuzzSeeds(f *testing.F, pattern string, limit int) {
+	f.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", pattern))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for i, file := range files {
+		if i >= limit {
+			break
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+}
+
+func parseFuzzDocument(data []byte) (interface{}, bool) {
+	doc, err := DocumentFromReader(bytes.NewReader(data))
+	return doc, err == nil
+}
+
+func checkFuzzError(t *testing.T, err error) {
+	t.Helper()
+	var jsonLdErr *JsonLdError
+	if err != nil && !errors.As(err, &jsonLdErr) {
+		t.Fatalf("expected a JsonLdError, got %T: %v", err, err)
+	}
+}
+
+func FuzzExpand(f *testing.F) {
+	addFuzzSeeds(f, "expand/*-in.jsonld", 100)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, ok := parseFuzzDocument(data)
+		if !ok {
+			return
+		}
+		_, err := NewJsonLdProcessor().Expand(doc, fuzzOptions())
+		checkFuzzError(t, err)
+	})
+}
+
+func FuzzCompact(f *testing.F) {
+	addFuzzSeeds(f, "compact/*-in.jsonld", 100)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, ok := parseFuzzDocument(data)
+		if !ok {
+			return
+		}
+		// the document doubles as its own context
+		_, err := NewJsonLdProcessor().Compact(doc, doc, fuzzOptions())
+		checkFuzzError(t, err)
+	})
+}
+
+func FuzzToRDF(f *testing.F) {
+	addFuzzSeeds(f, "toRdf/*-in.jsonld", 100)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, ok := parseFuzzDocument(data)
+		if !ok {
+			return
+		}
+		opts := fuzzOptions()
+		opts.Format = "application/n-quads"
+		_, err := NewJsonLdProcessor().ToRDF(doc, opts)
+		checkFuzzError(t, err)
+	})
+}
+
+func FuzzParseNQuads(f *testing.F) {
+	addFuzzSeeds(f, "toRdf/*-out.nq", 100)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, err := ParseNQuads(string(data))
+		checkFuzzError(t, err)
+	})
+}
+
+func FuzzNormalize(f *testing.F) {
+	addFuzzSeeds(f, "normalization/test*-in.nq", 100)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		opts := fuzzOptions()
+		opts.InputFormat = "application/n-quads"
+		opts.Format = "application/n-quads"
+		opts.Algorithm = AlgorithmURDNA2015
+		_, err := NewJsonLdProcessor().Normalize(string(data), opts)
+		checkFuzzError(t, err)
+	})
+}
+
+func FuzzFrame(f *testing.F) {
+	addFuzzSeeds(f, "frame/*-frame.jsonld", 100)
+	input := map[string]interface{}{
+		"@context": map[string]interface{}{"@vocab": "http://example.org/"},
+		"@id":      "http://example.org/a",
+		"@type":    "T",
+		"p":        []interface{}{"x", map[string]interface{}{"@id": "http://example.org/b", "q": 1.0}},
+		"l":        map[string]interface{}{"@list": []interface{}{"y", map[string]interface{}{"@id": "_:c"}}},
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		frame, ok := parseFuzzDocument(data)
+		if !ok {
+			return
+		}
+		_, err := NewJsonLdProcessor().Frame(input, frame, fuzzOptions())
+		checkFuzzError(t, err)
+	})
+}
@@ -15,7 +15,7 @@
 package ld
 
 import (
-	"fmt"
+	"strconv"
 )
 
 // IdentifierIssuer issues unique identifiers, keeping track of any previously issued identifiers.
@@ -42,7 +42,7 @@ func (ii *IdentifierIssuer) Clone() *IdentifierIssuer {
 		prefix:        ii.prefix,
 		counter:       ii.counter,
 		existing:      make(map[string]string, len(ii.existing)),
-		existingOrder: make([]string, len(ii.existingOrder)),
+		existingOrder: make([]string, len(ii.existingOrder), len(ii.existingOrder)+1),
 	}
 	i := 0
 	for k, v := range ii.existing {
@@ -64,7 +64,7 @@ func (ii *IdentifierIssuer) GetId(oldID string) string { //nolint:stylecheck
 		}
 	}
 
-	id := ii.prefix + fmt.Sprintf("%d", ii.counter)
+	id := ii.prefix + strconv.Itoa(ii.counter)
 	ii.counter++
 
 	if oldID != "" {
@@ -0,0 +1,156 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package multibase implements the multibase encodings
+// (https://github.com/multiformats/multibase) used by json-gold.
+package multibase
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Encoding is a multibase prefix character.
+type Encoding byte
+
+const (
+	// Base58BTC is the base58 encoding with the Bitcoin alphabet.
+	Base58BTC Encoding = 'z'
+	// Base32 is the RFC 4648 base32 encoding, lower case and without padding.
+	Base32 Encoding = 'b'
+	// Base64URL is the RFC 4648 base64url encoding without padding.
+	Base64URL Encoding = 'u'
+)
+
+var base32Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Encode encodes data with the given encoding, including the multibase prefix.
+func Encode(encoding Encoding, data []byte) (string, error) {
+	switch encoding {
+	case Base58BTC:
+		return "z" + EncodeBase58(data), nil
+	case Base32:
+		return "b" + strings.ToLower(base32Encoding.EncodeToString(data)), nil
+	case Base64URL:
+		return "u" + base64.RawURLEncoding.EncodeToString(data), nil
+	default:
+		return "", fmt.Errorf("unsupported multibase encoding: %q", byte(encoding))
+	}
+}
+
+// Decode decodes a multibase value and returns its encoding and data.
+func Decode(value string) (Encoding, []byte, error) {
+	if value == "" {
+		return 0, nil, errors.New("empty multibase value")
+	}
+
+	encoding := Encoding(value[0])
+	var data []byte
+	var err error
+	switch encoding {
+	case Base58BTC:
+		data, err = DecodeBase58(value[1:])
+	case Base32:
+		data, err = base32Encoding.DecodeString(strings.ToUpper(value[1:]))
+	case Base64URL:
+		data, err = base64.RawURLEncoding.DecodeString(value[1:])
+	default:
+		err = fmt.Errorf("unsupported multibase encoding: %q", value[0])
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return encoding, data, nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Index = func() [256]int8 {
+	var index [256]int8
+	for i := range index {
+		index[i] = -1
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		index[base58Alphabet[i]] = int8(i)
+	}
+	return index
+}()
+
+// EncodeBase58 encodes data with the Bitcoin base58 alphabet.
+func EncodeBase58(data []byte) string {
+	zeros := 0
+	for zeros < len(data) && data[zeros] == 0 {
+		zeros++
+	}
+
+	// log(256) / log(58) < 1.37
+	digits := make([]byte, 0, len(data)*137/100+1)
+	for _, b := range data[zeros:] {
+		carry := int(b)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
+		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%58))
+			carry /= 58
+		}
+	}
+
+	out := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		out[i] = base58Alphabet[0]
+	}
+	for i, d := range digits {
+		out[len(out)-1-i] = base58Alphabet[d]
+	}
+	return string(out)
+}
+
+// DecodeBase58 decodes a string encoded with the Bitcoin base58 alphabet.
+func DecodeBase58(s string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+
+	// log(58) / log(256) < 0.74
+	bytes := make([]byte, 0, len(s)*74/100+1)
+	for i := zeros; i < len(s); i++ {
+		value := base58Index[s[i]]
+		if value < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", s[i])
+		}
+		carry := int(value)
+		for j := range bytes {
+			carry += int(bytes[j]) * 58
+			bytes[j] = byte(carry)
+			carry >>= 8
+		}
+		for carry > 0 {
+			bytes = append(bytes, byte(carry))
+			carry >>= 8
+		}
+	}
+
+	out := make([]byte, zeros+len(bytes))
+	for i, b := range bytes {
+		out[len(out)-1-i] = b
+	}
+	return out, nil
+}
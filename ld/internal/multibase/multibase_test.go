@@ -0,0 +1,74 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multibase_test
+
+import (
+	"testing"
+
+	. "github.com/piprate/json-gold/ld/internal/multibase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase58(t *testing.T) {
+	for decoded, encoded := range map[string]string{
+		"":              "",
+		"\x00":          "1",
+		"\x00\x00\x01":  "112",
+		"Hello World!":  "2NEpo7TZRRrLZSi2U",
+		"\x00\x00\xff":  "115Q",
+		"\xff\xff\xff":  "2UzHL",
+		"\x00\x01\x02x": "1Lfq",
+	} {
+		assert.Equal(t, encoded, EncodeBase58([]byte(decoded)))
+		actual, err := DecodeBase58(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(decoded), actual, encoded)
+	}
+
+	_, err := DecodeBase58("0OIl")
+	assert.Error(t, err)
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, tc := range []struct {
+		encoding Encoding
+		data     string
+		encoded  string
+	}{
+		{Base58BTC, "Hello World!", "z2NEpo7TZRRrLZSi2U"},
+		{Base32, "Hello World!", "bjbswy3dpeblw64tmmqqq"},
+		{Base32, "\x00\xff", "bad7q"},
+		{Base64URL, "Hello World!", "uSGVsbG8gV29ybGQh"},
+		{Base64URL, "\x00\xff", "uAP8"},
+	} {
+		encoded, err := Encode(tc.encoding, []byte(tc.data))
+		require.NoError(t, err)
+		assert.Equal(t, tc.encoded, encoded)
+
+		encoding, data, err := Decode(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, tc.encoding, encoding)
+		assert.Equal(t, []byte(tc.data), data)
+	}
+
+	_, err := Encode('f', []byte("hex"))
+	assert.Error(t, err)
+
+	for _, invalid := range []string{"", "f00", "z0", "b1", "u+/"} {
+		_, _, err = Decode(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
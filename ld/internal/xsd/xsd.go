@@ -0,0 +1,62 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xsd implements the parsing and comparison of literal values
+// (XML Schema datatypes and language tags) shared by the query and shacl packages.
+package xsd
+
+import (
+	"strings"
+	"time"
+)
+
+// LangMatches implements the basic filtering scheme of RFC 4647.
+func LangMatches(tag, langRange string) bool {
+	if langRange == "*" {
+		return tag != ""
+	}
+	tag = strings.ToLower(tag)
+	langRange = strings.ToLower(langRange)
+	return tag == langRange || strings.HasPrefix(tag, langRange+"-")
+}
+
+// CompareFloats returns -1, 0 or 1 if a is less than, equal to or greater than b.
+func CompareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ParseDateTime parses an xsd:dateTime value. Values without a timezone are taken as UTC.
+func ParseDateTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02T15:04:05.999999999", s)
+	}
+	return t, err
+}
+
+// ParseDate parses an xsd:date value. Values without a timezone are taken as UTC.
+func ParseDate(s string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02Z07:00", s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", s)
+	}
+	return t, err
+}
@@ -0,0 +1,68 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xsd_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/piprate/json-gold/ld/internal/xsd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLangMatches(t *testing.T) {
+	assert.True(t, LangMatches("en", "EN"))
+	assert.True(t, LangMatches("en-GB", "en"))
+	assert.True(t, LangMatches("fr", "*"))
+	assert.False(t, LangMatches("", "*"))
+	assert.False(t, LangMatches("eng", "en"))
+}
+
+func TestCompareFloats(t *testing.T) {
+	assert.Equal(t, -1, CompareFloats(1, 2))
+	assert.Equal(t, 0, CompareFloats(2, 2))
+	assert.Equal(t, 1, CompareFloats(3, 2))
+}
+
+func TestParseDateTime(t *testing.T) {
+	expected := time.Date(2023, 2, 24, 23, 36, 38, 0, time.UTC)
+	for _, value := range []string{"2023-02-24T23:36:38Z", "2023-02-24T23:36:38", "2023-02-25T00:36:38+01:00"} {
+		actual, err := ParseDateTime(value)
+		require.NoError(t, err, value)
+		assert.True(t, expected.Equal(actual), value)
+	}
+
+	actual, err := ParseDateTime("2023-02-24T23:36:38.5")
+	require.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, actual.Sub(expected))
+
+	for _, value := range []string{"2023-02-24", "2023-02-24 23:36:38", "yesterday"} {
+		_, err = ParseDateTime(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	actual, err := ParseDate("2023-02-24")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 2, 24, 0, 0, 0, 0, time.UTC), actual)
+
+	_, err = ParseDate("2023-02-24Z")
+	require.NoError(t, err)
+
+	_, err = ParseDate("2023-02-24T00:00:00")
+	assert.Error(t, err)
+}
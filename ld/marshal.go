@@ -0,0 +1,582 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Marshal returns the compacted JSON-LD representation of v, using the given context.
+// See MarshalDocument for details.
+func Marshal(v interface{}, context interface{}, opts *JsonLdOptions) ([]byte, error) {
+	doc, err := MarshalDocument(v, context, opts)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, NewJsonLdError(IOError, err)
+	}
+	return data, nil
+}
+
+// MarshalDocument converts v, which must be a struct, a map or a slice of those,
+// into a compacted JSON-LD document, using the given context.
+//
+// Struct fields are mapped to JSON-LD terms using `jsonld` struct tags, in the same way
+// encoding/json uses `json` tags. The tag holds the name of a term defined in the context
+// or a keyword (such as @id or @type), optionally followed by ",omitempty".
+// Fields without a tag, or with the tag "-", are ignored. Fields of embedded structs
+// are treated as if they were fields of the outer struct.
+//
+// Nested structs become nested nodes, slices become arrays and maps become JSON objects
+// (e.g. language maps). Values implementing encoding.TextMarshaler are marshaled as strings.
+// Values which contain themselves (through pointers, maps or slices) can't be marshaled
+// and result in an InvalidInput error.
+//
+// The result is produced by expanding the converted value and compacting it against
+// the context, so values which don't match the context are dropped or represented
+// with full IRIs, as in any other compacted document.
+func MarshalDocument(v interface{}, context interface{}, opts *JsonLdOptions) (map[string]interface{}, error) {
+	e := &encoder{visiting: make(map[visitKey]bool)}
+	value, err := e.marshalValue(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	var input map[string]interface{}
+	switch val := value.(type) {
+	case map[string]interface{}:
+		input = val
+	case []interface{}:
+		input = map[string]interface{}{"@graph": val}
+	default:
+		return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("cannot marshal %T as a JSON-LD node", v))
+	}
+
+	proc := NewJsonLdProcessor()
+	compiledCtx, err := proc.CompileContext(context, opts)
+	if err != nil {
+		return nil, err
+	}
+	input["@context"] = compiledCtx
+
+	expanded, err := proc.Expand(input, opts)
+	if err != nil {
+		return nil, err
+	}
+	return proc.Compact(expanded, compiledCtx, opts)
+}
+
+// encoder converts Go values into JSON-LD documents.
+type encoder struct {
+	// visiting holds the pointers, maps and slices being marshaled, to detect cycles
+	visiting map[visitKey]bool
+}
+
+// visitKey identifies a pointer, map or slice value. The type and length are needed
+// because different values may share the same address (e.g. a struct and its first field).
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+func (e *encoder) marshalValue(rv reflect.Value) (interface{}, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return nil, nil
+		}
+	}
+
+	if rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Map || rv.Kind() == reflect.Slice {
+		key := visitKey{ptr: rv.Pointer(), typ: rv.Type()}
+		if rv.Kind() == reflect.Slice {
+			key.len = rv.Len()
+		}
+		if e.visiting[key] {
+			return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("cannot marshal cyclic value of type %s", rv.Type()))
+		}
+		e.visiting[key] = true
+		defer delete(e.visiting, key)
+	}
+
+	if tm, isTextMarshaler := rv.Interface().(encoding.TextMarshaler); isTextMarshaler {
+		text, err := tm.MarshalText()
+		if err != nil {
+			return nil, NewJsonLdError(InvalidInput, err)
+		}
+		return string(text), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return e.marshalValue(rv.Elem())
+	case reflect.Struct:
+		return e.marshalStruct(rv)
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			item, err := e.marshalValue(rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			if item != nil {
+				list = append(list, item)
+			}
+		}
+		return list, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("cannot marshal map with non-string keys: %s", rv.Type()))
+		}
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			item, err := e.marshalValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			m[iter.Key().String()] = item
+		}
+		return m, nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// json.Number keeps large integers intact
+		return json.Number(strconv.FormatInt(rv.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return json.Number(strconv.FormatUint(rv.Uint(), 10)), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	default:
+		return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("cannot marshal value of type %s", rv.Type()))
+	}
+}
+
+func (e *encoder) marshalStruct(rv reflect.Value) (map[string]interface{}, error) {
+	fields := cachedStructFields(rv.Type())
+	node := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		fv := fieldByIndex(rv, f.index, false)
+		if !fv.IsValid() || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		val, err := e.marshalValue(fv)
+		if err != nil {
+			return nil, err
+		}
+		if val != nil {
+			node[f.name] = val
+		}
+	}
+	return node, nil
+}
+
+// Unmarshal parses JSON-LD data and stores the result in the value pointed to by v.
+// See UnmarshalDocument for details.
+func Unmarshal(data []byte, v interface{}, context interface{}, opts *JsonLdOptions) error {
+	doc, err := DocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return UnmarshalDocument(doc, v, context, opts)
+}
+
+// UnmarshalDocument stores the given JSON-LD document in the value pointed to by v,
+// which is usually a struct with `jsonld` tags (see MarshalDocument) or a slice of those.
+//
+// The document is expanded and then compacted against the given context before
+// it's mapped onto v, so the result doesn't depend on the aliases, prefixes or term
+// names used by the document itself. Single values are accepted for slice fields and
+// single-element arrays for non-slice fields. Value objects are unwrapped when they're
+// stored in scalar fields, and node references are stored in the @id field of structs.
+func UnmarshalDocument(document interface{}, v interface{}, context interface{}, opts *JsonLdOptions) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return NewJsonLdError(InvalidInput, fmt.Sprintf("cannot unmarshal into %T, a non-nil pointer is required", v))
+	}
+
+	proc := NewJsonLdProcessor()
+	compiledCtx, err := proc.CompileContext(context, opts)
+	if err != nil {
+		return err
+	}
+	expanded, err := proc.Expand(document, opts)
+	if err != nil {
+		return err
+	}
+	compacted, err := proc.Compact(expanded, compiledCtx, opts)
+	if err != nil {
+		return err
+	}
+	delete(compacted, "@context")
+
+	d := &decoder{
+		ctx:     compiledCtx.context,
+		aliases: make(map[string]string),
+	}
+
+	var value interface{} = compacted
+	if len(compacted) == 0 {
+		value = nil
+	} else if graph, hasGraph := d.lookup(compacted, "@graph"); hasGraph && len(compacted) == 1 {
+		value = graph
+	}
+
+	return d.decode(value, rv.Elem())
+}
+
+// decoder maps compacted JSON-LD values onto Go values.
+type decoder struct {
+	ctx     *Context
+	aliases map[string]string
+}
+
+// lookup returns the value of the given key. Keywords are looked up using their aliases, if any.
+func (d *decoder) lookup(m map[string]interface{}, key string) (interface{}, bool) {
+	if !IsKeyword(key) {
+		val, found := m[key]
+		return val, found
+	}
+
+	alias, found := d.aliases[key]
+	if !found {
+		var err error
+		alias, err = d.ctx.CompactIri(key, nil, true, false)
+		if err != nil {
+			alias = key
+		}
+		d.aliases[key] = alias
+	}
+	if val, found := m[alias]; found {
+		return val, true
+	}
+	val, found := m[key]
+	return val, found
+}
+
+func (d *decoder) decode(val interface{}, rv reflect.Value) error {
+	if val == nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return d.decode(val, rv.Elem())
+	}
+
+	list, isList := val.([]interface{})
+	if rv.Kind() == reflect.Slice {
+		if !isList {
+			list = []interface{}{val}
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(list), len(list))
+		for i, item := range list {
+			if err := d.decode(item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+	}
+	if isList {
+		switch len(list) {
+		case 0:
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		case 1:
+			return d.decode(list[0], rv)
+		default:
+			return NewJsonLdError(InvalidInput, fmt.Sprintf("cannot unmarshal %d values into %s", len(list), rv.Type()))
+		}
+	}
+
+	if rv.CanAddr() {
+		if tu, isTextUnmarshaler := rv.Addr().Interface().(encoding.TextUnmarshaler); isTextUnmarshaler {
+			s, isString := d.scalar(val).(string)
+			if !isString {
+				return d.typeError(val, rv)
+			}
+			if err := tu.UnmarshalText([]byte(s)); err != nil {
+				return NewJsonLdError(InvalidInput, err)
+			}
+			return nil
+		}
+	}
+
+	switch rv.Kind() { //nolint:exhaustive
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return d.typeError(val, rv)
+		}
+		rv.Set(reflect.ValueOf(val))
+		return nil
+	case reflect.Struct:
+		return d.decodeStruct(val, rv)
+	case reflect.Map:
+		return d.decodeMap(val, rv)
+	}
+
+	return d.decodeScalar(d.scalar(val), rv)
+}
+
+func (d *decoder) decodeStruct(val interface{}, rv reflect.Value) error {
+	fields := cachedStructFields(rv.Type())
+
+	m, isMap := val.(map[string]interface{})
+	if !isMap {
+		// a node reference compacted to an IRI
+		iri, isString := val.(string)
+		if !isString {
+			return d.typeError(val, rv)
+		}
+		for _, f := range fields {
+			if f.name == "@id" {
+				return d.decode(iri, fieldByIndex(rv, f.index, true))
+			}
+		}
+		return NewJsonLdError(InvalidInput, fmt.Sprintf("cannot unmarshal node reference %s into %s without an @id field", iri, rv.Type()))
+	}
+
+	for _, f := range fields {
+		item, found := d.lookup(m, f.name)
+		if !found {
+			continue
+		}
+		if err := d.decode(item, fieldByIndex(rv, f.index, true)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decoder) decodeMap(val interface{}, rv reflect.Value) error {
+	m, isMap := val.(map[string]interface{})
+	if !isMap || rv.Type().Key().Kind() != reflect.String {
+		return d.typeError(val, rv)
+	}
+	mv := reflect.MakeMapWithSize(rv.Type(), len(m))
+	for k, item := range m {
+		ev := reflect.New(rv.Type().Elem()).Elem()
+		if err := d.decode(item, ev); err != nil {
+			return err
+		}
+		mv.SetMapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()), ev)
+	}
+	rv.Set(mv)
+	return nil
+}
+
+// scalar unwraps value objects and node references.
+func (d *decoder) scalar(val interface{}) interface{} {
+	m, isMap := val.(map[string]interface{})
+	if !isMap {
+		return val
+	}
+	if value, hasValue := d.lookup(m, "@value"); hasValue {
+		return value
+	}
+	if id, hasID := d.lookup(m, "@id"); hasID && len(m) == 1 {
+		return id
+	}
+	return val
+}
+
+func (d *decoder) decodeScalar(val interface{}, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.String:
+		s, isString := val.(string)
+		if !isString {
+			return d.typeError(val, rv)
+		}
+		rv.SetString(s)
+	case reflect.Bool:
+		switch b := val.(type) {
+		case bool:
+			rv.SetBool(b)
+		case string:
+			parsed, err := strconv.ParseBool(b)
+			if err != nil {
+				return d.typeError(val, rv)
+			}
+			rv.SetBool(parsed)
+		default:
+			return d.typeError(val, rv)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(numberString(val), 10, 64)
+		if err != nil || rv.OverflowInt(n) {
+			return d.typeError(val, rv)
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(numberString(val), 10, 64)
+		if err != nil || rv.OverflowUint(n) {
+			return d.typeError(val, rv)
+		}
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(numberString(val), 64)
+		if err != nil || rv.OverflowFloat(n) {
+			return d.typeError(val, rv)
+		}
+		rv.SetFloat(n)
+	default:
+		return d.typeError(val, rv)
+	}
+	return nil
+}
+
+func (d *decoder) typeError(val interface{}, rv reflect.Value) error {
+	return NewJsonLdError(InvalidInput, fmt.Sprintf("cannot unmarshal %v into %s", val, rv.Type()))
+}
+
+// numberString returns the string representation of numeric values
+// (including numbers in typed literals, such as "42"^^xsd:integer).
+func numberString(val interface{}) string {
+	switch v := val.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		return ""
+	}
+}
+
+// structField describes a struct field mapped to a JSON-LD term.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var structFieldsCache sync.Map // map[reflect.Type][]structField
+
+func cachedStructFields(t reflect.Type) []structField {
+	if fields, found := structFieldsCache.Load(t); found {
+		return fields.([]structField)
+	}
+	fields, _ := structFieldsCache.LoadOrStore(t, typeStructFields(t, nil))
+	return fields.([]structField)
+}
+
+// typeStructFields returns the tagged fields of the given struct type. Fields of the struct
+// itself take precedence over fields of embedded structs with the same name.
+func typeStructFields(t reflect.Type, index []int) []structField {
+	var fields []structField
+	var embedded []reflect.StructField
+	seen := make(map[string]bool)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("jsonld")
+
+		if sf.Anonymous && tag == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			// embedded pointers to unexported types can't be allocated
+			if ft.Kind() == reflect.Struct && (sf.PkgPath == "" || sf.Type.Kind() != reflect.Ptr) {
+				embedded = append(embedded, sf)
+			}
+			continue
+		}
+		if sf.PkgPath != "" || tag == "" || tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		fields = append(fields, structField{
+			name:      name,
+			index:     append(append([]int(nil), index...), i),
+			omitEmpty: options == "omitempty",
+		})
+		seen[name] = true
+	}
+
+	for _, sf := range embedded {
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		for _, f := range typeStructFields(ft, append(append([]int(nil), index...), sf.Index...)) {
+			if !seen[f.name] {
+				fields = append(fields, f)
+				seen[f.name] = true
+			}
+		}
+	}
+
+	return fields
+}
+
+// fieldByIndex returns the nested field with the given index. If alloc is false,
+// an invalid value is returned when the field is inside a nil embedded pointer.
+func fieldByIndex(rv reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv
+}
+
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() { //nolint:exhaustive
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return rv.IsNil()
+	case reflect.Struct:
+		return rv.IsZero()
+	}
+	return false
+}
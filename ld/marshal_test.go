@@ -0,0 +1,187 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type marshalThing struct {
+	ID   string   `jsonld:"@id"`
+	Type []string `jsonld:"@type"`
+}
+
+type marshalPerson struct {
+	marshalThing
+	Name        string            `jsonld:"name"`
+	Age         int               `jsonld:"age,omitempty"`
+	Homepage    string            `jsonld:"homepage,omitempty"`
+	Description map[string]string `jsonld:"description,omitempty"`
+	Knows       []*marshalPerson  `jsonld:"knows,omitempty"`
+	BirthDate   *time.Time        `jsonld:"birthDate,omitempty"`
+	Internal    string
+}
+
+var marshalTestContext = map[string]interface{}{
+	"@context": map[string]interface{}{
+		"id":       "@id",
+		"type":     "@type",
+		"xsd":      "http://www.w3.org/2001/XMLSchema#",
+		"Person":   "http://schema.org/Person",
+		"name":     "http://schema.org/name",
+		"age":      map[string]interface{}{"@id": "http://schema.org/age", "@type": "xsd:integer"},
+		"homepage": map[string]interface{}{"@id": "http://schema.org/url", "@type": "@id"},
+		"description": map[string]interface{}{
+			"@id":        "http://schema.org/description",
+			"@container": "@language",
+		},
+		"knows":     map[string]interface{}{"@id": "http://schema.org/knows", "@container": "@set"},
+		"birthDate": map[string]interface{}{"@id": "http://schema.org/birthDate", "@type": "xsd:dateTime"},
+	},
+}
+
+func TestMarshal(t *testing.T) {
+	birthDate := time.Date(1970, 1, 2, 3, 4, 5, 0, time.UTC)
+	p := &marshalPerson{
+		marshalThing: marshalThing{ID: "http://example.org/jane", Type: []string{"Person"}},
+		Name:         "Jane Doe",
+		Age:          42,
+		Homepage:     "http://example.org/jane.html",
+		Description:  map[string]string{"en": "Professor", "fr": "Professeure"},
+		Knows: []*marshalPerson{{
+			marshalThing: marshalThing{ID: "http://example.org/john"},
+			Name:         "John Doe",
+		}},
+		BirthDate: &birthDate,
+		Internal:  "ignored",
+	}
+
+	data, err := Marshal(p, marshalTestContext, nil)
+	require.NoError(t, err)
+
+	var actual map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &actual))
+	expected := map[string]interface{}{
+		"@context":    marshalTestContext["@context"],
+		"id":          "http://example.org/jane",
+		"type":        "Person",
+		"name":        "Jane Doe",
+		"age":         float64(42),
+		"homepage":    "http://example.org/jane.html",
+		"description": map[string]interface{}{"en": "Professor", "fr": "Professeure"},
+		"knows": []interface{}{
+			map[string]interface{}{"id": "http://example.org/john", "name": "John Doe"},
+		},
+		"birthDate": "1970-01-02T03:04:05Z",
+	}
+	assert.Equal(t, expected, actual)
+
+	// round trip
+	var p2 marshalPerson
+	require.NoError(t, Unmarshal(data, &p2, marshalTestContext, nil))
+	p.Internal = ""
+	assert.Equal(t, p, &p2)
+}
+
+func TestUnmarshalNormalisesInput(t *testing.T) {
+	// the input uses its own prefixes and aliases, and a mix of single values and arrays
+	input := []byte(`{
+		"@context": {"s": "http://schema.org/", "identifier": "@id"},
+		"identifier": "http://example.org/jane",
+		"@type": ["s:Person"],
+		"s:name": ["Jane Doe"],
+		"s:age": {"@value": "42", "@type": "http://www.w3.org/2001/XMLSchema#integer"},
+		"s:url": {"@id": "http://example.org/jane.html"},
+		"s:description": [
+			{"@value": "Professor", "@language": "en"},
+			{"@value": "Professeure", "@language": "fr"}
+		],
+		"s:knows": {"@id": "http://example.org/john", "s:name": {"@value": "John Doe"}}
+	}`)
+
+	var p marshalPerson
+	require.NoError(t, Unmarshal(input, &p, marshalTestContext, nil))
+	assert.Equal(t, "http://example.org/jane", p.ID)
+	assert.Equal(t, []string{"Person"}, p.Type)
+	assert.Equal(t, "Jane Doe", p.Name)
+	assert.Equal(t, 42, p.Age)
+	assert.Equal(t, "http://example.org/jane.html", p.Homepage)
+	assert.Equal(t, map[string]string{"en": "Professor", "fr": "Professeure"}, p.Description)
+	require.Len(t, p.Knows, 1)
+	assert.Equal(t, "http://example.org/john", p.Knows[0].ID)
+	assert.Equal(t, "John Doe", p.Knows[0].Name)
+	assert.Nil(t, p.BirthDate)
+}
+
+func TestUnmarshalGraph(t *testing.T) {
+	input := map[string]interface{}{
+		"@context": map[string]interface{}{"name": "http://schema.org/name"},
+		"@graph": []interface{}{
+			map[string]interface{}{"@id": "http://example.org/jane", "name": "Jane Doe"},
+			map[string]interface{}{"@id": "http://example.org/john", "name": "John Doe"},
+		},
+	}
+
+	var people []marshalPerson
+	require.NoError(t, UnmarshalDocument(input, &people, marshalTestContext, nil))
+	require.Len(t, people, 2)
+	names := []string{people[0].Name, people[1].Name}
+	assert.ElementsMatch(t, []string{"Jane Doe", "John Doe"}, names)
+
+	// a single struct can't hold several nodes
+	var p marshalPerson
+	err := UnmarshalDocument(input, &p, marshalTestContext, nil)
+	require.Error(t, err)
+	assert.Equal(t, InvalidInput, err.(*JsonLdError).Code) //nolint:errorlint
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var p marshalPerson
+	err := Unmarshal([]byte(`{"http://schema.org/name": "Jane"}`), p, marshalTestContext, nil)
+	require.Error(t, err)
+	assert.Equal(t, InvalidInput, err.(*JsonLdError).Code) //nolint:errorlint
+
+	err = Unmarshal([]byte(`{"http://schema.org/age": {
+		"@value": "forty-two",
+		"@type": "http://www.w3.org/2001/XMLSchema#integer"
+	}}`), &p, marshalTestContext, nil)
+	require.Error(t, err)
+	assert.Equal(t, InvalidInput, err.(*JsonLdError).Code) //nolint:errorlint
+}
+
+func TestMarshalCycles(t *testing.T) {
+	alice := &marshalPerson{marshalThing: marshalThing{ID: "http://example.org/alice"}, Name: "Alice"}
+	bob := &marshalPerson{marshalThing: marshalThing{ID: "http://example.org/bob"}, Name: "Bob"}
+	alice.Knows = []*marshalPerson{bob}
+	bob.Knows = []*marshalPerson{alice}
+
+	_, err := Marshal(alice, marshalTestContext, nil)
+	require.Error(t, err)
+	assert.Equal(t, InvalidInput, err.(*JsonLdError).Code) //nolint:errorlint
+
+	// the same value may appear more than once, as long as it doesn't contain itself
+	bob.Knows = nil
+	carol := &marshalPerson{marshalThing: marshalThing{ID: "http://example.org/carol"}, Name: "Carol",
+		Knows: []*marshalPerson{bob}}
+	alice.Knows = []*marshalPerson{bob, carol}
+	_, err = Marshal(alice, marshalTestContext, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,217 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// leaf and node hash prefixes, as in RFC 6962, so that a leaf can't be mistaken for a node
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleTree is a Merkle tree over the sorted canonical N-Quads of a dataset. It follows
+// the Merkle Tree Hash definition of RFC 9162 (Certificate Transparency 2.0): leaves are
+// hashes of the canonical N-Quads, including the terminating newline.
+type MerkleTree struct {
+	hashFactory HashFactory
+	quads       []string
+	leaves      [][]byte
+	root        []byte
+}
+
+// MerkleProof is an inclusion proof of a quad in a MerkleTree.
+type MerkleProof struct {
+	// LeafIndex is the position of the quad in the sorted canonical N-Quads.
+	LeafIndex int
+	TreeSize  int
+	// Path holds the hashes of the sibling subtrees, from the leaf up to the root.
+	Path [][]byte
+}
+
+// NewMerkleTree builds a Merkle tree over the given sorted canonical N-Quads.
+func NewMerkleTree(quads []string, hashFactory HashFactory) *MerkleTree {
+	mt := &MerkleTree{
+		hashFactory: hashFactory,
+		quads:       quads,
+		leaves:      make([][]byte, len(quads)),
+	}
+	for i, quad := range quads {
+		mt.leaves[i] = merkleLeafHash(hashFactory, quad)
+	}
+	mt.root = mt.subtreeHash(0, len(mt.leaves))
+	return mt
+}
+
+// MerkleTree returns the Merkle tree over the canonical N-Quads produced by Normalize.
+func (na *NormalisationAlgorithm) MerkleTree() (*MerkleTree, error) {
+	if na.hashErr != nil {
+		return nil, na.hashErr
+	}
+	return NewMerkleTree(na.lines, na.hashFactory), nil
+}
+
+// MerkleTree normalizes the given input and builds a Merkle tree over its canonical N-Quads.
+// The input is JSON-LD unless the 'inputFormat' option is used or it's an *RDFDataset.
+// The hash function is defined by the Algorithm and MessageDigestAlgorithm options,
+// like in Normalize.
+func (jldp *JsonLdProcessor) MerkleTree(input interface{}, opts *JsonLdOptions) (*MerkleTree, error) {
+
+	opts = jldp.prepareOptions(opts)
+
+	dataset, err := jldp.normalizationInput(input, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	algo := newNormalisationAlgorithmWithOptions(opts)
+	if err = algo.Normalize(dataset); err != nil {
+		return nil, err
+	}
+	return algo.MerkleTree()
+}
+
+// Root returns the root hash of the tree.
+func (mt *MerkleTree) Root() []byte {
+	return mt.root
+}
+
+// Quads returns the canonical N-Quads the tree was built over.
+func (mt *MerkleTree) Quads() []string {
+	return mt.quads
+}
+
+// InclusionProof returns a proof that the given canonical N-Quad belongs to the tree.
+// The quad must use the canonical blank node labels; the terminating newline is optional.
+func (mt *MerkleTree) InclusionProof(quad string) (*MerkleProof, error) {
+	quad = terminateNQuad(quad)
+	index := sort.SearchStrings(mt.quads, quad)
+	if index == len(mt.quads) || mt.quads[index] != quad {
+		return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("quad not found: %s", strings.TrimSuffix(quad, "\n")))
+	}
+
+	return &MerkleProof{
+		LeafIndex: index,
+		TreeSize:  len(mt.leaves),
+		Path:      mt.path(index, 0, len(mt.leaves), nil),
+	}, nil
+}
+
+// VerifyMerkleProof checks that the proof shows the inclusion of the given canonical
+// N-Quad in the tree with the given root hash. The hash function is selected by the
+// Algorithm and MessageDigestAlgorithm options. If the proof is invalid, the error code
+// is IntegrityCheckFailed.
+func VerifyMerkleProof(root []byte, quad string, proof *MerkleProof, opts *JsonLdOptions) error {
+	if opts == nil {
+		opts = NewJsonLdOptions("")
+	}
+	na := NewNormalisationAlgorithm(opts.Algorithm, opts.MessageDigestAlgorithm)
+	if na.hashErr != nil {
+		return na.hashErr
+	}
+	hashFactory := na.hashFactory
+
+	if proof.LeafIndex < 0 || proof.LeafIndex >= proof.TreeSize {
+		return NewJsonLdError(IntegrityCheckFailed, "leaf index out of range")
+	}
+
+	// RFC 9162, section 2.1.3.2
+	fn := proof.LeafIndex
+	sn := proof.TreeSize - 1
+	r := merkleLeafHash(hashFactory, terminateNQuad(quad))
+	for _, p := range proof.Path {
+		if sn == 0 {
+			return NewJsonLdError(IntegrityCheckFailed, "inclusion proof is too long")
+		}
+		if fn%2 == 1 || fn == sn {
+			r = merkleNodeHash(hashFactory, p, r)
+			for fn%2 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = merkleNodeHash(hashFactory, r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(r, root) {
+		return NewJsonLdError(IntegrityCheckFailed, "inclusion proof doesn't match the root")
+	}
+	return nil
+}
+
+// subtreeHash returns the Merkle Tree Hash of leaves[start:end].
+func (mt *MerkleTree) subtreeHash(start, end int) []byte {
+	switch n := end - start; n {
+	case 0:
+		return mt.hashFactory().Sum(nil)
+	case 1:
+		return mt.leaves[start]
+	default:
+		k := largestPowerOfTwoBelow(n)
+		return merkleNodeHash(mt.hashFactory, mt.subtreeHash(start, start+k), mt.subtreeHash(start+k, end))
+	}
+}
+
+// path appends the audit path of leaf index within leaves[start:end] to dst.
+func (mt *MerkleTree) path(index, start, end int, dst [][]byte) [][]byte {
+	n := end - start
+	if n <= 1 {
+		return dst
+	}
+	k := largestPowerOfTwoBelow(n)
+	if index < start+k {
+		return append(mt.path(index, start, start+k, dst), mt.subtreeHash(start+k, end))
+	}
+	return append(mt.path(index, start+k, end, dst), mt.subtreeHash(start, start+k))
+}
+
+// largestPowerOfTwoBelow returns the largest power of two smaller than n, for n > 1.
+func largestPowerOfTwoBelow(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func merkleLeafHash(hashFactory HashFactory, quad string) []byte {
+	h := hashFactory()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write([]byte(quad))
+	return h.Sum(nil)
+}
+
+func merkleNodeHash(hashFactory HashFactory, left, right []byte) []byte {
+	h := hashFactory()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+func terminateNQuad(quad string) string {
+	if strings.HasSuffix(quad, "\n") {
+		return quad
+	}
+	return quad + "\n"
+}
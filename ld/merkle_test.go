@@ -0,0 +1,143 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func merkleOptions() *JsonLdOptions {
+	opts := NewJsonLdOptions("")
+	opts.Algorithm = AlgorithmURDNA2015
+	return opts
+}
+
+func sha256Of(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+func TestMerkleTreeRoot(t *testing.T) {
+	quads := []string{
+		"<http://example.org/a> <http://schema.org/name> \"A\" .\n",
+		"<http://example.org/b> <http://schema.org/name> \"B\" .\n",
+		"<http://example.org/c> <http://schema.org/name> \"C\" .\n",
+	}
+	leaf := func(i int) []byte { return sha256Of([]byte{0}, []byte(quads[i])) }
+	node := func(left, right []byte) []byte { return sha256Of([]byte{1}, left, right) }
+
+	assert.Equal(t, sha256Of(), NewMerkleTree(nil, sha256.New).Root())
+	assert.Equal(t, leaf(0), NewMerkleTree(quads[:1], sha256.New).Root())
+	assert.Equal(t, node(leaf(0), leaf(1)), NewMerkleTree(quads[:2], sha256.New).Root())
+	assert.Equal(t, node(node(leaf(0), leaf(1)), leaf(2)), NewMerkleTree(quads, sha256.New).Root())
+
+	proof, err := NewMerkleTree(quads, sha256.New).InclusionProof(strings.TrimSuffix(quads[2], "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, &MerkleProof{LeafIndex: 2, TreeSize: 3, Path: [][]byte{node(leaf(0), leaf(1))}}, proof)
+}
+
+func TestMerkleInclusionProofs(t *testing.T) {
+	opts := merkleOptions()
+	for n := 1; n <= 17; n++ {
+		quads := make([]string, n)
+		for i := range quads {
+			quads[i] = fmt.Sprintf("<http://example.org/s%02d> <http://schema.org/name> \"%d\" .\n", i, i)
+		}
+		tree := NewMerkleTree(quads, sha256.New)
+
+		for i, quad := range quads {
+			proof, err := tree.InclusionProof(quad)
+			require.NoError(t, err)
+			assert.Equal(t, i, proof.LeafIndex)
+			assert.NoError(t, VerifyMerkleProof(tree.Root(), quad, proof, opts), "leaf %d of %d", i, n)
+
+			err = VerifyMerkleProof(tree.Root(), quads[(i+1)%n], proof, opts)
+			if n > 1 {
+				require.Error(t, err, "leaf %d of %d", i, n)
+				assert.Equal(t, IntegrityCheckFailed, err.(*JsonLdError).Code) //nolint:errorlint
+			}
+
+			if len(proof.Path) > 0 {
+				truncated := *proof
+				truncated.Path = proof.Path[:len(proof.Path)-1]
+				assert.Error(t, VerifyMerkleProof(tree.Root(), quad, &truncated, opts), "leaf %d of %d", i, n)
+
+				extended := *proof
+				extended.Path = append(append([][]byte{}, proof.Path...), tree.Root())
+				assert.Error(t, VerifyMerkleProof(tree.Root(), quad, &extended, opts), "leaf %d of %d", i, n)
+			}
+
+			moved := *proof
+			moved.LeafIndex = n
+			assert.Error(t, VerifyMerkleProof(tree.Root(), quad, &moved, opts))
+		}
+	}
+}
+
+func TestProcessorMerkleTree(t *testing.T) {
+	proc := NewJsonLdProcessor()
+	input := credentialBatch(3)
+
+	opts := merkleOptions()
+	opts.InputFormat = "application/n-quads"
+	opts.MessageDigestAlgorithm = MessageDigestAlgorithmSHA384
+	tree, err := proc.MerkleTree(input, opts)
+	require.NoError(t, err)
+	assert.Len(t, tree.Root(), sha512.Size384)
+
+	opts.Format = "application/n-quads"
+	normalized, err := proc.Normalize(input, opts)
+	require.NoError(t, err)
+	assert.Equal(t, normalized, strings.Join(tree.Quads(), ""))
+	assert.Equal(t, NewMerkleTree(tree.Quads(), sha512.New384).Root(), tree.Root())
+
+	// prove one statement without revealing the others
+	quad := tree.Quads()[5]
+	proof, err := tree.InclusionProof(quad)
+	require.NoError(t, err)
+	assert.NoError(t, VerifyMerkleProof(tree.Root(), quad, proof, opts))
+
+	// the proof only verifies with the same hash function
+	opts.MessageDigestAlgorithm = MessageDigestAlgorithmSHA256
+	assert.Error(t, VerifyMerkleProof(tree.Root(), quad, proof, opts))
+	opts.MessageDigestAlgorithm = "SHA3-1024"
+	err = VerifyMerkleProof(tree.Root(), quad, proof, opts)
+	require.Error(t, err)
+	assert.Equal(t, UnknownMessageDigestAlgorithm, err.(*JsonLdError).Code) //nolint:errorlint
+
+	_, err = tree.InclusionProof("<http://example.org/x> <http://schema.org/name> \"X\" .")
+	require.Error(t, err)
+	assert.Equal(t, InvalidInput, err.(*JsonLdError).Code) //nolint:errorlint
+
+	// the algorithm exposes the tree after normalization
+	dataset, err := ParseNQuads(input)
+	require.NoError(t, err)
+	na := NewNormalisationAlgorithm(AlgorithmURDNA2015, MessageDigestAlgorithmSHA384)
+	require.NoError(t, na.Normalize(dataset))
+	naTree, err := na.MerkleTree()
+	require.NoError(t, err)
+	assert.Equal(t, tree.Root(), naTree.Root())
+}
@@ -0,0 +1,125 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"sync"
+)
+
+// HashFactory creates a new instance of a hash function. It must be safe to call
+// from several goroutines.
+type HashFactory func() hash.Hash
+
+var (
+	messageDigestsMu sync.RWMutex
+	messageDigests   = map[MessageDigestAlgorithm]HashFactory{
+		MessageDigestAlgorithmSHA256: sha256.New,
+		MessageDigestAlgorithmSHA384: sha512.New384,
+		MessageDigestAlgorithmSHA512: sha512.New,
+	}
+)
+
+// multihash codes (https://github.com/multiformats/multicodec) of message digest algorithms.
+// SHA-3 digests aren't registered by default, but they get the right code once registered
+// under these names.
+var multihashCodes = map[MessageDigestAlgorithm]uint64{
+	MessageDigestAlgorithmSHA256: 0x12,
+	MessageDigestAlgorithmSHA384: 0x20,
+	MessageDigestAlgorithmSHA512: 0x13,
+	"SHA3-256":                   0x16,
+	"SHA3-384":                   0x15,
+	"SHA3-512":                   0x14,
+}
+
+// RegisterMessageDigestAlgorithm makes a hash function available to URDNA2015 normalization
+// under the given name, which can then be selected with JsonLdOptions.MessageDigestAlgorithm.
+// Registering a name again replaces its factory. For example, SHA3-256 can be
+// registered with:
+//
+//	ld.RegisterMessageDigestAlgorithm("SHA3-256", func() hash.Hash { return sha3.New256() })
+func RegisterMessageDigestAlgorithm(name MessageDigestAlgorithm, factory HashFactory) {
+	messageDigestsMu.Lock()
+	defer messageDigestsMu.Unlock()
+
+	messageDigests[name] = factory
+}
+
+// MessageDigestAlgorithms returns the names of all registered message digest algorithms.
+func MessageDigestAlgorithms() []MessageDigestAlgorithm {
+	messageDigestsMu.RLock()
+	defer messageDigestsMu.RUnlock()
+
+	names := make([]MessageDigestAlgorithm, 0, len(messageDigests))
+	for name := range messageDigests {
+		names = append(names, name)
+	}
+	return names
+}
+
+// lookupMessageDigest returns the factory registered under the given name.
+// An empty name selects SHA-256, the default hash algorithm of RDF Dataset Canonicalization.
+func lookupMessageDigest(name MessageDigestAlgorithm) (HashFactory, error) {
+	if name == "" {
+		name = MessageDigestAlgorithmSHA256
+	}
+
+	messageDigestsMu.RLock()
+	factory, found := messageDigests[name]
+	messageDigestsMu.RUnlock()
+
+	if !found || factory == nil {
+		return nil, NewJsonLdError(UnknownMessageDigestAlgorithm, string(name))
+	}
+	return factory, nil
+}
+
+// RegisterMultihashCode assigns a multihash code to a message digest algorithm, so it can be used
+// in content identifiers (see JsonLdProcessor.ContentID).
+func RegisterMultihashCode(name MessageDigestAlgorithm, code uint64) {
+	messageDigestsMu.Lock()
+	defer messageDigestsMu.Unlock()
+
+	multihashCodes[name] = code
+}
+
+// lookupMultihashCode returns the multihash code of the given message digest algorithm.
+func lookupMultihashCode(name MessageDigestAlgorithm) (uint64, bool) {
+	if name == "" {
+		name = MessageDigestAlgorithmSHA256
+	}
+
+	messageDigestsMu.RLock()
+	defer messageDigestsMu.RUnlock()
+
+	code, found := multihashCodes[name]
+	return code, found
+}
+
+// lookupMultihashAlgorithm returns the name of the message digest algorithm
+// with the given multihash code.
+func lookupMultihashAlgorithm(code uint64) (MessageDigestAlgorithm, bool) {
+	messageDigestsMu.RLock()
+	defer messageDigestsMu.RUnlock()
+
+	for name, c := range multihashCodes {
+		if c == code {
+			return name, true
+		}
+	}
+	return "", false
+}
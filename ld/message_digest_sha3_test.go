@@ -0,0 +1,41 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.24
+
+package ld_test
+
+import (
+	"crypto/sha3"
+	"hash"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSHA3MessageDigest(t *testing.T) {
+	RestoreMessageDigestsOnCleanup(t)
+
+	RegisterMessageDigestAlgorithm("SHA3-256", func() hash.Hash { return sha3.New256() })
+
+	actual, err := normalizeWithDigest(t, "SHA3-256")
+	require.NoError(t, err)
+	assert.Equal(t, "_:c14n0 <http://schema.org/name> \"A\" .\n_:c14n1 <http://schema.org/name> \"B\" .\n", actual)
+
+	expected, err := normalizeWithDigest(t, MessageDigestAlgorithmSHA256)
+	require.NoError(t, err)
+	assert.NotEqual(t, expected, actual)
+}
@@ -0,0 +1,115 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// digestTestInput has two blank nodes with different first degree hashes, so the
+// canonical labels depend on the message digest algorithm.
+const digestTestInput = `_:x <http://schema.org/name> "A" .
+_:y <http://schema.org/name> "B" .
+`
+
+func normalizeWithDigest(t *testing.T, digest MessageDigestAlgorithm) (string, error) {
+	t.Helper()
+	opts := NewJsonLdOptions("")
+	opts.InputFormat = "application/n-quads"
+	opts.Format = "application/n-quads"
+	opts.Algorithm = AlgorithmURDNA2015
+	opts.MessageDigestAlgorithm = digest
+	normalized, err := NewJsonLdProcessor().Normalize(digestTestInput, opts)
+	if err != nil {
+		return "", err
+	}
+	return normalized.(string), nil
+}
+
+func TestRegisterMessageDigestAlgorithm(t *testing.T) {
+	RestoreMessageDigestsOnCleanup(t)
+
+	var calls int64
+	RegisterMessageDigestAlgorithm("COUNTING-SHA256", func() hash.Hash {
+		atomic.AddInt64(&calls, 1)
+		return sha256.New()
+	})
+	assert.Contains(t, MessageDigestAlgorithms(), MessageDigestAlgorithm("COUNTING-SHA256"))
+
+	expected, err := normalizeWithDigest(t, MessageDigestAlgorithmSHA256)
+	require.NoError(t, err)
+	actual, err := normalizeWithDigest(t, "COUNTING-SHA256")
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+	assert.Greater(t, atomic.LoadInt64(&calls), int64(0))
+
+	// an empty name selects SHA-256
+	actual, err = normalizeWithDigest(t, "")
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	RegisterMessageDigestAlgorithm("SHA512/256", sha512.New512_256)
+	actual, err = normalizeWithDigest(t, "SHA512/256")
+	require.NoError(t, err)
+	assert.Equal(t, "_:c14n0 <http://schema.org/name> \"A\" .\n_:c14n1 <http://schema.org/name> \"B\" .\n", actual)
+	assert.NotEqual(t, expected, actual)
+}
+
+func TestRestoreMessageDigestsOnCleanup(t *testing.T) {
+	t.Run("register", func(t *testing.T) {
+		RestoreMessageDigestsOnCleanup(t)
+		RegisterMessageDigestAlgorithm("TEMPORARY-SHA256", sha256.New)
+		assert.Contains(t, MessageDigestAlgorithms(), MessageDigestAlgorithm("TEMPORARY-SHA256"))
+	})
+	assert.NotContains(t, MessageDigestAlgorithms(), MessageDigestAlgorithm("TEMPORARY-SHA256"))
+}
+
+func TestUnknownMessageDigestAlgorithm(t *testing.T) {
+	_, err := normalizeWithDigest(t, "SHA3-1024")
+	require.Error(t, err)
+	assert.Equal(t, UnknownMessageDigestAlgorithm, err.(*JsonLdError).Code) //nolint:errorlint
+
+	dataset, err := ParseNQuads(digestTestInput)
+	require.NoError(t, err)
+
+	opts := NewJsonLdOptions("")
+	opts.Algorithm = AlgorithmURDNA2015
+	opts.MessageDigestAlgorithm = "SHA3-1024"
+	_, err = NewJsonLdApi().Normalize(dataset, opts)
+	require.Error(t, err)
+	assert.Equal(t, UnknownMessageDigestAlgorithm, err.(*JsonLdError).Code) //nolint:errorlint
+
+	err = NewNormalisationAlgorithm(AlgorithmURDNA2015, "SHA3-1024").Normalize(dataset)
+	require.Error(t, err)
+	assert.Equal(t, UnknownMessageDigestAlgorithm, err.(*JsonLdError).Code) //nolint:errorlint
+
+	err = NewNormalisationAlgorithm(AlgorithmURDNA2015, "SHA3-1024").NormalizeTo(dataset, &bytes.Buffer{}, 0, "")
+	require.Error(t, err)
+	assert.Equal(t, UnknownMessageDigestAlgorithm, err.(*JsonLdError).Code) //nolint:errorlint
+
+	// URGNA2012 always uses SHA-1
+	opts.Algorithm = AlgorithmURGNA2012
+	_, err = NewJsonLdApi().Normalize(dataset, opts)
+	assert.NoError(t, err)
+}
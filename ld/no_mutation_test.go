@@ -0,0 +1,243 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pristineLoader serves test suite documents from a cache, the way a caching loader
+// shared between requests would, and keeps a pristine copy of every document it returns.
+type pristineLoader struct {
+	next     DocumentLoader
+	mu       sync.Mutex
+	cache    map[string]*RemoteDocument
+	pristine map[string]interface{}
+}
+
+func newPristineLoader() *pristineLoader {
+	return &pristineLoader{
+		next: NewFSDocumentLoader(os.DirFS("testdata"), map[string]string{
+			"https://w3c.github.io/json-ld-api/tests/":     ".",
+			"https://w3c.github.io/json-ld-framing/tests/": ".",
+		}),
+		cache:    make(map[string]*RemoteDocument),
+		pristine: make(map[string]interface{}),
+	}
+}
+
+func (l *pristineLoader) LoadDocument(u string) (*RemoteDocument, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if doc, cached := l.cache[u]; cached {
+		return doc, nil
+	}
+	doc, err := l.next.LoadDocument(u)
+	if err != nil {
+		return nil, err
+	}
+	l.cache[u] = doc
+	l.pristine[u] = CloneDocument(doc.Document)
+	return doc, nil
+}
+
+func (l *pristineLoader) assertUnchanged(t *testing.T, name string) {
+	t.Helper()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for u, doc := range l.cache {
+		if !assert.Equal(t, l.pristine[u], doc.Document, "%s modified the cached document %s", name, u) {
+			// report each modification once
+			delete(l.cache, u)
+		}
+	}
+}
+
+func readTestDocument(t *testing.T, fileName string) interface{} {
+	t.Helper()
+	f, err := os.Open(fileName)
+	require.NoError(t, err)
+	defer f.Close()
+	doc, err := DocumentFromReader(f)
+	require.NoError(t, err)
+	return doc
+}
+
+// TestOperationsDontModifyInputs runs the processor operations over the test suite
+// and checks that neither the arguments nor the documents returned by the loader change.
+func TestOperationsDontModifyInputs(t *testing.T) {
+	proc := NewJsonLdProcessor()
+	loader := newPristineLoader()
+
+	for _, manifestName := range []string{"expand", "compact", "flatten", "frame", "toRdf"} {
+		manifest := readTestDocument(t, filepath.Join("testdata", manifestName+"-manifest.jsonld")).(map[string]interface{})
+		baseIRI := manifest["baseIri"].(string)
+
+		for _, entry := range manifest["sequence"].([]interface{}) {
+			test := entry.(map[string]interface{})
+			inputName, _ := test["input"].(string)
+			if !strings.HasSuffix(inputName, ".jsonld") {
+				continue
+			}
+			name := manifestName + test["@id"].(string)
+
+			input := readTestDocument(t, filepath.Join("testdata", inputName))
+			var context interface{}
+			if contextName, hasContext := test["context"].(string); hasContext {
+				context = readTestDocument(t, filepath.Join("testdata", contextName))
+			}
+			var frame interface{}
+			if frameName, hasFrame := test["frame"].(string); hasFrame {
+				frame = readTestDocument(t, filepath.Join("testdata", frameName))
+			}
+			var expandContext interface{}
+			if testOpts, hasOpts := test["option"].(map[string]interface{}); hasOpts {
+				if contextName, hasContext := testOpts["expandContext"].(string); hasContext {
+					expandContext = readTestDocument(t, filepath.Join("testdata", contextName))
+				}
+			}
+
+			originalInput := CloneDocument(input)
+			originalContext := CloneDocument(context)
+			originalFrame := CloneDocument(frame)
+			originalExpandContext := CloneDocument(expandContext)
+
+			opts := NewJsonLdOptions(baseIRI + inputName)
+			opts.DocumentLoader = loader
+			opts.ExpandContext = expandContext
+
+			// errors are expected for negative tests; only the inputs matter here
+			switch manifestName {
+			case "expand":
+				_, _ = proc.Expand(input, opts)
+			case "compact":
+				_, _ = proc.Compact(input, context, opts)
+			case "flatten":
+				_, _ = proc.Flatten(input, context, opts)
+			case "frame":
+				_, _ = proc.Frame(input, frame, opts)
+			case "toRdf":
+				_, _ = proc.ToRDF(input, opts)
+				normOpts := NewJsonLdOptions(baseIRI + inputName)
+				normOpts.DocumentLoader = loader
+				_, _ = proc.Normalize(input, normOpts)
+			}
+
+			assert.Equal(t, originalInput, input, "%s modified the input", name)
+			assert.Equal(t, originalContext, context, "%s modified the context", name)
+			assert.Equal(t, originalFrame, frame, "%s modified the frame", name)
+			assert.Equal(t, originalExpandContext, expandContext, "%s modified the expansion context", name)
+			loader.assertUnchanged(t, name)
+		}
+	}
+}
+
+func TestOperationsDontModifyOptions(t *testing.T) {
+	proc := NewJsonLdProcessor()
+	doc := map[string]interface{}{
+		"@context": map[string]interface{}{"@vocab": "http://schema.org/"},
+		"@id":      "http://example.org/jane",
+		"name":     "Jane",
+	}
+
+	opts := NewJsonLdOptions("")
+	opts.ExpandContext = map[string]interface{}{"@context": map[string]interface{}{"ex": "http://example.org/"}}
+	original := *opts
+	original.ExpandContext = CloneDocument(opts.ExpandContext)
+
+	_, err := proc.Expand(doc, opts)
+	require.NoError(t, err)
+	_, err = proc.Compact(doc, doc["@context"], opts)
+	require.NoError(t, err)
+	_, err = proc.Flatten(doc, doc["@context"], opts)
+	require.NoError(t, err)
+	_, err = proc.Frame(doc, map[string]interface{}{"@context": doc["@context"]}, opts)
+	require.NoError(t, err)
+	_, err = proc.ToRDF(doc, opts)
+	require.NoError(t, err)
+	_, err = proc.FromRDF("<http://example.org/jane> <http://schema.org/name> \"Jane\" .\n", opts)
+	require.NoError(t, err)
+	_, err = proc.Normalize(doc, opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, original.ExpandContext, opts.ExpandContext)
+	original.ExpandContext = opts.ExpandContext
+	assert.Equal(t, &original, opts)
+}
+
+func TestNormalizeDoesntModifyDataset(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "normalization", "test*-in.nq"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		for _, algorithm := range []string{AlgorithmURDNA2015, AlgorithmURGNA2012} {
+			dataset, err := ParseNQuads(string(data))
+			require.NoError(t, err)
+			original, err := ParseNQuads(string(data))
+			require.NoError(t, err)
+
+			opts := NewJsonLdOptions("")
+			opts.Algorithm = algorithm
+			opts.Format = "application/n-quads"
+
+			first, err := NewJsonLdApi().Normalize(dataset, opts)
+			require.NoError(t, err)
+			assert.Equal(t, original, dataset, "%s (%s)", file, algorithm)
+
+			// normalising the same dataset again must produce the same result
+			second, err := NewJsonLdApi().Normalize(dataset, opts)
+			require.NoError(t, err)
+			assert.Equal(t, first, second, "%s (%s)", file, algorithm)
+		}
+	}
+}
+
+func TestFromRDFDoesntModifyDataset(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "fromRdf", "*-in.nq"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		dataset, err := ParseNQuads(string(data))
+		if err != nil {
+			continue
+		}
+		original, err := ParseNQuads(string(data))
+		require.NoError(t, err)
+
+		for _, useRdfType := range []bool{false, true} {
+			opts := NewJsonLdOptions("")
+			opts.UseRdfType = useRdfType
+			opts.UseNativeTypes = true
+			_, _ = NewJsonLdApi().FromRDF(dataset, opts)
+			assert.Equal(t, original, dataset, file)
+		}
+	}
+
+}
@@ -230,7 +230,7 @@ func RdfToObject(n Node, useNativeTypes bool) (map[string]interface{}, error) {
 
 // objectToRDF converts a JSON-LD value object to an RDF literal or a JSON-LD string or
 // node object to an RDF resource.
-func objectToRDF(item interface{}, issuer *IdentifierIssuer, graphName string, triples []*Quad) (Node, []*Quad) {
+func objectToRDF(item interface{}, issuer *IdentifierIssuer, graphName string, triples []*Quad) (Node, []*Quad, error) {
 	// convert value object to RDF
 	if IsValue(item) {
 		itemMap := item.(map[string]interface{})
@@ -268,85 +268,96 @@ func objectToRDF(item interface{}, issuer *IdentifierIssuer, graphName string, t
 			// convert to XSD datatype
 			if isBool {
 				if datatype == nil {
-					return NewLiteral(strconv.FormatBool(booleanVal), XSDBoolean, ""), triples
+					return NewLiteral(strconv.FormatBool(booleanVal), XSDBoolean, ""), triples, nil
 				} else {
-					return NewLiteral(strconv.FormatBool(booleanVal), datatypeStr, ""), triples
+					return NewLiteral(strconv.FormatBool(booleanVal), datatypeStr, ""), triples, nil
 				}
 			} else if (isFloat && !isInteger) || XSDDouble == datatypeStr {
 				canonicalDouble := GetCanonicalDouble(floatVal)
 				if datatype == nil {
-					return NewLiteral(canonicalDouble, XSDDouble, ""), triples
+					return NewLiteral(canonicalDouble, XSDDouble, ""), triples, nil
 				} else {
-					return NewLiteral(canonicalDouble, datatypeStr, ""), triples
+					return NewLiteral(canonicalDouble, datatypeStr, ""), triples, nil
 				}
 			} else {
 				if datatype == nil {
-					return NewLiteral(fmt.Sprintf("%d", int64(floatVal)), XSDInteger, ""), triples
+					return NewLiteral(fmt.Sprintf("%d", int64(floatVal)), XSDInteger, ""), triples, nil
 				} else {
-					return NewLiteral(fmt.Sprintf("%d", int64(floatVal)), datatype.(string), ""), triples
+					return NewLiteral(fmt.Sprintf("%d", int64(floatVal)), datatypeStr, ""), triples, nil
 				}
 			}
-		} else if langVal, hasLang := itemMap["@language"]; hasLang {
-			if datatype == nil {
-				return NewLiteral(value.(string), RDFLangString, langVal.(string)), triples
+		}
+
+		if datatypeStr == RDFJSONLiteral {
+			var jsonLiteralValByte []byte
+			if v, isString := value.(string); isString {
+				jsonLiteralValByte = []byte(v)
 			} else {
-				return NewLiteral(value.(string), datatype.(string), langVal.(string)), triples
+				byteVal, err := json.Marshal(value)
+				if err != nil {
+					return NewLiteral("JSON Marshal error "+err.Error(), datatypeStr, ""), triples, nil
+				}
+				jsonLiteralValByte = byteVal
 			}
-		} else {
-			if datatype == nil {
-				return NewLiteral(value.(string), XSDString, ""), triples
-			} else {
-				if datatype != RDFJSONLiteral {
-					return NewLiteral(value.(string), datatype.(string), ""), triples
-				} else {
-					var jsonLiteralValByte []byte
-					switch v := value.(type) {
-					case string:
-						jsonLiteralValByte = []byte(v)
-					case map[string]interface{}:
-						byteVal, err := json.Marshal(v)
-						if err != nil {
-							return NewLiteral("JSON Marshal error "+err.Error(), datatype.(string), ""), triples
-						}
 
-						jsonLiteralValByte = byteVal
-					}
+			canonicalJSON, err := jsoncanonicalizer.Transform(jsonLiteralValByte)
+			if err != nil {
+				return NewLiteral("JSON Canonicalization error "+err.Error(), datatypeStr, ""), triples, nil
+			}
 
-					canonicalJSON, err := jsoncanonicalizer.Transform(jsonLiteralValByte)
-					if err != nil {
-						return NewLiteral("JSON Canonicalization error "+err.Error(), datatype.(string), ""), triples
-					}
+			return NewLiteral(string(canonicalJSON), datatypeStr, ""), triples, nil
+		}
 
-					return NewLiteral(string(canonicalJSON), datatype.(string), ""), triples
-				}
+		// anything else must be a string
+		valueStr, isString := value.(string)
+		if !isString {
+			return nil, triples, NewJsonLdError(InvalidValueObject,
+				fmt.Sprintf("value of @value must be a scalar, got %v", value))
+		}
+		if langVal, hasLang := itemMap["@language"]; hasLang {
+			langStr, isString := langVal.(string)
+			if !isString {
+				return nil, triples, NewJsonLdError(InvalidLanguageTaggedString,
+					fmt.Sprintf("value of @language must be a string, got %v", langVal))
+			}
+			if datatype == nil {
+				return NewLiteral(valueStr, RDFLangString, langStr), triples, nil
+			} else {
+				return NewLiteral(valueStr, datatypeStr, langStr), triples, nil
 			}
+		} else if datatype == nil {
+			return NewLiteral(valueStr, XSDString, ""), triples, nil
+		} else {
+			return NewLiteral(valueStr, datatypeStr, ""), triples, nil
 		}
 	} else if IsList(item) {
 		// if item is a list object, initialize list_results as an empty array,
 		// and object to the result of the List Conversion algorithm, passing
 		// the value associated with the @list key from item and list_results.
-		return parseList(item.(map[string]interface{})["@list"].([]interface{}), issuer, graphName, triples)
+		return parseList(Arrayify(item.(map[string]interface{})["@list"]), issuer, graphName, triples)
 	} else {
 		// convert string/node object to RDF
 		var id string
 		if itemMap, isMap := item.(map[string]interface{}); isMap {
-			id = itemMap["@id"].(string)
-			if IsRelativeIri(id) {
-				return nil, triples
+			id, _ = itemMap["@id"].(string)
+			if id == "" || IsRelativeIri(id) {
+				return nil, triples, nil
 			}
+		} else if idStr, isString := item.(string); isString {
+			id = idStr
 		} else {
-			id = item.(string)
+			return nil, triples, NewJsonLdError(InvalidInput, fmt.Sprintf("cannot convert %v to an RDF term", item))
 		}
 		if strings.Index(id, "_:") == 0 {
 			// NOTE: once again no need to rename existing blank nodes
-			return NewBlankNode(id), triples
+			return NewBlankNode(id), triples, nil
 		} else {
-			return NewIRI(id), triples
+			return NewIRI(id), triples, nil
 		}
 	}
 }
 
-func parseList(list []interface{}, issuer *IdentifierIssuer, graphName string, triples []*Quad) (Node, []*Quad) {
+func parseList(list []interface{}, issuer *IdentifierIssuer, graphName string, triples []*Quad) (Node, []*Quad, error) {
 
 	var res Node
 	var last interface{}
@@ -361,24 +372,31 @@ func parseList(list []interface{}, issuer *IdentifierIssuer, graphName string, t
 	subj := res
 
 	var obj Node
+	var err error
 	for i := 0; i < len(list)-1; i++ {
-		obj, triples = objectToRDF(list[i], issuer, graphName, triples)
+		obj, triples, err = objectToRDF(list[i], issuer, graphName, triples)
+		if err != nil {
+			return nil, triples, err
+		}
 		next := NewBlankNode(issuer.GetId(""))
-		triples = append(triples,
-			NewQuad(subj, first, obj, graphName),
-			NewQuad(subj, rest, next, graphName),
-		)
+		if obj != nil {
+			triples = append(triples, NewQuad(subj, first, obj, graphName))
+		}
+		triples = append(triples, NewQuad(subj, rest, next, graphName))
 		subj = next
 	}
 
 	// tail of list
 	if last != nil {
-		obj, triples = objectToRDF(last, issuer, graphName, triples)
-		triples = append(triples,
-			NewQuad(subj, first, obj, graphName),
-			NewQuad(subj, rest, nilIRI, graphName),
-		)
+		obj, triples, err = objectToRDF(last, issuer, graphName, triples)
+		if err != nil {
+			return nil, triples, err
+		}
+		if obj != nil {
+			triples = append(triples, NewQuad(subj, first, obj, graphName))
+		}
+		triples = append(triples, NewQuad(subj, rest, nilIRI, graphName))
 	}
 
-	return res, triples
+	return res, triples, nil
 }
@@ -42,6 +42,8 @@ type JsonLdOptions struct { //nolint:stylecheck
 	ProcessingMode string
 	// http://www.w3.org/TR/json-ld-api/#widl-JsonLdOptions-documentLoader
 	DocumentLoader DocumentLoader
+	// https://www.w3.org/TR/json-ld11-api/#dom-jsonldoptions-requestprofile
+	RequestProfile []string
 
 	// Frame options: http://json-ld.org/spec/latest/json-ld-framing/
 
@@ -67,7 +69,24 @@ type JsonLdOptions struct { //nolint:stylecheck
 	OutputForm    string
 	SafeMode      bool
 
+	// MessageDigestAlgorithm is the name of the hash function used by URDNA2015 normalization.
+	// See RegisterMessageDigestAlgorithm for adding hash functions.
 	MessageDigestAlgorithm MessageDigestAlgorithm
+
+	// NormalizationMaxLines, if greater than zero, limits the number of canonical N-Quads
+	// lines kept in memory during normalization. Sorted chunks of lines are written
+	// to temporary files in NormalizationTempDir and merged afterwards.
+	NormalizationMaxLines int
+	// NormalizationTempDir is the directory for temporary files. If empty,
+	// the default directory for temporary files is used.
+	NormalizationTempDir string
+	// NormalizationWorkers is the number of goroutines used to calculate blank node hashes
+	// during normalization. Values less than 2 disable parallel hashing.
+	// The result doesn't depend on this option.
+	NormalizationWorkers int
+
+	// contextCache is set by JsonLdProcessor and used when parsing remote contexts
+	contextCache *ContextCache
 }
 
 // NewJsonLdOptions creates and returns new instance of JsonLdOptions with the given base.
@@ -90,6 +109,9 @@ func NewJsonLdOptions(base string) *JsonLdOptions { //nolint:stylecheck
 		Format:                 "",
 		Algorithm:              AlgorithmURGNA2012,
 		MessageDigestAlgorithm: MessageDigestAlgorithmSHA256, // https://w3c.github.io/rdf-canon/spec/#dfn-hash-algorithm
+		NormalizationMaxLines:  0,
+		NormalizationTempDir:   "",
+		NormalizationWorkers:   0,
 		UseNamespaces:          false,
 		OutputForm:             "",
 		SafeMode:               false,
@@ -104,6 +126,7 @@ func (opt *JsonLdOptions) Copy() *JsonLdOptions {
 		ExpandContext:          opt.ExpandContext,
 		ProcessingMode:         opt.ProcessingMode,
 		DocumentLoader:         opt.DocumentLoader,
+		RequestProfile:         opt.RequestProfile,
 		Embed:                  opt.Embed,
 		Explicit:               opt.Explicit,
 		RequireAll:             opt.RequireAll,
@@ -117,8 +140,12 @@ func (opt *JsonLdOptions) Copy() *JsonLdOptions {
 		Format:                 opt.Format,
 		Algorithm:              opt.Algorithm,
 		MessageDigestAlgorithm: opt.MessageDigestAlgorithm,
+		NormalizationMaxLines:  opt.NormalizationMaxLines,
+		NormalizationTempDir:   opt.NormalizationTempDir,
+		NormalizationWorkers:   opt.NormalizationWorkers,
 		UseNamespaces:          opt.UseNamespaces,
 		OutputForm:             opt.OutputForm,
 		SafeMode:               opt.SafeMode,
+		contextCache:           opt.contextCache,
 	}
 }
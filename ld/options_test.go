@@ -25,9 +25,13 @@ func TestJsonLdOptions_Copy(t *testing.T) {
 		Format:                 "format",
 		Algorithm:              AlgorithmURGNA2012,
 		MessageDigestAlgorithm: MessageDigestAlgorithmSHA256,
+		NormalizationMaxLines:  1000,
+		NormalizationTempDir:   "/tmp",
+		NormalizationWorkers:   4,
 		UseNamespaces:          true,
 		OutputForm:             "output",
 		SafeMode:               true,
+		RequestProfile:         []string{"http://www.w3.org/ns/json-ld#expanded"},
 	}
 	assert.Equal(t, expected, *expected.Copy())
 }
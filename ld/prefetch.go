@@ -0,0 +1,157 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"strings"
+	"sync"
+)
+
+// maxPrefetchConcurrency is the maximum number of contexts loaded at the same time by PrefetchContexts.
+const maxPrefetchConcurrency = 8
+
+// PrefetchContexts loads all remote contexts referenced by the given input in parallel.
+// This includes contexts referenced from other contexts, imported contexts and
+// scoped contexts. The loaded contexts aren't processed; the call is only useful
+// if opts.DocumentLoader caches documents (e.g. CachingDocumentLoader), so that
+// the processing which follows doesn't need to wait for the network.
+//
+// All contexts are loaded even if some of them fail; the first error is returned.
+func (jldp *JsonLdProcessor) PrefetchContexts(input interface{}, opts *JsonLdOptions) error {
+	if opts == nil {
+		opts = NewJsonLdOptions("")
+	}
+
+	base := opts.Base
+	var urls []string
+	if iri, isString := input.(string); isString && strings.Contains(iri, ":") {
+		rd, err := loadDocumentWithOptions(opts.DocumentLoader, iri, &LoadDocumentOptions{
+			RequestProfile: opts.RequestProfile,
+		})
+		if err != nil {
+			return err
+		}
+		input = rd.Document
+		if base == "" {
+			base = rd.DocumentURL
+		}
+		if rd.ContextURL != "" {
+			urls = append(urls, Resolve(base, rd.ContextURL))
+		}
+	}
+
+	if opts.ExpandContext != nil {
+		urls = collectContextURLsFromContext(opts.ExpandContext, base, urls)
+	}
+	urls = collectContextURLs(input, base, urls)
+
+	p := &contextPrefetcher{
+		loader: opts.DocumentLoader,
+		seen:   make(map[string]bool),
+		sem:    make(chan struct{}, maxPrefetchConcurrency),
+	}
+	p.fetchAll(urls)
+	p.wg.Wait()
+
+	return p.err
+}
+
+type contextPrefetcher struct {
+	loader DocumentLoader
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	seen map[string]bool
+	err  error
+}
+
+func (p *contextPrefetcher) fetchAll(urls []string) {
+	for _, u := range urls {
+		p.mu.Lock()
+		seen := p.seen[u]
+		p.seen[u] = true
+		p.mu.Unlock()
+		if seen {
+			continue
+		}
+
+		p.wg.Add(1)
+		go p.fetch(u)
+	}
+}
+
+func (p *contextPrefetcher) fetch(u string) {
+	defer p.wg.Done()
+
+	p.sem <- struct{}{}
+	rd, err := loadDocumentWithOptions(p.loader, u, contextLoadOptions())
+	<-p.sem
+
+	if err != nil {
+		p.mu.Lock()
+		if p.err == nil {
+			p.err = NewJsonLdError(LoadingRemoteContextFailed, err)
+		}
+		p.mu.Unlock()
+		return
+	}
+
+	p.fetchAll(collectContextURLs(rd.Document, u, nil))
+}
+
+// collectContextURLs appends absolute URLs of all remote contexts referenced by @context
+// entries anywhere in the given document to urls.
+func collectContextURLs(document interface{}, base string, urls []string) []string {
+	switch v := document.(type) {
+	case []interface{}:
+		for _, item := range v {
+			urls = collectContextURLs(item, base, urls)
+		}
+	case map[string]interface{}:
+		for key, val := range v {
+			if key == "@context" {
+				urls = collectContextURLsFromContext(val, base, urls)
+			} else {
+				urls = collectContextURLs(val, base, urls)
+			}
+		}
+	}
+	return urls
+}
+
+// collectContextURLsFromContext appends absolute URLs of remote contexts referenced by
+// the given local context, including imported and scoped contexts, to urls.
+func collectContextURLsFromContext(context interface{}, base string, urls []string) []string {
+	switch ctx := context.(type) {
+	case string:
+		if u := Resolve(base, ctx); IsAbsoluteIri(u) {
+			urls = append(urls, u)
+		}
+	case []interface{}:
+		for _, item := range ctx {
+			urls = collectContextURLsFromContext(item, base, urls)
+		}
+	case map[string]interface{}:
+		if importStr, isString := ctx["@import"].(string); isString {
+			if u := Resolve(base, importStr); IsAbsoluteIri(u) {
+				urls = append(urls, u)
+			}
+		}
+		// scoped contexts are @context entries of term definitions
+		urls = collectContextURLs(ctx, base, urls)
+	}
+	return urls
+}
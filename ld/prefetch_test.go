@@ -0,0 +1,100 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLoader struct {
+	nextLoader DocumentLoader
+	mu         sync.Mutex
+	loaded     []string
+}
+
+func (rl *recordingLoader) LoadDocument(u string) (*RemoteDocument, error) {
+	rl.mu.Lock()
+	rl.loaded = append(rl.loaded, u)
+	rl.mu.Unlock()
+	return rl.nextLoader.LoadDocument(u)
+}
+
+func TestPrefetchContexts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"base.jsonld": {Data: []byte(`{"@context": [
+			"https://example.org/nested.jsonld",
+			{
+				"@import": "https://example.org/imported.jsonld",
+				"Person": {"@id": "http://schema.org/Person", "@context": "https://example.org/scoped.jsonld"}
+			}
+		]}`)},
+		"nested.jsonld":   {Data: []byte(`{"@context": {"name": "http://schema.org/name"}}`)},
+		"imported.jsonld": {Data: []byte(`{"@context": {"knows": "http://schema.org/knows"}}`)},
+		"scoped.jsonld":   {Data: []byte(`{"@context": {"title": "http://schema.org/title"}}`)},
+		"other.jsonld":    {Data: []byte(`{"@context": {"url": "http://schema.org/url"}}`)},
+	}
+	recorder := &recordingLoader{
+		nextLoader: NewFSDocumentLoader(fsys, map[string]string{"https://example.org/": "."}),
+	}
+	cl := NewCachingDocumentLoader(recorder)
+
+	opts := NewJsonLdOptions("")
+	opts.DocumentLoader = cl
+
+	doc := map[string]interface{}{
+		"@context": "https://example.org/base.jsonld",
+		"@type":    "Person",
+		"name":     "Jane Doe",
+		"knows": map[string]interface{}{
+			"@context": "https://example.org/other.jsonld",
+			"url":      "http://example.org/jane",
+		},
+	}
+
+	proc := NewJsonLdProcessor()
+	require.NoError(t, proc.PrefetchContexts(doc, opts))
+
+	sort.Strings(recorder.loaded)
+	assert.Equal(t, []string{
+		"https://example.org/base.jsonld",
+		"https://example.org/imported.jsonld",
+		"https://example.org/nested.jsonld",
+		"https://example.org/other.jsonld",
+		"https://example.org/scoped.jsonld",
+	}, recorder.loaded)
+
+	// processing is served from the cache
+	_, err := proc.Expand(doc, opts)
+	require.NoError(t, err)
+	assert.Len(t, recorder.loaded, 5)
+}
+
+func TestPrefetchContextsReportsErrors(t *testing.T) {
+	opts := NewJsonLdOptions("")
+	opts.DocumentLoader = NewFSDocumentLoader(fstest.MapFS{}, nil)
+
+	err := NewJsonLdProcessor().PrefetchContexts(map[string]interface{}{
+		"@context": "https://example.org/missing.jsonld",
+	}, opts)
+	require.Error(t, err)
+	assert.Equal(t, LoadingRemoteContextFailed, err.(*JsonLdError).Code) //nolint:errorlint
+}
@@ -16,12 +16,18 @@ package ld
 
 import (
 	"fmt"
+	"io"
 	"strings"
 )
 
 // JsonLdProcessor implements the JsonLdProcessor interface, see
 // http://www.w3.org/TR/json-ld-api/#the-jsonldprocessor-interface
+//
+// The operations never modify their arguments (input documents, contexts, frames and options)
+// or the documents returned by the document loader, so these may be shared between concurrent
+// calls. Results may still share JSON literals and other leaf values with the input.
 type JsonLdProcessor struct { //nolint:stylecheck
+	contextCache *ContextCache
 }
 
 // NewJsonLdProcessor creates an instance of JsonLdProcessor.
@@ -29,18 +35,34 @@ func NewJsonLdProcessor() *JsonLdProcessor { //nolint:stylecheck
 	return &JsonLdProcessor{}
 }
 
-// Compact operation compacts the given input using the context according to the steps
-// in the Compaction algorithm: http://www.w3.org/TR/json-ld-api/#compaction-algorithm
-func (jldp *JsonLdProcessor) Compact(input interface{}, context interface{},
-	opts *JsonLdOptions) (map[string]interface{}, error) {
+// NewJsonLdProcessorWithContextCache creates an instance of JsonLdProcessor which reuses
+// parsed remote contexts from the given cache. The cache may be shared between processors.
+func NewJsonLdProcessorWithContextCache(cache *ContextCache) *JsonLdProcessor { //nolint:stylecheck
+	return &JsonLdProcessor{contextCache: cache}
+}
 
+// prepareOptions returns a copy of the given options (or the default options, if nil)
+// set up for use by this processor.
+func (jldp *JsonLdProcessor) prepareOptions(opts *JsonLdOptions) *JsonLdOptions {
 	if opts == nil {
 		opts = NewJsonLdOptions("")
 	} else {
 		opts = opts.Copy()
 	}
+	if jldp.contextCache != nil {
+		opts.contextCache = jldp.contextCache
+	}
+	return opts
+}
+
+// Compact operation compacts the given input using the context according to the steps
+// in the Compaction algorithm: http://www.w3.org/TR/json-ld-api/#compaction-algorithm
+func (jldp *JsonLdProcessor) Compact(input interface{}, context interface{},
+	opts *JsonLdOptions) (map[string]interface{}, error) {
+
+	opts = jldp.prepareOptions(opts)
 
-	if inputStr, isString := input.(string); isString && opts.Base == "" {
+	if inputStr, isString := input.(string); isString && opts.Base == "" && !isDataURL(inputStr) {
 		opts.Base = inputStr
 	}
 
@@ -65,6 +87,16 @@ func (jldp *JsonLdProcessor) Compact(input interface{}, context interface{},
 	if err != nil {
 		return nil, err
 	}
+	// the output must contain the original context, not the compiled or parsed one
+	switch ctx := context.(type) {
+	case *CompiledContext:
+		context = ctx.Source()
+	case *Context:
+		context, err = ctx.sourceContext()
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// 8)
 	api := NewJsonLdApi()
@@ -106,11 +138,7 @@ func (jldp *JsonLdProcessor) Compact(input interface{}, context interface{},
 // http://www.w3.org/TR/json-ld-api/#expansion-algorithm
 func (jldp *JsonLdProcessor) Expand(input interface{}, opts *JsonLdOptions) ([]interface{}, error) {
 
-	if opts == nil {
-		opts = NewJsonLdOptions("")
-	} else {
-		opts = opts.Copy()
-	}
+	opts = jldp.prepareOptions(opts)
 
 	return jldp.expand(input, opts)
 }
@@ -124,21 +152,28 @@ func (jldp *JsonLdProcessor) expand(input interface{}, opts *JsonLdOptions) ([]i
 
 	// 2)
 	if iri, isString := input.(string); isString && strings.Contains(iri, ":") {
-		rd, err := opts.DocumentLoader.LoadDocument(iri)
+		rd, err := loadDocumentWithOptions(opts.DocumentLoader, iri, &LoadDocumentOptions{
+			RequestProfile: opts.RequestProfile,
+		})
 		if err != nil {
 			return nil, err
 		}
 		if rd.Document == "" {
 			return nil, NewJsonLdError(LoadingDocumentFailed, err)
 		}
+		// loaders which don't report the content type are trusted to return JSON
+		if rd.ContentType != "" && !isJSONMediaType(rd.ContentType) {
+			return nil, NewJsonLdError(LoadingDocumentFailed,
+				fmt.Sprintf("unsupported content type %s of document %s", rd.ContentType, iri))
+		}
 		input = rd.Document
 		iri = rd.DocumentURL
 
 		// if set the base in options should override the base iri in the
 		// active context
 		// thus only set this as the base iri if it's not already set in
-		// options
-		if opts.Base == "" {
+		// options. Data URLs are opaque, so they can't serve as a base IRI.
+		if opts.Base == "" && !isDataURL(iri) {
 			opts.Base = iri
 		}
 
@@ -147,28 +182,14 @@ func (jldp *JsonLdProcessor) expand(input interface{}, opts *JsonLdOptions) ([]i
 		}
 	}
 
-	// 3)
-	activeCtx := NewContext(nil, opts)
-
-	// 4)
-	if opts.ExpandContext != nil {
-		exCtx := CloneDocument(opts.ExpandContext)
-		if exCtxMap, isMap := exCtx.(map[string]interface{}); isMap {
-			if ctx, hasCtx := exCtxMap["@context"]; hasCtx {
-				exCtx = ctx
-			}
-		}
-
-		var err error
-		activeCtx, err = activeCtx.Parse(exCtx)
-		if err != nil {
-			return nil, err
-		}
+	// 3-4)
+	activeCtx, err := initialExpansionContext(opts)
+	if err != nil {
+		return nil, err
 	}
 
 	// 5)
 	if remoteContext != "" {
-		var err error
 		if activeCtx, err = activeCtx.Parse(remoteContext); err != nil {
 			return nil, err
 		}
@@ -203,18 +224,39 @@ func (jldp *JsonLdProcessor) expand(input interface{}, opts *JsonLdOptions) ([]i
 	return []interface{}{expanded}, nil
 }
 
+// initialExpansionContext returns the active context expansion starts with:
+// an empty context, updated with the expandContext option, if set.
+func initialExpansionContext(opts *JsonLdOptions) (*Context, error) {
+	// 3)
+	activeCtx := NewContext(nil, opts)
+
+	// 4)
+	if opts.ExpandContext != nil {
+		exCtx := CloneDocument(opts.ExpandContext)
+		if exCtxMap, isMap := exCtx.(map[string]interface{}); isMap {
+			if ctx, hasCtx := exCtxMap["@context"]; hasCtx {
+				exCtx = ctx
+			}
+		}
+
+		var err error
+		activeCtx, err = activeCtx.Parse(exCtx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return activeCtx, nil
+}
+
 // Flatten operation flattens the given input and compacts it using the passed context
 // according to the steps in the Flattening algorithm:
 // http://www.w3.org/TR/json-ld-api/#flattening-algorithm
 func (jldp *JsonLdProcessor) Flatten(input interface{}, context interface{}, opts *JsonLdOptions) (interface{}, error) {
 
-	if opts == nil {
-		opts = NewJsonLdOptions("")
-	} else {
-		opts = opts.Copy()
-	}
+	opts = jldp.prepareOptions(opts)
 
-	if inputStr, isString := input.(string); isString && opts.Base == "" {
+	if inputStr, isString := input.(string); isString && opts.Base == "" && !isDataURL(inputStr) {
 		opts.Base = inputStr
 	}
 
@@ -324,19 +366,18 @@ func (jldp *JsonLdProcessor) Flatten(input interface{}, context interface{}, opt
 // Returns the framed JSON-LD document.
 func (jldp *JsonLdProcessor) Frame(input interface{}, frame interface{}, opts *JsonLdOptions) (map[string]interface{}, error) {
 
-	if opts == nil {
-		opts = NewJsonLdOptions("")
-	} else {
-		opts = opts.Copy()
-	}
+	opts = jldp.prepareOptions(opts)
 
-	if inputStr, isString := input.(string); isString && opts.Base == "" {
+	if inputStr, isString := input.(string); isString && opts.Base == "" && !isDataURL(inputStr) {
 		opts.Base = inputStr
 	}
 
-	if _, isMap := frame.(map[string]interface{}); isMap {
-		frame = CloneDocument(frame)
+	frameMap, isMap := frame.(map[string]interface{})
+	if !isMap {
+		return nil, NewJsonLdError(InvalidFrame, "frame must be a JSON object")
 	}
+	frameMap = CloneDocument(frameMap).(map[string]interface{})
+	frame = frameMap
 
 	// 2. Set expanded input to the result of using the expand method using input and options.
 	expandedInput, err := jldp.Expand(input, opts)
@@ -362,14 +403,13 @@ func (jldp *JsonLdProcessor) Frame(input interface{}, frame interface{}, opts *J
 	api := NewJsonLdApi()
 
 	// FIXME should look for aliases of @graph
-	_, graphInFrame := frame.(map[string]interface{})["@graph"]
+	_, graphInFrame := frameMap["@graph"]
 
 	framed, bnodesToClear, err := api.Frame(expandedInput, expandedFrame, opts, !graphInFrame)
 	if err != nil {
 		return nil, err
 	}
 
-	frameMap := frame.(map[string]interface{})
 	activeCtx := NewContext(nil, opts)
 	activeCtx, err = activeCtx.Parse(frameMap["@context"])
 	if err != nil {
@@ -434,11 +474,7 @@ var rdfSerializers = map[string]RDFSerializer{
 // false not to (default: true).
 func (jldp *JsonLdProcessor) FromRDF(dataset interface{}, opts *JsonLdOptions) (interface{}, error) {
 
-	if opts == nil {
-		opts = NewJsonLdOptions("")
-	} else {
-		opts = opts.Copy()
-	}
+	opts = jldp.prepareOptions(opts)
 
 	// handle non specified serializer case
 	if _, isString := dataset.(string); opts.Format == "" && isString {
@@ -493,11 +529,7 @@ func (jldp *JsonLdProcessor) fromRDF(input interface{}, opts *JsonLdOptions, ser
 // [format] the format to use to output a string: 'application/n-quads' for N-Quads (default).
 func (jldp *JsonLdProcessor) ToRDF(input interface{}, opts *JsonLdOptions) (interface{}, error) {
 
-	if opts == nil {
-		opts = NewJsonLdOptions("")
-	} else {
-		opts = opts.Copy()
-	}
+	opts = jldp.prepareOptions(opts)
 
 	expandedInput, err := jldp.expand(input, opts)
 	if err != nil {
@@ -540,29 +572,62 @@ func (jldp *JsonLdProcessor) ToRDF(input interface{}, opts *JsonLdOptions) (inte
 }
 
 // Normalize RDF dataset normalization on the given input. The input is
-// JSON-LD unless the 'inputFormat' option is used. The output is an RDF
-// dataset unless the 'format' option is used.
+// JSON-LD unless the 'inputFormat' option is used or it's an *RDFDataset.
+// The output is an RDF dataset unless the 'format' option is used.
 func (jldp *JsonLdProcessor) Normalize(input interface{}, opts *JsonLdOptions) (interface{}, error) {
 
-	if opts == nil {
-		opts = NewJsonLdOptions("")
-	} else {
-		opts = opts.Copy()
+	opts = jldp.prepareOptions(opts)
+
+	dataset, err := jldp.normalizationInput(input, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	api := NewJsonLdApi()
+	return api.Normalize(dataset, opts)
+}
+
+// NormalizeTo performs RDF dataset normalization on the given input and writes
+// the result to w in N-Quads format. The input is JSON-LD unless the 'inputFormat'
+// option is used or it's an *RDFDataset.
+//
+// Set the NormalizationMaxLines option to normalize large datasets without keeping
+// all canonical N-Quads in memory.
+func (jldp *JsonLdProcessor) NormalizeTo(input interface{}, w io.Writer, opts *JsonLdOptions) error {
+
+	opts = jldp.prepareOptions(opts)
+
+	dataset, err := jldp.normalizationInput(input, opts)
+	if err != nil {
+		return err
 	}
 
+	algo := newNormalisationAlgorithmWithOptions(opts)
+	return algo.NormalizeTo(dataset, w, opts.NormalizationMaxLines, opts.NormalizationTempDir)
+}
+
+// normalizationInput validates the normalization options and converts the input to an RDF dataset.
+func (jldp *JsonLdProcessor) normalizationInput(input interface{}, opts *JsonLdOptions) (*RDFDataset, error) {
 	if opts.Algorithm != AlgorithmURDNA2015 && opts.Algorithm != AlgorithmURGNA2012 {
 		return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("Unknown normalization algorithm: %s",
 			opts.Algorithm))
 	}
+	if opts.Algorithm == AlgorithmURDNA2015 {
+		if _, err := lookupMessageDigest(opts.MessageDigestAlgorithm); err != nil {
+			return nil, err
+		}
+	}
 
 	var dataset *RDFDataset
-	if opts.InputFormat != "" {
+	if ds, isDataset := input.(*RDFDataset); isDataset {
+		dataset = ds
+	} else if opts.InputFormat != "" {
 		if opts.InputFormat != "application/n-quads" && opts.InputFormat != "application/nquads" {
 			return nil, NewJsonLdError(UnknownFormat, "Unknown normalization input format")
 		}
-		serializer, hasSerializer := rdfSerializers[opts.Format]
+		serializer, hasSerializer := rdfSerializers[opts.InputFormat]
 		if !hasSerializer {
-			return nil, NewJsonLdError(UnknownFormat, opts.Format)
+			return nil, NewJsonLdError(UnknownFormat, opts.InputFormat)
 		}
 		var err error
 		if dataset, err = serializer.Parse(input); err != nil {
@@ -571,6 +636,7 @@ func (jldp *JsonLdProcessor) Normalize(input interface{}, opts *JsonLdOptions) (
 	} else {
 		toRDFOpts := NewJsonLdOptions(opts.Base)
 		toRDFOpts.ProcessingMode = opts.ProcessingMode
+		toRDFOpts.SafeMode = opts.SafeMode
 		toRDFOpts.Format = ""
 		// it's important to pass the original DocumentLoader. The default one will be used otherwise!
 		toRDFOpts.DocumentLoader = opts.DocumentLoader
@@ -582,6 +648,5 @@ func (jldp *JsonLdProcessor) Normalize(input interface{}, opts *JsonLdOptions) (
 		dataset = datasetObj.(*RDFDataset)
 	}
 
-	api := NewJsonLdApi()
-	return api.Normalize(dataset, opts)
+	return dataset, nil
 }
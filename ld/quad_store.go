@@ -0,0 +1,385 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"fmt"
+	"sort"
+)
+
+// QuadStore is an in-memory set of quads with SPO, POS and OSP indexes in every graph,
+// so that quads can be matched by any combination of subject, predicate and object
+// without scanning the whole dataset.
+//
+// Graphs are identified by their names, as in RDFDataset.Graphs: "@default" for the default graph,
+// IRIs or blank node identifiers for named graphs. The store keeps the order in which
+// quads were added. QuadStore isn't safe for concurrent use if any goroutine modifies it.
+type QuadStore struct {
+	graphs     map[string]*indexedGraph
+	size       int
+	namespaces map[string]string
+}
+
+// storedQuad is an entry of an indexedGraph. Its position changes when the graph gets compacted.
+type storedQuad struct {
+	quad     *Quad
+	position int
+}
+
+// termIndex maps the terms of the first, second and third components of a quad to the quad.
+type termIndex map[string]map[string]termLeaves
+
+// indexedGraph holds the quads of one graph in insertion order, with nil entries
+// in place of removed quads until the graph gets compacted.
+type indexedGraph struct {
+	quads   []*storedQuad
+	removed int
+
+	spo termIndex
+	pos termIndex
+	osp termIndex
+}
+
+// NewQuadStore creates an empty QuadStore.
+func NewQuadStore() *QuadStore {
+	return &QuadStore{
+		graphs:     make(map[string]*indexedGraph),
+		namespaces: make(map[string]string),
+	}
+}
+
+// NewQuadStoreFromDataset creates a QuadStore with all quads of the given dataset.
+// The quads themselves aren't copied, so they must not be modified while they're in the store.
+// Duplicate quads are added once.
+func NewQuadStoreFromDataset(dataset *RDFDataset) *QuadStore {
+	qs := NewQuadStore()
+	for ns, prefix := range dataset.GetNamespaces() {
+		qs.namespaces[ns] = prefix
+	}
+	for graphName, quads := range dataset.Graphs {
+		graph := qs.graph(graphName, true)
+		for _, quad := range quads {
+			if graph.add(quad) {
+				qs.size++
+			}
+		}
+	}
+	return qs
+}
+
+// Dataset returns the quads of the store as an RDFDataset, in the order they were added.
+// The dataset shares the quads with the store, but not the slices holding them.
+func (qs *QuadStore) Dataset() *RDFDataset {
+	ds := NewRDFDataset()
+	for ns, prefix := range qs.namespaces {
+		ds.SetNamespace(ns, prefix)
+	}
+	for graphName, graph := range qs.graphs {
+		quads := make([]*Quad, 0, len(graph.quads)-graph.removed)
+		for _, sq := range graph.quads {
+			if sq != nil {
+				quads = append(quads, sq.quad)
+			}
+		}
+		if len(quads) > 0 || graphName == "@default" {
+			ds.Graphs[graphName] = quads
+		}
+	}
+	return ds
+}
+
+// Len returns the number of quads in the store.
+func (qs *QuadStore) Len() int {
+	return qs.size
+}
+
+// GraphNames returns the names of all non-empty graphs, sorted, with "@default" first.
+func (qs *QuadStore) GraphNames() []string {
+	names := make([]string, 0, len(qs.graphs))
+	for name, graph := range qs.graphs {
+		if len(graph.quads) > graph.removed {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "@default" || names[j] == "@default" {
+			return names[i] == "@default" && names[j] != "@default"
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// Add adds the quad to the graph given by quad.Graph (the default graph if it's nil).
+// It returns false if the store already contains an equal quad.
+func (qs *QuadStore) Add(quad *Quad) bool {
+	if qs.graph(quadGraphName(quad), true).add(quad) {
+		qs.size++
+		return true
+	}
+	return false
+}
+
+// Remove removes the quad equal to the given one. It returns false if there's no such quad.
+func (qs *QuadStore) Remove(quad *Quad) bool {
+	graph := qs.graph(quadGraphName(quad), false)
+	if graph == nil || !graph.remove(quad) {
+		return false
+	}
+	qs.size--
+	return true
+}
+
+// Contains returns true if the store contains a quad equal to the given one.
+func (qs *QuadStore) Contains(quad *Quad) bool {
+	graph := qs.graph(quadGraphName(quad), false)
+	if graph == nil {
+		return false
+	}
+	_, found := graph.spo.get(NodeTerm(quad.Subject), NodeTerm(quad.Predicate), NodeTerm(quad.Object))
+	return found
+}
+
+// Match returns all quads matching the given pattern. Nil subject, predicate or object
+// and an empty graph name match anything. Quads are grouped by graph, in the order
+// of GraphNames, and sorted in the order they were added within a graph.
+func (qs *QuadStore) Match(subject, predicate, object Node, graphName string) []*Quad {
+	var rval []*Quad
+	qs.ForEachMatch(subject, predicate, object, graphName, func(quad *Quad) bool {
+		rval = append(rval, quad)
+		return true
+	})
+	return rval
+}
+
+// ForEachMatch calls fn for every quad matching the given pattern, in the same order as Match,
+// until fn returns false. fn must not modify the store.
+func (qs *QuadStore) ForEachMatch(subject, predicate, object Node, graphName string, fn func(quad *Quad) bool) {
+	graphNames := []string{graphName}
+	if graphName == "" {
+		graphNames = qs.GraphNames()
+	}
+
+	for _, name := range graphNames {
+		graph := qs.graph(name, false)
+		if graph == nil {
+			continue
+		}
+		for _, sq := range graph.match(subject, predicate, object) {
+			if !fn(sq.quad) {
+				return
+			}
+		}
+	}
+}
+
+// ForEach calls fn for every quad in the store, in the same order as Match, until fn returns false.
+// fn must not modify the store.
+func (qs *QuadStore) ForEach(fn func(quad *Quad) bool) {
+	qs.ForEachMatch(nil, nil, nil, "", fn)
+}
+
+// List returns the items of the RDF collection (rdf:List) starting at head in the given graph
+// ("@default" if graphName is empty). An error is returned if the list is malformed:
+// a node without exactly one rdf:first and one rdf:rest value, or a cycle.
+func (qs *QuadStore) List(head Node, graphName string) ([]Node, error) {
+	if graphName == "" {
+		graphName = "@default"
+	}
+
+	var items []Node
+	visited := make(map[string]bool)
+	for node := head; !node.Equal(nilIRI); {
+		term := NodeTerm(node)
+		if visited[term] {
+			return nil, NewJsonLdError(InvalidInput, fmt.Sprintf("rdf:List contains a cycle at %s", term))
+		}
+		visited[term] = true
+
+		firsts := qs.Match(node, first, nil, graphName)
+		rests := qs.Match(node, rest, nil, graphName)
+		if len(firsts) != 1 || len(rests) != 1 {
+			return nil, NewJsonLdError(InvalidInput,
+				fmt.Sprintf("rdf:List node %s must have exactly one rdf:first and one rdf:rest", term))
+		}
+		items = append(items, firsts[0].Object)
+		node = rests[0].Object
+	}
+	return items, nil
+}
+
+func (qs *QuadStore) graph(graphName string, create bool) *indexedGraph {
+	graph := qs.graphs[graphName]
+	if graph == nil && create {
+		graph = &indexedGraph{
+			spo: make(termIndex),
+			pos: make(termIndex),
+			osp: make(termIndex),
+		}
+		qs.graphs[graphName] = graph
+	}
+	return graph
+}
+
+func (g *indexedGraph) add(quad *Quad) bool {
+	s, p, o := NodeTerm(quad.Subject), NodeTerm(quad.Predicate), NodeTerm(quad.Object)
+	if _, found := g.spo.get(s, p, o); found {
+		return false
+	}
+
+	sq := &storedQuad{quad: quad, position: len(g.quads)}
+	g.quads = append(g.quads, sq)
+	g.spo.put(s, p, o, sq)
+	g.pos.put(p, o, s, sq)
+	g.osp.put(o, s, p, sq)
+	return true
+}
+
+func (g *indexedGraph) remove(quad *Quad) bool {
+	s, p, o := NodeTerm(quad.Subject), NodeTerm(quad.Predicate), NodeTerm(quad.Object)
+	sq, found := g.spo.get(s, p, o)
+	if !found {
+		return false
+	}
+
+	g.spo.delete(s, p, o)
+	g.pos.delete(p, o, s)
+	g.osp.delete(o, s, p)
+	g.quads[sq.position] = nil
+	g.removed++
+
+	// compact the graph when most of it is gone
+	if g.removed > len(g.quads)/2 {
+		live := g.quads[:0]
+		for _, sq := range g.quads {
+			if sq != nil {
+				sq.position = len(live)
+				live = append(live, sq)
+			}
+		}
+		for i := len(live); i < len(g.quads); i++ {
+			g.quads[i] = nil
+		}
+		g.quads = live
+		g.removed = 0
+	}
+	return true
+}
+
+// match returns the matching quads in insertion order, using the index which fits the pattern.
+func (g *indexedGraph) match(subject, predicate, object Node) []*storedQuad {
+	var s, p, o string
+	if subject != nil {
+		s = NodeTerm(subject)
+	}
+	if predicate != nil {
+		p = NodeTerm(predicate)
+	}
+	if object != nil {
+		o = NodeTerm(object)
+	}
+
+	var rval []*storedQuad
+	switch {
+	case subject != nil && predicate != nil && object != nil:
+		if sq, found := g.spo.get(s, p, o); found {
+			rval = append(rval, sq)
+		}
+		return rval
+	case subject != nil && object != nil:
+		rval = g.osp[o][s].collect(rval)
+	case subject != nil && predicate != nil:
+		rval = g.spo[s][p].collect(rval)
+	case subject != nil:
+		for _, objects := range g.spo[s] {
+			rval = objects.collect(rval)
+		}
+	case predicate != nil && object != nil:
+		rval = g.pos[p][o].collect(rval)
+	case predicate != nil:
+		for _, subjects := range g.pos[p] {
+			rval = subjects.collect(rval)
+		}
+	case object != nil:
+		for _, predicates := range g.osp[o] {
+			rval = predicates.collect(rval)
+		}
+	default:
+		for _, sq := range g.quads {
+			if sq != nil {
+				rval = append(rval, sq)
+			}
+		}
+		return rval
+	}
+
+	sort.Slice(rval, func(i, j int) bool { return rval[i].position < rval[j].position })
+	return rval
+}
+
+// termLeaves maps the terms of the third components of quads to the quads.
+type termLeaves map[string]*storedQuad
+
+func (l termLeaves) collect(dst []*storedQuad) []*storedQuad {
+	for _, sq := range l {
+		dst = append(dst, sq)
+	}
+	return dst
+}
+
+func (ti termIndex) get(a, b, c string) (*storedQuad, bool) {
+	sq, found := ti[a][b][c]
+	return sq, found
+}
+
+func (ti termIndex) put(a, b, c string, sq *storedQuad) {
+	second, found := ti[a]
+	if !found {
+		second = make(map[string]termLeaves)
+		ti[a] = second
+	}
+	third, found := second[b]
+	if !found {
+		third = make(termLeaves)
+		second[b] = third
+	}
+	third[c] = sq
+}
+
+func (ti termIndex) delete(a, b, c string) {
+	second := ti[a]
+	third := second[b]
+	delete(third, c)
+	if len(third) == 0 {
+		delete(second, b)
+		if len(second) == 0 {
+			delete(ti, a)
+		}
+	}
+}
+
+// NodeTerm returns the N-Quads term of the given node (an IRI, blank node or literal),
+// which identifies it uniquely. It can be used as a map key for nodes.
+func NodeTerm(n Node) string {
+	return objectTerm(n)
+}
+
+// quadGraphName returns the name of the graph of the quad, as used in RDFDataset.Graphs.
+func quadGraphName(quad *Quad) string {
+	if quad.Graph == nil {
+		return "@default"
+	}
+	return quad.Graph.GetValue()
+}
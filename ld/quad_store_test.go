@@ -0,0 +1,263 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const quadStoreInput = `<http://example.org/alice> <http://xmlns.com/foaf/0.1/knows> <http://example.org/bob> .
+<http://example.org/alice> <http://xmlns.com/foaf/0.1/knows> <http://example.org/carol> .
+<http://example.org/alice> <http://xmlns.com/foaf/0.1/name> "Alice" .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/knows> <http://example.org/carol> .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/name> "Bob"@en .
+<http://example.org/carol> <http://xmlns.com/foaf/0.1/name> "Carol" .
+<http://example.org/carol> <http://xmlns.com/foaf/0.1/age> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/carol> <http://xmlns.com/foaf/0.1/knows> _:b0 .
+_:b0 <http://xmlns.com/foaf/0.1/name> "Alice" .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/knows> <http://example.org/alice> <http://example.org/graph1> .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/name> "Bob" <http://example.org/graph1> .
+_:b0 <http://xmlns.com/foaf/0.1/knows> <http://example.org/alice> _:g .
+`
+
+func newTestQuadStore(t *testing.T) (*QuadStore, *RDFDataset) {
+	t.Helper()
+	dataset, err := ParseNQuads(quadStoreInput)
+	require.NoError(t, err)
+	return NewQuadStoreFromDataset(dataset), dataset
+}
+
+// scanMatch is a reference implementation of QuadStore.Match.
+func scanMatch(dataset *RDFDataset, s, p, o Node, graphName string) []*Quad {
+	var rval []*Quad
+	for _, name := range []string{"@default", "_:g", "http://example.org/graph1"} {
+		if graphName != "" && graphName != name {
+			continue
+		}
+		for _, quad := range dataset.Graphs[name] {
+			if (s == nil || s.Equal(quad.Subject)) && (p == nil || p.Equal(quad.Predicate)) &&
+				(o == nil || o.Equal(quad.Object)) {
+				rval = append(rval, quad)
+			}
+		}
+	}
+	return rval
+}
+
+func TestQuadStoreMatch(t *testing.T) {
+	qs, dataset := newTestQuadStore(t)
+	assert.Equal(t, 12, qs.Len())
+	assert.Equal(t, []string{"@default", "_:g", "http://example.org/graph1"}, qs.GraphNames())
+
+	// every combination of bound and unbound terms of every quad, plus terms
+	// which don't occur in the dataset
+	patterns := make([][3]Node, 0)
+	for _, quad := range qs.Match(nil, nil, nil, "") {
+		for mask := 0; mask < 8; mask++ {
+			var pattern [3]Node
+			if mask&1 != 0 {
+				pattern[0] = quad.Subject
+			}
+			if mask&2 != 0 {
+				pattern[1] = quad.Predicate
+			}
+			if mask&4 != 0 {
+				pattern[2] = quad.Object
+			}
+			patterns = append(patterns, pattern)
+		}
+	}
+	patterns = append(patterns,
+		[3]Node{NewIRI("http://example.org/dave"), nil, nil},
+		[3]Node{nil, nil, NewLiteral("Bob", "", "")},
+		[3]Node{nil, nil, NewLiteral("42", XSDInteger, "")},
+		[3]Node{nil, nil, NewLiteral("42", "", "")},
+	)
+
+	for _, pattern := range patterns {
+		for _, graphName := range []string{"", "@default", "http://example.org/graph1", "_:g", "http://example.org/none"} {
+			expected := scanMatch(dataset, pattern[0], pattern[1], pattern[2], graphName)
+			actual := qs.Match(pattern[0], pattern[1], pattern[2], graphName)
+			assert.Equal(t, expected, actual, "%v in %q", pattern, graphName)
+		}
+	}
+
+	knows := NewIRI("http://xmlns.com/foaf/0.1/knows")
+	objects := qs.Match(NewIRI("http://example.org/alice"), knows, nil, "@default")
+	require.Len(t, objects, 2)
+	assert.Equal(t, "http://example.org/bob", objects[0].Object.GetValue())
+	assert.Equal(t, "http://example.org/carol", objects[1].Object.GetValue())
+
+	count := 0
+	qs.ForEach(func(quad *Quad) bool {
+		count++
+		return count < 3
+	})
+	assert.Equal(t, 3, count)
+}
+
+func TestQuadStoreAddRemove(t *testing.T) {
+	qs, _ := newTestQuadStore(t)
+
+	bob := NewIRI("http://example.org/bob")
+	name := NewIRI("http://xmlns.com/foaf/0.1/name")
+
+	duplicate := NewQuad(bob, name, NewLiteral("Bob", RDFLangString, "en"), "@default")
+	assert.True(t, qs.Contains(duplicate))
+	assert.False(t, qs.Add(duplicate))
+	assert.Equal(t, 12, qs.Len())
+
+	added := NewQuad(bob, name, NewLiteral("Robert", "", ""), "http://example.org/graph2")
+	assert.True(t, qs.Add(added))
+	assert.Equal(t, 13, qs.Len())
+	assert.Equal(t, []*Quad{added}, qs.Match(nil, nil, nil, "http://example.org/graph2"))
+
+	assert.True(t, qs.Remove(NewQuad(bob, name, NewLiteral("Robert", "", ""), "http://example.org/graph2")))
+	assert.False(t, qs.Remove(added))
+	assert.False(t, qs.Contains(added))
+	assert.Empty(t, qs.Match(nil, nil, nil, "http://example.org/graph2"))
+	assert.NotContains(t, qs.GraphNames(), "http://example.org/graph2")
+	assert.Equal(t, 12, qs.Len())
+
+	// removing most of a graph compacts it, keeping the order of the other quads
+	all := qs.Match(nil, nil, nil, "@default")
+	for i, quad := range all {
+		if i%4 != 0 {
+			assert.True(t, qs.Remove(quad))
+		}
+	}
+	assert.Equal(t, []*Quad{all[0], all[4], all[8]}, qs.Match(nil, nil, nil, "@default"))
+	assert.Equal(t, []*Quad{all[4]}, qs.Match(bob, nil, nil, "@default"))
+	assert.Equal(t, 6, qs.Len())
+
+	// removed quads can be added again
+	assert.True(t, qs.Add(all[1]))
+	assert.Equal(t, []*Quad{all[0], all[4], all[8], all[1]}, qs.Match(nil, nil, nil, "@default"))
+}
+
+func TestQuadStoreDataset(t *testing.T) {
+	qs, dataset := newTestQuadStore(t)
+
+	roundTrip := qs.Dataset()
+	assert.Equal(t, dataset.Graphs, roundTrip.Graphs)
+
+	api := NewJsonLdApi()
+	opts := NewJsonLdOptions("")
+	expected, err := api.FromRDF(dataset, opts)
+	require.NoError(t, err)
+	actual, err := api.FromRDF(roundTrip, opts)
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	// the store can be updated without affecting the dataset it was created from
+	qs.Remove(dataset.Graphs["_:g"][0])
+	assert.Len(t, dataset.Graphs["_:g"], 1)
+	assert.NotContains(t, qs.Dataset().Graphs, "_:g")
+	assert.Contains(t, NewQuadStore().Dataset().Graphs, "@default")
+}
+
+func TestQuadStoreList(t *testing.T) {
+	doc := map[string]interface{}{
+		"@id": "http://example.org/playlist",
+		"http://example.org/tracks": map[string]interface{}{
+			"@list": []interface{}{"one", "two", map[string]interface{}{"@id": "http://example.org/three"}},
+		},
+		"http://example.org/empty": map[string]interface{}{"@list": []interface{}{}},
+	}
+	dataset, err := NewJsonLdProcessor().ToRDF(doc, nil)
+	require.NoError(t, err)
+	qs := NewQuadStoreFromDataset(dataset.(*RDFDataset))
+
+	playlist := NewIRI("http://example.org/playlist")
+	heads := qs.Match(playlist, NewIRI("http://example.org/tracks"), nil, "")
+	require.Len(t, heads, 1)
+	items, err := qs.List(heads[0].Object, "")
+	require.NoError(t, err)
+	assert.Equal(t, []Node{
+		NewLiteral("one", "", ""),
+		NewLiteral("two", "", ""),
+		NewIRI("http://example.org/three"),
+	}, items)
+
+	// the list is in the default graph
+	_, err = qs.List(heads[0].Object, "http://example.org/graph")
+	assert.Error(t, err)
+
+	heads = qs.Match(playlist, NewIRI("http://example.org/empty"), nil, "")
+	require.Len(t, heads, 1)
+	items, err = qs.List(heads[0].Object, "")
+	require.NoError(t, err)
+	assert.Empty(t, items)
+
+	for name, input := range map[string]string{
+		"missing rdf:rest": `_:l <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "a" .
+`,
+		"two rdf:first values": `_:l <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "a" .
+_:l <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "b" .
+_:l <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .
+`,
+		"cycle": `_:l <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "a" .
+_:l <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:m .
+_:m <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "b" .
+_:m <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:l .
+`,
+	} {
+		dataset, err := ParseNQuads(input)
+		require.NoError(t, err)
+		_, err = NewQuadStoreFromDataset(dataset).List(NewBlankNode("_:l"), "@default")
+		require.Error(t, err, name)
+		assert.Equal(t, InvalidInput, err.(*JsonLdError).Code, name) //nolint:errorlint
+	}
+}
+
+func BenchmarkQuadStoreMatch(b *testing.B) {
+	dataset, err := ParseNQuads(credentialBatch(1000))
+	require.NoError(b, err)
+	qs := NewQuadStoreFromDataset(dataset)
+
+	b.Run("store", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			subject := NewBlankNode(fmt.Sprintf("_:subj%d", i%1000))
+			if len(qs.Match(subject, nil, nil, "@default")) != 2 {
+				b.Fatal("unexpected result")
+			}
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			subject := NewBlankNode(fmt.Sprintf("_:subj%d", i%1000))
+			if len(scanMatch(dataset, subject, nil, nil, "@default")) != 2 {
+				b.Fatal("unexpected result")
+			}
+		}
+	})
+}
+
+func TestNodeTerm(t *testing.T) {
+	assert.Equal(t, "<http://example.org/alice>", NodeTerm(NewIRI("http://example.org/alice")))
+	assert.Equal(t, "_:b0", NodeTerm(NewBlankNode("_:b0")))
+	assert.Equal(t, `"Alice"`, NodeTerm(NewLiteral("Alice", XSDString, "")))
+	assert.Equal(t, `"Bob"@en`, NodeTerm(NewLiteral("Bob", RDFLangString, "en")))
+	assert.Equal(t, `"27"^^<http://www.w3.org/2001/XMLSchema#integer>`, NodeTerm(NewLiteral("27", XSDInteger, "")))
+
+	// nodes of different kinds with the same value have different terms
+	assert.NotEqual(t, NodeTerm(NewIRI("_:b0")), NodeTerm(NewBlankNode("_:b0")))
+	assert.NotEqual(t, NodeTerm(NewIRI("http://example.org/")), NodeTerm(NewLiteral("http://example.org/", XSDString, "")))
+}
@@ -0,0 +1,344 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/piprate/json-gold/ld"
+)
+
+// binding maps variable names to their values in a solution.
+type binding map[string]ld.Node
+
+// compatible returns true if the bindings agree on the values of their shared variables.
+func (b binding) compatible(other binding) bool {
+	for name, node := range b {
+		if value, bound := other[name]; bound && !value.Equal(node) {
+			return false
+		}
+	}
+	return true
+}
+
+// merge returns a new binding with the variables of both bindings.
+func (b binding) merge(other binding) binding {
+	rval := make(binding, len(b)+len(other))
+	for name, node := range b {
+		rval[name] = node
+	}
+	for name, node := range other {
+		rval[name] = node
+	}
+	return rval
+}
+
+// evaluator evaluates graph patterns against a store, following the algebra
+// of the SPARQL 1.1 specification (section 18).
+type evaluator struct {
+	store *ld.QuadStore
+}
+
+// evalGroup evaluates a group pattern in the given active graph.
+func (e *evaluator) evalGroup(g *groupPattern, graphName string) []binding {
+	return filterSolutions(e.evalGroupElements(g, graphName), g.filters)
+}
+
+// evalGroupElements evaluates a group pattern without applying its filters.
+func (e *evaluator) evalGroupElements(g *groupPattern, graphName string) []binding {
+	solutions := []binding{{}}
+	for _, element := range g.elements {
+		switch el := element.(type) {
+		case *basicGraphPattern:
+			// joining with a basic graph pattern is the same as matching it
+			// once for every solution with the variables of the solution bound
+			solutions = e.evalBGP(el, graphName, solutions)
+		case *groupPattern:
+			solutions = join(solutions, e.evalGroup(el, graphName))
+		case *optionalPattern:
+			// the filters of the optional group are the condition of the left join
+			solutions = leftJoin(solutions, e.evalGroupElements(el.group, graphName), el.group.filters)
+		case *unionPattern:
+			var alternatives []binding
+			for _, alternative := range el.alternatives {
+				alternatives = append(alternatives, e.evalGroup(alternative, graphName)...)
+			}
+			solutions = join(solutions, alternatives)
+		case *graphPattern:
+			solutions = join(solutions, e.evalGraph(el))
+		}
+		if len(solutions) == 0 {
+			break
+		}
+	}
+	return solutions
+}
+
+// evalBGP matches the triple patterns of a basic graph pattern in the active graph,
+// extending each of the given solutions.
+func (e *evaluator) evalBGP(bgp *basicGraphPattern, graphName string, solutions []binding) []binding {
+	for _, tp := range bgp.triples {
+		var next []binding
+		for _, b := range solutions {
+			subject, predicate, object := tp.subject.value(b), tp.predicate.value(b), tp.object.value(b)
+			e.store.ForEachMatch(subject, predicate, object, graphName, func(quad *ld.Quad) bool {
+				if extended, ok := tp.bind(b, quad); ok {
+					next = append(next, extended)
+				}
+				return true
+			})
+		}
+		solutions = next
+		if len(solutions) == 0 {
+			break
+		}
+	}
+	return solutions
+}
+
+// evalGraph evaluates a GRAPH pattern. A variable graph name iterates over all named graphs.
+func (e *evaluator) evalGraph(gp *graphPattern) []binding {
+	if gp.name.variable == "" {
+		return e.evalGroup(gp.group, gp.name.node.GetValue())
+	}
+
+	var solutions []binding
+	for _, graphName := range e.store.GraphNames() {
+		if graphName == "@default" {
+			continue
+		}
+		var graphNode ld.Node
+		if strings.HasPrefix(graphName, "_:") {
+			graphNode = ld.NewBlankNode(graphName)
+		} else {
+			graphNode = ld.NewIRI(graphName)
+		}
+		for _, b := range e.evalGroup(gp.group, graphName) {
+			if value, bound := b[gp.name.variable]; bound {
+				if !value.Equal(graphNode) {
+					continue
+				}
+			} else {
+				b = b.merge(binding{gp.name.variable: graphNode})
+			}
+			solutions = append(solutions, b)
+		}
+	}
+	return solutions
+}
+
+func join(left, right []binding) []binding {
+	var rval []binding
+	for _, l := range left {
+		for _, r := range right {
+			if l.compatible(r) {
+				rval = append(rval, l.merge(r))
+			}
+		}
+	}
+	return rval
+}
+
+func leftJoin(left, right []binding, filters []expression) []binding {
+	var rval []binding
+	for _, l := range left {
+		extended := false
+		for _, r := range right {
+			if !l.compatible(r) {
+				continue
+			}
+			merged := l.merge(r)
+			if matchesFilters(merged, filters) {
+				rval = append(rval, merged)
+				extended = true
+			}
+		}
+		if !extended {
+			rval = append(rval, l)
+		}
+	}
+	return rval
+}
+
+func filterSolutions(solutions []binding, filters []expression) []binding {
+	if len(filters) == 0 {
+		return solutions
+	}
+	rval := solutions[:0]
+	for _, b := range solutions {
+		if matchesFilters(b, filters) {
+			rval = append(rval, b)
+		}
+	}
+	return rval
+}
+
+// matchesFilters returns true if the effective boolean values of all filters are true.
+// Evaluation errors count as false.
+func matchesFilters(b binding, filters []expression) bool {
+	for _, filter := range filters {
+		if v, err := evaluateEBV(filter, b); err != nil || !v {
+			return false
+		}
+	}
+	return true
+}
+
+// value returns the RDF term, the value of the variable or nil if the variable is unbound.
+func (t *term) value(b binding) ld.Node {
+	if t.variable == "" {
+		return t.node
+	}
+	return b[t.variable]
+}
+
+// bind returns the binding extended with the values of the variables of the triple pattern
+// matched by the quad. It returns false if a variable occurs twice with different values.
+func (tp *triplePattern) bind(b binding, quad *ld.Quad) (binding, bool) {
+	var extended binding
+	for _, pair := range [3]struct {
+		t    *term
+		node ld.Node
+	}{{tp.subject, quad.Subject}, {tp.predicate, quad.Predicate}, {tp.object, quad.Object}} {
+		if pair.t.variable == "" {
+			continue
+		}
+		if extended == nil {
+			extended = b.merge(nil)
+		}
+		if value, bound := extended[pair.t.variable]; bound {
+			if !value.Equal(pair.node) {
+				return nil, false
+			}
+			continue
+		}
+		extended[pair.t.variable] = pair.node
+	}
+	if extended == nil {
+		return b, true
+	}
+	return extended, true
+}
+
+// instantiate returns the RDF term of a CONSTRUCT template for the given solution,
+// or nil if the variable is unbound. Blank nodes get a new label for every solution.
+func (t *term) instantiate(b binding, labels *templateLabels, solution int) ld.Node {
+	switch {
+	case t.variable != "":
+		return b[t.variable]
+	case ld.IsBlankNode(t.node):
+		return labels.blankNode(t.node.GetValue(), solution)
+	default:
+		return t.node
+	}
+}
+
+// templateLabels issues the labels of the blank nodes of a CONSTRUCT template.
+type templateLabels struct {
+	issuer *ld.IdentifierIssuer
+	// used holds the labels of the blank nodes of the data bound to variables
+	used map[string]bool
+	// issued maps the template label and solution number to the issued node
+	issued map[string]ld.Node
+}
+
+func newTemplateLabels(solutions []binding) *templateLabels {
+	labels := &templateLabels{
+		issuer: ld.NewIdentifierIssuer("_:c"),
+		used:   make(map[string]bool),
+		issued: make(map[string]ld.Node),
+	}
+	for _, b := range solutions {
+		for _, node := range b {
+			if bn, isBlankNode := node.(*ld.BlankNode); isBlankNode {
+				labels.used[bn.Attribute] = true
+			}
+		}
+	}
+	return labels
+}
+
+// blankNode returns the blank node for the given template label in the given solution.
+// Its label doesn't clash with the blank nodes of the data.
+func (tl *templateLabels) blankNode(label string, solution int) ld.Node {
+	key := fmt.Sprintf("%s/%d", label, solution)
+	if node, found := tl.issued[key]; found {
+		return node
+	}
+	for {
+		if id := tl.issuer.GetId(""); !tl.used[id] {
+			node := ld.NewBlankNode(id)
+			tl.issued[key] = node
+			return node
+		}
+	}
+}
+
+// sortSolutions sorts the solutions by the ORDER BY conditions.
+func sortSolutions(solutions []binding, conditions []*orderCondition) {
+	sort.SliceStable(solutions, func(i, j int) bool {
+		for _, cond := range conditions {
+			a, _ := cond.expr.evaluate(solutions[i])
+			b, _ := cond.expr.evaluate(solutions[j])
+			c := orderCompare(a, b)
+			if cond.descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// orderCompare compares two values in the ORDER BY order: unbound values (and errors)
+// first, then blank nodes, IRIs and literals. Literals which can't be compared with
+// the operator '<' are ordered by their lexical forms, datatypes and languages.
+func orderCompare(a, b ld.Node) int {
+	ra, rb := orderRank(a), orderRank(b)
+	if ra != rb || a == nil {
+		return ra - rb
+	}
+	if ld.IsLiteral(a) {
+		if c, err := compareValues(a, b); err == nil {
+			return c
+		}
+		la, lb := a.(*ld.Literal), b.(*ld.Literal)
+		if c := strings.Compare(la.Value, lb.Value); c != 0 {
+			return c
+		}
+		if c := strings.Compare(la.Datatype, lb.Datatype); c != 0 {
+			return c
+		}
+		return strings.Compare(la.Language, lb.Language)
+	}
+	return strings.Compare(a.GetValue(), b.GetValue())
+}
+
+func orderRank(n ld.Node) int {
+	switch {
+	case n == nil:
+		return 0
+	case ld.IsBlankNode(n):
+		return 1
+	case ld.IsIRI(n):
+		return 2
+	default:
+		return 3
+	}
+}
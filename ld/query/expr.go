@@ -0,0 +1,574 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/piprate/json-gold/ld"
+	"github.com/piprate/json-gold/ld/internal/xsd"
+)
+
+// errEvaluation is the SPARQL expression evaluation error (for example, a type error
+// or an unbound variable). A FILTER whose expression raises it rejects the solution.
+var errEvaluation = errors.New("expression evaluation error")
+
+// expression is a FILTER or ORDER BY expression.
+type expression interface {
+	evaluate(b binding) (ld.Node, error)
+}
+
+type variableExpr string
+
+type constantExpr struct {
+	node ld.Node
+}
+
+type notExpr struct {
+	arg expression
+}
+
+type signExpr struct {
+	negate bool
+	arg    expression
+}
+
+type logicalExpr struct {
+	and         bool
+	left, right expression
+}
+
+type comparisonExpr struct {
+	op          string
+	left, right expression
+}
+
+type arithmeticExpr struct {
+	op          string
+	left, right expression
+}
+
+type callExpr struct {
+	name string
+	args []expression
+	// regex is the compiled pattern of a REGEX call with a constant pattern and flags
+	regex *regexp.Regexp
+}
+
+// functionArity holds the minimum and maximum number of arguments of the supported functions.
+var functionArity = map[string][2]int{
+	"BOUND":       {1, 1},
+	"STR":         {1, 1},
+	"LANG":        {1, 1},
+	"DATATYPE":    {1, 1},
+	"LANGMATCHES": {2, 2},
+	"SAMETERM":    {2, 2},
+	"ISIRI":       {1, 1},
+	"ISURI":       {1, 1},
+	"ISBLANK":     {1, 1},
+	"ISLITERAL":   {1, 1},
+	"ISNUMERIC":   {1, 1},
+	"LCASE":       {1, 1},
+	"UCASE":       {1, 1},
+	"STRLEN":      {1, 1},
+	"CONTAINS":    {2, 2},
+	"STRSTARTS":   {2, 2},
+	"STRENDS":     {2, 2},
+	"REGEX":       {2, 3},
+}
+
+var (
+	trueLiteral  = ld.NewLiteral("true", ld.XSDBoolean, "")
+	falseLiteral = ld.NewLiteral("false", ld.XSDBoolean, "")
+)
+
+func booleanLiteral(v bool) *ld.Literal {
+	if v {
+		return trueLiteral
+	}
+	return falseLiteral
+}
+
+func (e variableExpr) evaluate(b binding) (ld.Node, error) {
+	if node, bound := b[string(e)]; bound {
+		return node, nil
+	}
+	return nil, errEvaluation
+}
+
+func (e *constantExpr) evaluate(binding) (ld.Node, error) {
+	return e.node, nil
+}
+
+func (e *notExpr) evaluate(b binding) (ld.Node, error) {
+	v, err := evaluateEBV(e.arg, b)
+	if err != nil {
+		return nil, err
+	}
+	return booleanLiteral(!v), nil
+}
+
+func (e *signExpr) evaluate(b binding) (ld.Node, error) {
+	v, err := e.arg.evaluate(b)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := numericValue(v)
+	if !ok {
+		return nil, errEvaluation
+	}
+	if e.negate {
+		n.value = -n.value
+	}
+	return n.literal(), nil
+}
+
+// evaluate implements the logical-or and logical-and operators, which only raise
+// an error if the result can't be determined by the other operand.
+func (e *logicalExpr) evaluate(b binding) (ld.Node, error) {
+	left, leftErr := evaluateEBV(e.left, b)
+	if leftErr == nil && left != e.and {
+		return booleanLiteral(left), nil
+	}
+	right, rightErr := evaluateEBV(e.right, b)
+	switch {
+	case rightErr == nil && right != e.and:
+		return booleanLiteral(right), nil
+	case leftErr != nil:
+		return nil, leftErr
+	case rightErr != nil:
+		return nil, rightErr
+	default:
+		return booleanLiteral(e.and), nil
+	}
+}
+
+func (e *comparisonExpr) evaluate(b binding) (ld.Node, error) {
+	left, err := e.left.evaluate(b)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.evaluate(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.op == "=" || e.op == "!=" {
+		equal, err := valuesEqual(left, right)
+		if err != nil {
+			return nil, err
+		}
+		return booleanLiteral(equal == (e.op == "=")), nil
+	}
+
+	c, err := compareValues(left, right)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "<":
+		return booleanLiteral(c < 0), nil
+	case ">":
+		return booleanLiteral(c > 0), nil
+	case "<=":
+		return booleanLiteral(c <= 0), nil
+	default:
+		return booleanLiteral(c >= 0), nil
+	}
+}
+
+func (e *arithmeticExpr) evaluate(b binding) (ld.Node, error) {
+	left, err := e.left.evaluate(b)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.evaluate(b)
+	if err != nil {
+		return nil, err
+	}
+	l, lok := numericValue(left)
+	r, rok := numericValue(right)
+	if !lok || !rok {
+		return nil, errEvaluation
+	}
+
+	result := numeric{kind: l.kind}
+	if r.kind > result.kind {
+		result.kind = r.kind
+	}
+	switch e.op {
+	case "+":
+		result.value = l.value + r.value
+	case "-":
+		result.value = l.value - r.value
+	case "*":
+		result.value = l.value * r.value
+	default:
+		if result.kind <= numericDecimal {
+			if r.value == 0 {
+				return nil, errEvaluation
+			}
+			result.kind = numericDecimal
+		}
+		result.value = l.value / r.value
+	}
+	return result.literal(), nil
+}
+
+func (e *callExpr) evaluate(b binding) (ld.Node, error) {
+	if e.name == "BOUND" {
+		_, bound := b[string(e.args[0].(variableExpr))]
+		return booleanLiteral(bound), nil
+	}
+
+	args := make([]ld.Node, len(e.args))
+	for i, arg := range e.args {
+		v, err := arg.evaluate(b)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch e.name {
+	case "STR":
+		if ld.IsBlankNode(args[0]) {
+			return nil, errEvaluation
+		}
+		return ld.NewLiteral(args[0].GetValue(), ld.XSDString, ""), nil
+	case "LANG":
+		l, ok := args[0].(*ld.Literal)
+		if !ok {
+			return nil, errEvaluation
+		}
+		return ld.NewLiteral(l.Language, ld.XSDString, ""), nil
+	case "DATATYPE":
+		l, ok := args[0].(*ld.Literal)
+		if !ok {
+			return nil, errEvaluation
+		}
+		return ld.NewIRI(l.Datatype), nil
+	case "LANGMATCHES":
+		tag, ok1 := simpleLiteral(args[0])
+		langRange, ok2 := simpleLiteral(args[1])
+		if !ok1 || !ok2 {
+			return nil, errEvaluation
+		}
+		return booleanLiteral(xsd.LangMatches(tag, langRange)), nil
+	case "SAMETERM":
+		return booleanLiteral(args[0].Equal(args[1])), nil
+	case "ISIRI", "ISURI":
+		return booleanLiteral(ld.IsIRI(args[0])), nil
+	case "ISBLANK":
+		return booleanLiteral(ld.IsBlankNode(args[0])), nil
+	case "ISLITERAL":
+		return booleanLiteral(ld.IsLiteral(args[0])), nil
+	case "ISNUMERIC":
+		_, ok := numericValue(args[0])
+		return booleanLiteral(ok), nil
+	case "LCASE", "UCASE":
+		l, ok := stringLiteral(args[0])
+		if !ok {
+			return nil, errEvaluation
+		}
+		value := strings.ToLower(l.Value)
+		if e.name == "UCASE" {
+			value = strings.ToUpper(l.Value)
+		}
+		return ld.NewLiteral(value, l.Datatype, l.Language), nil
+	case "STRLEN":
+		l, ok := stringLiteral(args[0])
+		if !ok {
+			return nil, errEvaluation
+		}
+		return ld.NewLiteral(strconv.Itoa(utf8.RuneCountInString(l.Value)), ld.XSDInteger, ""), nil
+	case "CONTAINS", "STRSTARTS", "STRENDS":
+		l, ok1 := stringLiteral(args[0])
+		s, ok2 := stringLiteral(args[1])
+		if !ok1 || !ok2 || s.Language != "" && s.Language != l.Language {
+			return nil, errEvaluation
+		}
+		switch e.name {
+		case "CONTAINS":
+			return booleanLiteral(strings.Contains(l.Value, s.Value)), nil
+		case "STRSTARTS":
+			return booleanLiteral(strings.HasPrefix(l.Value, s.Value)), nil
+		default:
+			return booleanLiteral(strings.HasSuffix(l.Value, s.Value)), nil
+		}
+	case "REGEX":
+		text, ok := stringLiteral(args[0])
+		if !ok {
+			return nil, errEvaluation
+		}
+		re := e.regex
+		if re == nil {
+			var flags ld.Node
+			if len(args) == 3 {
+				flags = args[2]
+			}
+			var err error
+			if re, err = compileRegex(args[1], flags); err != nil {
+				return nil, errEvaluation
+			}
+		}
+		return booleanLiteral(re.MatchString(text.Value)), nil
+	}
+	return nil, errEvaluation
+}
+
+// compileRegex compiles a REGEX pattern with the given flags (nil if not given).
+// The flags 'i', 's' and 'm' are supported.
+func compileRegex(pattern, flags ld.Node) (*regexp.Regexp, error) {
+	p, ok := simpleLiteral(pattern)
+	if !ok {
+		return nil, errors.New("REGEX pattern must be a simple literal")
+	}
+	if flags != nil {
+		f, ok := simpleLiteral(flags)
+		if !ok {
+			return nil, errors.New("REGEX flags must be a simple literal")
+		}
+		if strings.Trim(f, "ism") != "" {
+			return nil, fmt.Errorf("unsupported REGEX flags: %s", f)
+		}
+		if f != "" {
+			p = "(?" + f + ")" + p
+		}
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REGEX pattern: %w", err)
+	}
+	return re, nil
+}
+
+// stringLiteral returns the node if it's a simple, xsd:string or language-tagged literal.
+func stringLiteral(n ld.Node) (*ld.Literal, bool) {
+	l, ok := n.(*ld.Literal)
+	if !ok || l.Datatype != ld.XSDString && l.Datatype != ld.RDFLangString {
+		return nil, false
+	}
+	return l, true
+}
+
+// simpleLiteral returns the lexical form of the node if it's a simple or xsd:string literal.
+func simpleLiteral(n ld.Node) (string, bool) {
+	l, ok := n.(*ld.Literal)
+	if !ok || l.Datatype != ld.XSDString {
+		return "", false
+	}
+	return l.Value, true
+}
+
+// evaluateEBV evaluates the expression and returns its effective boolean value.
+func evaluateEBV(e expression, b binding) (bool, error) {
+	v, err := e.evaluate(b)
+	if err != nil {
+		return false, err
+	}
+	return effectiveBooleanValue(v)
+}
+
+func effectiveBooleanValue(n ld.Node) (bool, error) {
+	l, ok := n.(*ld.Literal)
+	if !ok {
+		return false, errEvaluation
+	}
+	switch l.Datatype {
+	case ld.XSDBoolean:
+		v, err := strconv.ParseBool(l.Value)
+		return err == nil && v, nil
+	case ld.XSDString:
+		return l.Value != "", nil
+	}
+	if _, isNumeric := numericTypes[l.Datatype]; isNumeric {
+		n, ok := numericValue(l)
+		return ok && n.value != 0 && !math.IsNaN(n.value), nil
+	}
+	return false, errEvaluation
+}
+
+// numericKind is the type of numeric values, in the order of SPARQL type promotion.
+type numericKind int
+
+const (
+	numericInteger numericKind = iota
+	numericDecimal
+	numericFloat
+	numericDouble
+)
+
+// numericTypes maps the supported numeric datatypes to their kinds.
+var numericTypes = map[string]numericKind{
+	ld.XSDInteger:                   numericInteger,
+	ld.XSDNS + "nonPositiveInteger": numericInteger,
+	ld.XSDNS + "negativeInteger":    numericInteger,
+	ld.XSDNS + "long":               numericInteger,
+	ld.XSDNS + "int":                numericInteger,
+	ld.XSDNS + "short":              numericInteger,
+	ld.XSDNS + "byte":               numericInteger,
+	ld.XSDNS + "nonNegativeInteger": numericInteger,
+	ld.XSDNS + "unsignedLong":       numericInteger,
+	ld.XSDNS + "unsignedInt":        numericInteger,
+	ld.XSDNS + "unsignedShort":      numericInteger,
+	ld.XSDNS + "unsignedByte":       numericInteger,
+	ld.XSDNS + "positiveInteger":    numericInteger,
+	ld.XSDDecimal:                   numericDecimal,
+	ld.XSDFloat:                     numericFloat,
+	ld.XSDDouble:                    numericDouble,
+}
+
+type numeric struct {
+	kind  numericKind
+	value float64
+}
+
+// numericValue returns the value of a numeric literal with a valid lexical form.
+func numericValue(n ld.Node) (numeric, bool) {
+	l, ok := n.(*ld.Literal)
+	if !ok {
+		return numeric{}, false
+	}
+	kind, ok := numericTypes[l.Datatype]
+	if !ok {
+		return numeric{}, false
+	}
+	value := strings.TrimSpace(l.Value)
+	if kind <= numericDecimal && strings.ContainsAny(value, "eEnN") {
+		return numeric{}, false
+	}
+	switch value {
+	case "INF", "+INF":
+		return numeric{kind: kind, value: math.Inf(1)}, kind >= numericFloat
+	case "-INF":
+		return numeric{kind: kind, value: math.Inf(-1)}, kind >= numericFloat
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return numeric{}, false
+	}
+	if kind == numericInteger && f != math.Trunc(f) {
+		return numeric{}, false
+	}
+	return numeric{kind: kind, value: f}, true
+}
+
+// literal returns the canonical literal of the value.
+func (n numeric) literal() *ld.Literal {
+	switch n.kind {
+	case numericInteger:
+		return ld.NewLiteral(strconv.FormatFloat(n.value, 'f', 0, 64), ld.XSDInteger, "")
+	case numericDecimal:
+		value := strconv.FormatFloat(n.value, 'f', -1, 64)
+		if !strings.Contains(value, ".") {
+			value += ".0"
+		}
+		return ld.NewLiteral(value, ld.XSDDecimal, "")
+	case numericFloat:
+		return ld.NewLiteral(ld.GetCanonicalDouble(n.value), ld.XSDFloat, "")
+	default:
+		return ld.NewLiteral(ld.GetCanonicalDouble(n.value), ld.XSDDouble, "")
+	}
+}
+
+// valuesEqual implements the '=' operator.
+func valuesEqual(a, b ld.Node) (bool, error) {
+	if c, err := compareValues(a, b); err == nil {
+		return c == 0, nil
+	}
+	if a.Equal(b) {
+		return true, nil
+	}
+	// literals with different datatypes which can't be compared may still denote the same value
+	la, aIsLiteral := a.(*ld.Literal)
+	lb, bIsLiteral := b.(*ld.Literal)
+	if aIsLiteral && bIsLiteral && la.Datatype != lb.Datatype && !isKnownDatatype(la.Datatype) &&
+		!isKnownDatatype(lb.Datatype) {
+		return false, errEvaluation
+	}
+	return false, nil
+}
+
+func isKnownDatatype(datatype string) bool {
+	switch datatype {
+	case ld.XSDString, ld.RDFLangString, ld.XSDBoolean, xsdDateTime:
+		return true
+	}
+	_, isNumeric := numericTypes[datatype]
+	return isNumeric
+}
+
+const xsdDateTime = ld.XSDNS + "dateTime"
+
+// compareValues compares two numeric, string, boolean or dateTime literals of the same kind.
+func compareValues(a, b ld.Node) (int, error) {
+	la, aIsLiteral := a.(*ld.Literal)
+	lb, bIsLiteral := b.(*ld.Literal)
+	if !aIsLiteral || !bIsLiteral {
+		return 0, errEvaluation
+	}
+
+	if na, ok := numericValue(la); ok {
+		nb, ok := numericValue(lb)
+		if !ok || math.IsNaN(na.value) || math.IsNaN(nb.value) {
+			return 0, errEvaluation
+		}
+		return xsd.CompareFloats(na.value, nb.value), nil
+	}
+
+	switch {
+	case la.Datatype != lb.Datatype:
+		return 0, errEvaluation
+	case la.Datatype == ld.XSDString:
+		return strings.Compare(la.Value, lb.Value), nil
+	case la.Datatype == ld.XSDBoolean:
+		ba, errA := strconv.ParseBool(la.Value)
+		bb, errB := strconv.ParseBool(lb.Value)
+		if errA != nil || errB != nil {
+			return 0, errEvaluation
+		}
+		switch {
+		case ba == bb:
+			return 0, nil
+		case bb:
+			return -1, nil
+		default:
+			return 1, nil
+		}
+	case la.Datatype == xsdDateTime:
+		ta, errA := xsd.ParseDateTime(la.Value)
+		tb, errB := xsd.ParseDateTime(lb.Value)
+		if errA != nil || errB != nil {
+			return 0, errEvaluation
+		}
+		switch {
+		case ta.Before(tb):
+			return -1, nil
+		case ta.After(tb):
+			return 1, nil
+		default:
+			return 0, nil
+		}
+	default:
+		return 0, errEvaluation
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIRI
+	tokenPrefixedName
+	tokenVar
+	tokenString
+	tokenLangTag
+	tokenInteger
+	tokenDecimal
+	tokenDouble
+	tokenBlankNode
+	tokenWord
+	tokenPunct
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenIRI:
+		return "<" + t.value + ">"
+	case tokenVar:
+		return "?" + t.value
+	case tokenString:
+		return fmt.Sprintf("%q", t.value)
+	case tokenLangTag:
+		return "@" + t.value
+	default:
+		return t.value
+	}
+}
+
+// is returns true if the token is the given punctuation or (case-insensitive) keyword.
+func (t token) is(value string) bool {
+	switch t.kind {
+	case tokenPunct:
+		return t.value == value
+	case tokenWord:
+		return strings.EqualFold(t.value, value)
+	default:
+		return false
+	}
+}
+
+// lexer splits a query into tokens.
+type lexer struct {
+	input string
+	pos   int
+	line  int
+}
+
+// tokenize returns all tokens of the query, ending with a tokenEOF.
+func tokenize(input string) ([]token, error) {
+	l := &lexer{input: input, line: 1}
+	var tokens []token
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+		if t.kind == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	return newParseError(l.line, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) skipSpaceAndComments() {
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipSpaceAndComments()
+	if l.pos >= len(l.input) {
+		return token{kind: tokenEOF, line: l.line}, nil
+	}
+
+	start := l.pos
+	c := l.input[l.pos]
+	switch {
+	case c == '<':
+		if iri, ok := l.scanIRI(); ok {
+			return token{kind: tokenIRI, value: iri, line: l.line}, nil
+		}
+		if strings.HasPrefix(l.input[l.pos:], "<=") {
+			l.pos += 2
+			return l.punct("<="), nil
+		}
+		l.pos++
+		return l.punct("<"), nil
+	case c == '?' || c == '$':
+		l.pos++
+		name := l.scanName()
+		if name == "" {
+			return token{}, l.errorf("variable name expected")
+		}
+		return token{kind: tokenVar, value: name, line: l.line}, nil
+	case c == '"' || c == '\'':
+		s, err := l.scanString()
+		if err != nil {
+			return token{}, err
+		}
+		return token{kind: tokenString, value: s, line: l.line}, nil
+	case c == '@':
+		l.pos++
+		for l.pos < len(l.input) && (isAlnum(l.input[l.pos]) || l.input[l.pos] == '-') {
+			l.pos++
+		}
+		if l.pos == start+1 {
+			return token{}, l.errorf("language tag expected")
+		}
+		return token{kind: tokenLangTag, value: l.input[start+1 : l.pos], line: l.line}, nil
+	case c >= '0' && c <= '9' || c == '.' && l.pos+1 < len(l.input) && isDigit(l.input[l.pos+1]):
+		return l.scanNumber(), nil
+	case c == '_' && strings.HasPrefix(l.input[l.pos:], "_:"):
+		l.pos += 2
+		label := l.scanLocalName()
+		if label == "" {
+			return token{}, l.errorf("blank node label expected")
+		}
+		return token{kind: tokenBlankNode, value: "_:" + label, line: l.line}, nil
+	case c == ':' || isNameStart(l.input[l.pos:]):
+		name := l.scanName()
+		if l.pos < len(l.input) && l.input[l.pos] == ':' {
+			l.pos++
+			local := l.scanLocalName()
+			return token{kind: tokenPrefixedName, value: name + ":" + local, line: l.line}, nil
+		}
+		return token{kind: tokenWord, value: name, line: l.line}, nil
+	}
+
+	for _, op := range []string{"^^", "&&", "||", "!=", ">="} {
+		if strings.HasPrefix(l.input[l.pos:], op) {
+			l.pos += len(op)
+			return l.punct(op), nil
+		}
+	}
+	if strings.ContainsRune("{}()[].,;*=<>!+-/", rune(c)) {
+		l.pos++
+		return l.punct(string(c)), nil
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.input[l.pos:])
+	return token{}, l.errorf("unexpected character %q", r)
+}
+
+func (l *lexer) punct(value string) token {
+	return token{kind: tokenPunct, value: value, line: l.line}
+}
+
+// scanIRI scans an IRI reference. It returns false, without consuming any input,
+// if '<' doesn't start an IRI (for example, it's the less-than operator).
+func (l *lexer) scanIRI() (string, bool) {
+	for i := l.pos + 1; i < len(l.input); i++ {
+		switch c := l.input[i]; {
+		case c == '>':
+			iri := l.input[l.pos+1 : i]
+			l.pos = i + 1
+			return iri, true
+		case c <= ' ' || strings.IndexByte("<\"{}|^`\\", c) >= 0:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// scanName scans a variable name or the prefix of a prefixed name.
+func (l *lexer) scanName() string {
+	start := l.pos
+	for l.pos < len(l.input) {
+		r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || (r == '-' || r == '.') && l.pos > start) {
+			break
+		}
+		l.pos += size
+	}
+	// names can't end with a dot
+	for l.pos > start && l.input[l.pos-1] == '.' {
+		l.pos--
+	}
+	return l.input[start:l.pos]
+}
+
+// scanLocalName scans the local part of a prefixed name or a blank node label.
+func (l *lexer) scanLocalName() string {
+	start := l.pos
+	for l.pos < len(l.input) {
+		r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' || r == ':') {
+			break
+		}
+		l.pos += size
+	}
+	for l.pos > start && l.input[l.pos-1] == '.' {
+		l.pos--
+	}
+	return l.input[start:l.pos]
+}
+
+func (l *lexer) scanNumber() token {
+	start := l.pos
+	kind := tokenInteger
+	for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+		l.pos++
+	}
+	if l.pos+1 < len(l.input) && l.input[l.pos] == '.' && isDigit(l.input[l.pos+1]) {
+		kind = tokenDecimal
+		l.pos++
+		for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+			l.pos++
+		}
+	}
+	if l.pos < len(l.input) && (l.input[l.pos] == 'e' || l.input[l.pos] == 'E') {
+		end := l.pos + 1
+		if end < len(l.input) && (l.input[end] == '+' || l.input[end] == '-') {
+			end++
+		}
+		if end < len(l.input) && isDigit(l.input[end]) {
+			kind = tokenDouble
+			l.pos = end
+			for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+				l.pos++
+			}
+		}
+	}
+	return token{kind: kind, value: l.input[start:l.pos], line: l.line}
+}
+
+// scanString scans a short or long ("""...""") string literal and returns its unescaped value.
+func (l *lexer) scanString() (string, error) {
+	quote := l.input[l.pos : l.pos+1]
+	long := strings.HasPrefix(l.input[l.pos:], strings.Repeat(quote, 3))
+	if long {
+		quote = strings.Repeat(quote, 3)
+	}
+	l.pos += len(quote)
+
+	var sb strings.Builder
+	for {
+		if l.pos >= len(l.input) {
+			return "", l.errorf("unterminated string")
+		}
+		if strings.HasPrefix(l.input[l.pos:], quote) {
+			l.pos += len(quote)
+			return sb.String(), nil
+		}
+		c := l.input[l.pos]
+		switch {
+		case c == '\\':
+			if l.pos+1 >= len(l.input) {
+				return "", l.errorf("unterminated string")
+			}
+			l.pos++
+			switch e := l.input[l.pos]; e {
+			case 't':
+				sb.WriteByte('\t')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case '"', '\'', '\\':
+				sb.WriteByte(e)
+			default:
+				return "", l.errorf("invalid escape sequence \\%c", e)
+			}
+			l.pos++
+		case (c == '\n' || c == '\r') && !long:
+			return "", l.errorf("line break in string")
+		default:
+			if c == '\n' {
+				l.line++
+			}
+			sb.WriteByte(c)
+			l.pos++
+		}
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNameStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLetter(r)
+}
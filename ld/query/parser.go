@@ -0,0 +1,869 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/piprate/json-gold/ld"
+)
+
+// term is a variable or an RDF term of a triple pattern.
+type term struct {
+	// variable is the name of the variable, or empty if the term is an RDF term.
+	// Blank nodes of the WHERE clause are variables with names starting with "_:",
+	// which can't be used in queries directly.
+	variable string
+	node     ld.Node
+}
+
+type triplePattern struct {
+	subject   *term
+	predicate *term
+	object    *term
+}
+
+// patternElement is one of *basicGraphPattern, *groupPattern, *optionalPattern,
+// *unionPattern or *graphPattern.
+type patternElement interface{}
+
+type basicGraphPattern struct {
+	triples []*triplePattern
+}
+
+type groupPattern struct {
+	elements []patternElement
+	filters  []expression
+}
+
+type optionalPattern struct {
+	group *groupPattern
+}
+
+type unionPattern struct {
+	alternatives []*groupPattern
+}
+
+type graphPattern struct {
+	name  *term
+	group *groupPattern
+}
+
+type orderCondition struct {
+	expr       expression
+	descending bool
+}
+
+// isHiddenVariable returns true if the variable stands for a blank node of the WHERE clause.
+func isHiddenVariable(name string) bool {
+	return strings.HasPrefix(name, "_:")
+}
+
+// variables appends the names of the variables used in the group to dst, in order of appearance.
+func (g *groupPattern) variables(dst []string, seen map[string]bool) []string {
+	addTerm := func(t *term) {
+		if t.variable != "" && !isHiddenVariable(t.variable) && !seen[t.variable] {
+			seen[t.variable] = true
+			dst = append(dst, t.variable)
+		}
+	}
+	for _, element := range g.elements {
+		switch el := element.(type) {
+		case *basicGraphPattern:
+			for _, tp := range el.triples {
+				addTerm(tp.subject)
+				addTerm(tp.predicate)
+				addTerm(tp.object)
+			}
+		case *groupPattern:
+			dst = el.variables(dst, seen)
+		case *optionalPattern:
+			dst = el.group.variables(dst, seen)
+		case *unionPattern:
+			for _, alternative := range el.alternatives {
+				dst = alternative.variables(dst, seen)
+			}
+		case *graphPattern:
+			addTerm(el.name)
+			dst = el.group.variables(dst, seen)
+		}
+	}
+	return dst
+}
+
+// parser is a recursive descent parser of SPARQL queries.
+type parser struct {
+	tokens   []token
+	pos      int
+	prefixes map[string]string
+	base     string
+	// template is true while parsing a CONSTRUCT template, where blank nodes aren't variables
+	template  bool
+	anonCount int
+}
+
+// Parse parses a SPARQL SELECT or CONSTRUCT query. Syntax errors and unsupported
+// features are reported as ld.ParseError errors.
+func Parse(query string) (*Query, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		tokens:   tokens,
+		prefixes: make(map[string]string),
+	}
+	return p.parseQuery()
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it's the given punctuation or keyword.
+func (p *parser) accept(value string) bool {
+	if p.peek().is(value) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(value string) error {
+	if !p.accept(value) {
+		return p.errorf("expected '%s', found %s", value, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return newParseError(p.peek().line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseQuery() (*Query, error) {
+	if err := p.parsePrologue(); err != nil {
+		return nil, err
+	}
+
+	q := &Query{limit: -1}
+	var err error
+	switch {
+	case p.accept("SELECT"):
+		q.Form = FormSelect
+		err = p.parseSelectClause(q)
+	case p.accept("CONSTRUCT"):
+		q.Form = FormConstruct
+		err = p.parseConstructClause(q)
+	case p.peek().is("ASK") || p.peek().is("DESCRIBE"):
+		err = p.errorf("%s queries are not supported", strings.ToUpper(p.peek().value))
+	default:
+		err = p.errorf("expected SELECT or CONSTRUCT, found %s", p.peek())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if q.where == nil {
+		p.accept("WHERE")
+		if q.where, err = p.parseGroup(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.parseSolutionModifiers(q); err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.errorf("unexpected %s", p.peek())
+	}
+	return q, nil
+}
+
+func (p *parser) parsePrologue() error {
+	for {
+		switch {
+		case p.accept("PREFIX"):
+			name := p.advance()
+			if name.kind != tokenPrefixedName || !strings.HasSuffix(name.value, ":") {
+				return newParseError(name.line, fmt.Sprintf("prefix name expected, found %s", name))
+			}
+			iri, err := p.parseIRIRef()
+			if err != nil {
+				return err
+			}
+			p.prefixes[strings.TrimSuffix(name.value, ":")] = iri
+		case p.accept("BASE"):
+			iri, err := p.parseIRIRef()
+			if err != nil {
+				return err
+			}
+			p.base = iri
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseSelectClause(q *Query) error {
+	q.Distinct = p.accept("DISTINCT") || p.accept("REDUCED")
+	if p.accept("*") {
+		return nil
+	}
+	q.Variables = make([]string, 0)
+	for p.peek().kind == tokenVar {
+		q.Variables = append(q.Variables, p.advance().value)
+	}
+	if len(q.Variables) == 0 {
+		if p.peek().is("(") {
+			return p.errorf("SELECT expressions are not supported")
+		}
+		return p.errorf("expected variables or '*', found %s", p.peek())
+	}
+	return nil
+}
+
+func (p *parser) parseConstructClause(q *Query) error {
+	if p.accept("WHERE") {
+		// the short form, where the pattern is also the template
+		if err := p.expect("{"); err != nil {
+			return err
+		}
+		triples, err := p.parseTriplesBlock()
+		if err != nil {
+			return err
+		}
+		if err := p.expect("}"); err != nil {
+			return err
+		}
+		q.where = &groupPattern{elements: []patternElement{&basicGraphPattern{triples: triples}}}
+		q.template = triples
+		return nil
+	}
+
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	p.template = true
+	triples, err := p.parseTriplesBlock()
+	p.template = false
+	if err != nil {
+		return err
+	}
+	q.template = triples
+	return p.expect("}")
+}
+
+// parseTriplesBlock parses triples separated by '.' up to the closing '}'.
+func (p *parser) parseTriplesBlock() ([]*triplePattern, error) {
+	var triples []*triplePattern
+	for !p.peek().is("}") {
+		var err error
+		if triples, err = p.parseTriplesSameSubject(triples); err != nil {
+			return nil, err
+		}
+		if !p.accept(".") && !p.peek().is("}") {
+			return nil, p.errorf("expected '.' or '}', found %s", p.peek())
+		}
+	}
+	return triples, nil
+}
+
+func (p *parser) parseSolutionModifiers(q *Query) error {
+	if p.accept("ORDER") {
+		if err := p.expect("BY"); err != nil {
+			return err
+		}
+		for {
+			cond, ok, err := p.parseOrderCondition()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			q.orderBy = append(q.orderBy, cond)
+		}
+		if len(q.orderBy) == 0 {
+			return p.errorf("expected an order condition, found %s", p.peek())
+		}
+	}
+
+	for {
+		switch {
+		case p.accept("LIMIT"):
+			n, err := p.parseNonNegativeInteger()
+			if err != nil {
+				return err
+			}
+			q.limit = n
+		case p.accept("OFFSET"):
+			n, err := p.parseNonNegativeInteger()
+			if err != nil {
+				return err
+			}
+			q.offset = n
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseOrderCondition() (*orderCondition, bool, error) {
+	t := p.peek()
+	switch {
+	case t.is("ASC") || t.is("DESC"):
+		p.advance()
+		if err := p.expect("("); err != nil {
+			return nil, false, err
+		}
+		expr, err := p.parseExpression()
+		if err != nil {
+			return nil, false, err
+		}
+		return &orderCondition{expr: expr, descending: t.is("DESC")}, true, p.expect(")")
+	case t.kind == tokenVar:
+		p.advance()
+		return &orderCondition{expr: variableExpr(t.value)}, true, nil
+	case t.is("(") || t.kind == tokenWord && p.peekAt(1).is("("):
+		expr, err := p.parsePrimaryExpression()
+		return &orderCondition{expr: expr}, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+func (p *parser) parseNonNegativeInteger() (int, error) {
+	t := p.advance()
+	if t.kind != tokenInteger {
+		return 0, newParseError(t.line, fmt.Sprintf("integer expected, found %s", t))
+	}
+	n, err := strconv.Atoi(t.value)
+	if err != nil {
+		return 0, newParseError(t.line, fmt.Sprintf("invalid integer %s", t.value))
+	}
+	return n, nil
+}
+
+func (p *parser) parseGroup() (*groupPattern, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	g := &groupPattern{}
+	for !p.accept("}") {
+		t := p.peek()
+		switch {
+		case t.kind == tokenEOF:
+			return nil, p.errorf("expected '}', found %s", t)
+		case p.accept("."):
+		case p.accept("FILTER"):
+			expr, err := p.parseConstraint()
+			if err != nil {
+				return nil, err
+			}
+			g.filters = append(g.filters, expr)
+		case p.accept("OPTIONAL"):
+			group, err := p.parseGroup()
+			if err != nil {
+				return nil, err
+			}
+			g.elements = append(g.elements, &optionalPattern{group: group})
+		case p.accept("GRAPH"):
+			name, err := p.parseVarOrIRI()
+			if err != nil {
+				return nil, err
+			}
+			group, err := p.parseGroup()
+			if err != nil {
+				return nil, err
+			}
+			g.elements = append(g.elements, &graphPattern{name: name, group: group})
+		case t.is("{"):
+			group, err := p.parseGroup()
+			if err != nil {
+				return nil, err
+			}
+			if !p.peek().is("UNION") {
+				g.elements = append(g.elements, group)
+				continue
+			}
+			union := &unionPattern{alternatives: []*groupPattern{group}}
+			for p.accept("UNION") {
+				if group, err = p.parseGroup(); err != nil {
+					return nil, err
+				}
+				union.alternatives = append(union.alternatives, group)
+			}
+			g.elements = append(g.elements, union)
+		case t.kind == tokenWord && isUnsupportedKeyword(t.value):
+			return nil, p.errorf("%s is not supported", strings.ToUpper(t.value))
+		default:
+			var bgp *basicGraphPattern
+			if n := len(g.elements); n > 0 {
+				bgp, _ = g.elements[n-1].(*basicGraphPattern)
+			}
+			if bgp == nil {
+				bgp = &basicGraphPattern{}
+				g.elements = append(g.elements, bgp)
+			}
+			var err error
+			if bgp.triples, err = p.parseTriplesSameSubject(bgp.triples); err != nil {
+				return nil, err
+			}
+			if !p.peek().is(".") && !p.peek().is("}") && !isGroupKeyword(p.peek()) {
+				return nil, p.errorf("expected '.' or '}', found %s", p.peek())
+			}
+		}
+	}
+	return g, nil
+}
+
+func isGroupKeyword(t token) bool {
+	return t.is("FILTER") || t.is("OPTIONAL") || t.is("GRAPH") || t.is("{")
+}
+
+func isUnsupportedKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "BIND", "VALUES", "MINUS", "SERVICE", "SELECT":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseConstraint parses the expression of a FILTER: a bracketted expression or a function call.
+func (p *parser) parseConstraint() (expression, error) {
+	if !p.peek().is("(") && !(p.peek().kind == tokenWord && p.peekAt(1).is("(")) {
+		return nil, p.errorf("expected '(' or a function call, found %s", p.peek())
+	}
+	return p.parsePrimaryExpression()
+}
+
+// parseTriplesSameSubject parses a subject with its property list and appends the triples to dst.
+func (p *parser) parseTriplesSameSubject(dst []*triplePattern) ([]*triplePattern, error) {
+	var subject *term
+	var err error
+	if p.peek().is("[") {
+		p.advance()
+		subject = p.newAnonymousTerm()
+		if !p.accept("]") {
+			if dst, err = p.parsePropertyList(subject, dst); err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			// the property list after a blank node property list is optional
+			if p.peek().is(".") || p.peek().is("}") || isGroupKeyword(p.peek()) {
+				return dst, nil
+			}
+		}
+	} else if subject, err = p.parseVarOrTerm(); err != nil {
+		return nil, err
+	}
+	return p.parsePropertyList(subject, dst)
+}
+
+func (p *parser) parsePropertyList(subject *term, dst []*triplePattern) ([]*triplePattern, error) {
+	for {
+		predicate, err := p.parseVerb()
+		if err != nil {
+			return nil, err
+		}
+		for {
+			var object *term
+			if p.accept("[") {
+				object = p.newAnonymousTerm()
+				if !p.accept("]") {
+					if dst, err = p.parsePropertyList(object, dst); err != nil {
+						return nil, err
+					}
+					if err := p.expect("]"); err != nil {
+						return nil, err
+					}
+				}
+			} else if object, err = p.parseVarOrTerm(); err != nil {
+				return nil, err
+			}
+			dst = append(dst, &triplePattern{subject: subject, predicate: predicate, object: object})
+			if !p.accept(",") {
+				break
+			}
+		}
+
+		if !p.accept(";") {
+			return dst, nil
+		}
+		for p.accept(";") {
+		}
+		// a trailing ';' is allowed
+		if t := p.peek(); t.is(".") || t.is("]") || t.is("}") || isGroupKeyword(t) {
+			return dst, nil
+		}
+	}
+}
+
+func (p *parser) parseVerb() (*term, error) {
+	t := p.peek()
+	if t.kind == tokenWord && t.value == "a" {
+		p.advance()
+		return &term{node: ld.NewIRI(ld.RDFType)}, nil
+	}
+	return p.parseVarOrIRI()
+}
+
+func (p *parser) parseVarOrIRI() (*term, error) {
+	if t := p.peek(); t.kind == tokenVar {
+		p.advance()
+		return &term{variable: t.value}, nil
+	}
+	iri, err := p.parseIRI()
+	if err != nil {
+		return nil, err
+	}
+	return &term{node: ld.NewIRI(iri)}, nil
+}
+
+func (p *parser) parseVarOrTerm() (*term, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenVar:
+		p.advance()
+		return &term{variable: t.value}, nil
+	case tokenBlankNode:
+		p.advance()
+		if p.template {
+			return &term{node: ld.NewBlankNode(t.value)}, nil
+		}
+		return &term{variable: t.value}, nil
+	case tokenPunct:
+		if t.is("(") {
+			return nil, p.errorf("collections are not supported")
+		}
+	}
+	node, err := p.parseRDFTerm()
+	if err != nil {
+		return nil, err
+	}
+	return &term{node: node}, nil
+}
+
+// newAnonymousTerm returns the term of a new blank node written as '[]'.
+func (p *parser) newAnonymousTerm() *term {
+	p.anonCount++
+	label := fmt.Sprintf("_:[]%d", p.anonCount)
+	if p.template {
+		return &term{node: ld.NewBlankNode(label)}
+	}
+	return &term{variable: label}
+}
+
+// parseRDFTerm parses an IRI or a literal.
+func (p *parser) parseRDFTerm() (ld.Node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenIRI, tokenPrefixedName:
+		iri, err := p.parseIRI()
+		if err != nil {
+			return nil, err
+		}
+		return ld.NewIRI(iri), nil
+	case tokenString:
+		p.advance()
+		switch next := p.peek(); {
+		case next.kind == tokenLangTag:
+			p.advance()
+			return ld.NewLiteral(t.value, ld.RDFLangString, next.value), nil
+		case next.is("^^"):
+			p.advance()
+			datatype, err := p.parseIRI()
+			if err != nil {
+				return nil, err
+			}
+			return ld.NewLiteral(t.value, datatype, ""), nil
+		default:
+			return ld.NewLiteral(t.value, ld.XSDString, ""), nil
+		}
+	case tokenInteger, tokenDecimal, tokenDouble:
+		p.advance()
+		return numericLiteral(t, ""), nil
+	case tokenPunct:
+		if (t.is("-") || t.is("+")) && isNumberToken(p.peekAt(1)) {
+			p.advance()
+			return numericLiteral(p.advance(), t.value), nil
+		}
+	case tokenWord:
+		if t.value == "true" || t.value == "false" {
+			p.advance()
+			return ld.NewLiteral(t.value, ld.XSDBoolean, ""), nil
+		}
+	}
+	return nil, p.errorf("unexpected %s", t)
+}
+
+func isNumberToken(t token) bool {
+	return t.kind == tokenInteger || t.kind == tokenDecimal || t.kind == tokenDouble
+}
+
+func numericLiteral(t token, sign string) *ld.Literal {
+	switch t.kind {
+	case tokenInteger:
+		return ld.NewLiteral(sign+t.value, ld.XSDInteger, "")
+	case tokenDecimal:
+		return ld.NewLiteral(sign+t.value, ld.XSDDecimal, "")
+	default:
+		return ld.NewLiteral(sign+t.value, ld.XSDDouble, "")
+	}
+}
+
+// parseIRI parses an IRI reference or a prefixed name and returns the absolute IRI.
+func (p *parser) parseIRI() (string, error) {
+	t := p.peek()
+	if t.kind != tokenPrefixedName {
+		return p.parseIRIRef()
+	}
+	p.advance()
+	i := strings.IndexByte(t.value, ':')
+	ns, found := p.prefixes[t.value[:i]]
+	if !found {
+		return "", newParseError(t.line, fmt.Sprintf("undefined prefix '%s'", t.value[:i]))
+	}
+	return ns + t.value[i+1:], nil
+}
+
+func (p *parser) parseIRIRef() (string, error) {
+	t := p.advance()
+	if t.kind != tokenIRI {
+		return "", newParseError(t.line, fmt.Sprintf("IRI expected, found %s", t))
+	}
+	if p.base != "" {
+		return ld.Resolve(p.base, t.value), nil
+	}
+	return t.value, nil
+}
+
+func (p *parser) parseExpression() (expression, error) {
+	return p.parseOrExpression()
+}
+
+func (p *parser) parseOrExpression() (expression, error) {
+	left, err := p.parseAndExpression()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAndExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAndExpression() (expression, error) {
+	left, err := p.parseRelationalExpression()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseRelationalExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseRelationalExpression() (expression, error) {
+	left, err := p.parseAdditiveExpression()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"=", "!=", "<", ">", "<=", ">="} {
+		if p.accept(op) {
+			right, err := p.parseAdditiveExpression()
+			if err != nil {
+				return nil, err
+			}
+			return &comparisonExpr{op: op, left: left, right: right}, nil
+		}
+	}
+	if p.peek().is("IN") || p.peek().is("NOT") {
+		return nil, p.errorf("IN and NOT IN are not supported")
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditiveExpression() (expression, error) {
+	left, err := p.parseMultiplicativeExpression()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("+") || p.peek().is("-") {
+		op := p.advance().value
+		right, err := p.parseMultiplicativeExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicativeExpression() (expression, error) {
+	left, err := p.parseUnaryExpression()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("*") || p.peek().is("/") {
+		op := p.advance().value
+		right, err := p.parseUnaryExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnaryExpression() (expression, error) {
+	switch {
+	case p.accept("!"):
+		arg, err := p.parseUnaryExpression()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{arg: arg}, nil
+	case p.peek().is("-") || p.peek().is("+"):
+		op := p.advance().value
+		arg, err := p.parseUnaryExpression()
+		if err != nil {
+			return nil, err
+		}
+		return &signExpr{negate: op == "-", arg: arg}, nil
+	default:
+		return p.parsePrimaryExpression()
+	}
+}
+
+func (p *parser) parsePrimaryExpression() (expression, error) {
+	t := p.peek()
+	switch {
+	case t.is("("):
+		p.advance()
+		expr, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+	case t.kind == tokenVar:
+		p.advance()
+		return variableExpr(t.value), nil
+	case t.kind == tokenWord && p.peekAt(1).is("("):
+		return p.parseFunctionCall()
+	case (t.kind == tokenIRI || t.kind == tokenPrefixedName) && p.peekAt(1).is("("):
+		return nil, p.errorf("function %s is not supported", t)
+	}
+
+	node, err := p.parseRDFTerm()
+	if err != nil {
+		return nil, err
+	}
+	return &constantExpr{node: node}, nil
+}
+
+func (p *parser) parseFunctionCall() (expression, error) {
+	nameToken := p.advance()
+	name := strings.ToUpper(nameToken.value)
+	arity, supported := functionArity[name]
+	if !supported {
+		return nil, newParseError(nameToken.line, fmt.Sprintf("function %s is not supported", nameToken.value))
+	}
+
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	call := &callExpr{name: name}
+	if !p.accept(")") {
+		for {
+			arg, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(call.args) < arity[0] || len(call.args) > arity[1] {
+		return nil, newParseError(nameToken.line,
+			fmt.Sprintf("wrong number of arguments of %s: %d", nameToken.value, len(call.args)))
+	}
+	if name == "BOUND" {
+		if _, isVar := call.args[0].(variableExpr); !isVar {
+			return nil, newParseError(nameToken.line, "the argument of BOUND must be a variable")
+		}
+	}
+	if name == "REGEX" {
+		re, err := compileConstantRegex(call.args)
+		if err != nil {
+			return nil, newParseError(nameToken.line, err.Error())
+		}
+		call.regex = re
+	}
+	return call, nil
+}
+
+// compileConstantRegex compiles the pattern of a REGEX call if the pattern and flags are constants.
+func compileConstantRegex(args []expression) (*regexp.Regexp, error) {
+	var pattern, flags ld.Node
+	if c, isConstant := args[1].(*constantExpr); isConstant {
+		pattern = c.node
+	} else {
+		return nil, nil
+	}
+	if len(args) == 3 {
+		c, isConstant := args[2].(*constantExpr)
+		if !isConstant {
+			return nil, nil
+		}
+		flags = c.node
+	}
+	return compileRegex(pattern, flags)
+}
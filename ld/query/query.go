@@ -0,0 +1,204 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package query evaluates a practical subset of SPARQL 1.1 (https://www.w3.org/TR/sparql11-query/)
+// over in-memory RDF datasets.
+//
+// Supported are SELECT and CONSTRUCT queries with PREFIX and BASE declarations,
+// basic graph patterns (including the ';', ',' and 'a' abbreviations and blank node
+// property lists), FILTER, OPTIONAL, UNION, GRAPH, nested groups, DISTINCT and
+// the ORDER BY, LIMIT and OFFSET modifiers.
+//
+// FILTER expressions support logical, comparison and arithmetic operators and the functions
+// REGEX, BOUND, STR, LANG, DATATYPE, LANGMATCHES, SAMETERM, isIRI, isURI, isBlank, isLiteral,
+// isNumeric, LCASE, UCASE, STRLEN, CONTAINS, STRSTARTS and STRENDS.
+//
+// Property paths, aggregates, subqueries, BIND, VALUES, MINUS, ASK and DESCRIBE
+// queries and SPARQL Update aren't supported.
+//
+// CONSTRUCT queries return an *ld.RDFDataset, so the results can be converted
+// to JSON-LD with ld.JsonLdApi.FromRDF and compacted as usual.
+package query
+
+import (
+	"fmt"
+
+	"github.com/piprate/json-gold/ld"
+)
+
+// Form is the form of a query.
+type Form int
+
+const (
+	// FormSelect is a SELECT query, which returns variable bindings.
+	FormSelect Form = iota
+	// FormConstruct is a CONSTRUCT query, which returns an RDF dataset.
+	FormConstruct
+)
+
+// Query is a parsed SPARQL query. A Query may be evaluated any number of times,
+// concurrently if the stores it's evaluated against aren't modified.
+type Query struct {
+	// Form is the form of the query.
+	Form Form
+	// Variables holds the names (without '?') of the projected variables of a SELECT query.
+	// It's nil for SELECT * queries, in which case all variables used in the
+	// WHERE clause are projected.
+	Variables []string
+	// Distinct is true if duplicate solutions are eliminated (SELECT DISTINCT or REDUCED).
+	Distinct bool
+
+	template []*triplePattern
+	where    *groupPattern
+	orderBy  []*orderCondition
+	limit    int
+	offset   int
+}
+
+// Solution holds the bindings of the variables of a solution, keyed by variable names without '?'.
+// Unbound variables aren't present.
+type Solution map[string]ld.Node
+
+// Results holds the solutions of a SELECT query.
+type Results struct {
+	// Variables holds the names of the projected variables, in order.
+	Variables []string
+	// Solutions holds the solutions, in the order defined by ORDER BY, if any.
+	Solutions []Solution
+}
+
+// Select parses the given SELECT query and evaluates it against the dataset.
+func Select(dataset *ld.RDFDataset, query string) (*Results, error) {
+	q, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Select(ld.NewQuadStoreFromDataset(dataset))
+}
+
+// Construct parses the given CONSTRUCT query and evaluates it against the dataset.
+func Construct(dataset *ld.RDFDataset, query string) (*ld.RDFDataset, error) {
+	q, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Construct(ld.NewQuadStoreFromDataset(dataset))
+}
+
+// Select evaluates a SELECT query against the given store.
+func (q *Query) Select(store *ld.QuadStore) (*Results, error) {
+	if q.Form != FormSelect {
+		return nil, ld.NewJsonLdError(ld.InvalidInput, "not a SELECT query")
+	}
+
+	variables := q.Variables
+	if variables == nil {
+		variables = q.where.variables(nil, make(map[string]bool))
+	}
+
+	solutions := q.solutions(store)
+	results := &Results{
+		Variables: variables,
+		Solutions: make([]Solution, 0, len(solutions)),
+	}
+	seen := make(map[string]bool)
+	for _, b := range solutions {
+		solution := make(Solution, len(variables))
+		for _, v := range variables {
+			if node, bound := b[v]; bound {
+				solution[v] = node
+			}
+		}
+		if q.Distinct {
+			key := solutionKey(solution, variables)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		results.Solutions = append(results.Solutions, solution)
+	}
+	start, end := sliceBounds(len(results.Solutions), q.offset, q.limit)
+	results.Solutions = results.Solutions[start:end]
+	return results, nil
+}
+
+// Construct evaluates a CONSTRUCT query against the given store. The triples
+// are added to the default graph of the returned dataset. Blank nodes of
+// the template get fresh labels for every solution, which don't clash with
+// the labels of the blank nodes of the data in the result. Triples with unbound
+// variables or which aren't valid RDF (for example, with a literal subject) are skipped.
+func (q *Query) Construct(store *ld.QuadStore) (*ld.RDFDataset, error) {
+	if q.Form != FormConstruct {
+		return nil, ld.NewJsonLdError(ld.InvalidInput, "not a CONSTRUCT query")
+	}
+
+	solutions := q.solutions(store)
+	start, end := sliceBounds(len(solutions), q.offset, q.limit)
+	solutions = solutions[start:end]
+
+	result := ld.NewQuadStore()
+	labels := newTemplateLabels(solutions)
+	for i, b := range solutions {
+		for _, tp := range q.template {
+			subject := tp.subject.instantiate(b, labels, i)
+			predicate := tp.predicate.instantiate(b, labels, i)
+			object := tp.object.instantiate(b, labels, i)
+			if subject == nil || predicate == nil || object == nil ||
+				ld.IsLiteral(subject) || !ld.IsIRI(predicate) {
+				continue
+			}
+			result.Add(ld.NewQuad(subject, predicate, object, "@default"))
+		}
+	}
+	return result.Dataset(), nil
+}
+
+// solutions evaluates the WHERE clause and sorts the solutions.
+func (q *Query) solutions(store *ld.QuadStore) []binding {
+	e := &evaluator{store: store}
+	solutions := e.evalGroup(q.where, "@default")
+	if len(q.orderBy) > 0 {
+		sortSolutions(solutions, q.orderBy)
+	}
+	return solutions
+}
+
+// sliceBounds returns the range of n solutions selected by the OFFSET and LIMIT modifiers.
+func sliceBounds(n, offset, limit int) (int, int) {
+	start := offset
+	if start > n {
+		start = n
+	}
+	end := n
+	if limit >= 0 && start+limit < n {
+		end = start + limit
+	}
+	return start, end
+}
+
+func solutionKey(s Solution, variables []string) string {
+	key := ""
+	for _, v := range variables {
+		if node, bound := s[v]; bound {
+			key += ld.NodeTerm(node)
+		}
+		key += "\x00"
+	}
+	return key
+}
+
+func newParseError(line int, msg string) error {
+	return ld.NewJsonLdError(ld.ParseError, fmt.Sprintf("line %d: %s", line, msg))
+}
@@ -0,0 +1,390 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package query_test
+
+import (
+	"testing"
+
+	"github.com/piprate/json-gold/ld"
+	"github.com/piprate/json-gold/ld/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const peopleInput = `<http://example.org/alice> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://xmlns.com/foaf/0.1/Person> .
+<http://example.org/alice> <http://xmlns.com/foaf/0.1/name> "Alice" .
+<http://example.org/alice> <http://xmlns.com/foaf/0.1/age> "34"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/alice> <http://xmlns.com/foaf/0.1/mbox> <mailto:alice@example.org> .
+<http://example.org/alice> <http://xmlns.com/foaf/0.1/knows> <http://example.org/bob> .
+<http://example.org/alice> <http://xmlns.com/foaf/0.1/knows> <http://example.org/carol> .
+<http://example.org/bob> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://xmlns.com/foaf/0.1/Person> .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/name> "Bob"@en .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/name> "Robert"@fr .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/age> "27"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/knows> <http://example.org/carol> .
+<http://example.org/carol> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://xmlns.com/foaf/0.1/Person> .
+<http://example.org/carol> <http://xmlns.com/foaf/0.1/name> "Carol" .
+<http://example.org/carol> <http://xmlns.com/foaf/0.1/age> "41.5"^^<http://www.w3.org/2001/XMLSchema#decimal> .
+<http://example.org/carol> <http://xmlns.com/foaf/0.1/knows> _:b0 .
+_:b0 <http://xmlns.com/foaf/0.1/name> "Dave" .
+<http://example.org/alice> <http://purl.org/dc/terms/title> "Alice in graph 1" <http://example.org/graph1> .
+<http://example.org/bob> <http://purl.org/dc/terms/title> "Bob in graph 1" <http://example.org/graph1> .
+<http://example.org/bob> <http://purl.org/dc/terms/title> "Bob in graph 2" <http://example.org/graph2> .
+`
+
+const prologue = `PREFIX foaf: <http://xmlns.com/foaf/0.1/>
+PREFIX dc: <http://purl.org/dc/terms/>
+PREFIX ex: <http://example.org/>
+PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+`
+
+func peopleDataset(t *testing.T) *ld.RDFDataset {
+	t.Helper()
+	dataset, err := ld.ParseNQuads(peopleInput)
+	require.NoError(t, err)
+	return dataset
+}
+
+// values returns the lexical forms of the values of the variable in every solution ("" if unbound).
+func values(results *query.Results, variable string) []string {
+	rval := make([]string, len(results.Solutions))
+	for i, solution := range results.Solutions {
+		if node, bound := solution[variable]; bound {
+			rval[i] = node.GetValue()
+		}
+	}
+	return rval
+}
+
+func TestSelect(t *testing.T) {
+	dataset := peopleDataset(t)
+
+	tests := []struct {
+		name     string
+		query    string
+		variable string
+		expected []string
+	}{
+		{
+			name:     "basic graph pattern",
+			query:    `SELECT ?name WHERE { ?p a foaf:Person ; foaf:knows ex:carol ; foaf:name ?name } ORDER BY ?name`,
+			variable: "name",
+			expected: []string{"Alice", "Bob", "Robert"},
+		},
+		{
+			name:     "object list and blank node",
+			query:    `SELECT ?name { ex:carol foaf:knows _:x . _:x foaf:name ?name }`,
+			variable: "name",
+			expected: []string{"Dave"},
+		},
+		{
+			name:     "blank node property list",
+			query:    `SELECT ?p WHERE { ?p foaf:knows [ foaf:name "Dave" ] }`,
+			variable: "p",
+			expected: []string{"http://example.org/carol"},
+		},
+		{
+			name:     "numeric comparison",
+			query:    `SELECT ?p WHERE { ?p foaf:age ?age FILTER(?age > 30 && ?age < 100.0) } ORDER BY ?p`,
+			variable: "p",
+			expected: []string{"http://example.org/alice", "http://example.org/carol"},
+		},
+		{
+			name:     "arithmetic",
+			query:    `SELECT ?p WHERE { ?p foaf:age ?age FILTER(?age * 2 - 1 = 53) }`,
+			variable: "p",
+			expected: []string{"http://example.org/bob"},
+		},
+		{
+			name:     "regex",
+			query:    `SELECT ?name WHERE { ?p foaf:name ?name FILTER regex(?name, "^(a|rob)", "i") } ORDER BY ?name`,
+			variable: "name",
+			expected: []string{"Alice", "Robert"},
+		},
+		{
+			name:     "language",
+			query:    `SELECT ?name WHERE { ex:bob foaf:name ?name FILTER(langMatches(lang(?name), "FR")) }`,
+			variable: "name",
+			expected: []string{"Robert"},
+		},
+		{
+			name: "string functions",
+			query: `SELECT ?name WHERE {
+				?p foaf:name ?name
+				FILTER(isLiteral(?name) && !isIRI(?name) && STRLEN(?name) = 5 && CONTAINS(UCASE(?name), "AR"))
+			}`,
+			variable: "name",
+			expected: []string{"Carol"},
+		},
+		{
+			name:     "term equality",
+			query:    `SELECT ?name WHERE { ?p foaf:name ?name FILTER(?name = "Bob"@en || STR(?name) = "Alice") } ORDER BY ?name`,
+			variable: "name",
+			expected: []string{"Alice", "Bob"},
+		},
+		{
+			name: "optional",
+			query: `SELECT ?name ?mbox WHERE {
+				?p foaf:name ?name .
+				OPTIONAL { ?p foaf:mbox ?mbox }
+				FILTER(lang(?name) != "fr")
+			} ORDER BY ?name`,
+			variable: "mbox",
+			expected: []string{"mailto:alice@example.org", "", "", ""},
+		},
+		{
+			name: "optional with filter",
+			query: `SELECT ?p ?age WHERE {
+				?p a foaf:Person .
+				OPTIONAL { ?p foaf:age ?age FILTER(?age < 40) }
+			} ORDER BY ?p`,
+			variable: "age",
+			expected: []string{"34", "27", ""},
+		},
+		{
+			name:     "unbound",
+			query:    `SELECT ?p WHERE { ?p a foaf:Person OPTIONAL { ?p foaf:mbox ?m } FILTER(!bound(?m)) } ORDER BY ?p`,
+			variable: "p",
+			expected: []string{"http://example.org/bob", "http://example.org/carol"},
+		},
+		{
+			name:     "union",
+			query:    `SELECT ?x WHERE { { ex:alice foaf:knows ?x } UNION { ?x foaf:knows ex:alice } UNION { ex:bob foaf:knows ?x } }`,
+			variable: "x",
+			expected: []string{"http://example.org/bob", "http://example.org/carol", "http://example.org/carol"},
+		},
+		{
+			name:     "distinct",
+			query:    `SELECT DISTINCT ?x WHERE { ?p foaf:knows ?x FILTER(isIRI(?x)) }`,
+			variable: "x",
+			expected: []string{"http://example.org/bob", "http://example.org/carol"},
+		},
+		{
+			name:     "named graph",
+			query:    `SELECT ?title WHERE { GRAPH ex:graph1 { ex:bob dc:title ?title } }`,
+			variable: "title",
+			expected: []string{"Bob in graph 1"},
+		},
+		{
+			name:     "graph variable",
+			query:    `SELECT ?g WHERE { ?p foaf:name "Bob"@en GRAPH ?g { ?p dc:title ?title } } ORDER BY DESC(?g)`,
+			variable: "g",
+			expected: []string{"http://example.org/graph2", "http://example.org/graph1"},
+		},
+		{
+			name:     "default graph only",
+			query:    `SELECT ?title WHERE { ?p dc:title ?title }`,
+			variable: "title",
+			expected: []string{},
+		},
+		{
+			name:     "order by expression with limit and offset",
+			query:    `SELECT ?p WHERE { ?p foaf:age ?age } ORDER BY DESC(?age * -1) LIMIT 2 OFFSET 1`,
+			variable: "p",
+			expected: []string{"http://example.org/alice", "http://example.org/carol"},
+		},
+		{
+			name: "base and relative IRIs",
+			query: `BASE <http://example.org/people/>
+				SELECT ?name WHERE { <../alice> <http://xmlns.com/foaf/0.1/name> ?name }`,
+			variable: "name",
+			expected: []string{"Alice"},
+		},
+		{
+			name:     "typed literal",
+			query:    `SELECT ?p WHERE { ?p foaf:age "27"^^xsd:integer }`,
+			variable: "p",
+			expected: []string{"http://example.org/bob"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results, err := query.Select(dataset, prologue+test.query)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, values(results, test.variable))
+		})
+	}
+}
+
+func TestSelectVariables(t *testing.T) {
+	dataset := peopleDataset(t)
+
+	results, err := query.Select(dataset, prologue+`
+		SELECT * WHERE {
+			?p foaf:knows _:x .
+			_:x foaf:name ?name .
+			OPTIONAL { ?p foaf:mbox ?mbox }
+			FILTER(?p = ex:carol)
+		}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p", "name", "mbox"}, results.Variables)
+	assert.Equal(t, []query.Solution{
+		{"p": ld.NewIRI("http://example.org/carol"), "name": ld.NewLiteral("Dave", "", "")},
+	}, results.Solutions)
+
+	// a parsed query can be evaluated against a store many times
+	q, err := query.Parse(prologue + `SELECT ?age ?p WHERE { ?p foaf:age ?age } ORDER BY ?age LIMIT 1`)
+	require.NoError(t, err)
+	assert.Equal(t, query.FormSelect, q.Form)
+	store := ld.NewQuadStoreFromDataset(dataset)
+	for i := 0; i < 2; i++ {
+		results, err = q.Select(store)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"age", "p"}, results.Variables)
+		assert.Equal(t, []query.Solution{
+			{"p": ld.NewIRI("http://example.org/bob"), "age": ld.NewLiteral("27", ld.XSDInteger, "")},
+		}, results.Solutions)
+	}
+
+	_, err = q.Construct(store)
+	assert.Error(t, err)
+}
+
+func TestConstruct(t *testing.T) {
+	dataset := peopleDataset(t)
+
+	result, err := query.Construct(dataset, prologue+`
+		PREFIX schema: <http://schema.org/>
+		CONSTRUCT {
+			?p a schema:Person ;
+				schema:name ?name ;
+				schema:email ?mbox ;
+				schema:knows [ a schema:Person ; schema:name ?friendName ] .
+		} WHERE {
+			?p a foaf:Person ; foaf:name ?name ; foaf:knows ?friend .
+			?friend foaf:name ?friendName .
+			OPTIONAL { ?p foaf:mbox ?mbox }
+			FILTER(?p = ex:alice)
+		}`)
+	require.NoError(t, err)
+	assert.Len(t, result.Graphs, 1)
+
+	// the results convert to JSON-LD like any other dataset
+	api := ld.NewJsonLdApi()
+	opts := ld.NewJsonLdOptions("")
+	expanded, err := api.FromRDF(result, opts)
+	require.NoError(t, err)
+	context := map[string]interface{}{
+		"@context": map[string]interface{}{
+			"@vocab": "http://schema.org/",
+			"email":  map[string]interface{}{"@type": "@id"},
+		},
+	}
+	compacted, err := ld.NewJsonLdProcessor().Compact(expanded, context, opts)
+	require.NoError(t, err)
+
+	graph := compacted["@graph"].([]interface{})
+	var alice map[string]interface{}
+	friends := make(map[string]bool)
+	for _, item := range graph {
+		node := item.(map[string]interface{})
+		if node["@id"] == "http://example.org/alice" {
+			alice = node
+		} else {
+			assert.Equal(t, "Person", node["@type"])
+			name := node["name"]
+			if value, isValue := name.(map[string]interface{}); isValue {
+				name = value["@value"]
+			}
+			friends[name.(string)] = true
+		}
+	}
+	require.NotNil(t, alice)
+	assert.Equal(t, "Alice", alice["name"])
+	assert.Equal(t, "mailto:alice@example.org", alice["email"])
+	assert.Len(t, alice["knows"], 3)
+	// the blank node of the template is a new node for every solution
+	assert.Equal(t, map[string]bool{"Bob": true, "Robert": true, "Carol": true}, friends)
+	assert.Len(t, graph, 4)
+}
+
+func TestConstructWhere(t *testing.T) {
+	dataset := peopleDataset(t)
+
+	result, err := query.Construct(dataset, prologue+`CONSTRUCT WHERE { ?p foaf:knows _:f . _:f foaf:name ?n }`)
+	require.NoError(t, err)
+
+	expected, err := ld.ParseNQuads(`<http://example.org/carol> <http://xmlns.com/foaf/0.1/knows> _:b0 .
+_:b0 <http://xmlns.com/foaf/0.1/name> "Dave" .
+<http://example.org/alice> <http://xmlns.com/foaf/0.1/knows> <http://example.org/bob> .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/name> "Bob"@en .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/name> "Robert"@fr .
+<http://example.org/alice> <http://xmlns.com/foaf/0.1/knows> <http://example.org/carol> .
+<http://example.org/carol> <http://xmlns.com/foaf/0.1/name> "Carol" .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/knows> <http://example.org/carol> .
+`)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, expected.Graphs["@default"], result.Graphs["@default"])
+
+	// triples with unbound variables are skipped
+	result, err = query.Construct(dataset, prologue+`
+		CONSTRUCT { ?p foaf:mbox ?mbox } WHERE { ?p a foaf:Person OPTIONAL { ?p foaf:mbox ?mbox } }`)
+	require.NoError(t, err)
+	assert.Len(t, result.Graphs["@default"], 1)
+}
+
+func TestConstructBlankNodeLabels(t *testing.T) {
+	dataset, err := ld.ParseNQuads(`<http://example.org/alice> <http://example.org/knows> _:c0 .
+<http://example.org/alice> <http://example.org/knows> _:c2 .
+`)
+	require.NoError(t, err)
+
+	result, err := query.Construct(dataset, prologue+`CONSTRUCT { ?k ex:friendOf _:n } WHERE { ?s ex:knows ?k }`)
+	require.NoError(t, err)
+
+	// the template blank nodes don't reuse the labels of the data blank nodes
+	quads := result.Graphs["@default"]
+	require.Len(t, quads, 2)
+	subjects := make(map[string]bool)
+	objects := make(map[string]bool)
+	for _, quad := range quads {
+		subjects[quad.Subject.GetValue()] = true
+		objects[quad.Object.GetValue()] = true
+	}
+	assert.Equal(t, map[string]bool{"_:c0": true, "_:c2": true}, subjects)
+	assert.Equal(t, map[string]bool{"_:c1": true, "_:c3": true}, objects)
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, input := range map[string]string{
+		"empty":              ``,
+		"ask":                `ASK { ?s ?p ?o }`,
+		"undefined prefix":   `SELECT * WHERE { ?s foo:bar ?o }`,
+		"missing brace":      `SELECT * WHERE { ?s ?p ?o `,
+		"no variables":       `SELECT WHERE { ?s ?p ?o }`,
+		"unknown function":   `SELECT * WHERE { ?s ?p ?o FILTER(foo(?o)) }`,
+		"bound of constant":  `SELECT * WHERE { ?s ?p ?o FILTER(bound("x")) }`,
+		"invalid regex":      `SELECT * WHERE { ?s ?p ?o FILTER(regex(?o, "(")) }`,
+		"regex flags":        `SELECT * WHERE { ?s ?p ?o FILTER(regex(?o, "a", "q")) }`,
+		"bind":               `SELECT * WHERE { ?s ?p ?o BIND(1 AS ?x) }`,
+		"unterminated":       `SELECT * WHERE { ?s ?p "abc }`,
+		"trailing tokens":    `SELECT * WHERE { ?s ?p ?o } LIMIT 1 ?x`,
+		"missing separator":  `SELECT * WHERE { ?s ?p ?o ?a ?b ?c }`,
+		"literal predicate":  `SELECT * WHERE { ?s "p" ?o }`,
+		"wrong arg number":   `SELECT * WHERE { ?s ?p ?o FILTER(STR(?o, ?p)) }`,
+		"unexpected char":    `SELECT * WHERE { ?s ?p ?o } ~`,
+		"negative limit":     `SELECT * WHERE { ?s ?p ?o } LIMIT -1`,
+		"select expressions": `SELECT (?o AS ?x) WHERE { ?s ?p ?o }`,
+	} {
+		_, err := query.Parse(input)
+		require.Error(t, err, name)
+		assert.Equal(t, ld.ParseError, err.(*ld.JsonLdError).Code, name) //nolint:errorlint
+	}
+
+	_, err := query.Parse("SELECT *\nWHERE {\n  ?s ?p ?o .\n  ?s }")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 4")
+}
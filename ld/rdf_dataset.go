@@ -221,8 +221,9 @@ var rest = NewIRI(RDFRest)
 var nilIRI = NewIRI(RDFNil)
 
 // GraphToRDF creates an array of RDF triples for the given graph.
+// It returns an error if the graph contains a malformed node or value object.
 func (ds *RDFDataset) GraphToRDF(graphName string, graph map[string]interface{}, issuer *IdentifierIssuer,
-	produceGeneralizedRdf bool) {
+	produceGeneralizedRdf bool) error {
 	// 4.2)
 	triples := make([]*Quad, 0)
 	// 4.3)
@@ -231,12 +232,15 @@ func (ds *RDFDataset) GraphToRDF(graphName string, graph map[string]interface{},
 			continue
 		}
 
-		node := graph[id].(map[string]interface{})
+		node, isMap := graph[id].(map[string]interface{})
+		if !isMap {
+			return NewJsonLdError(InvalidInput, fmt.Sprintf("node %s is not a map", id))
+		}
 		for _, property := range GetOrderedKeys(node) {
 			var values []interface{}
 			// 4.3.2.1)
 			if property == "@type" {
-				values = node["@type"].([]interface{})
+				values = Arrayify(node["@type"])
 				property = RDFType
 			} else if IsKeyword(property) {
 				// 4.3.2.2)
@@ -248,7 +252,7 @@ func (ds *RDFDataset) GraphToRDF(graphName string, graph map[string]interface{},
 				// 4.3.2.4)
 				continue
 			} else {
-				values = node[property].([]interface{})
+				values = Arrayify(node[property])
 			}
 
 			var subject Node
@@ -268,8 +272,11 @@ func (ds *RDFDataset) GraphToRDF(graphName string, graph map[string]interface{},
 			}
 
 			for _, item := range values {
-				var object Node
-				object, triples = objectToRDF(item, issuer, graphName, triples)
+				object, newTriples, err := objectToRDF(item, issuer, graphName, triples)
+				if err != nil {
+					return err
+				}
+				triples = newTriples
 				if object != nil {
 					triples = append(triples, NewQuad(subject, predicate, object, graphName))
 				}
@@ -285,6 +292,7 @@ func (ds *RDFDataset) GraphToRDF(graphName string, graph map[string]interface{},
 		}
 	}
 	ds.Graphs[graphName] = sanitisedTriples
+	return nil
 }
 
 // GetQuads returns a list of quads for the given graph
@@ -19,8 +19,51 @@ import (
 
 	. "github.com/piprate/json-gold/ld"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetCanonicalDouble(t *testing.T) {
 	assert.Equal(t, "5.3E0", GetCanonicalDouble(5.3))
 }
+
+func TestGraphToRDFRejectsMalformedValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		code  ErrorCode
+	}{
+		{
+			name:  "non-scalar value",
+			value: map[string]interface{}{"@value": []interface{}{"a"}},
+			code:  InvalidValueObject,
+		},
+		{
+			name:  "non-string language",
+			value: map[string]interface{}{"@value": "a", "@language": true},
+			code:  InvalidLanguageTaggedString,
+		},
+		{
+			name:  "malformed list item",
+			value: map[string]interface{}{"@list": []interface{}{map[string]interface{}{"@value": nil}}},
+			code:  InvalidValueObject,
+		},
+		{
+			name:  "non-term object",
+			value: 5.0,
+			code:  InvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := map[string]interface{}{
+				"http://example.org/s": map[string]interface{}{
+					"@id":                  "http://example.org/s",
+					"http://example.org/p": []interface{}{tt.value},
+				},
+			}
+			err := NewRDFDataset().GraphToRDF("@default", graph, NewIdentifierIssuer("_:b"), false)
+			require.Error(t, err)
+			assert.Equal(t, tt.code, err.(*JsonLdError).Code) //nolint:errorlint
+		})
+	}
+}
@@ -58,54 +58,81 @@ func (s *NQuadRDFSerializer) Serialize(dataset *RDFDataset) (interface{}, error)
 }
 
 func toNQuad(triple *Quad, graphName string) string {
+	return joinNQuad(
+		subjectTerm(triple.Subject),
+		predicateTerm(triple.Predicate),
+		objectTerm(triple.Object),
+		graphTerm(graphName),
+	)
+}
 
-	s := triple.Subject
-	p := triple.Predicate
-	o := triple.Object
+// joinNQuad returns an N-Quads statement made of the given terms. If graph is empty,
+// the statement belongs to the default graph.
+func joinNQuad(subject, predicate, object, graph string) string {
+	size := len(subject) + len(predicate) + len(object) + 5
+	if graph != "" {
+		size += len(graph) + 1
+	}
 
-	quad := ""
+	var sb strings.Builder
+	sb.Grow(size)
+	sb.WriteString(subject)
+	sb.WriteByte(' ')
+	sb.WriteString(predicate)
+	sb.WriteByte(' ')
+	sb.WriteString(object)
+	if graph != "" {
+		sb.WriteByte(' ')
+		sb.WriteString(graph)
+	}
+	sb.WriteString(" .\n")
+	return sb.String()
+}
 
-	// subject is an IRI or bnode
+// subjectTerm returns the N-Quads term for the given subject (an IRI or bnode).
+func subjectTerm(s Node) string {
 	if IsIRI(s) {
-		quad += "<" + escape(s.GetValue()) + ">"
-	} else {
-		quad += s.GetValue()
+		return "<" + escape(s.GetValue()) + ">"
 	}
+	return s.GetValue()
+}
 
+// predicateTerm returns the N-Quads term for the given predicate.
+func predicateTerm(p Node) string {
 	if IsIRI(p) {
-		quad += " <" + escape(p.GetValue()) + "> "
-	} else {
-		quad += " " + escape(p.GetValue()) + " "
+		return "<" + escape(p.GetValue()) + ">"
 	}
+	return escape(p.GetValue())
+}
 
-	// object is IRI, bnode or literal
+// objectTerm returns the N-Quads term for the given object (an IRI, bnode or literal).
+func objectTerm(o Node) string {
 	if IsIRI(o) {
-		quad += "<" + escape(o.GetValue()) + ">"
+		return "<" + escape(o.GetValue()) + ">"
 	} else if IsBlankNode(o) {
-		quad += o.GetValue()
-	} else {
-		literal := o.(*Literal)
-		escaped := escape(literal.GetValue())
-		quad += "\"" + escaped + "\""
-		if literal.Datatype == RDFLangString {
-			quad += "@" + literal.Language
-		} else if literal.Datatype != XSDString {
-			quad += "^^<" + escape(literal.Datatype) + ">"
-		}
+		return o.GetValue()
 	}
 
-	// graph
-	if graphName != "" {
-		if strings.Index(graphName, "_:") != 0 {
-			quad += " <" + escape(graphName) + ">"
-		} else {
-			quad += " " + graphName
-		}
+	literal := o.(*Literal)
+	term := "\"" + escape(literal.GetValue()) + "\""
+	if literal.Datatype == RDFLangString {
+		term += "@" + literal.Language
+	} else if literal.Datatype != XSDString {
+		term += "^^<" + escape(literal.Datatype) + ">"
 	}
+	return term
+}
 
-	quad += " .\n"
-
-	return quad
+// graphTerm returns the N-Quads term for the given graph name, or an empty string
+// for the default graph.
+func graphTerm(graphName string) string {
+	if graphName == "" {
+		return ""
+	}
+	if strings.Index(graphName, "_:") != 0 {
+		return "<" + escape(graphName) + ">"
+	}
+	return graphName
 }
 
 func unescape(str string) string {
@@ -120,6 +147,9 @@ func unescape(str string) string {
 }
 
 func escape(str string) string {
+	if !strings.ContainsAny(str, "\\\"\n\r\t\b\f") {
+		return str
+	}
 	str = strings.ReplaceAll(str, "\\", "\\\\")
 	str = strings.ReplaceAll(str, "\"", "\\\"")
 	str = strings.ReplaceAll(str, "\n", "\\n")
@@ -241,11 +271,42 @@ func ParseNQuadsFrom(o interface{}) (*RDFDataset, error) {
 	// build RDF dataset
 	dataset := NewRDFDataset()
 
-	scanner, err := newScannerFor(o)
+	err := scanNQuads(o, func(triple *Quad, name string) error {
+		// initialise graph in dataset
+		triples, present := dataset.Graphs[name]
+		if !present {
+			dataset.Graphs[name] = []*Quad{triple}
+		} else {
+			// add triple if unique to its graph
+			containsTriple := false
+			for _, elem := range triples {
+				if triple.Equal(elem) {
+					containsTriple = true
+					break
+				}
+			}
+			if !containsTriple {
+				dataset.Graphs[name] = append(triples, triple)
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	return dataset, nil
+}
+
+// scanNQuads parses N-Quads from io.Reader, []byte or string and calls fn for every quad,
+// in the order they appear in the input. The graph name is '@default' for the default graph.
+func scanNQuads(o interface{}, fn func(quad *Quad, graphName string) error) error {
+
+	scanner, err := newScannerFor(o)
+	if err != nil {
+		return err
+	}
+
 	// scan N-Quad input lines
 	lineNumber := 0
 	for scanner.Scan() {
@@ -259,7 +320,7 @@ func ParseNQuadsFrom(o interface{}) (*RDFDataset, error) {
 
 		// parse quad
 		if !regexQuad.Match(line) {
-			return nil, NewJsonLdError(SyntaxError, fmt.Errorf("error while parsing N-Quads; invalid quad. line: %d", lineNumber))
+			return NewJsonLdError(SyntaxError, fmt.Errorf("error while parsing N-Quads; invalid quad. line: %d", lineNumber))
 		}
 		match := regexQuad.FindStringSubmatch(string(line))
 
@@ -302,31 +363,15 @@ func ParseNQuadsFrom(o interface{}) (*RDFDataset, error) {
 			name = unescape(match[10])
 		}
 
-		triple := NewQuad(subject, predicate, object, name)
-
-		// initialise graph in dataset
-		triples, present := dataset.Graphs[name]
-		if !present {
-			dataset.Graphs[name] = []*Quad{triple}
-		} else {
-			// add triple if unique to its graph
-			containsTriple := false
-			for _, elem := range triples {
-				if triple.Equal(elem) {
-					containsTriple = true
-					break
-				}
-			}
-			if !containsTriple {
-				dataset.Graphs[name] = append(triples, triple)
-			}
+		if err := fn(NewQuad(subject, predicate, object, name), name); err != nil {
+			return err
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, NewJsonLdError(IOError, err)
+		return NewJsonLdError(IOError, err)
 	}
 
-	return dataset, nil
+	return nil
 }
 
 // ParseNQuads parses RDF in the form of N-Quads.
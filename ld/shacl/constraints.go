@@ -0,0 +1,665 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shacl
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/piprate/json-gold/ld"
+	"github.com/piprate/json-gold/ld/internal/xsd"
+)
+
+// constraint is an instance of a constraint component in a shape.
+type constraint struct {
+	// component is the IRI of the constraint component
+	component string
+	// validate reports the violations of the constraint by the value nodes of the focus node
+	validate func(vc *validationContext, rc *resultCollector, valueNodes []ld.Node)
+}
+
+// constraintFactory creates the constraint of the parameter (given by its local name)
+// with the given value in the shape. It may return a nil constraint for parameters which have no effect.
+type constraintFactory func(p *shapeParser, s *shape, name string, value ld.Node) (*constraint, error)
+
+// constraintFactories maps the local names of SHACL parameters to their constraint factories.
+var constraintFactories map[string]constraintFactory
+
+func init() {
+	// assigned here to break the initialization cycle through shapeParser.shape
+	constraintFactories = map[string]constraintFactory{
+		"class":               newClassConstraint,
+		"datatype":            newDatatypeConstraint,
+		"nodeKind":            newNodeKindConstraint,
+		"minCount":            newCountConstraint,
+		"maxCount":            newCountConstraint,
+		"minExclusive":        newRangeConstraint,
+		"minInclusive":        newRangeConstraint,
+		"maxExclusive":        newRangeConstraint,
+		"maxInclusive":        newRangeConstraint,
+		"minLength":           newLengthConstraint,
+		"maxLength":           newLengthConstraint,
+		"pattern":             newPatternConstraint,
+		"languageIn":          newLanguageInConstraint,
+		"uniqueLang":          newUniqueLangConstraint,
+		"equals":              newPropertyPairConstraint,
+		"disjoint":            newPropertyPairConstraint,
+		"not":                 newNotConstraint,
+		"and":                 newLogicalConstraint,
+		"or":                  newLogicalConstraint,
+		"xone":                newLogicalConstraint,
+		"node":                newNodeConstraint,
+		"property":            newPropertyConstraint,
+		"closed":              newClosedConstraint,
+		"hasValue":            newHasValueConstraint,
+		"in":                  newInConstraint,
+		"lessThan":            unsupportedConstraint,
+		"lessThanOrEquals":    unsupportedConstraint,
+		"qualifiedValueShape": unsupportedConstraint,
+	}
+}
+
+// resultCollector collects the validation results of a shape for a focus node.
+type resultCollector struct {
+	shape     *shape
+	focusNode ld.Node
+	component string
+	results   []*ValidationResult
+}
+
+// add adds a result for the given value node (nil if the result isn't about a value node).
+// The message is used if the shape has no sh:message.
+func (rc *resultCollector) add(value ld.Node, format string, args ...interface{}) {
+	var resultPath ld.Node
+	if rc.shape.path != nil {
+		resultPath = rc.shape.path.node
+	}
+	rc.addWithPath(resultPath, value, format, args...)
+}
+
+func (rc *resultCollector) addWithPath(resultPath, value ld.Node, format string, args ...interface{}) {
+	messages := rc.shape.messages
+	if len(messages) == 0 {
+		messages = []ld.Node{ld.NewLiteral(fmt.Sprintf(format, args...), ld.XSDString, "")}
+	}
+	rc.results = append(rc.results, &ValidationResult{
+		FocusNode:                 rc.focusNode,
+		ResultPath:                resultPath,
+		Value:                     value,
+		SourceShape:               rc.shape.id,
+		SourceConstraintComponent: rc.component,
+		Severity:                  rc.shape.severity,
+		Messages:                  messages,
+	})
+}
+
+// forEachValue returns a validation function which reports every value node for which valid returns false.
+func forEachValue(valid func(vc *validationContext, value ld.Node) bool, format string,
+	args ...interface{}) func(*validationContext, *resultCollector, []ld.Node) {
+	return func(vc *validationContext, rc *resultCollector, valueNodes []ld.Node) {
+		for _, value := range valueNodes {
+			if !valid(vc, value) {
+				rc.add(value, format, append([]interface{}{describe(value)}, args...)...)
+			}
+		}
+	}
+}
+
+func newClassConstraint(_ *shapeParser, _ *shape, _ string, class ld.Node) (*constraint, error) {
+	return &constraint{
+		component: SHACL + "ClassConstraintComponent",
+		validate: forEachValue(func(vc *validationContext, value ld.Node) bool {
+			return vc.isInstance(value, class)
+		}, "Value %s is not an instance of %s", describe(class)),
+	}, nil
+}
+
+func newDatatypeConstraint(_ *shapeParser, s *shape, _ string, datatype ld.Node) (*constraint, error) {
+	if !ld.IsIRI(datatype) {
+		return nil, invalidShapes("sh:datatype of %s must be an IRI", describe(s.id))
+	}
+	return &constraint{
+		component: SHACL + "DatatypeConstraintComponent",
+		validate: forEachValue(func(_ *validationContext, value ld.Node) bool {
+			l, isLiteral := value.(*ld.Literal)
+			return isLiteral && l.Datatype == datatype.GetValue() && isWellFormed(l)
+		}, "Value %s does not have datatype %s", describe(datatype)),
+	}, nil
+}
+
+func newNodeKindConstraint(_ *shapeParser, s *shape, _ string, nodeKind ld.Node) (*constraint, error) {
+	var blankNode, iri, literal bool
+	switch nodeKind.GetValue() {
+	case shBlankNode:
+		blankNode = true
+	case shIRI:
+		iri = true
+	case shLiteral:
+		literal = true
+	case shBlankNodeOrIRI:
+		blankNode, iri = true, true
+	case shBlankNodeOrLiteral:
+		blankNode, literal = true, true
+	case shIRIOrLiteral:
+		iri, literal = true, true
+	default:
+		return nil, invalidShapes("invalid sh:nodeKind of %s: %s", describe(s.id), describe(nodeKind))
+	}
+	return &constraint{
+		component: SHACL + "NodeKindConstraintComponent",
+		validate: forEachValue(func(_ *validationContext, value ld.Node) bool {
+			return ld.IsBlankNode(value) && blankNode || ld.IsIRI(value) && iri || ld.IsLiteral(value) && literal
+		}, "Value %s does not have node kind %s", describe(nodeKind)),
+	}, nil
+}
+
+func newCountConstraint(_ *shapeParser, s *shape, name string, value ld.Node) (*constraint, error) {
+	if s.path == nil {
+		return nil, invalidShapes("sh:%s can only be used in property shapes", name)
+	}
+	n, err := integerParameter(s, name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "minCount" {
+		return &constraint{
+			component: SHACL + "MinCountConstraintComponent",
+			validate: func(_ *validationContext, rc *resultCollector, valueNodes []ld.Node) {
+				if len(valueNodes) < n {
+					rc.add(nil, "Less than %d values", n)
+				}
+			},
+		}, nil
+	}
+	return &constraint{
+		component: SHACL + "MaxCountConstraintComponent",
+		validate: func(_ *validationContext, rc *resultCollector, valueNodes []ld.Node) {
+			if len(valueNodes) > n {
+				rc.add(nil, "More than %d values", n)
+			}
+		},
+	}, nil
+}
+
+func newRangeConstraint(_ *shapeParser, s *shape, name string, bound ld.Node) (*constraint, error) {
+	l, isLiteral := bound.(*ld.Literal)
+	if !isLiteral {
+		return nil, invalidShapes("range constraints of %s must be literals", describe(s.id))
+	}
+	for _, r := range []struct {
+		name     string
+		operator string
+		accept   func(c int) bool
+	}{
+		{"minExclusive", ">", func(c int) bool { return c > 0 }},
+		{"minInclusive", ">=", func(c int) bool { return c >= 0 }},
+		{"maxExclusive", "<", func(c int) bool { return c < 0 }},
+		{"maxInclusive", "<=", func(c int) bool { return c <= 0 }},
+	} {
+		if r.name != name {
+			continue
+		}
+		accept := r.accept
+		return &constraint{
+			component: SHACL + strings.ToUpper(r.name[:1]) + r.name[1:] + "ConstraintComponent",
+			validate: forEachValue(func(_ *validationContext, value ld.Node) bool {
+				v, isLiteral := value.(*ld.Literal)
+				if !isLiteral {
+					return false
+				}
+				c, comparable := compareLiterals(v, l)
+				return comparable && accept(c)
+			}, "Value %s is not "+r.operator+" %s", describe(bound)),
+		}, nil
+	}
+	return nil, nil
+}
+
+func newLengthConstraint(_ *shapeParser, s *shape, name string, value ld.Node) (*constraint, error) {
+	component, operator := "MinLengthConstraintComponent", "shorter"
+	if name == "maxLength" {
+		component, operator = "MaxLengthConstraintComponent", "longer"
+	}
+	n, err := integerParameter(s, name, value)
+	if err != nil {
+		return nil, err
+	}
+	return &constraint{
+		component: SHACL + component,
+		validate: forEachValue(func(_ *validationContext, value ld.Node) bool {
+			if ld.IsBlankNode(value) {
+				return false
+			}
+			length := utf8.RuneCountInString(value.GetValue())
+			if name == "minLength" {
+				return length >= n
+			}
+			return length <= n
+		}, "Value %s is "+operator+" than %d characters", n),
+	}, nil
+}
+
+func newPatternConstraint(p *shapeParser, s *shape, _ string, value ld.Node) (*constraint, error) {
+	pattern, isLiteral := value.(*ld.Literal)
+	if !isLiteral {
+		return nil, invalidShapes("sh:pattern of %s must be a literal", describe(s.id))
+	}
+	expr := pattern.Value
+	flags, err := p.singleObject(s.id, shFlags)
+	if err != nil {
+		return nil, err
+	}
+	if flags != nil {
+		f := flags.GetValue()
+		if strings.Trim(f, "ism") != "" {
+			return nil, invalidShapes("unsupported sh:flags of %s: %s", describe(s.id), f)
+		}
+		if f != "" {
+			expr = "(?" + f + ")" + expr
+		}
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, invalidShapes("invalid sh:pattern of %s: %v", describe(s.id), err)
+	}
+	return &constraint{
+		component: SHACL + "PatternConstraintComponent",
+		validate: forEachValue(func(_ *validationContext, value ld.Node) bool {
+			return !ld.IsBlankNode(value) && re.MatchString(value.GetValue())
+		}, "Value %s does not match pattern %q", pattern.Value),
+	}, nil
+}
+
+func newLanguageInConstraint(p *shapeParser, s *shape, _ string, value ld.Node) (*constraint, error) {
+	items, err := p.list(value)
+	if err != nil {
+		return nil, err
+	}
+	ranges := make([]string, len(items))
+	for i, item := range items {
+		if !ld.IsLiteral(item) {
+			return nil, invalidShapes("sh:languageIn of %s must be a list of literals", describe(s.id))
+		}
+		ranges[i] = item.GetValue()
+	}
+	return &constraint{
+		component: SHACL + "LanguageInConstraintComponent",
+		validate: forEachValue(func(_ *validationContext, value ld.Node) bool {
+			l, isLiteral := value.(*ld.Literal)
+			if !isLiteral || l.Language == "" {
+				return false
+			}
+			for _, r := range ranges {
+				if xsd.LangMatches(l.Language, r) {
+					return true
+				}
+			}
+			return false
+		}, "Language of value %s is not in %v", ranges),
+	}, nil
+}
+
+func newUniqueLangConstraint(_ *shapeParser, s *shape, name string, value ld.Node) (*constraint, error) {
+	if s.path == nil {
+		return nil, invalidShapes("sh:uniqueLang can only be used in property shapes")
+	}
+	unique, err := booleanValue(s.id, SHACL+name, value)
+	if err != nil || !unique {
+		return nil, err
+	}
+	return &constraint{
+		component: SHACL + "UniqueLangConstraintComponent",
+		validate: func(_ *validationContext, rc *resultCollector, valueNodes []ld.Node) {
+			counts := make(map[string]int)
+			var languages []string
+			for _, value := range valueNodes {
+				if l, isLiteral := value.(*ld.Literal); isLiteral && l.Language != "" {
+					lang := strings.ToLower(l.Language)
+					if counts[lang]++; counts[lang] == 2 {
+						languages = append(languages, lang)
+					}
+				}
+			}
+			for _, lang := range languages {
+				rc.add(nil, "Language %q is used by more than one value", lang)
+			}
+		},
+	}, nil
+}
+
+func newPropertyPairConstraint(_ *shapeParser, s *shape, name string, predicate ld.Node) (*constraint, error) {
+	if !ld.IsIRI(predicate) {
+		return nil, invalidShapes("sh:equals and sh:disjoint of %s must be IRIs", describe(s.id))
+	}
+	equals := name == "equals"
+	component := SHACL + "DisjointConstraintComponent"
+	if equals {
+		component = SHACL + "EqualsConstraintComponent"
+	}
+	return &constraint{
+		component: component,
+		validate: func(vc *validationContext, rc *resultCollector, valueNodes []ld.Node) {
+			values := newNodeSet()
+			values.add(valueNodes...)
+			others := newNodeSet()
+			others.add(vc.pathValues(&path{kind: predicatePath, node: predicate}, []ld.Node{rc.focusNode}, false)...)
+
+			for _, value := range valueNodes {
+				switch {
+				case equals && !others.contains(value):
+					rc.add(value, "Value %s is not a value of %s", describe(value), describe(predicate))
+				case !equals && others.contains(value):
+					rc.add(value, "Value %s is also a value of %s", describe(value), describe(predicate))
+				}
+			}
+			if equals {
+				for _, other := range others.nodes {
+					if !values.contains(other) {
+						rc.add(other, "Value %s of %s is missing", describe(other), describe(predicate))
+					}
+				}
+			}
+		},
+	}, nil
+}
+
+func newNotConstraint(p *shapeParser, _ *shape, _ string, shapeNode ld.Node) (*constraint, error) {
+	negated, err := p.shape(shapeNode)
+	if err != nil {
+		return nil, err
+	}
+	return &constraint{
+		component: SHACL + "NotConstraintComponent",
+		validate: forEachValue(func(vc *validationContext, value ld.Node) bool {
+			return !vc.conforms(value, negated)
+		}, "Value %s conforms to shape %s", describe(shapeNode)),
+	}, nil
+}
+
+func newLogicalConstraint(p *shapeParser, _ *shape, name string, value ld.Node) (*constraint, error) {
+	items, err := p.list(value)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]*shape, len(items))
+	for i, item := range items {
+		if members[i], err = p.shape(item); err != nil {
+			return nil, err
+		}
+	}
+
+	var component string
+	var accept func(conforming int) bool
+	switch name {
+	case "and":
+		component, accept = "AndConstraintComponent", func(conforming int) bool { return conforming == len(members) }
+	case "or":
+		component, accept = "OrConstraintComponent", func(conforming int) bool { return conforming > 0 }
+	default:
+		component, accept = "XoneConstraintComponent", func(conforming int) bool { return conforming == 1 }
+	}
+	return &constraint{
+		component: SHACL + component,
+		validate: forEachValue(func(vc *validationContext, value ld.Node) bool {
+			conforming := 0
+			for _, member := range members {
+				if vc.conforms(value, member) {
+					conforming++
+				}
+			}
+			return accept(conforming)
+		}, "Value %s does not satisfy sh:"+name),
+	}, nil
+}
+
+func newNodeConstraint(p *shapeParser, _ *shape, _ string, shapeNode ld.Node) (*constraint, error) {
+	nodeShape, err := p.shape(shapeNode)
+	if err != nil {
+		return nil, err
+	}
+	return &constraint{
+		component: SHACL + "NodeConstraintComponent",
+		validate: forEachValue(func(vc *validationContext, value ld.Node) bool {
+			return vc.conforms(value, nodeShape)
+		}, "Value %s does not conform to shape %s", describe(shapeNode)),
+	}, nil
+}
+
+func newPropertyConstraint(p *shapeParser, _ *shape, _ string, shapeNode ld.Node) (*constraint, error) {
+	propertyShape, err := p.shape(shapeNode)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.objects(shapeNode, shPath)) == 0 {
+		return nil, invalidShapes("property shape %s has no sh:path", describe(shapeNode))
+	}
+	return &constraint{
+		component: SHACL + "PropertyConstraintComponent",
+		validate: func(vc *validationContext, rc *resultCollector, valueNodes []ld.Node) {
+			// the results of property shapes are the results of the enclosing shape
+			for _, value := range valueNodes {
+				rc.results = append(rc.results, vc.validateShape(propertyShape, value)...)
+			}
+		},
+	}, nil
+}
+
+func newClosedConstraint(p *shapeParser, s *shape, name string, value ld.Node) (*constraint, error) {
+	closed, err := booleanValue(s.id, SHACL+name, value)
+	if err != nil || !closed {
+		return nil, err
+	}
+
+	allowed := newNodeSet()
+	for _, property := range p.objects(s.id, SHACL+"property") {
+		for _, pathNode := range p.objects(property, shPath) {
+			if ld.IsIRI(pathNode) {
+				allowed.add(pathNode)
+			}
+		}
+	}
+	ignored, err := p.singleObject(s.id, shIgnoredProperties)
+	if err != nil {
+		return nil, err
+	}
+	if ignored != nil {
+		items, err := p.list(ignored)
+		if err != nil {
+			return nil, err
+		}
+		allowed.add(items...)
+	}
+
+	return &constraint{
+		component: SHACL + "ClosedConstraintComponent",
+		validate: func(vc *validationContext, rc *resultCollector, valueNodes []ld.Node) {
+			for _, value := range valueNodes {
+				vc.dataGraph.ForEachMatch(value, nil, nil, "@default", func(quad *ld.Quad) bool {
+					if !allowed.contains(quad.Predicate) {
+						rc.addWithPath(quad.Predicate, quad.Object, "Property %s is not allowed", describe(quad.Predicate))
+					}
+					return true
+				})
+			}
+		},
+	}, nil
+}
+
+func newHasValueConstraint(_ *shapeParser, _ *shape, _ string, expected ld.Node) (*constraint, error) {
+	return &constraint{
+		component: SHACL + "HasValueConstraintComponent",
+		validate: func(_ *validationContext, rc *resultCollector, valueNodes []ld.Node) {
+			for _, value := range valueNodes {
+				if value.Equal(expected) {
+					return
+				}
+			}
+			rc.add(nil, "Missing expected value %s", describe(expected))
+		},
+	}, nil
+}
+
+func newInConstraint(p *shapeParser, _ *shape, _ string, value ld.Node) (*constraint, error) {
+	items, err := p.list(value)
+	if err != nil {
+		return nil, err
+	}
+	allowed := newNodeSet()
+	allowed.add(items...)
+	return &constraint{
+		component: SHACL + "InConstraintComponent",
+		validate: forEachValue(func(_ *validationContext, value ld.Node) bool {
+			return allowed.contains(value)
+		}, "Value %s is not in the list of allowed values"),
+	}, nil
+}
+
+func unsupportedConstraint(_ *shapeParser, _ *shape, name string, _ ld.Node) (*constraint, error) {
+	return nil, invalidShapes("sh:%s is not supported", name)
+}
+
+func integerParameter(s *shape, name string, value ld.Node) (int, error) {
+	if l, isLiteral := value.(*ld.Literal); isLiteral && integerTypes[l.Datatype] {
+		if n, err := strconv.Atoi(l.Value); err == nil && n >= 0 {
+			return n, nil
+		}
+	}
+	return 0, invalidShapes("sh:%s of %s must be a non-negative integer, found %s", name, describe(s.id), describe(value))
+}
+
+var integerTypes = map[string]bool{
+	ld.XSDInteger:                   true,
+	ld.XSDNS + "nonPositiveInteger": true,
+	ld.XSDNS + "negativeInteger":    true,
+	ld.XSDNS + "long":               true,
+	ld.XSDNS + "int":                true,
+	ld.XSDNS + "short":              true,
+	ld.XSDNS + "byte":               true,
+	ld.XSDNS + "nonNegativeInteger": true,
+	ld.XSDNS + "unsignedLong":       true,
+	ld.XSDNS + "unsignedInt":        true,
+	ld.XSDNS + "unsignedShort":      true,
+	ld.XSDNS + "unsignedByte":       true,
+	ld.XSDNS + "positiveInteger":    true,
+}
+
+const (
+	xsdDateTime = ld.XSDNS + "dateTime"
+	xsdDate     = ld.XSDNS + "date"
+)
+
+var (
+	integerPattern = regexp.MustCompile(`^[+-]?\d+$`)
+	decimalPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+	doublePattern  = regexp.MustCompile(`^([+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?|[+-]?INF|NaN)$`)
+)
+
+// isWellFormed returns false if the lexical form of the literal isn't valid for its datatype.
+// Only the lexical forms of common XSD datatypes are checked.
+func isWellFormed(l *ld.Literal) bool {
+	switch {
+	case integerTypes[l.Datatype]:
+		return integerPattern.MatchString(l.Value)
+	case l.Datatype == ld.XSDDecimal:
+		return decimalPattern.MatchString(l.Value)
+	case l.Datatype == ld.XSDDouble || l.Datatype == ld.XSDFloat:
+		return doublePattern.MatchString(l.Value)
+	case l.Datatype == ld.XSDBoolean:
+		return l.Value == "true" || l.Value == "false" || l.Value == "1" || l.Value == "0"
+	case l.Datatype == xsdDateTime:
+		_, err := xsd.ParseDateTime(l.Value)
+		return err == nil
+	case l.Datatype == xsdDate:
+		_, err := xsd.ParseDate(l.Value)
+		return err == nil
+	case l.Datatype == ld.RDFLangString:
+		return l.Language != ""
+	default:
+		return true
+	}
+}
+
+// compareLiterals compares two numeric, string, dateTime or date literals.
+// It returns false if the literals can't be compared.
+func compareLiterals(a, b *ld.Literal) (int, bool) {
+	if fa, ok := numericValue(a); ok {
+		fb, ok := numericValue(b)
+		if !ok || math.IsNaN(fa) || math.IsNaN(fb) {
+			return 0, false
+		}
+		return xsd.CompareFloats(fa, fb), true
+	}
+	if a.Datatype != b.Datatype {
+		return 0, false
+	}
+
+	switch a.Datatype {
+	case ld.XSDString:
+		return strings.Compare(a.Value, b.Value), true
+	case xsdDateTime, xsdDate:
+		parse := xsd.ParseDateTime
+		if a.Datatype == xsdDate {
+			parse = xsd.ParseDate
+		}
+		ta, errA := parse(a.Value)
+		tb, errB := parse(b.Value)
+		if errA != nil || errB != nil {
+			return 0, false
+		}
+		return xsd.CompareFloats(float64(ta.Sub(tb)), 0), true
+	default:
+		return 0, false
+	}
+}
+
+func numericValue(l *ld.Literal) (float64, bool) {
+	switch {
+	case integerTypes[l.Datatype] || l.Datatype == ld.XSDDecimal ||
+		l.Datatype == ld.XSDDouble || l.Datatype == ld.XSDFloat:
+		if !isWellFormed(l) {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(strings.TrimPrefix(strings.Replace(l.Value, "INF", "Inf", 1), "+"), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// describe returns the N-Quads-like representation of the node, for messages.
+func describe(n ld.Node) string {
+	switch v := n.(type) {
+	case *ld.IRI:
+		return "<" + v.Value + ">"
+	case *ld.BlankNode:
+		return v.Attribute
+	case *ld.Literal:
+		switch {
+		case v.Language != "":
+			return strconv.Quote(v.Value) + "@" + v.Language
+		case v.Datatype == ld.XSDString:
+			return strconv.Quote(v.Value)
+		default:
+			return strconv.Quote(v.Value) + "^^<" + v.Datatype + ">"
+		}
+	default:
+		return fmt.Sprint(n)
+	}
+}
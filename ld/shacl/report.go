@@ -0,0 +1,155 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shacl
+
+import (
+	"github.com/piprate/json-gold/ld"
+)
+
+// ValidationReport is the result of the validation of a data graph.
+type ValidationReport struct {
+	// Conforms is true if there are no validation results.
+	Conforms bool
+	Results  []*ValidationResult
+
+	shapesGraph *ld.QuadStore
+}
+
+// ValidationResult describes a violation of a constraint by a focus node.
+type ValidationResult struct {
+	FocusNode ld.Node
+	// ResultPath is the node of the path of the property shape in the shapes graph,
+	// the property of sh:closed violations or nil.
+	ResultPath ld.Node
+	// Value is the value node which violates the constraint, or nil if the violation
+	// isn't about a single value (for example, a sh:minCount violation).
+	Value       ld.Node
+	SourceShape ld.Node
+	// SourceConstraintComponent is the IRI of the violated constraint component,
+	// for example http://www.w3.org/ns/shacl#MinCountConstraintComponent.
+	SourceConstraintComponent string
+	// Severity is the IRI of the severity of the shape (Violation by default).
+	Severity string
+	// Messages holds the sh:message values of the shape or a generated message.
+	Messages []ld.Node
+}
+
+// Dataset returns the report as an RDF dataset, using the SHACL vocabulary in the default graph.
+//
+// Focus nodes and values keep their blank node identifiers from the data graph.
+// Blank nodes of the shapes graph (for example, in complex result paths) and
+// the nodes of the report and its results get new identifiers which don't clash with them.
+// Complex result paths are copied from the shapes graph.
+func (r *ValidationReport) Dataset() *ld.RDFDataset {
+	rb := &reportBuilder{
+		store:       ld.NewQuadStore(),
+		shapesGraph: r.shapesGraph,
+		issuer:      ld.NewIdentifierIssuer("_:r"),
+		used:        make(map[string]bool),
+		shapeLabels: make(map[string]ld.Node),
+	}
+	for _, result := range r.Results {
+		for _, node := range []ld.Node{result.FocusNode, result.Value} {
+			if bn, isBlankNode := node.(*ld.BlankNode); isBlankNode {
+				rb.used[bn.Attribute] = true
+			}
+		}
+	}
+
+	reportNode := rb.newBlankNode()
+	rb.add(reportNode, rdfType, ld.NewIRI(shValidationReport))
+	rb.add(reportNode, ld.NewIRI(shConforms), ld.NewLiteral(boolString(r.Conforms), ld.XSDBoolean, ""))
+	for _, result := range r.Results {
+		resultNode := rb.newBlankNode()
+		rb.add(reportNode, ld.NewIRI(shResult), resultNode)
+		rb.add(resultNode, rdfType, ld.NewIRI(shValidationResult))
+		rb.add(resultNode, ld.NewIRI(shFocusNode), result.FocusNode)
+		if result.ResultPath != nil {
+			rb.add(resultNode, ld.NewIRI(shResultPath), rb.copyFromShapes(result.ResultPath))
+		}
+		if result.Value != nil {
+			rb.add(resultNode, ld.NewIRI(shValue), result.Value)
+		}
+		rb.add(resultNode, ld.NewIRI(shSourceShape), rb.shapeNode(result.SourceShape))
+		rb.add(resultNode, ld.NewIRI(shSourceConstraintComponent), ld.NewIRI(result.SourceConstraintComponent))
+		rb.add(resultNode, ld.NewIRI(shResultSeverity), ld.NewIRI(result.Severity))
+		for _, message := range result.Messages {
+			rb.add(resultNode, ld.NewIRI(shResultMessage), message)
+		}
+	}
+	return rb.store.Dataset()
+}
+
+// reportBuilder builds the RDF representation of a validation report.
+type reportBuilder struct {
+	store       *ld.QuadStore
+	shapesGraph *ld.QuadStore
+	issuer      *ld.IdentifierIssuer
+	// used holds the blank node identifiers of the data graph used in the report
+	used map[string]bool
+	// shapeLabels maps the blank nodes of the shapes graph to the blank nodes of the report
+	shapeLabels map[string]ld.Node
+}
+
+func (rb *reportBuilder) add(subject, predicate, object ld.Node) {
+	rb.store.Add(ld.NewQuad(subject, predicate, object, "@default"))
+}
+
+func (rb *reportBuilder) newBlankNode() ld.Node {
+	for {
+		if id := rb.issuer.GetId(""); !rb.used[id] {
+			return ld.NewBlankNode(id)
+		}
+	}
+}
+
+// shapeNode returns the node of the report for the given node of the shapes graph.
+func (rb *reportBuilder) shapeNode(node ld.Node) ld.Node {
+	bn, isBlankNode := node.(*ld.BlankNode)
+	if !isBlankNode {
+		return node
+	}
+	if mapped, found := rb.shapeLabels[bn.Attribute]; found {
+		return mapped
+	}
+	mapped := rb.newBlankNode()
+	rb.shapeLabels[bn.Attribute] = mapped
+	return mapped
+}
+
+// copyFromShapes returns the report node for the given node of the shapes graph and,
+// if it's a blank node, copies the triples of the blank nodes reachable from it.
+func (rb *reportBuilder) copyFromShapes(node ld.Node) ld.Node {
+	if !ld.IsBlankNode(node) {
+		return node
+	}
+	_, copied := rb.shapeLabels[node.GetValue()]
+	mapped := rb.shapeNode(node)
+	if copied || rb.shapesGraph == nil {
+		return mapped
+	}
+	rb.shapesGraph.ForEachMatch(node, nil, nil, "@default", func(quad *ld.Quad) bool {
+		rb.add(mapped, quad.Predicate, rb.copyFromShapes(quad.Object))
+		return true
+	})
+	return mapped
+}
+
+func boolString(v bool) string {
+	if v {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,147 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shacl validates RDF data against shapes as defined by
+// the Shapes Constraint Language (SHACL) Core (https://www.w3.org/TR/shacl/).
+//
+// The data and shapes graphs are the default graphs of the given datasets,
+// so both can be produced from JSON-LD with ld.JsonLdProcessor.ToRDF.
+// Validation reports can be converted back to RDF with ValidationReport.Dataset
+// and then to JSON-LD with ld.JsonLdApi.FromRDF and ld.JsonLdProcessor.Compact.
+//
+// Supported are node and property shapes with all target types, predicate,
+// sequence, alternative, inverse, zero-or-more, one-or-more and zero-or-one paths,
+// sh:deactivated, sh:severity and sh:message, and the constraint components
+// sh:class, sh:datatype, sh:nodeKind, sh:minCount, sh:maxCount, sh:minExclusive,
+// sh:minInclusive, sh:maxExclusive, sh:maxInclusive, sh:minLength, sh:maxLength,
+// sh:pattern, sh:languageIn, sh:uniqueLang, sh:equals, sh:disjoint, sh:not, sh:and,
+// sh:or, sh:xone, sh:node, sh:property, sh:closed, sh:hasValue and sh:in.
+// sh:lessThan, sh:lessThanOrEquals, qualified value shapes and SHACL-SPARQL aren't supported.
+package shacl
+
+import (
+	"github.com/piprate/json-gold/ld"
+)
+
+// SHACL is the namespace of the SHACL vocabulary.
+const SHACL = "http://www.w3.org/ns/shacl#"
+
+// Severities of validation results.
+const (
+	Violation = SHACL + "Violation"
+	Warning   = SHACL + "Warning"
+	Info      = SHACL + "Info"
+)
+
+const (
+	rdfsSubClassOf = ld.RDFSchemaNS + "subClassOf"
+	rdfsClass      = ld.RDFSchemaNS + "Class"
+
+	shNodeShape     = SHACL + "NodeShape"
+	shPropertyShape = SHACL + "PropertyShape"
+
+	shTargetNode       = SHACL + "targetNode"
+	shTargetClass      = SHACL + "targetClass"
+	shTargetSubjectsOf = SHACL + "targetSubjectsOf"
+	shTargetObjectsOf  = SHACL + "targetObjectsOf"
+
+	shPath              = SHACL + "path"
+	shInversePath       = SHACL + "inversePath"
+	shAlternativePath   = SHACL + "alternativePath"
+	shZeroOrMorePath    = SHACL + "zeroOrMorePath"
+	shOneOrMorePath     = SHACL + "oneOrMorePath"
+	shZeroOrOnePath     = SHACL + "zeroOrOnePath"
+	shDeactivated       = SHACL + "deactivated"
+	shSeverity          = SHACL + "severity"
+	shMessage           = SHACL + "message"
+	shFlags             = SHACL + "flags"
+	shIgnoredProperties = SHACL + "ignoredProperties"
+
+	shValidationReport          = SHACL + "ValidationReport"
+	shValidationResult          = SHACL + "ValidationResult"
+	shConforms                  = SHACL + "conforms"
+	shResult                    = SHACL + "result"
+	shFocusNode                 = SHACL + "focusNode"
+	shResultPath                = SHACL + "resultPath"
+	shValue                     = SHACL + "value"
+	shSourceShape               = SHACL + "sourceShape"
+	shSourceConstraintComponent = SHACL + "sourceConstraintComponent"
+	shResultSeverity            = SHACL + "resultSeverity"
+	shResultMessage             = SHACL + "resultMessage"
+
+	shBlankNode          = SHACL + "BlankNode"
+	shIRI                = SHACL + "IRI"
+	shLiteral            = SHACL + "Literal"
+	shBlankNodeOrIRI     = SHACL + "BlankNodeOrIRI"
+	shBlankNodeOrLiteral = SHACL + "BlankNodeOrLiteral"
+	shIRIOrLiteral       = SHACL + "IRIOrLiteral"
+)
+
+var rdfType = ld.NewIRI(ld.RDFType)
+
+// Validator validates data graphs against a shapes graph. A Validator may be used
+// concurrently by multiple goroutines.
+type Validator struct {
+	shapesGraph *ld.QuadStore
+	shapes      []*shape
+}
+
+// NewValidator parses the shapes in the default graph of the given dataset.
+// An ld.InvalidInput error is returned if the shapes are malformed, for example,
+// if sh:minCount isn't an integer or a SHACL list is broken.
+func NewValidator(shapes *ld.RDFDataset) (*Validator, error) {
+	v := &Validator{shapesGraph: ld.NewQuadStoreFromDataset(shapes)}
+	p := &shapeParser{
+		shapesGraph: v.shapesGraph,
+		shapes:      make(map[string]*shape),
+	}
+	var err error
+	if v.shapes, err = p.parseShapes(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Validate validates the default graph of the data dataset against the shapes
+// in the default graph of the shapes dataset.
+func Validate(data, shapes *ld.RDFDataset) (*ValidationReport, error) {
+	v, err := NewValidator(shapes)
+	if err != nil {
+		return nil, err
+	}
+	return v.Validate(data), nil
+}
+
+// Validate validates the default graph of the given dataset against the shapes of the validator.
+func (v *Validator) Validate(data *ld.RDFDataset) *ValidationReport {
+	vc := &validationContext{
+		dataGraph: ld.NewQuadStoreFromDataset(data),
+		active:    make(map[string]bool),
+	}
+	report := &ValidationReport{
+		Conforms:    true,
+		Results:     make([]*ValidationResult, 0),
+		shapesGraph: v.shapesGraph,
+	}
+	for _, s := range v.shapes {
+		if s.deactivated {
+			continue
+		}
+		for _, focusNode := range vc.targetNodes(s) {
+			report.Results = append(report.Results, vc.validateShape(s, focusNode)...)
+		}
+	}
+	report.Conforms = len(report.Results) == 0
+	return report
+}
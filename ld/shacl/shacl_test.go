@@ -0,0 +1,496 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shacl_test
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/piprate/json-gold/ld"
+	"github.com/piprate/json-gold/ld/shacl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const shapesContext = `{
+	"sh": "http://www.w3.org/ns/shacl#",
+	"ex": "http://example.org/",
+	"xsd": "http://www.w3.org/2001/XMLSchema#",
+	"rdfs": "http://www.w3.org/2000/01/rdf-schema#",
+	"sh:targetClass": {"@type": "@id"},
+	"sh:targetNode": {"@type": "@id"},
+	"sh:path": {"@type": "@id"},
+	"sh:datatype": {"@type": "@id"},
+	"sh:class": {"@type": "@id"},
+	"sh:node": {"@type": "@id"},
+	"sh:nodeKind": {"@type": "@id"},
+	"sh:severity": {"@type": "@id"},
+	"sh:inversePath": {"@type": "@id"},
+	"sh:in": {"@container": "@list"},
+	"sh:and": {"@container": "@list"},
+	"sh:or": {"@container": "@list"},
+	"sh:xone": {"@container": "@list"},
+	"sh:ignoredProperties": {"@container": "@list", "@type": "@id"},
+	"rdfs:subClassOf": {"@type": "@id"}
+}`
+
+const dataContext = `{
+	"@vocab": "http://example.org/",
+	"ex": "http://example.org/",
+	"xsd": "http://www.w3.org/2001/XMLSchema#",
+	"rdfs": "http://www.w3.org/2000/01/rdf-schema#",
+	"knows": {"@type": "@id"},
+	"status": {"@type": "@id"},
+	"rdfs:subClassOf": {"@type": "@id"}
+}`
+
+// toRDF converts the JSON-LD graph to RDF using the given inline context.
+func toRDF(t *testing.T, context, graph string) *ld.RDFDataset {
+	t.Helper()
+	var ctx, nodes interface{}
+	require.NoError(t, json.Unmarshal([]byte(context), &ctx))
+	require.NoError(t, json.Unmarshal([]byte(graph), &nodes))
+	doc := map[string]interface{}{
+		"@context": ctx,
+		"@graph":   nodes,
+	}
+	dataset, err := ld.NewJsonLdProcessor().ToRDF(doc, ld.NewJsonLdOptions(""))
+	require.NoError(t, err)
+	return dataset.(*ld.RDFDataset)
+}
+
+func validate(t *testing.T, shapes, data string) *shacl.ValidationReport {
+	t.Helper()
+	report, err := shacl.Validate(toRDF(t, dataContext, data), toRDF(t, shapesContext, shapes))
+	require.NoError(t, err)
+	return report
+}
+
+// components returns the sorted local names of the constraint components of the results.
+func components(report *shacl.ValidationReport) []string {
+	rval := make([]string, 0, len(report.Results))
+	for _, result := range report.Results {
+		rval = append(rval, result.SourceConstraintComponent[len(shacl.SHACL):])
+	}
+	sort.Strings(rval)
+	return rval
+}
+
+const personShapes = `[
+	{
+		"@id": "ex:PersonShape",
+		"@type": "sh:NodeShape",
+		"sh:targetClass": "ex:Person",
+		"sh:property": [
+			{
+				"sh:path": "ex:name",
+				"sh:minCount": 1,
+				"sh:maxCount": 1,
+				"sh:datatype": "xsd:string"
+			},
+			{
+				"sh:path": "ex:age",
+				"sh:datatype": "xsd:integer",
+				"sh:minInclusive": 0
+			},
+			{
+				"sh:path": "ex:email",
+				"sh:pattern": "^[^@]+@example\\.org$"
+			},
+			{
+				"sh:path": "ex:knows",
+				"sh:class": "ex:Person"
+			},
+			{
+				"sh:path": "ex:status",
+				"sh:in": [{"@id": "ex:Active"}, {"@id": "ex:Retired"}]
+			}
+		]
+	}
+]`
+
+func TestValidateConforms(t *testing.T) {
+	report := validate(t, personShapes, `[
+		{"@id": "ex:alice", "@type": "Person", "name": "Alice", "age": 34,
+			"email": "alice@example.org", "knows": "ex:bob", "status": "ex:Active"},
+		{"@id": "ex:bob", "@type": "Employee", "name": "Bob"},
+		{"@id": "ex:Employee", "rdfs:subClassOf": "ex:Person"}
+	]`)
+
+	assert.True(t, report.Conforms)
+	assert.Empty(t, report.Results)
+}
+
+func TestValidatePropertyShapes(t *testing.T) {
+	report := validate(t, personShapes, `[
+		{"@id": "ex:alice", "@type": "Person", "name": ["Alice", "Alicia"], "age": -1,
+			"email": "alice@example.com", "knows": "ex:rex", "status": "ex:Unknown"},
+		{"@id": "ex:bob", "@type": "Person", "age": "27"},
+		{"@id": "ex:rex", "@type": "Dog", "name": "Rex"}
+	]`)
+
+	assert.False(t, report.Conforms)
+	assert.Equal(t, []string{
+		"ClassConstraintComponent",
+		"DatatypeConstraintComponent",
+		"InConstraintComponent",
+		"MaxCountConstraintComponent",
+		"MinCountConstraintComponent",
+		"MinInclusiveConstraintComponent",
+		// "27" is a string, which can't be compared with 0
+		"MinInclusiveConstraintComponent",
+		"PatternConstraintComponent",
+	}, components(report))
+
+	for _, result := range report.Results {
+		assert.Equal(t, shacl.Violation, result.Severity)
+		assert.True(t, ld.IsBlankNode(result.SourceShape))
+		assert.NotEmpty(t, result.Messages)
+		switch result.SourceConstraintComponent[len(shacl.SHACL):] {
+		case "MinCountConstraintComponent":
+			assert.Equal(t, "http://example.org/bob", result.FocusNode.GetValue())
+			assert.Equal(t, "http://example.org/name", result.ResultPath.GetValue())
+			assert.Nil(t, result.Value)
+		case "DatatypeConstraintComponent":
+			assert.Equal(t, "http://example.org/bob", result.FocusNode.GetValue())
+			assert.Equal(t, "27", result.Value.GetValue())
+		case "ClassConstraintComponent":
+			assert.Equal(t, "http://example.org/alice", result.FocusNode.GetValue())
+			assert.Equal(t, "http://example.org/rex", result.Value.GetValue())
+		case "InConstraintComponent":
+			assert.Equal(t, "http://example.org/Unknown", result.Value.GetValue())
+		}
+	}
+}
+
+func TestValidateLogicalConstraints(t *testing.T) {
+	shapes := `[
+		{
+			"@id": "ex:HasName",
+			"sh:property": {"sh:path": "ex:name", "sh:minCount": 1}
+		},
+		{
+			"@id": "ex:HasAlias",
+			"sh:property": {"sh:path": "ex:alias", "sh:minCount": 1}
+		},
+		{
+			"@id": "ex:Named",
+			"@type": "sh:NodeShape",
+			"sh:targetClass": "ex:Thing",
+			"sh:or": [{"@id": "ex:HasName"}, {"@id": "ex:HasAlias"}]
+		},
+		{
+			"@id": "ex:OneName",
+			"@type": "sh:NodeShape",
+			"sh:targetClass": "ex:Thing",
+			"sh:xone": [{"@id": "ex:HasName"}, {"@id": "ex:HasAlias"}]
+		},
+		{
+			"@id": "ex:NotAnonymous",
+			"@type": "sh:NodeShape",
+			"sh:targetClass": "ex:Thing",
+			"sh:not": {"sh:property": {"sh:path": "ex:anonymous", "sh:hasValue": true}}
+		},
+		{
+			"@id": "ex:Both",
+			"@type": "sh:NodeShape",
+			"sh:targetNode": "ex:c",
+			"sh:and": [{"@id": "ex:HasName"}, {"@id": "ex:HasAlias"}]
+		}
+	]`
+	report := validate(t, shapes, `[
+		{"@id": "ex:a", "@type": "Thing", "name": "A"},
+		{"@id": "ex:b", "@type": "Thing"},
+		{"@id": "ex:c", "@type": "Thing", "name": "C", "alias": "See", "anonymous": true}
+	]`)
+
+	focusNodes := make(map[string][]string)
+	for _, result := range report.Results {
+		component := result.SourceConstraintComponent[len(shacl.SHACL):]
+		focusNodes[component] = append(focusNodes[component], result.FocusNode.GetValue())
+		assert.Equal(t, result.FocusNode, result.Value)
+	}
+	for _, nodes := range focusNodes {
+		sort.Strings(nodes)
+	}
+	assert.Equal(t, map[string][]string{
+		"OrConstraintComponent":   {"http://example.org/b"},
+		"XoneConstraintComponent": {"http://example.org/b", "http://example.org/c"},
+		"NotConstraintComponent":  {"http://example.org/c"},
+	}, focusNodes)
+}
+
+func TestValidateNodeConstraint(t *testing.T) {
+	shapes := `[
+		{
+			"@id": "ex:AddressShape",
+			"sh:property": [
+				{"sh:path": "ex:city", "sh:minCount": 1, "sh:nodeKind": {"@id": "sh:Literal"}},
+				{"sh:path": "ex:postcode", "sh:maxLength": 8}
+			]
+		},
+		{
+			"@id": "ex:PersonShape",
+			"@type": "sh:NodeShape",
+			"sh:targetClass": "ex:Person",
+			"sh:property": {"sh:path": "ex:address", "sh:node": "ex:AddressShape"}
+		}
+	]`
+	report := validate(t, shapes, `[
+		{"@id": "ex:alice", "@type": "Person", "address": {"city": "London", "postcode": "EC1A 1BB"}},
+		{"@id": "ex:bob", "@type": "Person", "address": {"postcode": "SW1A 1AA 1"}}
+	]`)
+
+	require.Len(t, report.Results, 1)
+	result := report.Results[0]
+	assert.Equal(t, shacl.SHACL+"NodeConstraintComponent", result.SourceConstraintComponent)
+	assert.Equal(t, "http://example.org/bob", result.FocusNode.GetValue())
+	assert.True(t, ld.IsBlankNode(result.Value))
+	assert.Equal(t, "http://example.org/address", result.ResultPath.GetValue())
+}
+
+func TestValidateRecursiveShape(t *testing.T) {
+	shapes := `[
+		{
+			"@id": "ex:PersonShape",
+			"@type": "sh:NodeShape",
+			"sh:targetClass": "ex:Person",
+			"sh:property": [
+				{"sh:path": "ex:name", "sh:minCount": 1},
+				{"sh:path": "ex:knows", "sh:node": "ex:PersonShape"}
+			]
+		}
+	]`
+	report := validate(t, shapes, `[
+		{"@id": "ex:alice", "@type": "Person", "name": "Alice", "knows": "ex:bob"},
+		{"@id": "ex:bob", "@type": "Person", "name": "Bob", "knows": ["ex:alice", "ex:carol"]},
+		{"@id": "ex:carol"}
+	]`)
+
+	// carol has no name, so neither bob, who knows her, nor alice, who knows bob, conform
+	var violations []string
+	for _, result := range report.Results {
+		assert.Equal(t, shacl.SHACL+"NodeConstraintComponent", result.SourceConstraintComponent)
+		violations = append(violations, result.FocusNode.GetValue()+" "+result.Value.GetValue())
+	}
+	assert.ElementsMatch(t, []string{
+		"http://example.org/alice http://example.org/bob",
+		"http://example.org/bob http://example.org/alice",
+		"http://example.org/bob http://example.org/carol",
+	}, violations)
+}
+
+func TestValidateComplexPaths(t *testing.T) {
+	shapes := `[
+		{
+			"@id": "ex:ParentShape",
+			"@type": "sh:NodeShape",
+			"sh:targetNode": "ex:alice",
+			"sh:property": [
+				{"sh:path": {"sh:inversePath": "ex:parent"}, "sh:minCount": 2},
+				{"sh:path": {"@list": [{"@id": "ex:parent"}, {"@id": "ex:name"}]}, "sh:hasValue": "Eve"},
+				{"sh:path": {"sh:oneOrMorePath": {"@id": "ex:parent"}}, "sh:maxCount": 1}
+			]
+		}
+	]`
+	report := validate(t, shapes, `[
+		{"@id": "ex:alice", "parent": {"@id": "ex:adam", "name": "Adam", "parent": {"@id": "ex:god"}}},
+		{"@id": "ex:bob", "parent": {"@id": "ex:alice"}}
+	]`)
+
+	assert.Equal(t, []string{
+		"HasValueConstraintComponent",
+		"MaxCountConstraintComponent",
+		"MinCountConstraintComponent",
+	}, components(report))
+	for _, result := range report.Results {
+		assert.True(t, ld.IsBlankNode(result.ResultPath))
+	}
+}
+
+func TestValidateClosedShape(t *testing.T) {
+	shapes := `[
+		{
+			"@id": "ex:PointShape",
+			"@type": "sh:NodeShape",
+			"sh:targetClass": "ex:Point",
+			"sh:closed": true,
+			"sh:ignoredProperties": ["http://www.w3.org/1999/02/22-rdf-syntax-ns#type"],
+			"sh:property": [{"sh:path": "ex:x"}, {"sh:path": "ex:y"}]
+		}
+	]`
+	report := validate(t, shapes, `[
+		{"@id": "ex:p", "@type": "Point", "x": 1, "y": 2, "z": 3}
+	]`)
+
+	require.Len(t, report.Results, 1)
+	result := report.Results[0]
+	assert.Equal(t, shacl.SHACL+"ClosedConstraintComponent", result.SourceConstraintComponent)
+	assert.Equal(t, "http://example.org/z", result.ResultPath.GetValue())
+	assert.Equal(t, "3", result.Value.GetValue())
+}
+
+func TestValidateSeverityMessageAndDeactivated(t *testing.T) {
+	shapes := `[
+		{
+			"@id": "ex:NameShape",
+			"@type": "sh:PropertyShape",
+			"sh:targetClass": "ex:Person",
+			"sh:path": "ex:name",
+			"sh:minCount": 1,
+			"sh:severity": "sh:Warning",
+			"sh:message": [{"@value": "Name is missing", "@language": "en"}, "Nom manquant"]
+		},
+		{
+			"@id": "ex:AgeShape",
+			"@type": "sh:PropertyShape",
+			"sh:targetClass": "ex:Person",
+			"sh:path": "ex:age",
+			"sh:minCount": 1,
+			"sh:deactivated": true
+		}
+	]`
+	report := validate(t, shapes, `[{"@id": "ex:alice", "@type": "Person"}]`)
+
+	require.Len(t, report.Results, 1)
+	result := report.Results[0]
+	assert.Equal(t, shacl.Warning, result.Severity)
+	assert.Equal(t, "http://example.org/NameShape", result.SourceShape.GetValue())
+	assert.Len(t, result.Messages, 2)
+	assert.False(t, report.Conforms)
+}
+
+func TestValidateValueConstraints(t *testing.T) {
+	shapes := `[
+		{
+			"@id": "ex:BookShape",
+			"@type": "sh:NodeShape",
+			"sh:targetClass": "ex:Book",
+			"sh:nodeKind": {"@id": "sh:IRI"},
+			"sh:property": [
+				{"sh:path": "ex:title", "sh:languageIn": {"@list": ["en", "fr"]}, "sh:uniqueLang": true},
+				{"sh:path": "ex:isbn", "sh:minLength": 10, "sh:pattern": "^[0-9-]+$"},
+				{"sh:path": "ex:author", "sh:disjoint": {"@id": "ex:editor"}},
+				{"sh:path": "ex:published", "sh:maxExclusive": {"@value": "2030-01-01", "@type": "xsd:date"}}
+			]
+		}
+	]`
+	report := validate(t, shapes, `[
+		{"@id": "ex:ok", "@type": "Book", "title": [{"@value": "Dune", "@language": "en-GB"}],
+			"isbn": "978-0441172719", "published": {"@value": "1965-08-01", "@type": "xsd:date"}},
+		{"@id": "_:b", "@type": "Book",
+			"title": [{"@value": "A", "@language": "de"}, {"@value": "B", "@language": "fr"}, {"@value": "C", "@language": "fr"}],
+			"isbn": "12x", "author": "Ann", "editor": "Ann",
+			"published": {"@value": "2031-01-01", "@type": "xsd:date"}}
+	]`)
+
+	for _, result := range report.Results {
+		assert.True(t, ld.IsBlankNode(result.FocusNode))
+	}
+	assert.Equal(t, []string{
+		"DisjointConstraintComponent",
+		"LanguageInConstraintComponent",
+		"MaxExclusiveConstraintComponent",
+		"MinLengthConstraintComponent",
+		"NodeKindConstraintComponent",
+		"PatternConstraintComponent",
+		"UniqueLangConstraintComponent",
+	}, components(report))
+}
+
+func TestNewValidatorInvalidShapes(t *testing.T) {
+	for name, shapes := range map[string]string{
+		"non-integer minCount": `[{"sh:targetNode": "ex:a", "sh:path": "ex:p", "sh:minCount": "one"}]`,
+		"multiple paths":       `[{"sh:targetNode": "ex:a", "sh:path": ["ex:p", "ex:q"]}]`,
+		"invalid pattern":      `[{"sh:targetNode": "ex:a", "sh:path": "ex:p", "sh:pattern": "("}]`,
+		"literal node shape":   `[{"sh:targetNode": "ex:a", "sh:node": {"@value": "shape"}}]`,
+		"unsupported":          `[{"sh:targetNode": "ex:a", "sh:path": "ex:p", "sh:lessThan": {"@id": "ex:q"}}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := shacl.NewValidator(toRDF(t, shapesContext, shapes))
+			require.Error(t, err)
+			assert.Equal(t, ld.InvalidInput, err.(*ld.JsonLdError).Code) //nolint:errorlint
+		})
+	}
+}
+
+func TestValidatorReuse(t *testing.T) {
+	v, err := shacl.NewValidator(toRDF(t, shapesContext, personShapes))
+	require.NoError(t, err)
+
+	assert.True(t, v.Validate(toRDF(t, dataContext, `[{"@id": "ex:a", "@type": "Person", "name": "A"}]`)).Conforms)
+	assert.False(t, v.Validate(toRDF(t, dataContext, `[{"@id": "ex:b", "@type": "Person"}]`)).Conforms)
+}
+
+func TestValidationReportDataset(t *testing.T) {
+	shapes := `[
+		{
+			"@id": "ex:PersonShape",
+			"@type": "sh:NodeShape",
+			"sh:targetClass": "ex:Person",
+			"sh:property": {"sh:path": {"sh:inversePath": "ex:child"}, "sh:minCount": 1}
+		}
+	]`
+	// the label of the focus node is the first label of the report's own blank nodes
+	data, err := ld.ParseNQuads("_:r0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Person> .\n")
+	require.NoError(t, err)
+	report, err := shacl.Validate(data, toRDF(t, shapesContext, shapes))
+	require.NoError(t, err)
+	require.Len(t, report.Results, 1)
+
+	opts := ld.NewJsonLdOptions("")
+	expanded, err := ld.NewJsonLdApi().FromRDF(report.Dataset(), opts)
+	require.NoError(t, err)
+	compacted, err := ld.NewJsonLdProcessor().Compact(expanded, map[string]interface{}{
+		"@vocab": shacl.SHACL,
+		"sh":     shacl.SHACL,
+		"ex":     "http://example.org/",
+	}, opts)
+	require.NoError(t, err)
+
+	graph, ok := compacted["@graph"].([]interface{})
+	require.True(t, ok, "%v", compacted)
+	byType := make(map[string]map[string]interface{})
+	for _, n := range graph {
+		node := n.(map[string]interface{})
+		if typ, hasType := node["@type"].(string); hasType {
+			byType[typ] = node
+		}
+	}
+
+	reportNode := byType["ValidationReport"]
+	require.NotNil(t, reportNode)
+	assert.Equal(t, map[string]interface{}{"@type": "http://www.w3.org/2001/XMLSchema#boolean", "@value": "false"},
+		reportNode["conforms"])
+
+	result := byType["ValidationResult"]
+	require.NotNil(t, result)
+	assert.Equal(t, map[string]interface{}{"@id": "_:r0"}, result["focusNode"])
+	assert.Equal(t, map[string]interface{}{"@id": "sh:MinCountConstraintComponent"}, result["sourceConstraintComponent"])
+	assert.Equal(t, map[string]interface{}{"@id": "sh:Violation"}, result["resultSeverity"])
+	assert.NotEqual(t, "_:r0", reportNode["@id"])
+
+	// the inverse path is copied from the shapes graph
+	pathID := result["resultPath"].(map[string]interface{})["@id"].(string)
+	var pathNode map[string]interface{}
+	for _, n := range graph {
+		if node := n.(map[string]interface{}); node["@id"] == pathID {
+			pathNode = node
+		}
+	}
+	require.NotNil(t, pathNode)
+	assert.Equal(t, map[string]interface{}{"@id": "ex:child"}, pathNode["inversePath"])
+}
@@ -0,0 +1,316 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shacl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/piprate/json-gold/ld"
+)
+
+// shape is a node or property shape of the shapes graph.
+type shape struct {
+	id ld.Node
+	// path is nil for node shapes
+	path        *path
+	deactivated bool
+	severity    string
+	messages    []ld.Node
+
+	targetNodes      []ld.Node
+	targetClasses    []ld.Node
+	targetSubjectsOf []ld.Node
+	targetObjectsOf  []ld.Node
+
+	constraints []*constraint
+}
+
+func (s *shape) hasTargets() bool {
+	return len(s.targetNodes) > 0 || len(s.targetClasses) > 0 ||
+		len(s.targetSubjectsOf) > 0 || len(s.targetObjectsOf) > 0
+}
+
+type pathKind int
+
+const (
+	predicatePath pathKind = iota
+	sequencePath
+	alternativePath
+	inversePath
+	zeroOrMorePath
+	oneOrMorePath
+	zeroOrOnePath
+)
+
+// pathKinds maps the properties of non-predicate, non-sequence paths to their kinds.
+var pathKinds = []struct {
+	predicate string
+	kind      pathKind
+}{
+	{shInversePath, inversePath},
+	{shAlternativePath, alternativePath},
+	{shZeroOrMorePath, zeroOrMorePath},
+	{shOneOrMorePath, oneOrMorePath},
+	{shZeroOrOnePath, zeroOrOnePath},
+}
+
+// path is a SHACL property path.
+type path struct {
+	kind pathKind
+	// node is the node of the path in the shapes graph (the predicate of predicate paths)
+	node ld.Node
+	// elements holds the members of sequence and alternative paths and
+	// the single path of the other non-predicate paths
+	elements []*path
+}
+
+// shapeParser builds shapes from a shapes graph.
+type shapeParser struct {
+	shapesGraph *ld.QuadStore
+	// shapes holds the shapes parsed so far, by their term keys
+	shapes map[string]*shape
+}
+
+// parseShapes parses all shapes of the shapes graph and returns the ones with targets.
+func (p *shapeParser) parseShapes() ([]*shape, error) {
+	var candidates []ld.Node
+	seen := make(map[string]bool)
+	addCandidate := func(node ld.Node) {
+		if key := ld.NodeTerm(node); !seen[key] {
+			seen[key] = true
+			candidates = append(candidates, node)
+		}
+	}
+	for _, shapeType := range []string{shNodeShape, shPropertyShape} {
+		for _, quad := range p.shapesGraph.Match(nil, rdfType, ld.NewIRI(shapeType), "@default") {
+			addCandidate(quad.Subject)
+		}
+	}
+	for _, target := range []string{shTargetNode, shTargetClass, shTargetSubjectsOf, shTargetObjectsOf} {
+		for _, quad := range p.shapesGraph.Match(nil, ld.NewIRI(target), nil, "@default") {
+			addCandidate(quad.Subject)
+		}
+	}
+
+	var rval []*shape
+	for _, node := range candidates {
+		s, err := p.shape(node)
+		if err != nil {
+			return nil, err
+		}
+		if s.hasTargets() {
+			rval = append(rval, s)
+		}
+	}
+	return rval, nil
+}
+
+// shape returns the shape with the given node, parsing it if it hasn't been parsed yet.
+func (p *shapeParser) shape(node ld.Node) (*shape, error) {
+	if ld.IsLiteral(node) {
+		return nil, invalidShapes("a shape must be an IRI or a blank node, found %s", describe(node))
+	}
+	key := ld.NodeTerm(node)
+	if s, found := p.shapes[key]; found {
+		return s, nil
+	}
+	s := &shape{id: node, severity: Violation}
+	// register the shape before parsing the constraints, which may refer to it
+	p.shapes[key] = s
+
+	s.targetNodes = p.objects(node, shTargetNode)
+	s.targetClasses = p.objects(node, shTargetClass)
+	if p.hasType(node, rdfsClass) && (p.hasType(node, shNodeShape) || p.hasType(node, shPropertyShape)) {
+		// implicit class target
+		s.targetClasses = append(s.targetClasses, node)
+	}
+	s.targetSubjectsOf = p.objects(node, shTargetSubjectsOf)
+	s.targetObjectsOf = p.objects(node, shTargetObjectsOf)
+	s.messages = p.objects(node, shMessage)
+
+	var err error
+	if s.deactivated, err = p.booleanParameter(node, shDeactivated); err != nil {
+		return nil, err
+	}
+	if severity, err := p.singleObject(node, shSeverity); err != nil {
+		return nil, err
+	} else if severity != nil {
+		if !ld.IsIRI(severity) {
+			return nil, invalidShapes("sh:severity of %s must be an IRI", describe(node))
+		}
+		s.severity = severity.GetValue()
+	}
+
+	pathNode, err := p.singleObject(node, shPath)
+	if err != nil {
+		return nil, err
+	}
+	if pathNode != nil {
+		if s.path, err = p.parsePath(pathNode, make(map[string]bool)); err != nil {
+			return nil, err
+		}
+	}
+
+	// parameters are processed in the order they appear in the shapes graph
+	for _, quad := range p.shapesGraph.Match(node, nil, nil, "@default") {
+		predicate := quad.Predicate.GetValue()
+		if !strings.HasPrefix(predicate, SHACL) {
+			continue
+		}
+		name := predicate[len(SHACL):]
+		factory, found := constraintFactories[name]
+		if !found {
+			continue
+		}
+		c, err := factory(p, s, name, quad.Object)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			s.constraints = append(s.constraints, c)
+		}
+	}
+	return s, nil
+}
+
+// parsePath parses the SHACL property path at the given node. Visited holds the blank nodes
+// of the enclosing paths, which must not be used recursively.
+func (p *shapeParser) parsePath(node ld.Node, visited map[string]bool) (*path, error) {
+	switch {
+	case ld.IsIRI(node):
+		return &path{kind: predicatePath, node: node}, nil
+	case ld.IsLiteral(node):
+		return nil, invalidShapes("a path must be an IRI or a blank node, found %s", describe(node))
+	}
+
+	key := ld.NodeTerm(node)
+	if visited[key] {
+		return nil, invalidShapes("recursive path at %s", describe(node))
+	}
+	visited[key] = true
+	defer delete(visited, key)
+
+	if len(p.objects(node, ld.RDFFirst)) > 0 {
+		members, err := p.parsePathList(node, visited)
+		if err != nil {
+			return nil, err
+		}
+		if len(members) < 2 {
+			return nil, invalidShapes("a sequence path must have at least two members")
+		}
+		return &path{kind: sequencePath, node: node, elements: members}, nil
+	}
+
+	for _, pk := range pathKinds {
+		kind := pk.kind
+		value, err := p.singleObject(node, pk.predicate)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		if kind == alternativePath {
+			members, err := p.parsePathList(value, visited)
+			if err != nil {
+				return nil, err
+			}
+			if len(members) < 2 {
+				return nil, invalidShapes("an alternative path must have at least two members")
+			}
+			return &path{kind: kind, node: node, elements: members}, nil
+		}
+		element, err := p.parsePath(value, visited)
+		if err != nil {
+			return nil, err
+		}
+		return &path{kind: kind, node: node, elements: []*path{element}}, nil
+	}
+	return nil, invalidShapes("unsupported path at %s", describe(node))
+}
+
+func (p *shapeParser) parsePathList(head ld.Node, visited map[string]bool) ([]*path, error) {
+	items, err := p.list(head)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]*path, len(items))
+	for i, item := range items {
+		if members[i], err = p.parsePath(item, visited); err != nil {
+			return nil, err
+		}
+	}
+	return members, nil
+}
+
+// list returns the members of the SHACL list starting at head.
+func (p *shapeParser) list(head ld.Node) ([]ld.Node, error) {
+	items, err := p.shapesGraph.List(head, "@default")
+	if err != nil {
+		return nil, invalidShapes("%v", err)
+	}
+	return items, nil
+}
+
+func (p *shapeParser) objects(subject ld.Node, predicate string) []ld.Node {
+	var rval []ld.Node
+	p.shapesGraph.ForEachMatch(subject, ld.NewIRI(predicate), nil, "@default", func(quad *ld.Quad) bool {
+		rval = append(rval, quad.Object)
+		return true
+	})
+	return rval
+}
+
+// singleObject returns the only value of the property, or nil if it has no value.
+func (p *shapeParser) singleObject(subject ld.Node, predicate string) (ld.Node, error) {
+	values := p.objects(subject, predicate)
+	switch len(values) {
+	case 0:
+		return nil, nil
+	case 1:
+		return values[0], nil
+	default:
+		return nil, invalidShapes("%s has more than one value of %s", describe(subject), predicate)
+	}
+}
+
+func (p *shapeParser) hasType(node ld.Node, class string) bool {
+	return p.shapesGraph.Contains(ld.NewQuad(node, rdfType, ld.NewIRI(class), "@default"))
+}
+
+func (p *shapeParser) booleanParameter(node ld.Node, predicate string) (bool, error) {
+	value, err := p.singleObject(node, predicate)
+	if err != nil || value == nil {
+		return false, err
+	}
+	return booleanValue(node, predicate, value)
+}
+
+func booleanValue(node ld.Node, predicate string, value ld.Node) (bool, error) {
+	if l, isLiteral := value.(*ld.Literal); isLiteral && l.Datatype == ld.XSDBoolean {
+		switch l.Value {
+		case "true", "1":
+			return true, nil
+		case "false", "0":
+			return false, nil
+		}
+	}
+	return false, invalidShapes("%s of %s must be a boolean, found %s", predicate, describe(node), describe(value))
+}
+
+func invalidShapes(format string, args ...interface{}) error {
+	return ld.NewJsonLdError(ld.InvalidInput, "invalid shapes graph: "+fmt.Sprintf(format, args...))
+}
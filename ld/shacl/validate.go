@@ -0,0 +1,193 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shacl
+
+import (
+	"github.com/piprate/json-gold/ld"
+)
+
+// validationContext holds the state of the validation of a data graph.
+type validationContext struct {
+	dataGraph *ld.QuadStore
+	// active holds the shape and focus node pairs being validated, to stop the recursion
+	// of shapes which refer to themselves
+	active map[string]bool
+}
+
+// targetNodes returns the focus nodes of the targets of the shape.
+func (vc *validationContext) targetNodes(s *shape) []ld.Node {
+	nodes := newNodeSet()
+	nodes.add(s.targetNodes...)
+	for _, class := range s.targetClasses {
+		for _, c := range vc.subClasses(class) {
+			vc.dataGraph.ForEachMatch(nil, rdfType, c, "@default", func(quad *ld.Quad) bool {
+				nodes.add(quad.Subject)
+				return true
+			})
+		}
+	}
+	for _, predicate := range s.targetSubjectsOf {
+		vc.dataGraph.ForEachMatch(nil, predicate, nil, "@default", func(quad *ld.Quad) bool {
+			nodes.add(quad.Subject)
+			return true
+		})
+	}
+	for _, predicate := range s.targetObjectsOf {
+		vc.dataGraph.ForEachMatch(nil, predicate, nil, "@default", func(quad *ld.Quad) bool {
+			nodes.add(quad.Object)
+			return true
+		})
+	}
+	return nodes.nodes
+}
+
+// validateShape validates the focus node against the shape.
+func (vc *validationContext) validateShape(s *shape, focusNode ld.Node) []*ValidationResult {
+	if s.deactivated {
+		return nil
+	}
+
+	valueNodes := []ld.Node{focusNode}
+	if s.path != nil {
+		valueNodes = vc.pathValues(s.path, []ld.Node{focusNode}, false)
+	}
+
+	rc := &resultCollector{shape: s, focusNode: focusNode}
+	for _, c := range s.constraints {
+		rc.component = c.component
+		c.validate(vc, rc, valueNodes)
+	}
+	return rc.results
+}
+
+// conforms returns true if the node conforms to the shape. A node conforms to
+// the shapes which are being validated for it further up the stack.
+func (vc *validationContext) conforms(node ld.Node, s *shape) bool {
+	key := ld.NodeTerm(s.id) + " " + ld.NodeTerm(node)
+	if vc.active[key] {
+		return true
+	}
+	vc.active[key] = true
+	defer delete(vc.active, key)
+	return len(vc.validateShape(s, node)) == 0
+}
+
+// pathValues returns the nodes reachable from the given nodes through the path,
+// or the nodes from which the given nodes are reachable, if inverse is true.
+func (vc *validationContext) pathValues(p *path, nodes []ld.Node, inverse bool) []ld.Node {
+	rval := newNodeSet()
+	switch p.kind {
+	case predicatePath:
+		for _, node := range nodes {
+			if inverse {
+				vc.dataGraph.ForEachMatch(nil, p.node, node, "@default", func(quad *ld.Quad) bool {
+					rval.add(quad.Subject)
+					return true
+				})
+			} else {
+				vc.dataGraph.ForEachMatch(node, p.node, nil, "@default", func(quad *ld.Quad) bool {
+					rval.add(quad.Object)
+					return true
+				})
+			}
+		}
+	case sequencePath:
+		current := nodes
+		for i := range p.elements {
+			element := p.elements[i]
+			if inverse {
+				element = p.elements[len(p.elements)-1-i]
+			}
+			current = vc.pathValues(element, current, inverse)
+		}
+		rval.add(current...)
+	case alternativePath:
+		for _, element := range p.elements {
+			rval.add(vc.pathValues(element, nodes, inverse)...)
+		}
+	case inversePath:
+		rval.add(vc.pathValues(p.elements[0], nodes, !inverse)...)
+	case zeroOrOnePath:
+		rval.add(nodes...)
+		rval.add(vc.pathValues(p.elements[0], nodes, inverse)...)
+	case zeroOrMorePath, oneOrMorePath:
+		if p.kind == zeroOrMorePath {
+			rval.add(nodes...)
+		}
+		reached := newNodeSet()
+		for next := vc.pathValues(p.elements[0], nodes, inverse); len(next) > 0; {
+			next = reached.add(next...)
+			next = vc.pathValues(p.elements[0], next, inverse)
+		}
+		rval.add(reached.nodes...)
+	}
+	return rval.nodes
+}
+
+// isInstance returns true if the node has the class or one of its subclasses as a type.
+func (vc *validationContext) isInstance(node, class ld.Node) bool {
+	if ld.IsLiteral(node) {
+		return false
+	}
+	for _, c := range vc.subClasses(class) {
+		if vc.dataGraph.Contains(ld.NewQuad(node, rdfType, c, "@default")) {
+			return true
+		}
+	}
+	return false
+}
+
+// subClasses returns the class and all its transitive subclasses in the data graph.
+func (vc *validationContext) subClasses(class ld.Node) []ld.Node {
+	classes := newNodeSet()
+	for next := []ld.Node{class}; len(next) > 0; {
+		added := classes.add(next...)
+		next = next[:0:0]
+		for _, c := range added {
+			vc.dataGraph.ForEachMatch(nil, ld.NewIRI(rdfsSubClassOf), c, "@default", func(quad *ld.Quad) bool {
+				next = append(next, quad.Subject)
+				return true
+			})
+		}
+	}
+	return classes.nodes
+}
+
+// nodeSet is an insertion-ordered set of nodes.
+type nodeSet struct {
+	nodes []ld.Node
+	keys  map[string]bool
+}
+
+func newNodeSet() *nodeSet {
+	return &nodeSet{keys: make(map[string]bool)}
+}
+
+// add adds the nodes to the set and returns the ones which weren't in the set.
+func (ns *nodeSet) add(nodes ...ld.Node) []ld.Node {
+	var added []ld.Node
+	for _, node := range nodes {
+		if key := ld.NodeTerm(node); !ns.keys[key] {
+			ns.keys[key] = true
+			ns.nodes = append(ns.nodes, node)
+			added = append(added, node)
+		}
+	}
+	return added
+}
+
+func (ns *nodeSet) contains(node ld.Node) bool {
+	return ns.keys[ld.NodeTerm(node)]
+}
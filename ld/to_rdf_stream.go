@@ -0,0 +1,286 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// QuadHandler receives the quads produced by ToRDFStream. If it returns an error,
+// the conversion stops and the error is returned to the caller.
+type QuadHandler func(quad *Quad) error
+
+// ToRDFStream converts the JSON-LD document read from r to RDF and passes the quads to handler
+// as soon as they are produced, without building the complete expanded document, node map
+// or dataset in memory.
+//
+// Following the assumptions of the JSON-LD Streaming note (https://w3c.github.io/json-ld-streaming/),
+// the memory use stays bounded by the size of the largest top-level node if the document is either:
+//
+//   - a top-level array of node objects, or
+//   - a top-level object which contains only @context (first) and @graph (or its alias).
+//
+// Any other document is read into memory and converted as a whole. A @graph entry which has been
+// streamed must be the last entry of the top-level object.
+//
+// Blank node identifiers are consistent across the whole document. As every node is converted
+// on its own, a statement made more than once in the document (for example, in two node objects
+// with the same @id) may be passed to handler more than once.
+func (jldp *JsonLdProcessor) ToRDFStream(r io.Reader, handler QuadHandler, opts *JsonLdOptions) error {
+
+	opts = jldp.prepareOptions(opts)
+
+	activeCtx, err := initialExpansionContext(opts)
+	if err != nil {
+		return err
+	}
+
+	s := &rdfStreamer{
+		jldp:    jldp,
+		api:     NewJsonLdApi(),
+		opts:    opts,
+		issuer:  NewIdentifierIssuer("_:b"),
+		handler: handler,
+	}
+
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return NewJsonLdError(LoadingDocumentFailed, err)
+	}
+
+	switch tok {
+	case json.Delim('['):
+		for dec.More() {
+			var element interface{}
+			if err := dec.Decode(&element); err != nil {
+				return NewJsonLdError(LoadingDocumentFailed, err)
+			}
+			if err := s.processElement(activeCtx, "", element); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return NewJsonLdError(LoadingDocumentFailed, err)
+		}
+		return nil
+	case json.Delim('{'):
+		return s.processObject(dec, activeCtx)
+	default:
+		// a top-level scalar doesn't produce any quads
+		return nil
+	}
+}
+
+// ToRDFStreamTo works like ToRDFStream, but it writes the quads to w in N-Quads format.
+func (jldp *JsonLdProcessor) ToRDFStreamTo(r io.Reader, w io.Writer, opts *JsonLdOptions) error {
+	bw := bufio.NewWriter(w)
+	err := jldp.ToRDFStream(r, func(quad *Quad) error {
+		graphName := ""
+		if quad.Graph != nil {
+			graphName = quad.Graph.GetValue()
+		}
+		if _, err := bw.WriteString(toNQuad(quad, graphName)); err != nil {
+			return NewJsonLdError(IOError, err)
+		}
+		return nil
+	}, opts)
+	if err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return NewJsonLdError(IOError, err)
+	}
+	return nil
+}
+
+type rdfStreamer struct {
+	jldp    *JsonLdProcessor
+	api     *JsonLdApi
+	opts    *JsonLdOptions
+	issuer  *IdentifierIssuer
+	handler QuadHandler
+}
+
+// processObject handles a top-level JSON object. The opening brace has already been consumed.
+func (s *rdfStreamer) processObject(dec *json.Decoder, activeCtx *Context) error {
+	var (
+		localCtx      interface{}
+		hasLocalCtx   bool
+		graphStreamed bool
+		// buffered collects the entries of the object if it can't be streamed
+		buffered map[string]interface{}
+	)
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return NewJsonLdError(LoadingDocumentFailed, err)
+		}
+		key, _ := tok.(string)
+
+		if graphStreamed {
+			return NewJsonLdError(InvalidInput,
+				fmt.Sprintf("entry %s follows a streamed @graph in the top-level object", key))
+		}
+
+		if buffered == nil {
+			if key == "@context" && !hasLocalCtx {
+				if err := dec.Decode(&localCtx); err != nil {
+					return NewJsonLdError(LoadingDocumentFailed, err)
+				}
+				hasLocalCtx = true
+				if activeCtx, err = activeCtx.Parse(localCtx); err != nil {
+					return err
+				}
+				continue
+			}
+
+			expandedKey, err := activeCtx.ExpandIri(key, false, true, nil, nil)
+			if err != nil {
+				return err
+			}
+			if expandedKey == "@graph" {
+				if err := s.processGraph(dec, activeCtx); err != nil {
+					return err
+				}
+				graphStreamed = true
+				continue
+			}
+
+			buffered = make(map[string]interface{})
+			if hasLocalCtx {
+				buffered["@context"] = localCtx
+			}
+		}
+
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return NewJsonLdError(LoadingDocumentFailed, err)
+		}
+		buffered[key] = value
+	}
+	if _, err := dec.Token(); err != nil {
+		return NewJsonLdError(LoadingDocumentFailed, err)
+	}
+
+	if buffered == nil {
+		return nil
+	}
+
+	expanded, err := s.jldp.expand(buffered, s.opts)
+	if err != nil {
+		return err
+	}
+	return s.emit(expanded)
+}
+
+// processGraph converts the value of a top-level @graph entry one element at a time.
+func (s *rdfStreamer) processGraph(dec *json.Decoder, activeCtx *Context) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return NewJsonLdError(LoadingDocumentFailed, err)
+	}
+
+	if tok != json.Delim('[') {
+		// a single node
+		value, err := decodeTokenValue(dec, tok)
+		if err != nil {
+			return NewJsonLdError(LoadingDocumentFailed, err)
+		}
+		return s.processElement(activeCtx, "@graph", value)
+	}
+
+	for dec.More() {
+		var element interface{}
+		if err := dec.Decode(&element); err != nil {
+			return NewJsonLdError(LoadingDocumentFailed, err)
+		}
+		if err := s.processElement(activeCtx, "@graph", element); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return NewJsonLdError(LoadingDocumentFailed, err)
+	}
+	return nil
+}
+
+func (s *rdfStreamer) processElement(activeCtx *Context, activeProperty string, element interface{}) error {
+	expanded, err := s.api.Expand(activeCtx, activeProperty, element, s.opts, false, nil)
+	if err != nil {
+		return err
+	}
+	if expanded == nil {
+		return nil
+	}
+	return s.emit(expanded)
+}
+
+// emit converts an expanded element to RDF and passes the quads to the handler.
+func (s *rdfStreamer) emit(expanded interface{}) error {
+	nodeMap := make(map[string]interface{})
+	nodeMap["@default"] = make(map[string]interface{})
+	if _, err := s.api.GenerateNodeMap(expanded, nodeMap, "@default", s.issuer, "", "", nil); err != nil {
+		return err
+	}
+
+	dataset := NewRDFDataset()
+	for _, graphName := range GetOrderedKeys(nodeMap) {
+		if IsRelativeIri(graphName) {
+			continue
+		}
+		graph, isMap := nodeMap[graphName].(map[string]interface{})
+		if !isMap {
+			return NewJsonLdError(InvalidInput, fmt.Sprintf("invalid node map for graph %s", graphName))
+		}
+		if err := dataset.GraphToRDF(graphName, graph, s.issuer, s.opts.ProduceGeneralizedRdf); err != nil {
+			return err
+		}
+		for _, quad := range dataset.Graphs[graphName] {
+			if err := s.handler(quad); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeTokenValue decodes the JSON value (an object or a scalar) which starts with the given token.
+func decodeTokenValue(dec *json.Decoder, tok json.Token) (interface{}, error) {
+	switch tok {
+	case json.Delim('{'):
+		obj := make(map[string]interface{})
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := keyTok.(string)
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				return nil, err
+			}
+			obj[key] = value
+		}
+		_, err := dec.Token()
+		return obj, err
+	default:
+		return tok, nil
+	}
+}
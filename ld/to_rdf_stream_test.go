@@ -0,0 +1,173 @@
+// Copyright 2015-2017 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ld_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func canonicalNQuads(t *testing.T, nquads string) string {
+	t.Helper()
+	opts := NewJsonLdOptions("")
+	opts.InputFormat = "application/n-quads"
+	opts.Format = "application/n-quads"
+	opts.Algorithm = AlgorithmURDNA2015
+	normalized, err := NewJsonLdProcessor().Normalize(nquads, opts)
+	require.NoError(t, err)
+	return normalized.(string)
+}
+
+func TestToRDFStreamMatchesToRDF(t *testing.T) {
+	manifest := readTestDocument(t, filepath.Join("testdata", "toRdf-manifest.jsonld")).(map[string]interface{})
+	baseIRI := manifest["baseIri"].(string)
+	loader := NewFSDocumentLoader(os.DirFS("testdata"), map[string]string{baseIRI: "."})
+	proc := NewJsonLdProcessor()
+
+	compared := 0
+	for _, entry := range manifest["sequence"].([]interface{}) {
+		test := entry.(map[string]interface{})
+		inputName := test["input"].(string)
+		if _, hasOpts := test["option"]; hasOpts || !strings.HasSuffix(inputName, ".jsonld") {
+			continue
+		}
+		name := test["@id"].(string)
+
+		opts := NewJsonLdOptions(baseIRI + inputName)
+		opts.DocumentLoader = loader
+		opts.Format = "application/n-quads"
+
+		input, err := os.ReadFile(filepath.Join("testdata", inputName))
+		require.NoError(t, err)
+		doc := readTestDocument(t, filepath.Join("testdata", inputName))
+
+		expected, expectedErr := proc.ToRDF(doc, opts)
+
+		var buf bytes.Buffer
+		err = proc.ToRDFStreamTo(bytes.NewReader(input), &buf, opts)
+		if expectedErr != nil {
+			assert.Error(t, err, name)
+			continue
+		}
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+
+		assert.Equal(t, canonicalNQuads(t, expected.(string)), canonicalNQuads(t, buf.String()), name)
+		compared++
+	}
+	assert.Greater(t, compared, 100)
+}
+
+func TestToRDFStreamGraph(t *testing.T) {
+	input := `{
+		"@context": {"@vocab": "http://schema.org/", "nodes": "@graph"},
+		"nodes": [
+			{"@id": "http://example.org/jane", "knows": {"@id": "_:john"}},
+			{"@id": "_:john", "name": "John", "tags": {"@list": ["a", "b"]}},
+			"ignored free-floating value"
+		]
+	}`
+
+	var buf bytes.Buffer
+	err := NewJsonLdProcessor().ToRDFStreamTo(strings.NewReader(input), &buf, nil)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 7)
+	// the first node is converted before the second one is read
+	assert.Equal(t, "<http://example.org/jane> <http://schema.org/knows> _:b0 .", lines[0])
+	// blank node identifiers are consistent across nodes
+	assert.Contains(t, lines, "_:b0 <http://schema.org/name> \"John\" .")
+}
+
+func TestToRDFStreamIsIncremental(t *testing.T) {
+	pr, pw := io.Pipe()
+	received := make(chan *Quad, 1000)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- NewJsonLdProcessor().ToRDFStream(pr, func(quad *Quad) error {
+			received <- quad
+			return nil
+		}, nil)
+		close(received)
+	}()
+
+	_, err := io.WriteString(pw, `{"@context": {"@vocab": "http://schema.org/"}, "@graph": [`)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			_, err = io.WriteString(pw, ",")
+			require.NoError(t, err)
+		}
+		_, err = fmt.Fprintf(pw, `{"@id": "http://example.org/%d", "name": "Node %d"}`, i, i)
+		require.NoError(t, err)
+
+		if i > 0 {
+			// the previous node must have been converted before the rest of the document arrived
+			select {
+			case quad := <-received:
+				assert.Equal(t, fmt.Sprintf("http://example.org/%d", i-1), quad.Subject.GetValue())
+			case <-time.After(5 * time.Second):
+				t.Fatalf("node %d wasn't converted before the end of the document", i-1)
+			}
+		}
+	}
+	_, err = io.WriteString(pw, `]}`)
+	require.NoError(t, err)
+	require.NoError(t, pw.Close())
+
+	quad := <-received
+	assert.Equal(t, "http://example.org/999", quad.Subject.GetValue())
+	require.NoError(t, <-done)
+}
+
+func TestToRDFStreamErrors(t *testing.T) {
+	proc := NewJsonLdProcessor()
+	noop := func(*Quad) error { return nil }
+
+	stop := errors.New("stop")
+	err := proc.ToRDFStream(strings.NewReader(`[{"@id": "http://example.org/a", "http://schema.org/name": "A"}]`),
+		func(*Quad) error { return stop }, nil)
+	assert.Equal(t, stop, err)
+
+	for name, input := range map[string]string{
+		"malformed JSON":      `[{"@id": `,
+		"entry after @graph":  `{"@graph": [], "@id": "http://example.org/a"}`,
+		"invalid context":     `{"@context": 5, "@graph": []}`,
+		"invalid node":        `[{"@id": 5}]`,
+		"malformed @graph":    `{"@graph": {"@id": }}`,
+		"malformed top level": `{"@id": "http://example.org/a", "http://schema.org/name": }`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := proc.ToRDFStream(strings.NewReader(input), noop, nil)
+			require.Error(t, err)
+			_, isJSONLDError := err.(*JsonLdError) //nolint:errorlint
+			assert.True(t, isJSONLDError)
+		})
+	}
+}